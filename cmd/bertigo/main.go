@@ -1,50 +1,115 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"vertigo/pkg/config"
 	"vertigo/pkg/database"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
-var db *database.DB
+type server struct {
+	store database.Store
+	// shoeImageDir holds the image folders of the shoes, removed when a shoe
+	// is purged.
+	shoeImageDir string
+}
 
-func initDB() {
-	var err error
-	db, err = database.GetDB("data/database/test.db")
+func initDB(cfg *config.Config) *database.DB {
+	db, err := database.GetDB(cfg.DatabasePath)
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 
-	err = db.Initialize()
+	db.SetQueryTimeout(cfg.DatabaseTimeout)
+
+	err = db.UseMigrationsDir(cfg.MigrationsDir)
+	if err != nil {
+		log.Fatalf("Failed to load migrations: %v", err)
+	}
+
+	err = db.MigrateUp(context.Background())
 	if err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
+		log.Fatalf("Failed to migrate database: %v", err)
 	}
+	return db
 }
 
 func main() {
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
 
-	initDB()
+	db := initDB(cfg)
 	defer db.Close()
 
+	r := newRouter(db, cfg)
+	log.Printf("Server is running on port %d...", cfg.Port)
+	r.Run(fmt.Sprintf(":%d", cfg.Port))
+}
+
+func newRouter(store database.Store, cfg *config.Config) *gin.Engine {
+	s := &server{store: store, shoeImageDir: cfg.ShoeImageDir()}
+
 	r := gin.Default()
 
-	r.Use(cors.Default())
+	// Without origins browsers only reach bertigo from its own origin.
+	if len(cfg.API.Origins) > 0 {
+		corsConfig := cors.DefaultConfig()
+		corsConfig.AllowOrigins = cfg.API.Origins
+		corsConfig.AddAllowHeaders("Authorization")
+		r.Use(cors.New(corsConfig))
+	}
 
-	r.Static("/img_data", "./img_data")
+	r.Static("/img_data", cfg.ImageDir)
+	// Pictures uploaded to the local storage backend, point
+	// VERTIGO_PUBLIC_URL at http://host:port/blobs/{key} to use them.
+	if cfg.Storage.ResolvedBackend() == config.StorageLocal {
+		r.Static("/blobs", cfg.Storage.LocalDir)
+	}
 
-	r.GET("/shoes", handleShoes)
-	r.GET("/shoes/:productName", handleShoeDetails)
-	r.GET("/shoentries/:id", handleShoentries)
-	r.GET("/recent-shoentries", handleRecentShoentries)
-	log.Println("Server is running on port 8080...")
-	r.Run(":8080")
+	r.GET("/shoes", s.handleShoes)
+	r.GET("/shoes/:productName", s.handleShoeDetails)
+	r.GET("/shoes/:productName/prices", s.handleShoePrices)
+	r.GET("/shoentries/:id", s.handleShoentries)
+	r.GET("/recent-shoentries", s.handleRecentShoentries)
+
+	r.GET("/history/:table/:id", s.handleHistory)
+	r.GET("/alerts", s.handleAlerts)
+
+	// Everything that changes data needs an API token.
+	write := r.Group("", requireToken(cfg.API.Tokens))
+	for _, table := range database.Tables {
+		write.PATCH("/"+table+"/:id", s.handleUpdate(table))
+		write.DELETE("/"+table+"/:id", s.handleDelete(table))
+		write.POST("/"+table+"/:id/restore", s.handleRestore(table))
+		write.DELETE("/"+table+"/:id/purge", s.handlePurge(table))
+	}
+	write.POST("/alerts", s.handleAddAlert)
+	write.DELETE("/alerts/:id", s.handleDeleteAlert)
+	for _, table := range database.MergeTables {
+		write.POST("/"+table+"/:id/merge", s.handleMerge(table))
+	}
+	return r
 }
 
-func handleShoes(c *gin.Context) {
-	shoes, err := db.QueryShoes()
+func (s *server) handleShoes(c *gin.Context) {
+	shoes, err := s.store.QueryShoes(c.Request.Context())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch shoes"})
 		return
@@ -53,9 +118,9 @@ func handleShoes(c *gin.Context) {
 	c.JSON(http.StatusOK, shoes)
 }
 
-func handleShoeDetails(c *gin.Context) {
+func (s *server) handleShoeDetails(c *gin.Context) {
 	name := c.Param("productName")
-	shoe, err := db.GetShoeByProductName(name)
+	shoe, err := s.store.GetShoeByProductName(c.Request.Context(), name)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch shoe details"})
 		return
@@ -68,167 +133,361 @@ func handleShoeDetails(c *gin.Context) {
 	c.JSON(http.StatusOK, shoe)
 }
 
-func handleShoentries(c *gin.Context) {
-	shoeID := c.Param("id")
-	query := `
-        SELECT 
-            shoentries.ID AS ShoentryID,
-            shoentries.ItemID,
-            shoes.ID AS ShoeID,
-            shoes.Name AS ShoeName,
-            shoes.Subtitle AS ShoeSubtitle,
-            shoes.LastSale AS ShoeLastSale,
-            shoes.ProductName AS ShoeProductName,
-            shoes.MainPicture AS ShoeMainPicture,
-            shoes.Attributes AS ShoeAttributes,
-            shoes.Description AS ShoeDescription,
-            shoes.Timestamp AS ShoeTimestamp,
-            shoentries.PictureID,
-            pictures.LocalLocation AS PictureLocalPath,
-            pictures.DiscordImageLink AS PictureDiscordURL,
-            pictures.DiscordMessageId AS PictureMessageID,
-            pictures.Latitude AS PictureLatitude,
-            pictures.Longitude AS PictureLongitude,
-            pictures.TakenAt AS PictureTakenAt,
-            pictures.UpdatedAt AS PictureUpdatedAt,
-            pictures.CreatedAt AS PictureCreatedAt,
-            shoentries.UpdatedAt AS ShoentryUpdatedAt,
-            shoentries.CreatedAt AS ShoentryCreatedAt
-        FROM 
-            shoentries
-        INNER JOIN 
-            shoes ON shoentries.ItemID = shoes.ID
-        INNER JOIN 
-            pictures ON shoentries.PictureID = pictures.ID
-        WHERE 
-            shoes.ID = ?
-    `
-
-	rows, err := db.Query(query, shoeID)
+// handleShoePrices returns the price history of a shoe, oldest first.
+func (s *server) handleShoePrices(c *gin.Context) {
+	ctx := c.Request.Context()
+	shoe, err := s.store.GetShoeByProductName(ctx, c.Param("productName"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch shoentries"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch shoe details"})
 		return
 	}
-	defer rows.Close()
-
-	var shoentries []database.ShoentryDetails
-	for rows.Next() {
-		var details database.ShoentryDetails
-		err := rows.Scan(
-			&details.ShoentryID,
-			&details.ItemID,
-			&details.ShoeID,
-			&details.ShoeName,
-			&details.ShoeSubtitle,
-			&details.ShoeLastSale,
-			&details.ShoeProductName,
-			&details.ShoeMainPicture,
-			&details.ShoeAttributes,
-			&details.ShoeDescription,
-			&details.ShoeTimestamp,
-			&details.PictureID,
-			&details.PictureLocalPath,
-			&details.PictureDiscordURL,
-			&details.PictureMessageID,
-			&details.PictureLatitude,
-			&details.PictureLongitude,
-			&details.PictureTakenAt,
-			&details.PictureUpdatedAt,
-			&details.PictureCreatedAt,
-			&details.ShoentryUpdatedAt,
-			&details.ShoentryCreatedAt,
-		)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan shoentry details"})
-			return
-		}
-		shoentries = append(shoentries, details)
+	if shoe == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shoe not found"})
+		return
+	}
+
+	prices, err := s.store.GetShoePrices(ctx, shoe.ID)
+	if err != nil {
+		log.Printf("Error querying shoe prices: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch shoe prices"})
+		return
+	}
+	c.JSON(http.StatusOK, prices)
+}
+
+func (s *server) handleShoentries(c *gin.Context) {
+	shoeID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid shoe id"})
+		return
+	}
+
+	shoentries, err := s.store.GetShoentriesByShoeID(c.Request.Context(), shoeID)
+	if err != nil {
+		log.Printf("Error querying shoentries: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch shoentries"})
+		return
 	}
 
 	c.JSON(http.StatusOK, shoentries)
 }
 
-func handleRecentShoentries(c *gin.Context) {
-	query := `
-        SELECT 
-			shoentries.ID AS ShoentryID,
-			shoentries.ItemID,
-			shoes.ID AS ShoeID,
-			shoes.Name AS ShoeName,
-			shoes.Subtitle AS ShoeSubtitle,
-			shoes.LastSale AS ShoeLastSale,
-			shoes.ProductName AS ShoeProductName,
-			shoes.MainPicture AS ShoeMainPicture,
-			shoes.Attributes AS ShoeAttributes,
-			shoes.Description AS ShoeDescription,
-			shoes.Timestamp AS ShoeTimestamp,
-			shoentries.PictureID,
-			pictures.LocalLocation AS PictureLocalPath,
-			pictures.DiscordImageLink AS PictureDiscordURL,
-			pictures.DiscordMessageId AS PictureMessageID,
-			pictures.Latitude AS PictureLatitude,
-			pictures.Longitude AS PictureLongitude,
-			pictures.TakenAt AS PictureTakenAt,
-			pictures.UpdatedAt AS PictureUpdatedAt,
-			pictures.CreatedAt AS PictureCreatedAt,
-			shoentries.UpdatedAt AS ShoentryUpdatedAt,
-			shoentries.CreatedAt AS ShoentryCreatedAt
-        FROM 
-            shoentries
-        INNER JOIN 
-            pictures ON shoentries.PictureID = pictures.ID
-        INNER JOIN 
-            shoes ON shoentries.ItemID = shoes.ID
-        ORDER BY shoentries.CreatedAt DESC
-        LIMIT 10
-    `
-
-	rows, err := db.Query(query)
+func (s *server) handleRecentShoentries(c *gin.Context) {
+	shoentries, err := s.store.GetRecentShoentries(c.Request.Context(), 10)
 	if err != nil {
 		log.Printf("Error querying recent shoentries: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch recent shoentries"})
 		return
 	}
-	defer rows.Close()
-
-	var shoentries []database.ShoentryDetails
-	for rows.Next() {
-		var shoentry database.ShoentryDetails
-		err := rows.Scan(
-			&shoentry.ShoentryID,
-			&shoentry.ItemID,
-			&shoentry.ShoeID,
-			&shoentry.ShoeName,
-			&shoentry.ShoeSubtitle,
-			&shoentry.ShoeLastSale,
-			&shoentry.ShoeProductName,
-			&shoentry.ShoeMainPicture,
-			&shoentry.ShoeAttributes,
-			&shoentry.ShoeDescription,
-			&shoentry.ShoeTimestamp,
-			&shoentry.PictureID,
-			&shoentry.PictureLocalPath,
-			&shoentry.PictureDiscordURL,
-			&shoentry.PictureMessageID,
-			&shoentry.PictureLatitude,
-			&shoentry.PictureLongitude,
-			&shoentry.PictureTakenAt,
-			&shoentry.PictureUpdatedAt,
-			&shoentry.PictureCreatedAt,
-			&shoentry.ShoentryUpdatedAt,
-			&shoentry.ShoentryCreatedAt,
-		)
+	c.JSON(http.StatusOK, shoentries)
+}
+
+func rowID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid id"})
+		return 0, false
+	}
+	return id, true
+}
+
+// handleUpdate applies the fields of the JSON body to the row. Fields that
+// are not editable, like timestamps, are rejected.
+func (s *server) handleUpdate(table string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := rowID(c)
+		if !ok {
+			return
+		}
+		ctx := c.Request.Context()
+
+		row, err := database.GetRow(ctx, s.store, table, id)
+		if errors.Is(err, database.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Row not found"})
+			return
+		}
+		if err != nil {
+			log.Printf("Error querying %s: %v", table, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch " + table})
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
 		if err != nil {
-			log.Printf("Error scanning recent shoentry: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error scanning recent shoentry"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid body: %v", err)})
+			return
+		}
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(body, &fields); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid body: %v", err)})
 			return
 		}
-		shoentries = append(shoentries, shoentry)
+		allowed := database.EditableFields[table]
+		for key := range fields {
+			if !slices.Contains(allowed, key) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s cannot be edited, expected one of %s", key, strings.Join(allowed, ", "))})
+				return
+			}
+		}
+
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(row); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid body: %v", err)})
+			return
+		}
+
+		if err := database.UpdateRow(ctx, s.store, id, row); err != nil {
+			log.Printf("Error updating %s %d: %v", table, id, err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Failed to update %s: %v", table, err)})
+			return
+		}
+
+		row, err = database.GetRow(ctx, s.store, table, id)
+		if err != nil {
+			log.Printf("Error querying %s: %v", table, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch " + table})
+			return
+		}
+		c.JSON(http.StatusOK, row)
 	}
-	if err = rows.Err(); err != nil {
-		log.Printf("Error reading recent shoentry rows: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading recent shoentry rows"})
+}
+
+func (s *server) handleDelete(table string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := rowID(c)
+		if !ok {
+			return
+		}
+
+		_, err := database.DeleteRow(c.Request.Context(), s.store, table, id)
+		if errors.Is(err, database.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Row not found"})
+			return
+		}
+		if err != nil {
+			log.Printf("Error deleting %s %d: %v", table, id, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete from " + table})
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// handlePurge removes a deleted row for good, with the files of its
+// pictures.
+func (s *server) handlePurge(table string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := rowID(c)
+		if !ok {
+			return
+		}
+
+		_, err := database.PurgeRowAndFiles(c.Request.Context(), s.store, s.shoeImageDir, table, id)
+		if errors.Is(err, database.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Row not found"})
+			return
+		}
+		if err != nil {
+			log.Printf("Error purging %s %d: %v", table, id, err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Failed to purge %s: %v", table, err)})
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// handleMerge merges the row into the row with the id in the body, e.g.
+// POST /items/2/merge {"into": 1}.
+func (s *server) handleMerge(table string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := rowID(c)
+		if !ok {
+			return
+		}
+		var body struct {
+			Into int64 `json:"into" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Expected {\"into\": id}"})
+			return
+		}
+		ctx := c.Request.Context()
+
+		err := database.MergeRows(ctx, s.store, table, id, body.Into)
+		if errors.Is(err, database.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Row not found"})
+			return
+		}
+		if err != nil {
+			log.Printf("Error merging %s %d into %d: %v", table, id, body.Into, err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Failed to merge %s: %v", table, err)})
+			return
+		}
+
+		row, err := database.GetRow(ctx, s.store, table, body.Into)
+		if err != nil {
+			log.Printf("Error querying %s: %v", table, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch " + table})
+			return
+		}
+		c.JSON(http.StatusOK, row)
+	}
+}
+
+// requireToken only lets requests through that send one of tokens as
+// "Authorization: Bearer <token>", and records their changes under the user
+// of the token.
+func requireToken(tokens map[string]string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		actor := ""
+		if ok {
+			actor = tokenUser(tokens, token)
+		}
+		if actor == "" {
+			c.Header("WWW-Authenticate", "Bearer")
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Missing or invalid API token"})
+			return
+		}
+		c.Request = c.Request.WithContext(database.WithActor(c.Request.Context(), actor))
+		c.Next()
+	}
+}
+
+// tokenUser returns the user of token, or "" for an unknown token. Every
+// token is compared in constant time.
+func tokenUser(tokens map[string]string, token string) string {
+	user := ""
+	for known, name := range tokens {
+		if subtle.ConstantTimeCompare([]byte(known), []byte(token)) == 1 {
+			user = name
+		}
+	}
+	return user
+}
+
+func (s *server) handleRestore(table string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := rowID(c)
+		if !ok {
+			return
+		}
+		ctx := c.Request.Context()
+
+		err := s.store.RestoreRow(ctx, table, id)
+		if errors.Is(err, database.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Row not found"})
+			return
+		}
+		if err != nil {
+			log.Printf("Error restoring %s %d: %v", table, id, err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Failed to restore %s: %v", table, err)})
+			return
+		}
+
+		row, err := database.GetRow(ctx, s.store, table, id)
+		if err != nil {
+			log.Printf("Error querying %s: %v", table, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch " + table})
+			return
+		}
+		c.JSON(http.StatusOK, row)
+	}
+}
+
+func (s *server) handleHistory(c *gin.Context) {
+	id, ok := rowID(c)
+	if !ok {
 		return
 	}
-	c.JSON(http.StatusOK, shoentries)
+
+	changes, err := s.store.History(c.Request.Context(), c.Param("table"), id)
+	if err != nil {
+		log.Printf("Error querying history: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch history"})
+		return
+	}
+	c.JSON(http.StatusOK, changes)
+}
+
+func (s *server) handleAlerts(c *gin.Context) {
+	alerts, err := s.store.QueryPriceAlerts(c.Request.Context())
+	if err != nil {
+		log.Printf("Error querying price alerts: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch alerts"})
+		return
+	}
+	c.JSON(http.StatusOK, alerts)
+}
+
+// handleAddAlert adds an alert on the shoe with the product name in the body,
+// e.g. POST /alerts {"product_name": "Air-Jordan-1", "kind": "below", "threshold": 900}.
+func (s *server) handleAddAlert(c *gin.Context) {
+	var body struct {
+		ProductName string  `json:"product_name" binding:"required"`
+		Kind        string  `json:"kind" binding:"required"`
+		Threshold   float64 `json:"threshold" binding:"required"`
+		Currency    string  `json:"currency"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid body: %v", err)})
+		return
+	}
+	ctx := c.Request.Context()
+
+	shoe, err := s.store.GetShoeByProductName(ctx, body.ProductName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch shoe details"})
+		return
+	}
+	if shoe == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shoe not found"})
+		return
+	}
+
+	alert := database.PriceAlert{ShoeID: shoe.ID, Kind: body.Kind, Threshold: body.Threshold, Currency: strings.ToUpper(body.Currency)}
+	if err := alert.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid alert: %v", err)})
+		return
+	}
+	id, err := s.store.InsertPriceAlert(ctx, alert)
+	if err != nil {
+		log.Printf("Error inserting price alert: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add alert"})
+		return
+	}
+
+	alerts, err := s.store.GetPriceAlertsByShoeID(ctx, shoe.ID)
+	if err != nil {
+		log.Printf("Error querying price alerts: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch alerts"})
+		return
+	}
+	for _, a := range alerts {
+		if a.ID == id {
+			c.JSON(http.StatusCreated, a)
+			return
+		}
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch alerts"})
+}
+
+func (s *server) handleDeleteAlert(c *gin.Context) {
+	id, ok := rowID(c)
+	if !ok {
+		return
+	}
+
+	err := s.store.DeletePriceAlert(c.Request.Context(), id)
+	if errors.Is(err, database.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alert not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error deleting price alert %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete alert"})
+		return
+	}
+	c.Status(http.StatusNoContent)
 }
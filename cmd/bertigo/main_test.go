@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"vertigo/pkg/config"
+	"vertigo/pkg/database"
+	"vertigo/pkg/restaurant"
+	"vertigo/pkg/stockx"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testConfig lets the token "secret" of alice change data.
+func testConfig(t *testing.T) *config.Config {
+	return &config.Config{ImageDir: t.TempDir(), API: config.API{Tokens: map[string]string{"secret": "alice"}}}
+}
+
+// serve sends a request with the token, if any, to r.
+func serve(r http.Handler, method, path, body, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestShoentryHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	if err := store.InsertShoe(ctx, stockx.ProductDetails{Name: "Air Jordan 1", ProductName: "Air-Jordan-1", LastSale: "$180"}); err != nil {
+		t.Fatalf("InsertShoe failed: %v", err)
+	}
+	shoe, _ := store.GetShoeByProductName(ctx, "Air-Jordan-1")
+	pictureID, _ := store.InsertPicture(ctx, "img_data/shoentries/1.jpg", "https://cdn.discordapp.com/1.jpg", "1", 0, 0, time.Now())
+	if _, err := store.InsertShoentry(ctx, shoe.ID, pictureID); err != nil {
+		t.Fatalf("InsertShoentry failed: %v", err)
+	}
+
+	r := newRouter(store, testConfig(t))
+
+	tests := []struct {
+		path   string
+		status int
+		count  int
+	}{
+		{"/shoentries/1", http.StatusOK, 1},
+		{"/shoentries/2", http.StatusOK, 0},
+		{"/shoentries/abc", http.StatusBadRequest, 0},
+		{"/recent-shoentries", http.StatusOK, 1},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if w.Code != tt.status {
+			t.Fatalf("%s: expected status %d, got %d", tt.path, tt.status, w.Code)
+		}
+		if tt.status != http.StatusOK {
+			continue
+		}
+		var shoentries []database.ShoentryDetails
+		if err := json.Unmarshal(w.Body.Bytes(), &shoentries); err != nil {
+			t.Fatalf("%s: invalid JSON: %v", tt.path, err)
+		}
+		if len(shoentries) != tt.count {
+			t.Fatalf("%s: expected %d shoentries, got %d", tt.path, tt.count, len(shoentries))
+		}
+		if tt.count > 0 && shoentries[0].ShoeProductName != "Air-Jordan-1" {
+			t.Fatalf("%s: expected Air-Jordan-1, got %s", tt.path, shoentries[0].ShoeProductName)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/shoes/Air-Jordan-1/prices", nil))
+	var prices []database.ShoePrice
+	if err := json.Unmarshal(w.Body.Bytes(), &prices); err != nil {
+		t.Fatalf("Invalid prices JSON: %v", err)
+	}
+	if len(prices) != 1 || prices[0].Amount != 180 || prices[0].Currency != "USD" {
+		t.Fatalf("Unexpected prices %+v", prices)
+	}
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/shoes/Unknown/prices", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Expected 404 for an unknown shoe, got %d", w.Code)
+	}
+
+	alertTests := []struct {
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{http.MethodPost, "/alerts", `{"product_name": "Air-Jordan-1", "kind": "below", "threshold": 150, "currency": "usd"}`, http.StatusCreated},
+		{http.MethodPost, "/alerts", `{"product_name": "Air-Jordan-1", "kind": "above", "threshold": 150}`, http.StatusBadRequest},
+		{http.MethodPost, "/alerts", `{"product_name": "Unknown", "kind": "drop", "threshold": 10}`, http.StatusNotFound},
+		{http.MethodGet, "/alerts", "", http.StatusOK},
+		{http.MethodDelete, "/alerts/1", "", http.StatusNoContent},
+		{http.MethodDelete, "/alerts/1", "", http.StatusNotFound},
+	}
+	for _, tt := range alertTests {
+		w := serve(r, tt.method, tt.path, tt.body, "secret")
+		if w.Code != tt.status {
+			t.Fatalf("%s %s: expected status %d, got %d: %s", tt.method, tt.path, tt.status, w.Code, w.Body.String())
+		}
+		if tt.method == http.MethodGet {
+			var alerts []database.PriceAlert
+			if err := json.Unmarshal(w.Body.Bytes(), &alerts); err != nil || len(alerts) != 1 || alerts[0].Currency != "USD" {
+				t.Fatalf("Unexpected alerts %s", w.Body.String())
+			}
+		}
+	}
+}
+
+func TestUpdateDeleteMergeHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	keepID, _ := store.InsertRestaurant(ctx, restaurant.RestaurantDetails{Name: "Pizza Place"})
+	dropID, _ := store.InsertRestaurant(ctx, restaurant.RestaurantDetails{Name: "Pizza-Place"})
+	pictureID, _ := store.InsertPicture(ctx, "", "", "1", 0, 0, time.Now())
+	entryID, _ := store.InsertFoodentry(ctx, "Margherita", dropID, pictureID)
+	watchID, _ := store.InsertItem(ctx, database.Item{Category: "watches", Name: "Speedmaster"})
+	dupID, _ := store.InsertItem(ctx, database.Item{Category: "watches", Name: "Speedy"})
+
+	r := newRouter(store, testConfig(t))
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{http.MethodPatch, fmt.Sprintf("/items/%d", keepID), `{"name": "Pizza Palace"}`, http.StatusOK},
+		{http.MethodPatch, fmt.Sprintf("/items/%d", keepID), `{"unknown": 1}`, http.StatusBadRequest},
+		{http.MethodPatch, fmt.Sprintf("/items/%d", keepID), `{"name": "x", "created_at": "2024-01-01T00:00:00Z"}`, http.StatusBadRequest},
+		{http.MethodPatch, "/items/42", `{"name": "x"}`, http.StatusNotFound},
+		{http.MethodPost, fmt.Sprintf("/items/%d/merge", dropID), `{"into": 42}`, http.StatusNotFound},
+		{http.MethodPost, fmt.Sprintf("/items/%d/merge", dropID), fmt.Sprintf(`{"into": %d}`, keepID), http.StatusOK},
+		{http.MethodPatch, fmt.Sprintf("/items/%d", watchID), `{"title": "Omega Speedmaster"}`, http.StatusOK},
+		{http.MethodPatch, fmt.Sprintf("/items/%d", watchID), `{"category": "books"}`, http.StatusBadRequest},
+		{http.MethodPost, fmt.Sprintf("/items/%d/merge", dupID), fmt.Sprintf(`{"into": %d}`, watchID), http.StatusOK},
+		{http.MethodDelete, fmt.Sprintf("/items/%d", dupID), "", http.StatusNotFound},
+		{http.MethodDelete, fmt.Sprintf("/entries/%d", entryID), "", http.StatusNoContent},
+		{http.MethodDelete, fmt.Sprintf("/entries/%d", entryID), "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		w := serve(r, tt.method, tt.path, tt.body, "secret")
+		if w.Code != tt.status {
+			t.Fatalf("%s %s: expected status %d, got %d: %s", tt.method, tt.path, tt.status, w.Code, w.Body.String())
+		}
+	}
+
+	if rt, _ := store.GetRestaurantByName(ctx, "Pizza Palace"); rt == nil || rt.ID != keepID {
+		t.Fatalf("Expected restaurant %d to be renamed, got %+v", keepID, rt)
+	}
+	if rt, _ := store.GetRestaurantByID(ctx, dropID); rt != nil {
+		t.Fatalf("Expected restaurant %d to be merged away", dropID)
+	}
+	if picture, _ := store.GetPictureByID(ctx, pictureID); picture != nil {
+		t.Fatalf("Expected the picture of the deleted foodentry to be deleted")
+	}
+
+	w := serve(r, http.MethodPost, fmt.Sprintf("/entries/%d/restore", entryID), "", "secret")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected restore to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+	if picture, _ := store.GetPictureByID(ctx, pictureID); picture == nil {
+		t.Fatalf("Expected the picture to be restored with its foodentry")
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/history/entries/%d", entryID), nil))
+	var changes []database.Change
+	if err := json.Unmarshal(w.Body.Bytes(), &changes); err != nil {
+		t.Fatalf("Invalid history JSON: %v", err)
+	}
+	// Created, moved by the merge, deleted and restored.
+	if len(changes) != 4 || changes[0].Field != "CreatedAt" || changes[1].Field != "ItemID" || changes[3].ChangedBy != "alice" {
+		t.Fatalf("Unexpected history %+v", changes)
+	}
+}
+
+func TestPurgeHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	cfg := testConfig(t)
+	restaurantID, _ := store.InsertRestaurant(ctx, restaurant.RestaurantDetails{Name: "Pizza Place"})
+	picturePath := filepath.Join(cfg.ImageDir, "food", "margherita.jpg")
+	if err := os.MkdirAll(filepath.Dir(picturePath), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(picturePath, []byte("jpeg"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	pictureID, _ := store.InsertPicture(ctx, picturePath, "", "1", 0, 0, time.Now())
+	entryID, _ := store.InsertFoodentry(ctx, "Margherita", restaurantID, pictureID)
+	r := newRouter(store, cfg)
+
+	path := fmt.Sprintf("/items/%d/purge", restaurantID)
+	tests := []struct {
+		method string
+		path   string
+		token  string
+		status int
+	}{
+		{http.MethodDelete, path, "", http.StatusUnauthorized},
+		{http.MethodDelete, path, "secret", http.StatusBadRequest},
+		{http.MethodDelete, fmt.Sprintf("/items/%d", restaurantID), "secret", http.StatusNoContent},
+		{http.MethodDelete, path, "secret", http.StatusNoContent},
+		{http.MethodDelete, path, "secret", http.StatusNotFound},
+		{http.MethodPost, fmt.Sprintf("/items/%d/restore", restaurantID), "secret", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		w := serve(r, tt.method, tt.path, "", tt.token)
+		if w.Code != tt.status {
+			t.Fatalf("%s %s: expected status %d, got %d: %s", tt.method, tt.path, tt.status, w.Code, w.Body.String())
+		}
+	}
+
+	if _, err := os.Stat(picturePath); !os.IsNotExist(err) {
+		t.Fatalf("Expected the picture file to be removed, got %v", err)
+	}
+	if _, err := database.GetRow(ctx, store, "entries", entryID); !errors.Is(err, database.ErrNotFound) {
+		t.Fatalf("Expected the foodentry to be purged with its restaurant, got %v", err)
+	}
+}
+
+func TestAuthAndCORS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	id, _ := store.InsertRestaurant(ctx, restaurant.RestaurantDetails{Name: "Pizza Place"})
+	cfg := testConfig(t)
+	cfg.API.Origins = []string{"https://vertigo.example.com"}
+	r := newRouter(store, cfg)
+
+	path := fmt.Sprintf("/items/%d", id)
+	for _, token := range []string{"", "wrong"} {
+		if w := serve(r, http.MethodDelete, path, "", token); w.Code != http.StatusUnauthorized {
+			t.Fatalf("Token %q: expected 401, got %d", token, w.Code)
+		}
+	}
+	if w := serve(r, http.MethodPost, "/alerts", `{}`, ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected alerts to need a token, got %d", w.Code)
+	}
+	if w := serve(r, http.MethodGet, "/alerts", "", ""); w.Code != http.StatusOK {
+		t.Fatalf("Expected reads to need no token, got %d", w.Code)
+	}
+	if rt, _ := store.GetRestaurantByID(ctx, id); rt == nil {
+		t.Fatalf("Expected the restaurant to survive requests without a valid token")
+	}
+	if w := serve(newRouter(store, &config.Config{ImageDir: t.TempDir()}), http.MethodDelete, path, "", "secret"); w.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected changes to be refused without configured tokens, got %d", w.Code)
+	}
+
+	for origin, allowed := range map[string]bool{"https://vertigo.example.com": true, "https://evil.example.com": false} {
+		req := httptest.NewRequest(http.MethodGet, "/alerts", nil)
+		req.Header.Set("Origin", origin)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if got := w.Header().Get("Access-Control-Allow-Origin") == origin; got != allowed {
+			t.Errorf("Origin %s: expected allowed %v, got headers %v", origin, allowed, w.Header())
+		}
+	}
+}
@@ -1,17 +1,42 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"log"
+	"os"
+	"vertigo/pkg/config"
+	"vertigo/pkg/database"
 	"vertigo/pkg/discordBot"
 )
 
 func main() {
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	db, err := database.GetDB(cfg.DatabasePath)
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	bot, err := discordBot.New(cfg)
+	if err != nil {
+		log.Fatalf("Failed to set up discord: %v", err)
+	}
 	// product, err := stockx.GetShoeInformation("https://stockx.com/nike-air-force-1-low-07-chinese-new-year-2024")
 	// if err != nil {
 	// 	log.Fatalf("Can't get shoe information from stockx: %v", err)
 	// }
-	// stockx.GetVisualItem(product.ProductName, product.MainPicture)
-	_, _, err := discordBot.OnboardNewImage("img_data/shoentries/test.jpg")
+	// stockx.GetVisualItem(cfg, product.ProductName, product.MainPicture)
+	image, err := bot.UploadImage(context.Background(), "img_data/shoentries/test.jpg")
+	if err != nil {
+		log.Fatalf("Can't upload image: %v", err)
+	}
+	err = db.InUnitOfWork(context.Background(), func(uow database.UnitOfWork) error {
+		uow.OnRollback(func(ctx context.Context) error { return bot.DeleteUploadedImage(ctx, *image) })
+		_, err := bot.StoreImage(context.Background(), uow, *image, "shoe")
+		return err
+	})
 	if err != nil {
 		log.Fatalf("Can't onboard image: %v", err)
 	}
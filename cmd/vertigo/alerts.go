@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"vertigo/pkg/database"
+)
+
+// parseAlert reads the arguments of 'vertigo alerts add'.
+func parseAlert(ctx context.Context, shoes database.ShoeStore, args []string) (database.PriceAlert, error) {
+	if len(args) < 3 || len(args) > 4 {
+		return database.PriceAlert{}, errors.New("expected <product-name> below <price> [currency] or <product-name> drop <percent>")
+	}
+	shoe, err := shoes.GetShoeByProductName(ctx, args[0])
+	if err != nil {
+		return database.PriceAlert{}, err
+	}
+	if shoe == nil {
+		return database.PriceAlert{}, fmt.Errorf("no shoe with product name %s", args[0])
+	}
+	threshold, err := strconv.ParseFloat(args[2], 64)
+	if err != nil {
+		return database.PriceAlert{}, fmt.Errorf("invalid threshold %q", args[2])
+	}
+
+	alert := database.PriceAlert{ShoeID: shoe.ID, Kind: args[1], Threshold: threshold}
+	if len(args) == 4 {
+		if alert.Kind != database.AlertBelow {
+			return database.PriceAlert{}, errors.New("only a target price takes a currency")
+		}
+		alert.Currency = strings.ToUpper(args[3])
+	}
+	return alert, alert.Validate()
+}
+
+func runAlertsAdd(ctx context.Context, a *app, args []string) {
+	alert, err := parseAlert(ctx, a.db, args)
+	if err != nil {
+		log.Fatalf("Invalid alert: %v", err)
+	}
+	id, err := a.db.InsertPriceAlert(ctx, alert)
+	if err != nil {
+		log.Fatalf("Failed to add alert: %v", err)
+	}
+	fmt.Printf("Added alert %d on a %s\n", id, alert)
+}
+
+// alertRow is a price alert as listed, with the shoe and rule spelled out.
+type alertRow struct {
+	database.PriceAlert
+	Shoe string `json:"shoe"`
+	Rule string `json:"rule"`
+}
+
+func setupAlertsList(fs *flag.FlagSet) runFunc {
+	list := listFlags(fs, alertRow{}, "id", "shoe", "rule", "last_triggered_at", "created_by")
+	return func(ctx context.Context, a *app, args []string) {
+		alerts, err := a.db.QueryPriceAlerts(ctx)
+		if err != nil {
+			log.Fatalf("Failed to query alerts: %v", err)
+		}
+		rows := []alertRow{}
+		for _, alert := range alerts {
+			shoe := strconv.FormatInt(alert.ShoeID, 10)
+			if s, err := a.db.GetShoeByID(ctx, alert.ShoeID); err == nil && s != nil {
+				shoe = s.ProductName
+			}
+			rows = append(rows, alertRow{PriceAlert: alert, Shoe: shoe, Rule: alert.String()})
+		}
+		if err := list.print(os.Stdout, rows); err != nil {
+			log.Fatalf("Failed to print alerts: %v", err)
+		}
+	}
+}
+
+func runAlertsRemove(ctx context.Context, a *app, args []string) {
+	id := argID(args[0])
+	if err := a.db.DeletePriceAlert(ctx, id); err != nil {
+		log.Fatalf("Failed to remove alert %d: %v", id, err)
+	}
+	fmt.Printf("Removed alert %d\n", id)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"vertigo/pkg/assets"
+)
+
+type reconcileFunc func(ctx context.Context, r *assets.Reconciler) ([]assets.Issue, error)
+
+func verifyAssets(ctx context.Context, r *assets.Reconciler) ([]assets.Issue, error) {
+	return r.Verify(ctx)
+}
+
+func syncAssets(ctx context.Context, r *assets.Reconciler) ([]assets.Issue, error) {
+	return r.Sync(ctx)
+}
+
+func gcAssets(ctx context.Context, r *assets.Reconciler) ([]assets.Issue, error) {
+	return r.GC(ctx)
+}
+
+// assetChanges is whether an assets command changes anything.
+type assetChanges int
+
+const (
+	// reportOnly never changes anything.
+	reportOnly assetChanges = iota
+	// changeUnlessDryRun changes what it finds unless -dry-run is given.
+	changeUnlessDryRun
+	// changeWithDelete only reports what it would delete unless -delete is
+	// given.
+	changeWithDelete
+)
+
+// setupAssets returns the setup of an assets command, which reconciles
+// img_data, the blob store and the picture URLs of the shoes. It exits with
+// status 1 while issues are left unresolved.
+func setupAssets(reconcile reconcileFunc, changes assetChanges) func(fs *flag.FlagSet) runFunc {
+	return func(fs *flag.FlagSet) runFunc {
+		dryRun, remove := new(bool), new(bool)
+		switch changes {
+		case changeUnlessDryRun:
+			dryRun = fs.Bool("dry-run", false, "Report what would change without changing it")
+		case changeWithDelete:
+			remove = fs.Bool("delete", false, "Delete what is reported instead of only reporting it")
+		}
+		return func(ctx context.Context, a *app, args []string) {
+			reconciler := assets.New(a.cfg, a.db, a.blobs())
+			reconciler.DryRun = *dryRun || (changes == changeWithDelete && !*remove)
+
+			issues, err := reconcile(ctx, reconciler)
+			for _, issue := range issues {
+				fmt.Println(issue)
+			}
+			if err != nil {
+				log.Fatalf("Failed to reconcile assets: %v", err)
+			}
+
+			unresolved := assets.Unresolved(issues)
+			switch {
+			case len(issues) == 0:
+				fmt.Println("Assets are in sync.")
+			case unresolved == 0:
+				fmt.Printf("Resolved %d issue(s).\n", len(issues))
+			default:
+				fmt.Printf("%d of %d issue(s) unresolved.\n", unresolved, len(issues))
+				os.Exit(exitFailure)
+			}
+		}
+	}
+}
@@ -0,0 +1,376 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"vertigo/pkg/config"
+	"vertigo/pkg/database"
+)
+
+// Exit statuses of vertigo.
+const (
+	exitFailure = 1
+	exitUsage   = 2
+)
+
+// runFunc runs a command once its flags and arguments have been checked.
+type runFunc func(ctx context.Context, a *app, args []string)
+
+// command is a subcommand of vertigo, like "shoe add" or "history".
+type command struct {
+	name    string
+	args    string
+	summary string
+	// minArgs and maxArgs bound the number of arguments, maxArgs -1 allows
+	// any number.
+	minArgs, maxArgs int
+	// values lists what the argument at each position may be, a nil entry
+	// allows anything. They are also offered by the shell completion.
+	values [][]string
+	// ints are the positions of arguments that must be whole numbers.
+	ints []int
+	// bare commands run without configuration and database.
+	bare bool
+	// noMigrate commands run before pending migrations are applied.
+	noMigrate bool
+	// rawArgs commands get their arguments as typed, flags included.
+	rawArgs bool
+	hidden  bool
+	// setup registers the flags of the command on fs and returns the function
+	// running it.
+	setup func(fs *flag.FlagSet) runFunc
+}
+
+func noFlags(run runFunc) func(fs *flag.FlagSet) runFunc {
+	return func(*flag.FlagSet) runFunc { return run }
+}
+
+func discordFlag(fs *flag.FlagSet) *bool {
+	return fs.Bool("discord", false, "Post to the Discord notification channel")
+}
+
+var (
+	jobStates = []string{database.JobQueued, database.JobRunning, database.JobFailed, database.JobDone, database.JobCancelled}
+	shells    = []string{"bash", "zsh", "fish"}
+)
+
+var commands = []*command{
+	{name: "shoe add", args: "<stockx-url>", summary: "Add a shoe from its StockX page, or record its price if it exists", minArgs: 1, maxArgs: 1, setup: setupShoeAdd},
+	{name: "shoe create", args: "<field=value>...", summary: "Add a shoe that isn't on StockX from its name, image_url and other fields", minArgs: 2, maxArgs: -1, setup: setupShoeCreate},
+	{name: "shoe list", summary: "List the shoes", setup: setupShoeList},
+	{name: "shoe import", args: "<file>", summary: "Queue the StockX URLs in a file, one per line, and add them", minArgs: 1, maxArgs: 1, setup: setupShoeImport},
+	{name: "entry add", args: "<image> <product-name>", summary: "Add a picture of a shoe", minArgs: 2, maxArgs: 2, setup: setupEntryAdd},
+	{name: "entry list", summary: "List the shoe entries", setup: setupEntryList},
+	{name: "food add", args: "<image> <food-name>", summary: "Add a picture of food, the restaurant is looked up on OSM unless -restaurant is given", minArgs: 2, maxArgs: 2, setup: setupFoodAdd},
+	{name: "food list", summary: "List the food entries", setup: setupFoodList},
+	{name: "restaurant list", summary: "List the restaurants", setup: setupRestaurantList},
+	{name: "picture list", summary: "List the pictures", setup: setupPictureList},
+	{name: "item categories", summary: "List the categories of items", setup: setupItemCategories},
+	{name: "item add", args: "<category> <arg>...", summary: "Add an item to a category, from what its provider takes", minArgs: 2, maxArgs: -1, setup: noFlags(runItemAdd)},
+	{name: "item list", args: "<category>", summary: "List the items of a category", minArgs: 1, maxArgs: 1, setup: setupItemList},
+	{name: "item entry", args: "<category> <image> [item]", summary: "Add a picture of an item, found by its provider unless it is named", minArgs: 2, maxArgs: 3, setup: setupItemEntry},
+	{name: "item entries", args: "<category>", summary: "List the entries of a category", minArgs: 1, maxArgs: 1, setup: setupItemEntries},
+	{name: "edit", args: "<table> <id> <field=value>...", summary: "Change fields of a row", minArgs: 3, maxArgs: -1, values: [][]string{database.Tables}, ints: []int{1}, setup: noFlags(runEdit)},
+	{name: "delete", args: "<table> <id>", summary: "Delete a row with its entries and pictures, undo with restore", minArgs: 2, maxArgs: 2, values: [][]string{database.Tables}, ints: []int{1}, setup: noFlags(runDelete)},
+	{name: "merge", args: "<table> <id> <into-id>", summary: "Move the entries of an item to another one and delete it", minArgs: 3, maxArgs: 3, values: [][]string{database.MergeTables}, ints: []int{1, 2}, setup: noFlags(runMerge)},
+	{name: "history", args: "<table> <id>", summary: "List the changes made to a row", minArgs: 2, maxArgs: 2, values: [][]string{database.Tables}, ints: []int{1}, setup: setupHistory},
+	{name: "restore", args: "<table> <id>", summary: "Bring back a deleted row", minArgs: 2, maxArgs: 2, values: [][]string{database.Tables}, ints: []int{1}, setup: noFlags(runRestore)},
+	{name: "purge", args: "<table> <id>", summary: "Remove a deleted row for good, including its image files", minArgs: 2, maxArgs: 2, values: [][]string{database.Tables}, ints: []int{1}, setup: noFlags(runPurge)},
+	{name: "refresh", args: "[product-name...]", summary: "Record the current price of all or the given shoes", maxArgs: -1, setup: setupRefresh},
+	{name: "alerts add", args: "<product-name> below|drop <threshold> [currency]", summary: "Add an alert on the price falling below threshold or dropping by threshold percent", minArgs: 3, maxArgs: 4, values: [][]string{nil, {database.AlertBelow, database.AlertDrop}}, setup: noFlags(runAlertsAdd)},
+	{name: "alerts list", summary: "List the price alerts", setup: setupAlertsList},
+	{name: "alerts remove", args: "<id>", summary: "Remove a price alert", minArgs: 1, maxArgs: 1, ints: []int{0}, setup: noFlags(runAlertsRemove)},
+	{name: "jobs list", args: "[state]", summary: "List the jobs, optionally only those in one state", maxArgs: 1, values: [][]string{jobStates}, setup: setupJobsList},
+	{name: "jobs run", summary: "Work off the queued jobs", setup: setupJobsRun},
+	{name: "jobs retry", args: "<id>", summary: "Queue a failed or cancelled job again", minArgs: 1, maxArgs: 1, ints: []int{0}, setup: noFlags(runJobsRetry)},
+	{name: "jobs cancel", args: "<id>", summary: "Cancel a queued or running job", minArgs: 1, maxArgs: 1, ints: []int{0}, setup: noFlags(runJobsCancel)},
+	{name: "assets verify", summary: "Report where img_data, the blob store and the picture URLs disagree", setup: setupAssets(verifyAssets, reportOnly)},
+	{name: "assets sync", summary: "Fix what assets verify reports", setup: setupAssets(syncAssets, changeUnlessDryRun)},
+	{name: "assets gc", summary: "Report pictures nothing refers to any more, -delete deletes them", setup: setupAssets(gcAssets, changeWithDelete)},
+	{name: "migrate up", summary: "Apply the pending migrations", noMigrate: true, setup: noFlags(runMigrateUp)},
+	{name: "migrate down", args: "[steps]", summary: "Revert the last or the given number of migrations", maxArgs: 1, ints: []int{0}, noMigrate: true, setup: noFlags(runMigrateDown)},
+	{name: "migrate status", summary: "List the migrations and whether they are applied", noMigrate: true, setup: noFlags(runMigrateStatus)},
+	{name: "migrate check", summary: "Report rows that reference missing rows", noMigrate: true, setup: noFlags(runMigrateCheck)},
+	{name: "migrate force", args: "<version>", summary: "Mark a dirty database as being at version", minArgs: 1, maxArgs: 1, ints: []int{0}, noMigrate: true, setup: noFlags(runMigrateForce)},
+	{name: "completion", args: "bash|zsh|fish", summary: "Print the shell completion script", minArgs: 1, maxArgs: 1, values: [][]string{shells}, bare: true, setup: noFlags(runCompletion)},
+}
+
+func init() {
+	// These refer to the table, so they can't be part of its initializer.
+	commands = append(commands,
+		&command{name: "help", args: "[command]", summary: "Show the usage of vertigo or a command", maxArgs: 2, bare: true, setup: noFlags(runHelp)},
+		&command{name: "__complete", maxArgs: -1, bare: true, rawArgs: true, hidden: true, setup: noFlags(runComplete)},
+	)
+}
+
+// replacedFlags are the flags vertigo had before it had subcommands and
+// what replaced them.
+var replacedFlags = map[string]string{
+	"list":       "'vertigo shoe list'",
+	"add":        "'vertigo shoe add <stockx-url>', or 'vertigo shoe create name=<name> image_url=<url> [field=value...]' for shoes that aren't on StockX",
+	"file":       "'vertigo shoe import <file>'",
+	"shoentry":   "'vertigo entry add <image> <product-name>'",
+	"shoe":       "'vertigo entry add <image> <product-name>'",
+	"foodimage":  "'vertigo food add [-restaurant name] <image> <food-name>'",
+	"foodname":   "'vertigo food add [-restaurant name] <image> <food-name>'",
+	"restaurant": "'vertigo food add [-restaurant name] <image> <food-name>'",
+	"edit":       "'vertigo edit <table> <id> <field=value>...'",
+	"delete":     "'vertigo delete <table> <id>'",
+	"merge":      "'vertigo merge <table> <id> <into-id>'",
+	"id":         "'vertigo edit|delete|merge <table> <id>'",
+	"into":       "'vertigo merge <table> <id> <into-id>'",
+	"discord":    "a -discord flag after the command, e.g. 'vertigo shoe add -discord <stockx-url>'",
+}
+
+// replacedFlag explains what replaced the first old flag among the global
+// flags in args.
+func replacedFlag(args []string) string {
+	for _, arg := range args {
+		name, _, _ := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if replacement, ok := replacedFlags[name]; ok && strings.HasPrefix(arg, "-") {
+			return fmt.Sprintf("%s has been replaced by %s", arg, replacement)
+		}
+	}
+	return ""
+}
+
+func globalFlags() *flag.FlagSet {
+	fs := flag.NewFlagSet("vertigo", flag.ContinueOnError)
+	config.Flags(fs)
+	return fs
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// takesValue reports whether arg is a flag of fs whose value is the next
+// argument.
+func takesValue(fs *flag.FlagSet, arg string) bool {
+	if len(arg) < 2 || arg[0] != '-' || strings.Contains(arg, "=") {
+		return false
+	}
+	f := fs.Lookup(strings.TrimLeft(arg, "-"))
+	return f != nil && !isBoolFlag(f)
+}
+
+// commandIndex returns the index of the command in args, the first argument
+// that isn't a global flag or the value of one.
+func commandIndex(globals *flag.FlagSet, args []string) int {
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "--":
+			return i + 1
+		case len(arg) < 2 || arg[0] != '-':
+			return i
+		case takesValue(globals, arg):
+			i++
+		}
+	}
+	return len(args)
+}
+
+func lookupCommand(name string) *command {
+	for _, c := range commands {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// groupCommands returns the commands whose name starts with group, like the
+// shoe commands.
+func groupCommands(group string) []*command {
+	var cmds []*command
+	for _, c := range commands {
+		if strings.HasPrefix(c.name, group+" ") && !c.hidden {
+			cmds = append(cmds, c)
+		}
+	}
+	return cmds
+}
+
+// findCommand returns the command args start with and the arguments that
+// follow it.
+func findCommand(args []string) (*command, []string, error) {
+	if len(args) == 0 {
+		return nil, nil, errors.New("missing command")
+	}
+	if len(args) > 1 {
+		if c := lookupCommand(args[0] + " " + args[1]); c != nil {
+			return c, args[2:], nil
+		}
+	}
+	if c := lookupCommand(args[0]); c != nil {
+		return c, args[1:], nil
+	}
+	if len(groupCommands(args[0])) > 0 {
+		if len(args) == 1 {
+			return nil, nil, fmt.Errorf("missing %s command", args[0])
+		}
+		return nil, nil, fmt.Errorf("unknown %s command %q", args[0], args[1])
+	}
+	return nil, nil, fmt.Errorf("unknown command %q", args[0])
+}
+
+// parse parses the flags and checks the arguments of c. Errors have been
+// written to output together with the usage of c.
+func (c *command) parse(output io.Writer, args []string) (runFunc, []string, error) {
+	fs := flag.NewFlagSet("vertigo "+c.name, flag.ContinueOnError)
+	fs.SetOutput(output)
+	run := c.setup(fs)
+	fs.Usage = func() { c.printUsage(fs.Output(), fs) }
+	if c.rawArgs {
+		return run, args, nil
+	}
+
+	args, err := parseInterspersed(fs, args)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := c.check(args); err != nil {
+		fmt.Fprintf(output, "vertigo %s: %v\n", c.name, err)
+		fs.Usage()
+		return nil, nil, err
+	}
+	return run, args, nil
+}
+
+// parseInterspersed parses the flags of fs wherever they are in args, not
+// only before the first argument, and returns the arguments. Everything
+// after -- is an argument.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		rest := fs.Args()
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		if parsed := len(args) - len(rest); parsed > 0 && args[parsed-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+func (c *command) check(args []string) error {
+	switch {
+	case len(args) < c.minArgs:
+		return fmt.Errorf("missing arguments, expected %s", c.args)
+	case c.maxArgs == 0 && len(args) > 0:
+		return errors.New("no arguments expected")
+	case c.maxArgs >= 0 && len(args) > c.maxArgs:
+		return fmt.Errorf("too many arguments, expected %s", c.args)
+	}
+	for i, values := range c.values {
+		if i < len(args) && values != nil && !slices.Contains(values, args[i]) {
+			return fmt.Errorf("invalid argument %q, expected one of %s", args[i], strings.Join(values, ", "))
+		}
+	}
+	for _, i := range c.ints {
+		if i >= len(args) {
+			continue
+		}
+		if _, err := strconv.ParseInt(args[i], 10, 64); err != nil {
+			return fmt.Errorf("invalid number %q", args[i])
+		}
+	}
+	return nil
+}
+
+// argID returns an argument c.check made sure is a number.
+func argID(arg string) int64 {
+	id, _ := strconv.ParseInt(arg, 10, 64)
+	return id
+}
+
+func (c *command) printUsage(w io.Writer, fs *flag.FlagSet) {
+	hasFlags := false
+	fs.VisitAll(func(*flag.Flag) { hasFlags = true })
+
+	usage := "usage: vertigo " + c.name
+	if hasFlags {
+		usage += " [flags]"
+	}
+	if c.args != "" {
+		usage += " " + c.args
+	}
+	fmt.Fprintf(w, "%s\n\n%s.\n", usage, c.summary)
+	if hasFlags {
+		fmt.Fprintln(w, "\nflags:")
+		fs.PrintDefaults()
+	}
+}
+
+// printCommands lists the commands, or those of group.
+func printCommands(w io.Writer, group string) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, c := range commands {
+		if c.hidden || group != "" && !strings.HasPrefix(c.name, group+" ") {
+			continue
+		}
+		fmt.Fprintf(tw, "  %s %s\t%s\n", c.name, c.args, c.summary)
+	}
+	tw.Flush()
+}
+
+func printUsage(w io.Writer, globals *flag.FlagSet) {
+	fmt.Fprintln(w, "usage: vertigo [flags] <command> [command flags] [arguments]")
+	fmt.Fprintln(w, "\ncommands:")
+	printCommands(w, "")
+	fmt.Fprintln(w, "\nflags:")
+	globals.SetOutput(w)
+	globals.PrintDefaults()
+	fmt.Fprintln(w, "\nRun 'vertigo help <command>' for the flags of a command. vertigo exits with status 1")
+	fmt.Fprintln(w, "when a command fails and with status 2 when it is used wrongly.")
+}
+
+// printCommandError reports that args don't name a command, with the
+// commands of the group they start with if there is one.
+func printCommandError(w io.Writer, args []string, err error) {
+	fmt.Fprintf(w, "vertigo: %v\n", err)
+	if len(args) > 0 && len(groupCommands(args[0])) > 0 {
+		fmt.Fprintf(w, "\nusage: vertigo %s <command>\n\ncommands:\n", args[0])
+		printCommands(w, args[0])
+		return
+	}
+	fmt.Fprintln(w, "Run 'vertigo help' for usage.")
+}
+
+func runHelp(ctx context.Context, a *app, args []string) {
+	if len(args) == 0 {
+		printUsage(os.Stdout, globalFlags())
+		return
+	}
+	c, rest, err := findCommand(args)
+	if err != nil && len(args) == 1 && len(groupCommands(args[0])) > 0 {
+		fmt.Printf("usage: vertigo %s <command>\n\ncommands:\n", args[0])
+		printCommands(os.Stdout, args[0])
+		return
+	}
+	if err == nil && len(rest) > 0 {
+		err = fmt.Errorf("unknown command %q", strings.Join(args, " "))
+	}
+	if err != nil {
+		printCommandError(os.Stderr, args, err)
+		os.Exit(exitUsage)
+	}
+	fs := flag.NewFlagSet("vertigo "+c.name, flag.ContinueOnError)
+	fs.SetOutput(os.Stdout)
+	c.setup(fs)
+	c.printUsage(os.Stdout, fs)
+}
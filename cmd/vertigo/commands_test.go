@@ -0,0 +1,102 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseCommand(t *testing.T) {
+	globals := globalFlags()
+	args := []string{"-db", "test.db", "food", "add", "pizza.jpg", "-restaurant", "Da Mario", "Margherita", "-discord"}
+	i := commandIndex(globals, args)
+	if i != 2 || replacedFlag(args[:i]) != "" {
+		t.Fatalf("Expected the command at 2, got %d", i)
+	}
+
+	cmd, rest, err := findCommand(args[i:])
+	if err != nil || cmd.name != "food add" {
+		t.Fatalf("Expected food add, got %v, %v", cmd, err)
+	}
+	_, parsed, err := cmd.parse(io.Discard, rest)
+	if err != nil || !slices.Equal(parsed, []string{"pizza.jpg", "Margherita"}) {
+		t.Fatalf("Expected the flags to be parsed between the arguments, got %q, %v", parsed, err)
+	}
+
+	cmd, rest, _ = findCommand([]string{"edit", "items", "3", "--", "-title=x"})
+	if _, parsed, err := cmd.parse(io.Discard, rest); err != nil || parsed[2] != "-title=x" {
+		t.Fatalf("Expected everything after -- to be an argument, got %q, %v", parsed, err)
+	}
+}
+
+func TestCommandUsageErrors(t *testing.T) {
+	for _, tt := range []struct {
+		args []string
+		err  string
+	}{
+		{nil, "missing command"},
+		{[]string{"shoes"}, `unknown command "shoes"`},
+		{[]string{"shoe"}, "missing shoe command"},
+		{[]string{"shoe", "remove"}, `unknown shoe command "remove"`},
+		{[]string{"shoe", "add"}, "missing arguments"},
+		{[]string{"shoe", "list", "all"}, "no arguments expected"},
+		{[]string{"food", "add", "pizza.jpg"}, "missing arguments"},
+		{[]string{"history", "items", "3", "4"}, "too many arguments"},
+		{[]string{"delete", "shoe", "3"}, `invalid argument "shoe"`},
+		{[]string{"merge", "pictures", "1", "2"}, `invalid argument "pictures"`},
+		{[]string{"jobs", "retry", "last"}, `invalid number "last"`},
+		{[]string{"shoe", "add", "-notify", "url"}, "flag provided but not defined"},
+	} {
+		cmd, rest, err := findCommand(tt.args)
+		if err == nil {
+			_, _, err = cmd.parse(io.Discard, rest)
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%q: expected %q, got %v", tt.args, tt.err, err)
+		}
+	}
+
+	cmd, rest, _ := findCommand([]string{"shoe", "add", "-h"})
+	if _, _, err := cmd.parse(io.Discard, rest); err != flag.ErrHelp {
+		t.Fatalf("Expected -h to ask for help, got %v", err)
+	}
+}
+
+func TestReplacedFlag(t *testing.T) {
+	if got := replacedFlag([]string{"-db", "test.db", "--add=https://stockx.com/x"}); !strings.Contains(got, "'vertigo shoe add <stockx-url>'") {
+		t.Fatalf("Expected a pointer to shoe add, got %q", got)
+	}
+	if got := replacedFlag([]string{"-db", "test.db"}); got != "" {
+		t.Fatalf("Expected no replaced flag, got %q", got)
+	}
+}
+
+func TestComplete(t *testing.T) {
+	globals := globalFlags()
+	for _, tt := range []struct {
+		words []string
+		want  []string
+	}{
+		{[]string{"sh"}, []string{"shoe"}},
+		{[]string{"-db", "test.db", "re"}, []string{"refresh", "restaurant", "restore"}},
+		{[]string{"-db-t"}, []string{"-db-timeout"}},
+		{[]string{"-db", ""}, nil},
+		{[]string{"shoe", ""}, []string{"add", "create", "import", "list"}},
+		{[]string{"shoe", "add", "-"}, []string{"-discord"}},
+		{[]string{"history", "e"}, []string{"entries"}},
+		{[]string{"history", "items", ""}, nil},
+		{[]string{"refresh", "-every", "6h", "-discord", "Air"}, nil},
+		{[]string{"jobs", "list", "f"}, []string{"failed"}},
+		{[]string{"alerts", "add", "Air-Jordan-1", ""}, []string{"below", "drop"}},
+		{[]string{"help", "jobs", "r"}, []string{"retry", "run"}},
+		{[]string{"shoe", "list", "-output", "j"}, []string{"json", "jsonl"}},
+		{[]string{"jobs", "list", "-fields", "id,st"}, []string{"id,state"}},
+		{[]string{"history", "items", "1", "-sort", "-changed_b"}, []string{"-changed_by"}},
+	} {
+		if got := complete(globals, tt.words); !slices.Equal(got, tt.want) {
+			t.Errorf("%q: expected %q, got %q", tt.words, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// The completion scripts ask 'vertigo __complete' for the candidates of the
+// word being completed and fall back to file names when there are none.
+var completionScripts = map[string]string{
+	"bash": `# bash completion for vertigo, load it with: source <(vertigo completion bash)
+_vertigo() {
+	local IFS=$'\n'
+	COMPREPLY=($("${COMP_WORDS[0]}" __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null))
+}
+complete -o default -F _vertigo vertigo
+`,
+	"zsh": `# zsh completion for vertigo, load it with: source <(vertigo completion zsh)
+_vertigo() {
+	local -a candidates
+	candidates=(${(f)"$($words[1] __complete "${(@)words[2,CURRENT]}" 2>/dev/null)"})
+	if (( ${#candidates} )); then
+		compadd -a candidates
+	else
+		_files
+	fi
+}
+compdef _vertigo vertigo
+`,
+	"fish": `# fish completion for vertigo, load it with: vertigo completion fish | source
+function __vertigo_complete
+	set -l words (commandline -opc)
+	set -l vertigo $words[1]
+	set -e words[1]
+	set -l current (commandline -ct)
+	set -l candidates ($vertigo __complete $words "$current" 2>/dev/null)
+	if test (count $candidates) -gt 0
+		printf '%s\n' $candidates
+	else
+		__fish_complete_path "$current"
+	end
+end
+complete -c vertigo -f -a '(__vertigo_complete)'
+`,
+}
+
+func runCompletion(ctx context.Context, a *app, args []string) {
+	fmt.Print(completionScripts[args[0]])
+}
+
+func runComplete(ctx context.Context, a *app, args []string) {
+	for _, candidate := range complete(globalFlags(), args) {
+		fmt.Println(candidate)
+	}
+}
+
+// complete returns the candidates for the last of words, the arguments after
+// vertigo up to the word being completed.
+func complete(globals *flag.FlagSet, words []string) []string {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	current, before := words[len(words)-1], words[:len(words)-1]
+
+	i := commandIndex(globals, before)
+	if i == len(before) {
+		if len(before) > 0 && takesValue(globals, before[len(before)-1]) {
+			return nil
+		}
+		if strings.HasPrefix(current, "-") {
+			return flagNames(globals, current)
+		}
+		var names []string
+		for _, c := range commands {
+			if !c.hidden {
+				name, _, _ := strings.Cut(c.name, " ")
+				names = append(names, name)
+			}
+		}
+		return matching(names, current)
+	}
+
+	words = before[i:]
+	c := lookupCommand(words[0])
+	if c != nil {
+		words = words[1:]
+	} else if len(words) == 1 {
+		var names []string
+		for _, c := range groupCommands(words[0]) {
+			names = append(names, strings.TrimPrefix(c.name, words[0]+" "))
+		}
+		return matching(names, current)
+	} else if c = lookupCommand(words[0] + " " + words[1]); c != nil {
+		words = words[2:]
+	} else {
+		return nil
+	}
+
+	if c.name == "help" {
+		return complete(flag.NewFlagSet("help", flag.ContinueOnError), append(words, current))
+	}
+
+	fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	c.setup(fs)
+	if len(words) > 0 && takesValue(fs, words[len(words)-1]) {
+		f := fs.Lookup(strings.TrimLeft(words[len(words)-1], "-"))
+		if values, ok := f.Value.(completer); ok {
+			return values.complete(current)
+		}
+		return nil
+	}
+	if strings.HasPrefix(current, "-") {
+		return flagNames(fs, current)
+	}
+	position := 0
+	for j := 0; j < len(words); j++ {
+		switch {
+		case takesValue(fs, words[j]):
+			j++
+		case !strings.HasPrefix(words[j], "-"):
+			position++
+		}
+	}
+	if position < len(c.values) {
+		return matching(c.values[position], current)
+	}
+	return nil
+}
+
+func flagNames(fs *flag.FlagSet, prefix string) []string {
+	var names []string
+	fs.VisitAll(func(f *flag.Flag) { names = append(names, "-"+f.Name) })
+	return matching(names, prefix)
+}
+
+// matching returns the distinct values starting with prefix, sorted.
+func matching(values []string, prefix string) []string {
+	var matches []string
+	for _, v := range values {
+		if strings.HasPrefix(v, prefix) && !slices.Contains(matches, v) {
+			matches = append(matches, v)
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"vertigo/pkg/database"
+	discordBot "vertigo/pkg/discordBot"
+	rt "vertigo/pkg/restaurant"
+)
+
+// imageOnboarder posts pictures of entries and records them. The upload
+// happens before the unit of work, StoreImage inside it.
+type imageOnboarder interface {
+	UploadImage(ctx context.Context, filePath string) (*discordBot.UploadedImage, error)
+	DeleteUploadedImage(ctx context.Context, image discordBot.UploadedImage) error
+	StoreImage(ctx context.Context, uow database.UnitOfWork, image discordBot.UploadedImage, imageType string) (int64, error)
+}
+
+// entryStore reads outside of a unit of work and writes inside of one.
+type entryStore interface {
+	database.Store
+	database.UnitOfWorkRunner
+}
+
+// findRestaurant returns the restaurant called name, or without a name the
+// one OSM lists first near where the picture at imagePath was taken.
+func findRestaurant(client *http.Client, name string, imagePath string) (rt.RestaurantDetails, error) {
+	if name != "" {
+		return rt.RestaurantDetails{Name: name}, nil
+	}
+	rtDetailsList, err := rt.FindRestaurants(client, imagePath)
+	if err != nil {
+		return rt.RestaurantDetails{}, fmt.Errorf("Error requesting restaurant Details from OSM: %v", err)
+	}
+	if rtDetailsList == nil {
+		return rt.RestaurantDetails{}, fmt.Errorf("No Name provided and no retsaurant found.")
+	}
+	return rtDetailsList[0], nil // For now, just take the first element in the list of possible restaurants
+}
+
+// restaurantID returns the ID of the restaurant with the name of rtDetails,
+// inserting it if it is new.
+func restaurantID(ctx context.Context, restaurants database.RestaurantStore, rtDetails rt.RestaurantDetails) (int64, error) {
+	restaurant, err := restaurants.GetRestaurantByName(ctx, rtDetails.Name)
+	if err != nil {
+		return 0, fmt.Errorf("Could not check if restaurant already exists: %v", err)
+	}
+	if restaurant != nil {
+		return restaurant.ID, nil
+	}
+	id, err := restaurants.InsertRestaurant(ctx, rtDetails)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to insert restaurant: %v", err)
+	}
+	return id, nil
+}
+
+// recordUpload runs fn in a unit of work and deletes the uploaded image when
+// the unit of work fails, also when it couldn't be started.
+func recordUpload(ctx context.Context, store database.UnitOfWorkRunner, images imageOnboarder, image discordBot.UploadedImage, fn func(uow database.UnitOfWork) error) error {
+	started := false
+	err := store.InUnitOfWork(ctx, func(uow database.UnitOfWork) error {
+		started = true
+		uow.OnRollback(func(ctx context.Context) error {
+			return images.DeleteUploadedImage(ctx, image)
+		})
+		return fn(uow)
+	})
+	if err != nil && !started {
+		if undo := images.DeleteUploadedImage(context.WithoutCancel(ctx), image); undo != nil {
+			log.Printf("Failed to clean up after rollback: %v", undo)
+		}
+	}
+	return err
+}
+
+// addShoentry adds a picture of a shoe. The picture is uploaded first, the
+// rows are inserted as a single unit of work: a failure leaves neither a
+// picture nor an entry behind.
+func addShoentry(ctx context.Context, store entryStore, images imageOnboarder, imagePath string, productName string) (*database.ShoentryDetails, error) {
+	shoe, err := store.GetShoeByProductName(ctx, productName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get shoe by name: %v", err)
+	}
+	if shoe == nil {
+		return nil, fmt.Errorf("no shoe found with the name: %s", productName)
+	}
+
+	image, err := images.UploadImage(ctx, imagePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to onboard new image: %v", err)
+	}
+
+	var shoentryDetails *database.ShoentryDetails
+	err = recordUpload(ctx, store, images, *image, func(uow database.UnitOfWork) error {
+		pictureID, err := images.StoreImage(ctx, uow, *image, "shoe")
+		if err != nil {
+			return fmt.Errorf("failed to onboard new image: %v", err)
+		}
+
+		shoentryID, err := uow.InsertShoentry(ctx, shoe.ID, pictureID)
+		if err != nil {
+			return fmt.Errorf("failed to insert shoentry: %v", err)
+		}
+
+		shoentryDetails, err = uow.GetShoentryByID(ctx, shoentryID)
+		if err != nil {
+			return fmt.Errorf("failed to retrieve shoentry: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return shoentryDetails, nil
+}
+
+// addFoodentry adds a picture of food. The restaurant is looked up and the
+// picture uploaded first, the restaurant if it is new and the rows are
+// inserted as a single unit of work.
+func addFoodentry(ctx context.Context, store entryStore, images imageOnboarder, client *http.Client, imagePath string, foodName string, restaurantName string) (*database.FoodentryDetails, error) {
+	rtDetails, err := findRestaurant(client, restaurantName, imagePath)
+	if err != nil {
+		return nil, fmt.Errorf("could not add the restaurant: %v", err)
+	}
+
+	image, err := images.UploadImage(ctx, imagePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to onboard new image: %v", err)
+	}
+
+	var foodentryDetails *database.FoodentryDetails
+	err = recordUpload(ctx, store, images, *image, func(uow database.UnitOfWork) error {
+		restaurantID, err := restaurantID(ctx, uow, rtDetails)
+		if err != nil {
+			return fmt.Errorf("could not add the restaurant: %v", err)
+		}
+
+		pictureID, err := images.StoreImage(ctx, uow, *image, "food")
+		if err != nil {
+			return fmt.Errorf("failed to onboard new image: %v", err)
+		}
+
+		foodentryID, err := uow.InsertFoodentry(ctx, foodName, restaurantID, pictureID)
+		if err != nil {
+			return fmt.Errorf("failed to insert foodentry: %v", err)
+		}
+
+		foodentryDetails, err = uow.GetFoodEntryByID(ctx, foodentryID)
+		if err != nil {
+			return fmt.Errorf("failed to retrieve foodentry: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return foodentryDetails, nil
+}
+
+func setupEntryAdd(fs *flag.FlagSet) runFunc {
+	discord := discordFlag(fs)
+	return func(ctx context.Context, a *app, args []string) {
+		bot := a.discord()
+		shoentryDetails, err := addShoentry(ctx, a.db, bot, args[0], args[1])
+		if err != nil {
+			log.Fatalf("Failed to add shoe entry: %v", err)
+		}
+
+		fmt.Println("Shoe entry added successfully")
+
+		if *discord {
+			err = bot.PostNewShoeEntry(ctx, *shoentryDetails)
+			if err != nil {
+				log.Printf("Discord couldn't be notified. %v", err)
+			} else {
+				fmt.Println("Discord successfully notified.")
+			}
+		}
+	}
+}
+
+func setupFoodAdd(fs *flag.FlagSet) runFunc {
+	restaurantName := fs.String("restaurant", "", "Name of the restaurant, when it can't be found on OSM")
+	discord := discordFlag(fs)
+	return func(ctx context.Context, a *app, args []string) {
+		bot := a.discord()
+		foodentryDetails, err := addFoodentry(ctx, a.db, bot, a.client, args[0], args[1], *restaurantName)
+		if err != nil {
+			log.Fatalf("Failed to add food entry: %v", err)
+		}
+
+		fmt.Println("Food entry added successfully")
+
+		if *discord {
+			err = bot.PostNewFoodEntry(ctx, *foodentryDetails)
+			if err != nil {
+				log.Printf("Discord couldn't be notified. %v", err)
+			} else {
+				fmt.Println("Discord successfully notified.")
+			}
+		}
+	}
+}
+
+func setupEntryList(fs *flag.FlagSet) runFunc {
+	list := listFlags(fs, database.ShoentryDetails{}, "shoentry_id", "shoe_name", "picture_local_path", "picture_taken_at", "shoentry_created_at")
+	return func(ctx context.Context, a *app, args []string) {
+		entries, err := a.db.ListShoentries(ctx)
+		if err != nil {
+			log.Fatalf("Failed to list shoe entries: %v", err)
+		}
+		if err := list.print(os.Stdout, entries); err != nil {
+			log.Fatalf("Failed to print shoe entries: %v", err)
+		}
+	}
+}
+
+func setupFoodList(fs *flag.FlagSet) runFunc {
+	list := listFlags(fs, database.FoodentryDetails{}, "foodentry_id", "foodentry_name", "restaurant_name", "picture_taken_at", "foodentry_created_at")
+	return func(ctx context.Context, a *app, args []string) {
+		entries, err := a.db.ListFoodentries(ctx)
+		if err != nil {
+			log.Fatalf("Failed to list food entries: %v", err)
+		}
+		if err := list.print(os.Stdout, entries); err != nil {
+			log.Fatalf("Failed to print food entries: %v", err)
+		}
+	}
+}
+
+func setupRestaurantList(fs *flag.FlagSet) runFunc {
+	list := listFlags(fs, database.Restaurant{}, "id", "name", "timestamp")
+	return func(ctx context.Context, a *app, args []string) {
+		restaurants, err := a.db.ListRestaurants(ctx)
+		if err != nil {
+			log.Fatalf("Failed to list restaurants: %v", err)
+		}
+		if err := list.print(os.Stdout, restaurants); err != nil {
+			log.Fatalf("Failed to print restaurants: %v", err)
+		}
+	}
+}
+
+func setupPictureList(fs *flag.FlagSet) runFunc {
+	list := listFlags(fs, database.Picture{}, "id", "local_location", "discord_image_link", "taken_at", "created_at")
+	return func(ctx context.Context, a *app, args []string) {
+		pictures, err := a.db.ListPictures(ctx)
+		if err != nil {
+			log.Fatalf("Failed to list pictures: %v", err)
+		}
+		if err := list.print(os.Stdout, pictures); err != nil {
+			log.Fatalf("Failed to print pictures: %v", err)
+		}
+	}
+}
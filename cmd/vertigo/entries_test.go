@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"vertigo/pkg/database"
+	discordBot "vertigo/pkg/discordBot"
+	"vertigo/pkg/stockx"
+)
+
+type fakeOnboarder struct {
+	err error
+	// failAfterInsert fails once the picture row exists, like a failed copy.
+	failAfterInsert error
+	// compensated counts the uploads deleted again.
+	compensated *int
+}
+
+func (f fakeOnboarder) UploadImage(ctx context.Context, filePath string) (*discordBot.UploadedImage, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &discordBot.UploadedImage{FilePath: filePath, DiscordURL: "https://cdn.discordapp.com/image.jpg", MessageID: "1", TakenAt: time.Now()}, nil
+}
+
+func (f fakeOnboarder) DeleteUploadedImage(ctx context.Context, image discordBot.UploadedImage) error {
+	if f.compensated != nil {
+		*f.compensated++
+	}
+	return nil
+}
+
+func (f fakeOnboarder) StoreImage(ctx context.Context, uow database.UnitOfWork, image discordBot.UploadedImage, imageType string) (int64, error) {
+	id, err := uow.InsertPicture(ctx, image.FilePath, image.DiscordURL, image.MessageID, 0, 0, image.TakenAt)
+	if err == nil && f.failAfterInsert != nil {
+		return 0, f.failAfterInsert
+	}
+	return id, err
+}
+
+func TestAddShoentry(t *testing.T) {
+	store := database.NewMemoryStore()
+	if err := store.InsertShoe(context.Background(), stockx.ProductDetails{Name: "Air Jordan 1", ProductName: "Air-Jordan-1"}); err != nil {
+		t.Fatalf("InsertShoe failed: %v", err)
+	}
+
+	details, err := addShoentry(context.Background(), store, fakeOnboarder{}, "shoe.jpg", "Air-Jordan-1")
+	if err != nil {
+		t.Fatalf("addShoentry failed: %v", err)
+	}
+	if details.ShoeName != "Air Jordan 1" || details.PictureLocalPath != "shoe.jpg" {
+		t.Fatalf("Unexpected shoentry %+v", details)
+	}
+
+	if _, err := addShoentry(context.Background(), store, fakeOnboarder{}, "shoe.jpg", "Unknown"); err == nil {
+		t.Fatalf("Expected an error for an unknown shoe")
+	}
+	if _, err := addShoentry(context.Background(), store, fakeOnboarder{err: errors.New("discord down")}, "shoe.jpg", "Air-Jordan-1"); err == nil {
+		t.Fatalf("Expected an error when the image cannot be onboarded")
+	}
+}
+
+func TestAddFoodentryReusesRestaurant(t *testing.T) {
+	store := database.NewMemoryStore()
+
+	first, err := addFoodentry(context.Background(), store, fakeOnboarder{}, nil, "food.jpg", "Margherita", "Pizza Place")
+	if err != nil {
+		t.Fatalf("addFoodentry failed: %v", err)
+	}
+	second, err := addFoodentry(context.Background(), store, fakeOnboarder{}, nil, "food.jpg", "Marinara", "Pizza Place")
+	if err != nil {
+		t.Fatalf("addFoodentry failed: %v", err)
+	}
+	if first.RestaurantID != second.RestaurantID {
+		t.Fatalf("Expected both entries to use the same restaurant, got %d and %d", first.RestaurantID, second.RestaurantID)
+	}
+}
+
+func TestAddShoentryRollsBackOnFailure(t *testing.T) {
+	store := database.NewMemoryStore()
+	if err := store.InsertShoe(context.Background(), stockx.ProductDetails{Name: "Air Jordan 1", ProductName: "Air-Jordan-1"}); err != nil {
+		t.Fatalf("InsertShoe failed: %v", err)
+	}
+
+	compensated := 0
+	failing := fakeOnboarder{failAfterInsert: errors.New("copy failed"), compensated: &compensated}
+	if _, err := addShoentry(context.Background(), store, failing, "shoe.jpg", "Air-Jordan-1"); err == nil {
+		t.Fatalf("Expected an error when the image cannot be copied")
+	}
+	if compensated != 1 {
+		t.Fatalf("Expected the compensation to run once, got %d", compensated)
+	}
+
+	details, err := addShoentry(context.Background(), store, fakeOnboarder{compensated: &compensated}, "shoe.jpg", "Air-Jordan-1")
+	if err != nil {
+		t.Fatalf("addShoentry failed: %v", err)
+	}
+	if details.PictureID != 1 || details.ShoentryID != 1 {
+		t.Fatalf("Expected the failed picture to be rolled back, got picture %d and shoentry %d", details.PictureID, details.ShoentryID)
+	}
+	if compensated != 1 {
+		t.Fatalf("Expected no compensation after a successful entry, got %d", compensated)
+	}
+}
+
+// busyStore can't start a unit of work, like a database locked by another
+// writer.
+type busyStore struct {
+	*database.MemoryStore
+}
+
+func (busyStore) InUnitOfWork(ctx context.Context, fn func(uow database.UnitOfWork) error) error {
+	return errors.New("database is locked")
+}
+
+func TestAddFoodentryDeletesUploadWhenUnitOfWorkFails(t *testing.T) {
+	compensated := 0
+	_, err := addFoodentry(context.Background(), busyStore{database.NewMemoryStore()}, fakeOnboarder{compensated: &compensated}, nil, "food.jpg", "Margherita", "Pizza Place")
+	if err == nil {
+		t.Fatalf("Expected an error when the unit of work can't start")
+	}
+	if compensated != 1 {
+		t.Fatalf("Expected the upload to be deleted once, got %d", compensated)
+	}
+}
+
+func TestEditRow(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	if err := store.InsertShoe(ctx, stockx.ProductDetails{Name: "Air Jordan 1", ProductName: "Air-Jordan-1"}); err != nil {
+		t.Fatalf("InsertShoe failed: %v", err)
+	}
+
+	row, err := editRow(ctx, store, "items", 1, []string{"title=Air Jordan 1 Chicago", `attributes={"colorway":"Chicago"}`})
+	if err != nil {
+		t.Fatalf("editRow failed: %v", err)
+	}
+	item := row.(*database.Item)
+	if item.Title != "Air Jordan 1 Chicago" || item.Name != "Air-Jordan-1" || item.Attributes != `{"colorway":"Chicago"}` {
+		t.Fatalf("Unexpected item %+v", item)
+	}
+	if shoe, _ := store.GetShoeByID(ctx, 1); shoe == nil || shoe.Name != "Air Jordan 1 Chicago" {
+		t.Fatalf("Expected the shoe to show the new title, got %+v", shoe)
+	}
+
+	for _, fields := range [][]string{{"description=Worn once"}, {"id=2"}, {"title"}, {"attributes=not json"}} {
+		if _, err := editRow(ctx, store, "items", 1, fields); err == nil {
+			t.Fatalf("Expected %v to be rejected", fields)
+		}
+	}
+	if _, err := editRow(ctx, store, "items", 2, []string{"name=x"}); !errors.Is(err, database.ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound, got %v", err)
+	}
+}
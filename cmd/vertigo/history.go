@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"vertigo/pkg/database"
+)
+
+func setupHistory(fs *flag.FlagSet) runFunc {
+	list := listFlags(fs, database.Change{}, "changed_at", "changed_by", "field", "old_value", "new_value")
+	return func(ctx context.Context, a *app, args []string) {
+		table, id := args[0], argID(args[1])
+
+		changes, err := a.db.History(ctx, table, id)
+		if err != nil {
+			log.Fatalf("Failed to read history: %v", err)
+		}
+		if len(changes) == 0 && list.format == "table" {
+			fmt.Printf("No changes recorded for %s %d.\n", table, id)
+			return
+		}
+		if err := list.print(os.Stdout, changes); err != nil {
+			log.Fatalf("Failed to print history: %v", err)
+		}
+	}
+}
+
+func runRestore(ctx context.Context, a *app, args []string) {
+	table, id := args[0], argID(args[1])
+
+	if err := a.db.RestoreRow(ctx, table, id); err != nil {
+		log.Fatalf("Failed to restore %s %d: %v", table, id, err)
+	}
+	fmt.Printf("Restored %s %d.\n", table, id)
+}
+
+// runPurge removes a deleted row for good, including the image files.
+func runPurge(ctx context.Context, a *app, args []string) {
+	table, id := args[0], argID(args[1])
+
+	purged, err := database.PurgeRowAndFiles(ctx, a.db, a.cfg.ShoeImageDir(), table, id)
+	if err != nil {
+		log.Fatalf("Failed to purge %s %d: %v", table, id, err)
+	}
+	fmt.Printf("Purged %s %d and %d pictures.\n", table, id, len(purged.Pictures))
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"vertigo/pkg/catalog"
+	"vertigo/pkg/database"
+	"vertigo/pkg/stockx"
+)
+
+// stockxProvider adds shoes from their StockX page, like shoe add.
+type stockxProvider struct {
+	scrape  shoeScraper
+	visuals visualFetcher
+}
+
+func (p stockxProvider) Add(ctx context.Context, store database.Store, category catalog.Category, args []string) (*database.Item, error) {
+	if category.Name != database.ShoeCategory {
+		return nil, fmt.Errorf("provider %s only adds %s", category.Provider, database.ShoeCategory)
+	}
+	if len(args) != 1 {
+		return nil, fmt.Errorf("expected the StockX URL of the shoe")
+	}
+	// onboardShoe returns nil for a shoe that exists already.
+	var productName string
+	scrape := func(url string) (stockx.ProductDetails, error) {
+		product, err := p.scrape(url)
+		productName = product.ProductName
+		return product, err
+	}
+	if _, err := onboardShoe(ctx, store, scrape, p.visuals, nil, args[0]); err != nil {
+		return nil, err
+	}
+	return store.GetItemByName(ctx, category.Name, productName)
+}
+
+// osmProvider adds restaurants by name, or finds the one a picture was taken
+// at on OSM, like food add.
+type osmProvider struct {
+	client *http.Client
+}
+
+func (p osmProvider) Add(ctx context.Context, store database.Store, category catalog.Category, args []string) (*database.Item, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("expected the name of the restaurant")
+	}
+	resolve, err := p.Locate(ctx, category, args[0], "")
+	if err != nil {
+		return nil, err
+	}
+	return resolve(ctx, store)
+}
+
+// Locate asks OSM where the picture was taken unless the restaurant is named.
+func (p osmProvider) Locate(ctx context.Context, category catalog.Category, item, imagePath string) (catalog.Resolver, error) {
+	if category.Name != database.RestaurantCategory {
+		return nil, fmt.Errorf("provider %s only adds %s", category.Provider, database.RestaurantCategory)
+	}
+	rtDetails, err := findRestaurant(p.client, item, imagePath)
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context, store database.Store) (*database.Item, error) {
+		id, err := restaurantID(ctx, store, rtDetails)
+		if err != nil {
+			return nil, err
+		}
+		return store.GetItemByID(ctx, category.Name, id)
+	}, nil
+}
+
+// catalogue returns the categories with the providers vertigo has built in.
+func (a *app) catalogue() *catalog.Catalogue {
+	categories, err := catalog.CategoriesWithDir(a.cfg.CategoriesDir)
+	if err != nil {
+		log.Fatalf("Failed to load categories: %v", err)
+	}
+	c := catalog.New(categories)
+	c.Register("stockx", stockxProvider{
+		scrape: func(url string) (stockx.ProductDetails, error) { return a.stockx().GetShoeInformation(url) },
+		visuals: func(ctx context.Context, productName, imgURL string) (*stockx.VisualItem, error) {
+			return a.stockx().GetVisualItem(ctx, a.cfg, a.blobs(), productName, imgURL)
+		},
+	})
+	c.Register("osm", osmProvider{client: a.client})
+	c.Register("manual", catalog.Manual{})
+	return c
+}
+
+// addItemEntry adds a picture of an item of category as a single unit of
+// work. The item is named by itemName, or found by the provider of the
+// category when it is a catalog.Locator. Finding the item and uploading the
+// picture happen before the unit of work.
+func addItemEntry(ctx context.Context, store entryStore, images imageOnboarder, catalogue *catalog.Catalogue, categoryName, imagePath, itemName, entryName string) (*database.EntryDetails, error) {
+	category, _, err := catalogue.Category(categoryName)
+	if err != nil {
+		return nil, err
+	}
+	resolve, err := catalogue.Locate(ctx, category.Name, itemName, imagePath)
+	if err != nil {
+		return nil, fmt.Errorf("could not find the item: %v", err)
+	}
+	image, err := images.UploadImage(ctx, imagePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to onboard new image: %v", err)
+	}
+
+	var entry *database.EntryDetails
+	err = recordUpload(ctx, store, images, *image, func(uow database.UnitOfWork) error {
+		item, err := resolve(ctx, uow)
+		if err != nil {
+			return fmt.Errorf("could not find the item: %v", err)
+		}
+
+		pictureID, err := images.StoreImage(ctx, uow, *image, category.ImageType)
+		if err != nil {
+			return fmt.Errorf("failed to onboard new image: %v", err)
+		}
+
+		entryID, err := uow.InsertEntry(ctx, category.Name, entryName, item.ID, pictureID)
+		if err != nil {
+			return fmt.Errorf("failed to insert entry: %v", err)
+		}
+
+		entry, err = uow.GetEntryByID(ctx, category.Name, entryID)
+		if err != nil {
+			return fmt.Errorf("failed to retrieve entry: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func setupItemCategories(fs *flag.FlagSet) runFunc {
+	list := listFlags(fs, catalog.Category{}, "name", "title", "provider", "image_type")
+	return func(ctx context.Context, a *app, args []string) {
+		if err := list.print(os.Stdout, a.catalogue().Categories()); err != nil {
+			log.Fatalf("Failed to print categories: %v", err)
+		}
+	}
+}
+
+func runItemAdd(ctx context.Context, a *app, args []string) {
+	item, err := a.catalogue().Add(ctx, a.db, args[0], args[1:])
+	if err != nil {
+		log.Fatalf("Failed to add %s item: %v", args[0], err)
+	}
+	if item == nil {
+		log.Fatalf("Failed to add %s item: it was not found after adding it", args[0])
+	}
+	fmt.Printf("Added %s item %d: %s\n", item.Category, item.ID, item.Name)
+}
+
+func setupItemList(fs *flag.FlagSet) runFunc {
+	list := listFlags(fs, database.Item{}, "id", "name", "title", "created_at")
+	return func(ctx context.Context, a *app, args []string) {
+		if _, _, err := a.catalogue().Category(args[0]); err != nil {
+			log.Fatalf("Failed to list items: %v", err)
+		}
+		items, err := a.db.ListItems(ctx, args[0])
+		if err != nil {
+			log.Fatalf("Failed to list items: %v", err)
+		}
+		if err := list.print(os.Stdout, items); err != nil {
+			log.Fatalf("Failed to print items: %v", err)
+		}
+	}
+}
+
+func setupItemEntry(fs *flag.FlagSet) runFunc {
+	name := fs.String("name", "", "Name of the entry, e.g. the dish")
+	return func(ctx context.Context, a *app, args []string) {
+		itemName := ""
+		if len(args) > 2 {
+			itemName = args[2]
+		}
+		entry, err := addItemEntry(ctx, a.db, a.discord(), a.catalogue(), args[0], args[1], itemName, *name)
+		if err != nil {
+			log.Fatalf("Failed to add %s entry: %v", args[0], err)
+		}
+		fmt.Printf("Added %s entry %d of %s\n", entry.Category, entry.EntryID, entry.ItemTitle)
+	}
+}
+
+func setupItemEntries(fs *flag.FlagSet) runFunc {
+	list := listFlags(fs, database.EntryDetails{}, "entry_id", "entry_name", "item_title", "picture_taken_at", "entry_created_at")
+	return func(ctx context.Context, a *app, args []string) {
+		if _, _, err := a.catalogue().Category(args[0]); err != nil {
+			log.Fatalf("Failed to list entries: %v", err)
+		}
+		entries, err := a.db.ListEntries(ctx, args[0])
+		if err != nil {
+			log.Fatalf("Failed to list entries: %v", err)
+		}
+		if err := list.print(os.Stdout, entries); err != nil {
+			log.Fatalf("Failed to print entries: %v", err)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"vertigo/pkg/catalog"
+	"vertigo/pkg/database"
+	"vertigo/pkg/stockx"
+)
+
+func testCatalogue() *catalog.Catalogue {
+	c := catalog.New(append(catalog.DefaultCategories(), catalog.Category{Name: "watches", Provider: "manual", ImageType: "watch"}))
+	c.Register("manual", catalog.Manual{})
+	c.Register("osm", osmProvider{})
+	return c
+}
+
+func TestAddItemEntry(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	catalogue := testCatalogue()
+	if _, err := catalogue.Add(ctx, store, "watches", []string{"name=Speedmaster", "brand=Omega"}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	entry, err := addItemEntry(ctx, store, fakeOnboarder{}, catalogue, "watches", "watch.jpg", "Speedmaster", "Wrist shot")
+	if err != nil {
+		t.Fatalf("addItemEntry failed: %v", err)
+	}
+	if entry.Category != "watches" || entry.ItemName != "Speedmaster" || entry.EntryName != "Wrist shot" || entry.PictureLocalPath != "watch.jpg" {
+		t.Fatalf("Unexpected entry %+v", entry)
+	}
+
+	// Restaurants are kept where food add keeps them.
+	food, err := addItemEntry(ctx, store, fakeOnboarder{}, catalogue, "restaurants", "food.jpg", "Da Mario", "Margherita")
+	if err != nil {
+		t.Fatalf("addItemEntry failed: %v", err)
+	}
+	foodentries, _ := store.ListFoodentries(ctx)
+	if len(foodentries) != 1 || foodentries[0].FoodentryID != food.EntryID || foodentries[0].RestaurantName != "Da Mario" {
+		t.Fatalf("Expected a foodentry at Da Mario, got %+v", foodentries)
+	}
+
+	for _, tt := range []struct {
+		category, item string
+		images         imageOnboarder
+		err            string
+	}{
+		{"watches", "", fakeOnboarder{}, "name the item"},
+		{"watches", "Seamaster", fakeOnboarder{}, `no watches item "Seamaster"`},
+		{"watches", "Speedmaster", fakeOnboarder{err: errors.New("discord down")}, "discord down"},
+		{"cars", "Beetle", fakeOnboarder{}, `unknown category "cars"`},
+	} {
+		if _, err := addItemEntry(ctx, store, tt.images, catalogue, tt.category, "x.jpg", tt.item, ""); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s %s: expected %q, got %v", tt.category, tt.item, tt.err, err)
+		}
+	}
+	if entries, _ := store.ListEntries(ctx, "watches"); len(entries) != 1 {
+		t.Fatalf("Expected the failed entries to be rolled back, got %+v", entries)
+	}
+}
+
+// countingLocator counts the lookups it makes.
+type countingLocator struct {
+	catalog.Manual
+	located *int
+}
+
+func (l countingLocator) Locate(ctx context.Context, category catalog.Category, item, imagePath string) (catalog.Resolver, error) {
+	*l.located++
+	return func(ctx context.Context, store database.Store) (*database.Item, error) {
+		return store.GetItemByName(ctx, category.Name, item)
+	}, nil
+}
+
+func TestAddItemEntryLocatesBeforeUnitOfWork(t *testing.T) {
+	located, compensated := 0, 0
+	catalogue := catalog.New([]catalog.Category{{Name: "watches", Provider: "counting", ImageType: "watch"}})
+	catalogue.Register("counting", countingLocator{located: &located})
+
+	// The unit of work never starts, the lookup and the upload are made anyway.
+	_, err := addItemEntry(context.Background(), busyStore{database.NewMemoryStore()}, fakeOnboarder{compensated: &compensated}, catalogue, "watches", "watch.jpg", "Speedmaster", "")
+	if err == nil || !strings.Contains(err.Error(), "database is locked") {
+		t.Fatalf("Expected the unit of work to fail, got %v", err)
+	}
+	if located != 1 || compensated != 1 {
+		t.Fatalf("Expected one lookup and the upload to be deleted, got %d lookups and %d deletes", located, compensated)
+	}
+}
+
+func TestStockxProvider(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	catalogue := testCatalogue()
+	catalogue.Register("stockx", stockxProvider{
+		scrape: func(url string) (stockx.ProductDetails, error) {
+			return stockx.ProductDetails{Name: "Air Jordan 1", ProductName: "Air-Jordan-1", LastSale: "$180"}, nil
+		},
+		visuals: func(ctx context.Context, productName, imgURL string) (*stockx.VisualItem, error) {
+			return &stockx.VisualItem{MainImageURL: "https://cdn.example.com/" + productName + "/main.png"}, nil
+		},
+	})
+
+	// Adding the shoe again records its price again and returns it as well.
+	for i := 0; i < 2; i++ {
+		item, err := catalogue.Add(ctx, store, "shoes", []string{"https://stockx.com/air-jordan-1"})
+		if err != nil || item == nil || item.Name != "Air-Jordan-1" || item.Title != "Air Jordan 1" {
+			t.Fatalf("Expected the shoe, got %+v, %v", item, err)
+		}
+	}
+	if prices, _ := store.GetShoePrices(ctx, 1); len(prices) != 2 {
+		t.Fatalf("Expected the price to be recorded twice, got %+v", prices)
+	}
+	if _, err := catalogue.Add(ctx, store, "shoes", []string{"a", "b"}); err == nil {
+		t.Fatalf("Expected more than one URL to be rejected")
+	}
+}
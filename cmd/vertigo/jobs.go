@@ -0,0 +1,342 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"vertigo/pkg/blobstore"
+	"vertigo/pkg/config"
+	"vertigo/pkg/database"
+	discordBot "vertigo/pkg/discordBot"
+	"vertigo/pkg/stockx"
+)
+
+const (
+	// jobAttempts is how often a URL is tried before its job fails.
+	jobAttempts = 4
+	// jobBackoff is the wait before the first retry, it doubles after that.
+	jobBackoff = 30 * time.Second
+	// jobPoll is how often idle workers look for jobs that became due.
+	jobPoll = time.Second
+)
+
+// onboardFunc adds the shoe at url.
+type onboardFunc func(ctx context.Context, url string) error
+
+// newShoeOnboarder onboards shoes the way shoe add does and notifies bot, when
+// set, of every new shoe and of the alerts the price of a known one triggers.
+func newShoeOnboarder(cfg *config.Config, shoes shoePriceStore, scraper *stockx.Scraper, blobs blobstore.BlobStore, bot *discordBot.Bot) onboardFunc {
+	visuals := func(ctx context.Context, productName, imgURL string) (*stockx.VisualItem, error) {
+		return scraper.GetVisualItem(ctx, cfg, blobs, productName, imgURL)
+	}
+	return func(ctx context.Context, url string) error {
+		product, err := onboardShoe(ctx, shoes, scraper.GetShoeInformation, visuals, alertPosterOf(bot), url)
+		if err != nil || product == nil {
+			return err
+		}
+		fmt.Println("Shoe added successfully:", product.ProductName)
+		if bot != nil {
+			if err := bot.PostNewShoe(ctx, *product); err != nil {
+				// The shoe is in, retrying would only record its price.
+				log.Printf("Discord couldn't be notified of %s: %v", product.ProductName, err)
+			}
+		}
+		return nil
+	}
+}
+
+// normalizeShoeURL strips what doesn't change the page a URL points at, so
+// the same shoe isn't queued twice.
+func normalizeShoeURL(url string) string {
+	url = strings.TrimSpace(url)
+	if i := strings.IndexAny(url, "?#"); i >= 0 {
+		url = url[:i]
+	}
+	return strings.ToLower(strings.TrimRight(url, "/"))
+}
+
+// readURLs reads one URL per line, skipping blank lines and # comments.
+func readURLs(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var urls []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			urls = append(urls, line)
+		}
+	}
+	return urls, scanner.Err()
+}
+
+// enqueueURLs queues the URLs of shoes that aren't in the collection yet.
+// URLs of known shoes and URLs queued already are skipped.
+func enqueueURLs(ctx context.Context, jobs database.JobStore, shoes database.ShoeStore, urls []string) (queued, skipped int, err error) {
+	products, err := shoes.QueryShoes(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	known := make(map[string]bool, len(products))
+	for _, product := range products {
+		known[normalizeShoeURL(stockx.ProductURL(product.ProductName))] = true
+	}
+
+	for _, url := range urls {
+		url = normalizeShoeURL(url)
+		if known[url] {
+			skipped++
+			continue
+		}
+		_, isNew, err := jobs.EnqueueJob(ctx, url)
+		if err != nil {
+			return queued, skipped, err
+		}
+		if isNew {
+			queued++
+		} else {
+			skipped++
+		}
+	}
+	return queued, skipped, nil
+}
+
+type jobSummary struct {
+	Done      int
+	Cancelled int
+	Failed    []database.Job
+	// Queued counts the jobs left when the run was interrupted.
+	Queued int
+}
+
+// jobRunner works off the queue with a fixed number of workers. A failed
+// job is queued again with a growing delay until it ran out of attempts.
+type jobRunner struct {
+	jobs     database.JobStore
+	onboard  onboardFunc
+	workers  int
+	attempts int
+	backoff  time.Duration
+	poll     time.Duration
+
+	mu      sync.Mutex
+	summary jobSummary
+}
+
+func newJobRunner(jobs database.JobStore, onboard onboardFunc) *jobRunner {
+	return &jobRunner{
+		jobs:     jobs,
+		onboard:  onboard,
+		workers:  maxWorkers,
+		attempts: jobAttempts,
+		backoff:  jobBackoff,
+		poll:     jobPoll,
+	}
+}
+
+// run returns once no job is queued or running any more, or ctx is done.
+// Jobs a crashed run left running are queued again first.
+func (r *jobRunner) run(ctx context.Context) (jobSummary, error) {
+	if n, err := r.jobs.RequeueRunningJobs(ctx); err != nil {
+		return jobSummary{}, err
+	} else if n > 0 {
+		log.Printf("Resuming %d job(s) an earlier run left unfinished", n)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < r.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.work(ctx)
+		}()
+	}
+	wg.Wait()
+
+	queued, err := r.jobs.QueryJobs(context.WithoutCancel(ctx), database.JobQueued)
+	if err != nil {
+		return r.summary, err
+	}
+	r.summary.Queued = len(queued)
+	return r.summary, nil
+}
+
+func (r *jobRunner) work(ctx context.Context) {
+	for ctx.Err() == nil {
+		job, err := r.jobs.ClaimJob(ctx, time.Now())
+		if err != nil {
+			log.Printf("Failed to claim a job: %v", err)
+			if !sleep(ctx, r.poll) {
+				return
+			}
+			continue
+		}
+		if job == nil {
+			wait, pending := r.nextDue(ctx)
+			if !pending || !sleep(ctx, wait) {
+				return
+			}
+			continue
+		}
+		r.process(ctx, *job)
+	}
+}
+
+// nextDue returns how long until a queued job is due. Without queued jobs
+// the workers keep polling while jobs are running, they may be retried.
+func (r *jobRunner) nextDue(ctx context.Context) (time.Duration, bool) {
+	queued, err := r.jobs.QueryJobs(ctx, database.JobQueued)
+	if err != nil {
+		return r.poll, true
+	}
+	if len(queued) == 0 {
+		running, err := r.jobs.QueryJobs(ctx, database.JobRunning)
+		return r.poll, err != nil || len(running) > 0
+	}
+	wait := r.poll
+	for _, job := range queued {
+		if d := time.Until(job.NextAttemptAt); d < wait {
+			wait = d
+		}
+	}
+	return max(wait, 0), true
+}
+
+func (r *jobRunner) process(ctx context.Context, job database.Job) {
+	err := r.onboard(ctx, job.URL)
+	// An interrupted job is queued again for the next run.
+	store := context.WithoutCancel(ctx)
+
+	var outcome error
+	switch {
+	case err == nil:
+		outcome = r.jobs.FinishJob(store, job.ID)
+		if outcome == nil {
+			r.record(func(s *jobSummary) { s.Done++ })
+		}
+	case ctx.Err() != nil:
+		outcome = r.jobs.FailJob(store, job.ID, err.Error(), time.Now())
+	case job.Attempts < r.attempts:
+		delay := r.backoff << (job.Attempts - 1)
+		log.Printf("Job %d failed, retrying %s in %s: %v", job.ID, job.URL, delay, err)
+		outcome = r.jobs.FailJob(store, job.ID, err.Error(), time.Now().Add(delay))
+	default:
+		log.Printf("Job %d failed after %d attempts: %v", job.ID, job.Attempts, err)
+		outcome = r.jobs.FailJob(store, job.ID, err.Error(), time.Time{})
+		if outcome == nil {
+			job.State, job.LastError = database.JobFailed, err.Error()
+			r.record(func(s *jobSummary) { s.Failed = append(s.Failed, job) })
+		}
+	}
+
+	switch {
+	case errors.Is(outcome, database.ErrNotFound):
+		r.record(func(s *jobSummary) { s.Cancelled++ })
+	case outcome != nil:
+		log.Printf("Failed to record the outcome of job %d: %v", job.ID, outcome)
+	}
+}
+
+func (r *jobRunner) record(fn func(*jobSummary)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fn(&r.summary)
+}
+
+// sleep waits for d and reports whether ctx is still going.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+func printJobSummary(s jobSummary) {
+	fmt.Printf("Done: %d, failed: %d, cancelled: %d, still queued: %d\n", s.Done, len(s.Failed), s.Cancelled, s.Queued)
+	for _, job := range s.Failed {
+		fmt.Printf("  job %d %s: %s\n", job.ID, job.URL, job.LastError)
+	}
+	if len(s.Failed) > 0 {
+		fmt.Println("Run 'vertigo jobs retry <id>' to try a failed job again.")
+	}
+	if s.Queued > 0 {
+		fmt.Println("Run 'vertigo jobs run' to continue.")
+	}
+}
+
+// processJobs works off the queue and prints the summary. It exits with
+// status 1 when a job failed.
+func processJobs(ctx context.Context, a *app, notify bool) {
+	onboard := newShoeOnboarder(a.cfg, a.db, a.stockx(), a.blobs(), a.notifier(notify))
+	summary, err := newJobRunner(a.db, onboard).run(ctx)
+	if err != nil {
+		log.Fatalf("Failed to run jobs: %v", err)
+	}
+	printJobSummary(summary)
+	if len(summary.Failed) > 0 {
+		os.Exit(exitFailure)
+	}
+}
+
+func setupJobsList(fs *flag.FlagSet) runFunc {
+	list := listFlags(fs, database.Job{}, "id", "state", "attempts", "updated_at", "url", "last_error")
+	return func(ctx context.Context, a *app, args []string) {
+		state := ""
+		if len(args) > 0 {
+			state = args[0]
+		}
+		jobs, err := a.db.QueryJobs(ctx, state)
+		if err != nil {
+			log.Fatalf("Failed to list jobs: %v", err)
+		}
+		if err := list.print(os.Stdout, jobs); err != nil {
+			log.Fatalf("Failed to print jobs: %v", err)
+		}
+	}
+}
+
+func setupJobsRun(fs *flag.FlagSet) runFunc {
+	discord := discordFlag(fs)
+	return func(ctx context.Context, a *app, args []string) {
+		processJobs(ctx, a, *discord)
+	}
+}
+
+func runJobsRetry(ctx context.Context, a *app, args []string) {
+	id := argID(args[0])
+	_, err := a.db.RetryJob(ctx, id)
+	if errors.Is(err, database.ErrNotFound) {
+		log.Fatalf("No failed or cancelled job %d", id)
+	}
+	if err != nil {
+		log.Fatalf("Failed to retry job %d: %v", id, err)
+	}
+	fmt.Printf("Queued job %d again, run 'vertigo jobs run' to process it.\n", id)
+}
+
+func runJobsCancel(ctx context.Context, a *app, args []string) {
+	id := argID(args[0])
+	err := a.db.CancelJob(ctx, id)
+	if errors.Is(err, database.ErrNotFound) {
+		log.Fatalf("No queued or running job %d", id)
+	}
+	if err != nil {
+		log.Fatalf("Failed to cancel job %d: %v", id, err)
+	}
+	fmt.Printf("Cancelled job %d.\n", id)
+}
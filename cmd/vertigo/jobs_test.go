@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+	"vertigo/pkg/database"
+	"vertigo/pkg/stockx"
+)
+
+func TestEnqueueURLs(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	if err := store.InsertShoe(ctx, stockx.ProductDetails{Name: "Air Jordan 1", ProductName: "Air-Jordan-1"}); err != nil {
+		t.Fatalf("InsertShoe failed: %v", err)
+	}
+
+	urls := []string{
+		"https://stockx.com/air-jordan-1",
+		"https://stockx.com/yeezy-350",
+		"https://stockx.com/yeezy-350/?size=10",
+		"https://stockx.com/nike-mars-yard",
+	}
+	queued, skipped, err := enqueueURLs(ctx, store, store, urls)
+	if err != nil || queued != 2 || skipped != 2 {
+		t.Fatalf("Expected 2 queued and 2 skipped, got %d and %d, %v", queued, skipped, err)
+	}
+	jobs, _ := store.QueryJobs(ctx, database.JobQueued)
+	if len(jobs) != 2 || jobs[0].URL != "https://stockx.com/yeezy-350" {
+		t.Fatalf("Unexpected jobs %+v", jobs)
+	}
+}
+
+func TestJobRunner(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	for _, url := range []string{"ok-1", "flaky", "ok-2", "broken", "ok-3"} {
+		store.EnqueueJob(ctx, url)
+	}
+
+	var mu sync.Mutex
+	calls := make(map[string]int)
+	running, maxRunning := 0, 0
+	onboard := func(ctx context.Context, url string) error {
+		mu.Lock()
+		calls[url]++
+		attempt := calls[url]
+		running++
+		maxRunning = max(maxRunning, running)
+		mu.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		switch {
+		case url == "broken", url == "flaky" && attempt == 1:
+			return errors.New("status code error: 503")
+		}
+		return nil
+	}
+
+	runner := newJobRunner(store, onboard)
+	runner.workers = 2
+	runner.attempts = 3
+	runner.backoff = time.Millisecond
+	runner.poll = time.Millisecond
+	summary, err := runner.run(ctx)
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	if summary.Done != 4 || len(summary.Failed) != 1 || summary.Failed[0].URL != "broken" || summary.Queued != 0 {
+		t.Fatalf("Unexpected summary %+v", summary)
+	}
+	if calls["flaky"] != 2 || calls["broken"] != 3 || calls["ok-1"] != 1 {
+		t.Fatalf("Unexpected attempts %v", calls)
+	}
+	if maxRunning > 2 {
+		t.Fatalf("Expected at most 2 jobs at a time, got %d", maxRunning)
+	}
+	failed, _ := store.QueryJobs(ctx, database.JobFailed)
+	if len(failed) != 1 || failed[0].Attempts != 3 || failed[0].LastError != "status code error: 503" {
+		t.Fatalf("Expected the broken job to fail for good, got %+v", failed)
+	}
+}
@@ -1,265 +1,268 @@
 package main
 
 import (
-	"bufio"
+	"context"
 	"flag"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
-	"sync"
-	"time"
+	"os/signal"
+	"syscall"
+	"vertigo/pkg/blobstore"
+	"vertigo/pkg/config"
 	"vertigo/pkg/database"
+	"vertigo/pkg/dataitems"
 	discordBot "vertigo/pkg/discordBot"
-	rt "vertigo/pkg/restaurant"
+	"vertigo/pkg/httpclient"
 	"vertigo/pkg/stockx"
 )
 
-func onboardNewRestaurantIfNeeded(db *database.DB, foodname string, foodpath string) (int64, error) {
-	var rtDetails rt.RestaurantDetails
-	if foodname != "" {
-		rtDetails = rt.RestaurantDetails{
-			Name: foodname,
-		}
-	} else {
-		rtDetailsList, err := rt.FindRestaurants(foodpath)
-		if err != nil {
-			return 0, fmt.Errorf("Error requesting restaurant Details from OSM: %v", err)
-		}
-		if rtDetailsList == nil {
-			return 0, fmt.Errorf("No Name provided and no retsaurant found.")
-		}
-		rtDetails = rtDetailsList[0] // For now, just take the first element in the list of possible restaurants
-	}
-	restaurant, err := db.GetRestaurantByName(rtDetails.Name)
+const (
+	// maxWorkers is how many shoes are onboarded at the same time.
+	maxWorkers = 3
+)
+
+// visualFetcher publishes the pictures of a shoe, see
+// stockx.Scraper.GetVisualItem.
+type visualFetcher func(ctx context.Context, productName, imgURL string) (*stockx.VisualItem, error)
+
+// onboardShoe scrapes the shoe at url, publishes its pictures and inserts it
+// with their URLs. A shoe that exists already only gets its current price
+// recorded like a refresh, with its alerts posted by post, and nil is returned
+// for it.
+func onboardShoe(ctx context.Context, shoes shoePriceStore, scrape shoeScraper, visuals visualFetcher, post alertPoster, url string) (*stockx.ProductDetails, error) {
+	product, err := scrape(url)
 	if err != nil {
-		return 0, fmt.Errorf("Could not check if restaurant already exists: %v", err)
+		return nil, fmt.Errorf("can't get shoe information from stockx: %v", err)
 	}
 
-	var id int64
-	if restaurant == nil {
-		id, err = db.InsertRestaurant(rtDetails)
-	} else {
-		id = restaurant.ID
+	// Adding a shoe again records its current price.
+	existing, err := shoes.GetShoeByProductName(ctx, product.ProductName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up shoe: %v", err)
+	}
+	if existing != nil {
+		refreshed, err := recordPrice(ctx, shoes, post, *existing, product.LastSale)
+		if refreshed == nil {
+			return nil, fmt.Errorf("shoe %s already exists, %v", product.ProductName, err)
+		}
+		fmt.Printf("Shoe %s already exists, recorded its price of %.2f %s\n", product.ProductName, refreshed.Price.Amount, refreshed.Price.Currency)
+		printAlerts(refreshed.Alerts)
+		if err != nil {
+			// The price is in, retrying would only record it again.
+			log.Println(err)
+		}
+		return nil, nil
 	}
 
+	visual, err := visuals(ctx, product.ProductName, product.MainPicture)
 	if err != nil {
-		return 0, fmt.Errorf("Failed to insert restaurant: %v", err)
+		return nil, fmt.Errorf("failed to get visual items: %v", err)
 	}
+	product.MainPicture = visual.MainImageURL
+	product.SpinningGifURL = visual.SpinningGifURL
 
-	return id, nil
+	if err := shoes.InsertShoe(ctx, product); err != nil {
+		return nil, fmt.Errorf("failed to insert shoe: %v", err)
+	}
+	return &product, nil
 }
 
-const (
-	maxWorkers = 3
-)
-
-func processShoeURL(db *database.DB, url string, discordNotificationEnabled bool, wg *sync.WaitGroup, results chan<- error) {
-	defer wg.Done()
-
-	product, err := stockx.GetShoeInformation(url)
+// createShoe inserts a shoe entered by hand, with its picture downloaded from
+// the image URL, cropped and published by image.
+func createShoe(ctx context.Context, shoes database.ShoeStore, image visualFetcher, shoe dataitems.Shoe) (*stockx.ProductDetails, error) {
+	product := shoe.ProductDetails()
+	existing, err := shoes.GetShoeByProductName(ctx, product.ProductName)
 	if err != nil {
-		results <- fmt.Errorf("can't get shoe information from stockx: %v", err)
-		return
+		return nil, fmt.Errorf("failed to look up shoe: %v", err)
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("shoe %s already exists, change it with 'vertigo edit items %d'", product.ProductName, existing.ID)
 	}
 
-	err = stockx.GetVisualItem(product.ProductName, product.MainPicture)
+	visual, err := image(ctx, product.ProductName, shoe.ImageUrl)
 	if err != nil {
-		results <- fmt.Errorf("failed to get visual items: %v", err)
-		return
+		return nil, fmt.Errorf("failed to get the picture: %v", err)
 	}
+	product.MainPicture = visual.MainImageURL
 
-	err = db.InsertShoe(product)
-	if err != nil {
-		results <- fmt.Errorf("failed to insert shoe: %v", err)
-		return
+	if err := shoes.InsertShoe(ctx, product); err != nil {
+		return nil, fmt.Errorf("failed to insert shoe: %v", err)
 	}
+	return &product, nil
+}
 
-	fmt.Println("Shoe added successfully:", product)
+// app holds what the commands share. The database is opened before a
+// command runs, everything else when a command first needs it.
+type app struct {
+	cfg     *config.Config
+	db      *database.DB
+	client  *http.Client
+	scraper *stockx.Scraper
+	bot     *discordBot.Bot
+}
 
-	if discordNotificationEnabled {
-		err = discordBot.PostNewShoe(product)
+func (a *app) stockx() *stockx.Scraper {
+	if a.scraper == nil {
+		profiles, err := stockx.ProfilesWithDir(a.cfg.ProfilesDir)
 		if err != nil {
-			results <- fmt.Errorf("discord couldn't be notified. %v", err)
-			return
+			log.Fatalf("Failed to load StockX profiles: %v", err)
 		}
-		fmt.Println("Discord successfully notified.")
+		a.scraper = stockx.NewScraper(profiles)
+		a.scraper.Client = a.client
 	}
-
-	results <- nil
+	return a.scraper
 }
 
-func worker(db *database.DB, urls <-chan string, discordNotificationEnabled bool, wg *sync.WaitGroup, results chan<- error) {
-	for url := range urls {
-		wg.Add(1)
-		go processShoeURL(db, url, discordNotificationEnabled, wg, results)
+// discord returns the bot, which also hosts the pictures of entries.
+func (a *app) discord() *discordBot.Bot {
+	if a.bot == nil {
+		bot, err := discordBot.New(a.cfg)
+		if err != nil {
+			log.Fatalf("Failed to set up discord: %v", err)
+		}
+		a.bot = bot
 	}
+	return a.bot
 }
 
-
-func main() {
-	listItems := flag.String("list", "", "List all items of type, -list shoes")
-	addItems := flag.String("add", "", "Add an item of type, -add https://stockx.com/nike-air-force-1-low-07-chinese-new-year-2024")
-	discordNotificationEnabled := flag.Bool("discord", false, "Notify with discord if you are adding a shoe or shoe entry, -discord, default false")
-	shoeEntry := flag.String("shoentry", "", "Onboard a shoe image of type, -shoentry filepath -shoe name")
-	shoeName := flag.String("shoe", "", "The name of the shoe for the shoentry, -shoe name")
-	foodpath := flag.String("foodimage", "", "Upload food image. Will onboard new restaurant if required."+
-		"If restaurant name is not found via OSM, you have to provide it yourself with -restaurant name"+
-		"-foodImage path -foodName name")
-	restaurantName := flag.String("restaurant", "", "Set Restaurant Name -restaurant name")
-	foodName := flag.String("foodname", "", "Set Food Name -foodname name")
-	fileInput := flag.String("file", "", "File containing list of URLs to process")
-
-	flag.Parse()
-
-	db, err := database.GetDB("data/database/test.db")
-	if err != nil {
-		log.Fatalf("Failed to open database: %v", err)
+// notifier returns the bot when notifications are enabled and nil otherwise.
+func (a *app) notifier(enabled bool) *discordBot.Bot {
+	if !enabled {
+		return nil
 	}
+	return a.discord()
+}
 
-	err = db.Initialize()
+func (a *app) blobs() blobstore.BlobStore {
+	blobs, err := blobstore.New(a.cfg.Storage)
 	if err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
+		log.Fatalf("Failed to set up storage: %v", err)
 	}
+	return blobs
+}
 
-	if *fileInput != "" {
-		file, err := os.Open(*fileInput)
-		if err != nil {
-			log.Fatalf("Failed to open file: %v", err)
-		}
-		defer file.Close()
-
-		urls := make(chan string)
-		results := make(chan error)
-		var wg sync.WaitGroup
-
-		// Start worker pool
-		for i := 0; i < maxWorkers; i++ {
-			go worker(db, urls, *discordNotificationEnabled, &wg, results)
+func setupShoeAdd(fs *flag.FlagSet) runFunc {
+	discord := discordFlag(fs)
+	return func(ctx context.Context, a *app, args []string) {
+		onboard := newShoeOnboarder(a.cfg, a.db, a.stockx(), a.blobs(), a.notifier(*discord))
+		if err := onboard(ctx, args[0]); err != nil {
+			log.Fatalf("Failed to process URL %s: %v", args[0], err)
 		}
-
-		// Read URLs from file and send to workers
-		go func() {
-			scanner := bufio.NewScanner(file)
-			for scanner.Scan() {
-				urls <- scanner.Text()
-			}
-			close(urls)
-		}()
-
-		// Collect results
-		go func() {
-			for err := range results {
-				if err != nil {
-					log.Println(err)
-				}
-			}
-		}()
-
-		wg.Wait()
-		time.Sleep(10 * time.Minute)
-		close(results)
-		
-		return
 	}
+}
 
-	if *foodpath != "" && *foodName != "" {
-		restaurantid, err := onboardNewRestaurantIfNeeded(db, *restaurantName, *foodpath)
+func setupShoeCreate(fs *flag.FlagSet) runFunc {
+	discord := discordFlag(fs)
+	return func(ctx context.Context, a *app, args []string) {
+		shoe, err := dataitems.ParseShoe(args)
 		if err != nil {
-			log.Fatalf("Could not add the restaurant: %v", err)
+			log.Fatalf("Invalid shoe: %v", err)
 		}
-
-		pictureID, discordImageUrl, err := discordBot.OnboardNewImage(*foodpath, "food")
-		if err != nil {
-			log.Printf("Failed to onboard new image: %v", err)
+		image := func(ctx context.Context, productName, imgURL string) (*stockx.VisualItem, error) {
+			return a.stockx().GetMainImage(ctx, a.cfg, a.blobs(), productName, imgURL)
 		}
-
-		fmt.Println(discordImageUrl)
-		fmt.Println(discordImageUrl + "\n\nn\n\n")
-
-		foodentryID, err := db.InsertFoodentry(*foodName, restaurantid, pictureID)
+		product, err := createShoe(ctx, a.db, image, shoe)
 		if err != nil {
-			log.Fatalf("Failed to insert foodentry: %v", err)
+			log.Fatalf("Failed to create shoe: %v", err)
 		}
-
-		foodentryDetails, err := db.GetFoodEntryByID(foodentryID)
-		if err != nil {
-			log.Fatalf("Failed to retrieve foodentry: %v", err)
-		}
-
-		fmt.Println("Food entry added successfully")
-
-		if *discordNotificationEnabled {
-			err = discordBot.PostNewFoodEntry(*foodentryDetails)
-			if err != nil {
-				log.Printf("Discord couldn't be notified. %v", err)
-			} else {
-				fmt.Println("Discord successfully notified.")
+		fmt.Println("Shoe added successfully:", product.ProductName)
+		if bot := a.notifier(*discord); bot != nil {
+			if err := bot.PostNewShoe(ctx, *product); err != nil {
+				log.Printf("Discord couldn't be notified of %s: %v", product.ProductName, err)
 			}
 		}
 	}
+}
 
-	if *listItems == "shoes" {
-		shoes, err := db.QueryShoes()
+func setupShoeList(fs *flag.FlagSet) runFunc {
+	list := listFlags(fs, database.Shoe{}, "id", "name", "subtitle", "last_sale", "product_name")
+	return func(ctx context.Context, a *app, args []string) {
+		shoes, err := a.db.ListShoes(ctx)
 		if err != nil {
-			log.Fatalf("Failed to query shoes: %v", err)
+			log.Fatalf("Failed to list shoes: %v", err)
 		}
-
-		for _, shoe := range shoes {
-			fmt.Printf("%+v\n", shoe)
+		if err := list.print(os.Stdout, shoes); err != nil {
+			log.Fatalf("Failed to print shoes: %v", err)
 		}
-	} else if *addItems != "" {
-		var wg sync.WaitGroup
-		results := make(chan error, 1)
-
-		wg.Add(1)
-		go processShoeURL(db, *addItems, *discordNotificationEnabled, &wg, results)
-
-		go func() {
-			wg.Wait()
-			close(results)
-		}()
+	}
+}
 
-		for err := range results {
-			if err != nil {
-				log.Fatalf("Failed to process URL %s: %v", *addItems, err)
-			}
-		}
-	} else if *shoeEntry != "" && *shoeName != "" {
-		shoe, err := db.GetShoeByProductName(*shoeName)
+func setupShoeImport(fs *flag.FlagSet) runFunc {
+	discord := discordFlag(fs)
+	return func(ctx context.Context, a *app, args []string) {
+		urls, err := readURLs(args[0])
 		if err != nil {
-			log.Fatalf("Failed to get shoe by name: %v", err)
-		}
-		if shoe == nil {
-			log.Fatalf("No shoe found with the name: %s", *shoeName)
+			log.Fatalf("Failed to read file: %v", err)
 		}
-
-		pictureID, discordImageUrl, err := discordBot.OnboardNewImage(*shoeEntry, "shoe")
+		queued, skipped, err := enqueueURLs(ctx, a.db, a.db, urls)
 		if err != nil {
-			log.Printf("Failed to onboard new image: %v", err)
+			log.Fatalf("Failed to queue URLs: %v", err)
 		}
+		fmt.Printf("Queued %d URL(s), skipped %d already added or queued.\n", queued, skipped)
+		processJobs(ctx, a, *discord)
+	}
+}
 
-		fmt.Println(discordImageUrl)
-		fmt.Println(discordImageUrl + "\n\nn\n\n")
+func main() {
+	args := os.Args[1:]
+	globals := globalFlags()
+	globals.Usage = func() { printUsage(globals.Output(), globals) }
+	i := commandIndex(globals, args)
+	if replaced := replacedFlag(args[:i]); replaced != "" {
+		fmt.Fprintf(os.Stderr, "vertigo: %s\n", replaced)
+		os.Exit(exitUsage)
+	}
+	if err := globals.Parse(args[:i]); err == flag.ErrHelp {
+		return
+	} else if err != nil {
+		os.Exit(exitUsage)
+	}
 
-		shoentryID, err := db.InsertShoentry(shoe.ID, pictureID)
-		if err != nil {
-			log.Fatalf("Failed to insert shoentry: %v", err)
-		}
+	cmd, cmdArgs, err := findCommand(args[i:])
+	if err != nil {
+		printCommandError(os.Stderr, args[i:], err)
+		os.Exit(exitUsage)
+	}
+	run, cmdArgs, err := cmd.parse(os.Stderr, cmdArgs)
+	if err == flag.ErrHelp {
+		return
+	} else if err != nil {
+		os.Exit(exitUsage)
+	}
+	if cmd.bare {
+		run(context.Background(), nil, cmdArgs)
+		return
+	}
 
-		shoentryDetails, err := db.GetShoentryByID(shoentryID)
-		if err != nil {
-			log.Fatalf("Failed to retrieve shoentry: %v", err)
-		}
+	cfg, err := config.Load(flag.NewFlagSet("vertigo", flag.ContinueOnError), args[:i])
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
 
-		fmt.Println("Shoe entry added successfully")
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx = database.WithActor(ctx, cfg.User)
 
-		if *discordNotificationEnabled {
-			err = discordBot.PostNewShoeEntry(*shoentryDetails)
-			if err != nil {
-				log.Printf("Discord couldn't be notified. %v", err)
-			} else {
-				fmt.Println("Discord successfully notified.")
-			}
+	db, err := database.GetDB(cfg.DatabasePath)
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+
+	db.SetQueryTimeout(cfg.DatabaseTimeout)
+
+	err = db.UseMigrationsDir(cfg.MigrationsDir)
+	if err != nil {
+		log.Fatalf("Failed to load migrations: %v", err)
+	}
+
+	if !cmd.noMigrate {
+		err = db.MigrateUp(ctx)
+		if err != nil {
+			log.Fatalf("Failed to migrate database: %v", err)
 		}
 	}
+
+	run(ctx, &app{cfg: cfg, db: db, client: httpclient.New(cfg.HTTP)}, cmdArgs)
 }
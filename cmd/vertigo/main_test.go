@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"vertigo/pkg/database"
+	"vertigo/pkg/dataitems"
+	"vertigo/pkg/stockx"
+)
+
+func TestOnboardShoeStoresImageURLs(t *testing.T) {
+	ctx := context.Background()
+	db, err := database.GetDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("GetDB failed: %v", err)
+	}
+	defer db.Close()
+	if err := db.MigrateUp(ctx); err != nil {
+		t.Fatalf("MigrateUp failed: %v", err)
+	}
+
+	scrape := func(url string) (stockx.ProductDetails, error) {
+		return stockx.ProductDetails{
+			Name:        "Air Jordan 1",
+			ProductName: "Air-Jordan-1",
+			LastSale:    "$180",
+			MainPicture: "https://images.stockx.com/images/Air-Jordan-1-Product.jpg",
+		}, nil
+	}
+	var imgURL string
+	visuals := func(ctx context.Context, productName, url string) (*stockx.VisualItem, error) {
+		// The shoe is inserted once its pictures are published.
+		if shoe, _ := db.GetShoeByProductName(ctx, productName); shoe != nil {
+			t.Fatalf("Expected the pictures before the shoe")
+		}
+		imgURL = url
+		return &stockx.VisualItem{
+			MainImageURL:   "https://blobs.example.com/" + productName + "/main.png",
+			SpinningGifURL: "https://blobs.example.com/" + productName + "/spinning.gif",
+		}, nil
+	}
+
+	product, err := onboardShoe(ctx, db, scrape, visuals, nil, "https://stockx.com/air-jordan-1")
+	if err != nil || product == nil {
+		t.Fatalf("onboardShoe failed: %v", err)
+	}
+	if imgURL != "https://images.stockx.com/images/Air-Jordan-1-Product.jpg" {
+		t.Fatalf("Expected the pictures to be fetched from the scraped URL, got %q", imgURL)
+	}
+
+	shoe, err := db.GetShoeByProductName(ctx, "Air-Jordan-1")
+	if err != nil || shoe == nil {
+		t.Fatalf("Expected the shoe to be stored: %v", err)
+	}
+	if shoe.MainPicture != "https://blobs.example.com/Air-Jordan-1/main.png" || shoe.SpinningGifURL != "https://blobs.example.com/Air-Jordan-1/spinning.gif" {
+		t.Fatalf("Expected the published URLs, got %q and %q", shoe.MainPicture, shoe.SpinningGifURL)
+	}
+
+	// Onboarding it again only records the price.
+	product, err = onboardShoe(ctx, db, scrape, nil, nil, "https://stockx.com/air-jordan-1")
+	if err != nil || product != nil {
+		t.Fatalf("Expected the existing shoe to be skipped, got %v, %v", product, err)
+	}
+	if prices, _ := db.GetShoePrices(ctx, shoe.ID); len(prices) != 2 {
+		t.Fatalf("Expected a second price, got %+v", prices)
+	}
+}
+
+func TestOnboardShoeTriggersAlertsOnReAdd(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	if err := store.InsertShoe(ctx, stockx.ProductDetails{Name: "Air Jordan 1", ProductName: "Air-Jordan-1", LastSale: "$180"}); err != nil {
+		t.Fatalf("InsertShoe failed: %v", err)
+	}
+	shoe, _ := store.GetShoeByProductName(ctx, "Air-Jordan-1")
+	alertID, err := store.InsertPriceAlert(ctx, database.PriceAlert{ShoeID: shoe.ID, Kind: database.AlertBelow, Threshold: 170})
+	if err != nil {
+		t.Fatalf("InsertPriceAlert failed: %v", err)
+	}
+
+	scrape := func(url string) (stockx.ProductDetails, error) {
+		return stockx.ProductDetails{Name: "Air Jordan 1", ProductName: "Air-Jordan-1", LastSale: "$150"}, nil
+	}
+	var posted []database.TriggeredAlert
+	post := func(ctx context.Context, shoe database.Shoe, alerts []database.TriggeredAlert) error {
+		posted = append(posted, alerts...)
+		return nil
+	}
+
+	// The first price below the threshold comes from adding the shoe again.
+	if product, err := onboardShoe(ctx, store, scrape, nil, post, "https://stockx.com/air-jordan-1"); err != nil || product != nil {
+		t.Fatalf("Expected the existing shoe to be skipped, got %v, %v", product, err)
+	}
+	if len(posted) != 1 || posted[0].Alert.ID != alertID {
+		t.Fatalf("Expected alert %d to be posted, got %+v", alertID, posted)
+	}
+
+	// A refresh at the same price doesn't trigger it again.
+	refreshed, errs := refreshShoes(ctx, store, scrape, post, []string{"Air-Jordan-1"}, 0)
+	if len(errs) != 0 || len(refreshed[0].Alerts) != 0 || len(posted) != 1 {
+		t.Fatalf("Expected the alert to trigger once, got %+v, %v", posted, errs)
+	}
+}
+
+func TestCreateShoe(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	shoe, err := dataitems.ParseShoe([]string{"name=Mars Yard", "brand=Nike", "silhouette=Mars Yard", "retail_price=$2,500", "image_url=https://example.com/mars-yard.jpg"})
+	if err != nil {
+		t.Fatalf("ParseShoe failed: %v", err)
+	}
+
+	var imgURL string
+	image := func(ctx context.Context, productName, url string) (*stockx.VisualItem, error) {
+		imgURL = url
+		return &stockx.VisualItem{MainImageURL: "https://blobs.example.com/" + productName + "/main.png"}, nil
+	}
+	product, err := createShoe(ctx, store, image, shoe)
+	if err != nil || product == nil {
+		t.Fatalf("createShoe failed: %v", err)
+	}
+	if imgURL != "https://example.com/mars-yard.jpg" {
+		t.Fatalf("Expected the picture to be fetched from image_url, got %q", imgURL)
+	}
+	stored, _ := store.GetShoeByProductName(ctx, "Mars-Yard")
+	if stored == nil || stored.MainPicture != "https://blobs.example.com/Mars-Yard/main.png" || stored.SpinningGifURL != "" ||
+		stored.Attributes != `{"Brand":"Nike","Retail Price":"$2,500","Silhouette":"Mars Yard"}` {
+		t.Fatalf("Unexpected shoe %+v", stored)
+	}
+
+	if _, err := createShoe(ctx, store, nil, shoe); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("Expected the second shoe to be rejected, got %v", err)
+	}
+}
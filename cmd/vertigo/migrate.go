@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+)
+
+func runMigrateUp(ctx context.Context, a *app, args []string) {
+	if err := a.db.MigrateUp(ctx); err != nil {
+		log.Fatalf("Failed to migrate up: %v", err)
+	}
+	fmt.Println("Database is up to date.")
+}
+
+func runMigrateDown(ctx context.Context, a *app, args []string) {
+	steps := int64(1)
+	if len(args) > 0 {
+		if steps = argID(args[0]); steps < 1 {
+			log.Fatalf("Invalid number of steps %q", args[0])
+		}
+	}
+	if err := a.db.MigrateDown(ctx, int(steps)); err != nil {
+		log.Fatalf("Failed to migrate down: %v", err)
+	}
+	fmt.Printf("Reverted %d migration(s).\n", steps)
+}
+
+func runMigrateStatus(ctx context.Context, a *app, args []string) {
+	statuses, err := a.db.MigrationStatus(ctx)
+	if err != nil {
+		log.Fatalf("Failed to read migration status: %v", err)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATE\tAPPLIED AT")
+	for _, s := range statuses {
+		state, appliedAt := "pending", ""
+		if s.Applied {
+			state, appliedAt = "applied", s.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		if s.Dirty {
+			state = "dirty"
+		}
+		fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", s.Version, s.Name, state, appliedAt)
+	}
+	w.Flush()
+}
+
+func runMigrateCheck(ctx context.Context, a *app, args []string) {
+	orphans, err := a.db.FindOrphanedRows(ctx)
+	if err != nil {
+		log.Fatalf("Failed to check for orphaned rows: %v", err)
+	}
+	quarantined, err := a.db.OrphanedRowReport(ctx)
+	if err != nil {
+		log.Fatalf("Failed to read orphaned_rows: %v", err)
+	}
+	for _, o := range orphans {
+		fmt.Printf("%s.ID=%d references missing %s %d\n", o.Table, o.RowID, o.Column, o.MissingID)
+	}
+	for _, o := range quarantined {
+		fmt.Printf("%s.ID=%d referenced missing %s %d, reference cleared by migration\n", o.Table, o.RowID, o.Column, o.MissingID)
+	}
+	if len(orphans) == 0 && len(quarantined) == 0 {
+		fmt.Println("No orphaned rows found.")
+	}
+}
+
+func runMigrateForce(ctx context.Context, a *app, args []string) {
+	version := argID(args[0])
+	if err := a.db.ForceMigrationVersion(ctx, version); err != nil {
+		log.Fatalf("Failed to force migration version: %v", err)
+	}
+	fmt.Printf("Database forced to version %d.\n", version)
+}
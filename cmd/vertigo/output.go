@@ -0,0 +1,536 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+var outputFormats = []string{"table", "json", "jsonl", "csv", "yaml"}
+
+// listing prints records of one struct type in the format, fields, order and
+// selection given by the flags of a list command.
+type listing struct {
+	columns  []column
+	defaults []column
+	format   string
+	fields   []column
+	sortBy   *column
+	desc     bool
+	where    []condition
+}
+
+// column is a field of the records, named after its JSON tag, or a key of
+// the JSON object in an attributes field.
+type column struct {
+	name  string
+	index []int
+	key   string
+}
+
+type condition struct {
+	column column
+	op     string
+	value  string
+}
+
+// Operators of -where, two character ones first.
+var whereOps = []string{"!=", "<=", ">=", "=", "<", ">", "~"}
+
+// listFlags registers the output flags for a list of records like record on
+// fs. defaults are the fields of the table output.
+func listFlags(fs *flag.FlagSet, record any, defaults ...string) *listing {
+	l := &listing{format: "table", columns: recordColumns(reflect.TypeOf(record), nil)}
+	for _, name := range defaults {
+		c, err := l.column(name)
+		if err != nil {
+			panic(err)
+		}
+		l.defaults = append(l.defaults, c)
+	}
+
+	fs.Var(choiceValue{&l.format, outputFormats}, "output", "Output `format`, one of "+strings.Join(outputFormats, ", "))
+	fs.Var(funcValue{l.setFields, l.completeFields}, "fields", "Comma separated `fields` to print, attribute keys included")
+	fs.Var(funcValue{l.setSort, l.completeSort}, "sort", "Sort by `field`, descending if it is prefixed with -")
+	fs.Var(funcValue{l.addWhere, l.completeFields}, "where", "Only print records matching the `condition` field=value, also !=, ~ (contains), <, <=, > and >=, can be repeated")
+	return l
+}
+
+func recordColumns(t reflect.Type, index []int) []column {
+	var columns []column
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		fieldIndex := append(append([]int{}, index...), i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			columns = append(columns, recordColumns(f.Type, fieldIndex)...)
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		columns = append(columns, column{name: name, index: fieldIndex})
+	}
+	return columns
+}
+
+// normalize makes lastSale, last_sale and "Last Sale" the same name.
+func normalize(name string) string {
+	return strings.ToLower(strings.NewReplacer("_", "", "-", "", " ", "").Replace(name))
+}
+
+func isAttributes(c column) bool {
+	return c.key == "" && (c.name == "attributes" || strings.HasSuffix(c.name, "_attributes"))
+}
+
+// column looks up a field by name. Names that aren't fields are keys of the
+// first attributes field, or of a named one with attributes.key.
+func (l *listing) column(name string) (column, error) {
+	for _, c := range l.columns {
+		if normalize(c.name) == normalize(name) {
+			return c, nil
+		}
+	}
+	field, key, dotted := strings.Cut(name, ".")
+	for _, c := range l.columns {
+		if !isAttributes(c) {
+			continue
+		}
+		if !dotted {
+			return column{name: name, index: c.index, key: name}, nil
+		}
+		if normalize(c.name) == normalize(field) && key != "" {
+			return column{name: key, index: c.index, key: key}, nil
+		}
+	}
+	var names []string
+	for _, c := range l.columns {
+		names = append(names, c.name)
+	}
+	return column{}, fmt.Errorf("unknown field %q, expected one of %s", name, strings.Join(names, ", "))
+}
+
+func (l *listing) setFields(value string) error {
+	l.fields = nil
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		c, err := l.column(name)
+		if err != nil {
+			return err
+		}
+		l.fields = append(l.fields, c)
+	}
+	return nil
+}
+
+func (l *listing) setSort(value string) error {
+	name, desc := strings.CutPrefix(value, "-")
+	c, err := l.column(name)
+	if err != nil {
+		return err
+	}
+	l.sortBy, l.desc = &c, desc
+	return nil
+}
+
+func (l *listing) addWhere(value string) error {
+	i := strings.IndexAny(value, "!=<>~")
+	if i <= 0 {
+		return fmt.Errorf("invalid condition %q, expected field=value", value)
+	}
+	for _, op := range whereOps {
+		if strings.HasPrefix(value[i:], op) {
+			c, err := l.column(strings.TrimSpace(value[:i]))
+			if err != nil {
+				return err
+			}
+			l.where = append(l.where, condition{column: c, op: op, value: value[i+len(op):]})
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid condition %q, expected field=value", value)
+}
+
+// completeFields completes the last of the comma separated names in prefix.
+func (l *listing) completeFields(prefix string) []string {
+	done := prefix[:strings.LastIndex(prefix, ",")+1]
+	var names []string
+	for _, c := range l.columns {
+		names = append(names, done+c.name)
+	}
+	return matching(names, prefix)
+}
+
+func (l *listing) completeSort(prefix string) []string {
+	var names []string
+	for _, c := range l.columns {
+		names = append(names, c.name, "-"+c.name)
+	}
+	return matching(names, prefix)
+}
+
+// print writes records, a slice of the type given to listFlags.
+func (l *listing) print(w io.Writer, records any) error {
+	rows := reflect.ValueOf(records)
+	var selected []reflect.Value
+	for i := 0; i < rows.Len(); i++ {
+		if l.matches(rows.Index(i)) {
+			selected = append(selected, rows.Index(i))
+		}
+	}
+	if l.sortBy != nil {
+		c := *l.sortBy
+		sort.SliceStable(selected, func(i, j int) bool {
+			a, b := c.value(selected[i]), c.value(selected[j])
+			if l.desc {
+				a, b = b, a
+			}
+			return compare(a, text(b)) < 0
+		})
+	}
+
+	fields := l.fields
+	if fields == nil && l.format == "table" && l.defaults != nil {
+		fields = l.defaults
+	}
+	if fields == nil {
+		fields = l.columns
+	}
+	if err := checkKeys(rows, append(fields, l.conditionColumns()...)); err != nil {
+		return err
+	}
+
+	switch l.format {
+	case "json", "jsonl":
+		return writeJSON(w, fields, selected, l.format == "jsonl")
+	case "csv":
+		return writeCSV(w, fields, selected)
+	case "yaml":
+		return writeYAML(w, fields, selected)
+	}
+	return writeTable(w, fields, selected)
+}
+
+func (l *listing) conditionColumns() []column {
+	var columns []column
+	for _, cond := range l.where {
+		columns = append(columns, cond.column)
+	}
+	if l.sortBy != nil {
+		columns = append(columns, *l.sortBy)
+	}
+	return columns
+}
+
+// checkKeys rejects attribute keys that none of the rows has, which are
+// more likely misspelled fields than missing attributes.
+func checkKeys(rows reflect.Value, columns []column) error {
+	if rows.Len() == 0 {
+		return nil
+	}
+	for _, c := range columns {
+		if c.key == "" {
+			continue
+		}
+		found := false
+		for i := 0; i < rows.Len() && !found; i++ {
+			found = c.value(rows.Index(i)) != nil
+		}
+		if !found {
+			return fmt.Errorf("unknown field %q, it is neither a field nor an attribute", c.name)
+		}
+	}
+	return nil
+}
+
+func (l *listing) matches(row reflect.Value) bool {
+	for _, cond := range l.where {
+		v := cond.column.value(row)
+		n := compare(v, cond.value)
+		var ok bool
+		switch cond.op {
+		case "=":
+			ok = n == 0
+		case "!=":
+			ok = n != 0
+		case "~":
+			ok = strings.Contains(strings.ToLower(text(v)), strings.ToLower(cond.value))
+		case "<":
+			ok = n < 0
+		case "<=":
+			ok = n <= 0
+		case ">":
+			ok = n > 0
+		case ">=":
+			ok = n >= 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// value returns the value of c in row. Unset times and pointers are nil,
+// attributes are decoded into a map when they hold a JSON object.
+func (c column) value(row reflect.Value) any {
+	v := row.FieldByIndex(c.index)
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	switch value := v.Interface().(type) {
+	case time.Time:
+		if value.IsZero() {
+			return nil
+		}
+		return value
+	case string:
+		if c.key == "" && !isAttributes(c) {
+			return value
+		}
+		var attributes map[string]any
+		if json.Unmarshal([]byte(value), &attributes) != nil {
+			if c.key != "" {
+				return nil
+			}
+			return value
+		}
+		if c.key == "" {
+			return attributes
+		}
+		for key, v := range attributes {
+			if normalize(key) == normalize(c.key) {
+				return v
+			}
+		}
+		return nil
+	default:
+		return value
+	}
+}
+
+// text formats v for csv and for comparisons.
+func text(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case map[string]any:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+	return fmt.Sprint(v)
+}
+
+// compare compares v to s, as numbers if both are numbers, prices included,
+// and as times if v is one. Other values are compared as text, ignoring case.
+func compare(v any, s string) int {
+	if t, ok := v.(time.Time); ok {
+		for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+			if other, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+				return t.Compare(other)
+			}
+		}
+	}
+	a, b := text(v), s
+	x, errX := parseNumber(a)
+	y, errY := parseNumber(b)
+	switch {
+	case errX == nil && errY == nil && x < y:
+		return -1
+	case errX == nil && errY == nil && x > y:
+		return 1
+	case errX == nil && errY == nil:
+		return 0
+	}
+	return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+}
+
+// parseNumber parses s as a number, ignoring currency symbols and thousands
+// separators so that prices like $1,250 compare as numbers.
+func parseNumber(s string) (float64, error) {
+	s = strings.TrimLeft(strings.TrimSpace(s), "$€£¥")
+	return strconv.ParseFloat(strings.ReplaceAll(s, ",", ""), 64)
+}
+
+func writeTable(w io.Writer, fields []column, rows []reflect.Value) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	var header []string
+	for _, c := range fields {
+		header = append(header, strings.ToUpper(strings.ReplaceAll(c.name, "_", " ")))
+	}
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		var cells []string
+		for _, c := range fields {
+			switch v := c.value(row).(type) {
+			case nil:
+				cells = append(cells, "-")
+			case time.Time:
+				cells = append(cells, v.Local().Format("2006-01-02 15:04:05"))
+			default:
+				cells = append(cells, strings.NewReplacer("\t", " ", "\n", " ").Replace(text(v)))
+			}
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	return tw.Flush()
+}
+
+func writeCSV(w io.Writer, fields []column, rows []reflect.Value) error {
+	cw := csv.NewWriter(w)
+	var header []string
+	for _, c := range fields {
+		header = append(header, c.name)
+	}
+	cw.Write(header)
+	for _, row := range rows {
+		var record []string
+		for _, c := range fields {
+			record = append(record, text(c.value(row)))
+		}
+		cw.Write(record)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeJSON writes the records as objects with their fields in order, an
+// array of them or one per line.
+func writeJSON(w io.Writer, fields []column, rows []reflect.Value, lines bool) error {
+	var out bytes.Buffer
+	if !lines {
+		out.WriteByte('[')
+	}
+	for i, row := range rows {
+		if i > 0 && !lines {
+			out.WriteByte(',')
+		}
+		out.WriteByte('{')
+		for j, c := range fields {
+			if j > 0 {
+				out.WriteByte(',')
+			}
+			key, _ := json.Marshal(c.name)
+			value, err := json.Marshal(c.value(row))
+			if err != nil {
+				return fmt.Errorf("error encoding %s: %v", c.name, err)
+			}
+			out.Write(key)
+			out.WriteByte(':')
+			out.Write(value)
+		}
+		out.WriteByte('}')
+		if lines {
+			out.WriteByte('\n')
+		}
+	}
+	if lines {
+		_, err := w.Write(out.Bytes())
+		return err
+	}
+	out.WriteByte(']')
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, out.Bytes(), "", "  "); err != nil {
+		return err
+	}
+	indented.WriteByte('\n')
+	_, err := w.Write(indented.Bytes())
+	return err
+}
+
+func writeYAML(w io.Writer, fields []column, rows []reflect.Value) error {
+	list := &yaml.Node{Kind: yaml.SequenceNode}
+	if len(rows) == 0 {
+		list.Style = yaml.FlowStyle
+	}
+	for _, row := range rows {
+		record := &yaml.Node{Kind: yaml.MappingNode}
+		for _, c := range fields {
+			var value yaml.Node
+			if err := value.Encode(c.value(row)); err != nil {
+				return fmt.Errorf("error encoding %s: %v", c.name, err)
+			}
+			record.Content = append(record.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: c.name}, &value)
+		}
+		list.Content = append(list.Content, record)
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(list); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// choiceValue is a string flag that must be one of choices.
+type choiceValue struct {
+	value   *string
+	choices []string
+}
+
+func (c choiceValue) String() string {
+	if c.value == nil {
+		return ""
+	}
+	return *c.value
+}
+
+func (c choiceValue) Set(value string) error {
+	for _, choice := range c.choices {
+		if value == choice {
+			*c.value = value
+			return nil
+		}
+	}
+	return fmt.Errorf("expected one of %s", strings.Join(c.choices, ", "))
+}
+
+func (c choiceValue) complete(prefix string) []string {
+	return matching(c.choices, prefix)
+}
+
+// funcValue is a flag whose values are checked and stored by set.
+type funcValue struct {
+	set        func(string) error
+	completeFn func(prefix string) []string
+}
+
+func (f funcValue) String() string { return "" }
+
+func (f funcValue) Set(value string) error { return f.set(value) }
+
+func (f funcValue) complete(prefix string) []string { return f.completeFn(prefix) }
+
+// completer is implemented by flag values that can complete their value.
+type completer interface {
+	complete(prefix string) []string
+}
+
+var (
+	_ completer = choiceValue{}
+	_ completer = funcValue{}
+)
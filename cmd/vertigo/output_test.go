@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"strings"
+	"testing"
+	"time"
+	"vertigo/pkg/database"
+)
+
+var testShoes = []database.Shoe{
+	{ID: 1, Name: "Air Jordan 1", LastSale: "$180", Attributes: `{"Brand":"Nike","Retail Price":"$170"}`, Timestamp: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)},
+	{ID: 2, Name: "Yeezy 350", LastSale: "$250", Attributes: `{"Brand":"adidas"}`},
+	{ID: 3, Name: "Mars Yard", LastSale: "$3,000", Attributes: `{"Brand":"Nike"}`},
+}
+
+func printShoes(t *testing.T, args ...string) string {
+	t.Helper()
+	fs := flag.NewFlagSet("shoe list", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	list := listFlags(fs, database.Shoe{}, "id", "name")
+	if err := fs.Parse(args); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	var out strings.Builder
+	if err := list.print(&out, testShoes); err != nil {
+		t.Fatalf("print failed: %v", err)
+	}
+	return out.String()
+}
+
+func TestListOutput(t *testing.T) {
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{nil, "ID  NAME\n1   Air Jordan 1\n2   Yeezy 350\n3   Mars Yard\n"},
+		{[]string{"-where", "brand=nike", "-sort", "-lastSale", "-fields", "name,last_sale"}, "NAME          LAST SALE\nMars Yard     $3,000\nAir Jordan 1  $180\n"},
+		{[]string{"-where", "last_sale>200", "-where", "name~YARD", "-fields", "id"}, "ID\n3\n"},
+		{[]string{"-output", "csv", "-fields", "id,attributes", "-where", "id<=2"}, "id,attributes\n" + `1,"{""Brand"":""Nike"",""Retail Price"":""$170""}"` + "\n" + `2,"{""Brand"":""adidas""}"` + "\n"},
+		{[]string{"-output", "jsonl", "-fields", "id,retail_price,timestamp"}, `{"id":1,"retail_price":"$170","timestamp":"2024-05-01T10:00:00Z"}` + "\n" + `{"id":2,"retail_price":null,"timestamp":null}` + "\n" + `{"id":3,"retail_price":null,"timestamp":null}` + "\n"},
+		{[]string{"-output", "json", "-fields", "name,attributes.brand", "-where", "id=2"}, "[\n  {\n    \"name\": \"Yeezy 350\",\n    \"brand\": \"adidas\"\n  }\n]\n"},
+		{[]string{"-output", "json", "-where", "id=4"}, "[]\n"},
+		{[]string{"-output", "yaml", "-fields", "id,attributes", "-where", "id=2"}, "- id: 2\n  attributes:\n    Brand: adidas\n"},
+		{[]string{"-output", "yaml", "-where", "timestamp>=2024-05-01", "-fields", "id"}, "- id: 1\n"},
+	} {
+		if got := printShoes(t, tt.args...); got != tt.want {
+			t.Errorf("%q: expected\n%s\ngot\n%s", tt.args, tt.want, got)
+		}
+	}
+}
+
+func TestListFlagErrors(t *testing.T) {
+	for _, tt := range []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"shoe", "list", "-output", "xml"}, "expected one of table, json, jsonl, csv, yaml"},
+		{[]string{"jobs", "list", "-fields", "id,attributes.x"}, `unknown field "attributes.x"`},
+		{[]string{"history", "items", "1", "-sort", "colour"}, `unknown field "colour"`},
+		{[]string{"shoe", "list", "-where", "brand"}, `invalid condition "brand"`},
+	} {
+		cmd, rest, _ := findCommand(tt.args)
+		if _, _, err := cmd.parse(io.Discard, rest); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%q: expected %q, got %v", tt.args, tt.err, err)
+		}
+	}
+
+	fs := flag.NewFlagSet("shoe list", flag.ContinueOnError)
+	list := listFlags(fs, database.Shoe{})
+	fs.Parse([]string{"-fields", "nme"})
+	if err := list.print(io.Discard, testShoes); err == nil || !strings.Contains(err.Error(), `unknown field "nme"`) {
+		t.Fatalf("Expected an attribute no shoe has to be rejected, got %v", err)
+	}
+}
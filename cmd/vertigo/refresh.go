@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+	"vertigo/pkg/database"
+	discordBot "vertigo/pkg/discordBot"
+	"vertigo/pkg/stockx"
+)
+
+// refreshDelay keeps a refresh of the whole collection from hammering StockX.
+const refreshDelay = 2 * time.Second
+
+type shoeScraper func(url string) (stockx.ProductDetails, error)
+
+type shoePriceStore interface {
+	database.ShoeStore
+	database.PriceStore
+	database.AlertStore
+}
+
+// alertPoster posts the alerts a price of shoe triggered, like
+// discordBot.Bot.PostPriceAlerts.
+type alertPoster func(ctx context.Context, shoe database.Shoe, alerts []database.TriggeredAlert) error
+
+// alertPosterOf returns the alertPoster of bot, or nil without one.
+func alertPosterOf(bot *discordBot.Bot) alertPoster {
+	if bot == nil {
+		return nil
+	}
+	return bot.PostPriceAlerts
+}
+
+type refreshedShoe struct {
+	Shoe   database.Shoe
+	Price  database.ShoePrice
+	Alerts []database.TriggeredAlert
+}
+
+// recordPrice appends lastSale to the price history of shoe, evaluates the
+// price alerts against it and posts the triggered ones with post, when set.
+// The shoe is returned with an error once its price is recorded, nil before.
+func recordPrice(ctx context.Context, store shoePriceStore, post alertPoster, shoe database.Shoe, lastSale string) (*refreshedShoe, error) {
+	price, err := store.RecordShoePrice(ctx, shoe.ID, lastSale, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to record the price of %s: %v", shoe.ProductName, err)
+	}
+	refreshed := &refreshedShoe{Shoe: shoe, Price: *price}
+	refreshed.Alerts, err = database.EvaluatePriceAlerts(ctx, store, *price)
+	if err != nil {
+		return refreshed, fmt.Errorf("failed to evaluate the price alerts of %s: %v", shoe.ProductName, err)
+	}
+	if post != nil && len(refreshed.Alerts) > 0 {
+		if err := post(ctx, shoe, refreshed.Alerts); err != nil {
+			log.Printf("Discord couldn't be notified. %v", err)
+		}
+	}
+	return refreshed, nil
+}
+
+// printAlerts lists the alerts a price triggered.
+func printAlerts(alerts []database.TriggeredAlert) {
+	for _, t := range alerts {
+		fmt.Printf("  Alert %d triggered: %s\n", t.Alert.ID, t.Alert)
+	}
+}
+
+// refreshShoes scrapes the shoes again, appends their last sale to the price
+// history, evaluates the price alerts against it and posts the triggered ones
+// with post, when set. Without product names every shoe is refreshed. A shoe
+// that fails is reported and the others are still refreshed.
+func refreshShoes(ctx context.Context, store shoePriceStore, scrape shoeScraper, post alertPoster, productNames []string, delay time.Duration) ([]refreshedShoe, []error) {
+	if len(productNames) == 0 {
+		shoes, err := store.QueryShoes(ctx)
+		if err != nil {
+			return nil, []error{err}
+		}
+		for _, shoe := range shoes {
+			productNames = append(productNames, shoe.ProductName)
+		}
+	}
+
+	var refreshed []refreshedShoe
+	var errs []error
+	for i, productName := range productNames {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return refreshed, append(errs, ctx.Err())
+			case <-time.After(delay):
+			}
+		}
+
+		shoe, err := store.GetShoeByProductName(ctx, productName)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if shoe == nil {
+			errs = append(errs, fmt.Errorf("no shoe with product name %s", productName))
+			continue
+		}
+
+		product, err := scrape(stockx.ProductURL(productName))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("can't get shoe information for %s from stockx: %v", productName, err))
+			continue
+		}
+
+		r, err := recordPrice(ctx, store, post, *shoe, product.LastSale)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if r != nil {
+			refreshed = append(refreshed, *r)
+		}
+	}
+	return refreshed, errs
+}
+
+// setupRefresh refreshes the prices once, or with -every on a schedule until
+// it is interrupted. Triggered alerts are posted to Discord with -discord.
+func setupRefresh(fs *flag.FlagSet) runFunc {
+	every := fs.Duration("every", 0, "Refresh again after this long until interrupted, -every 6h")
+	discord := discordFlag(fs)
+	return func(ctx context.Context, a *app, args []string) {
+		scraper, bot := a.stockx(), a.notifier(*discord)
+		for {
+			failed := refreshOnce(ctx, a.db, scraper.GetShoeInformation, bot, args)
+			if *every <= 0 {
+				if failed > 0 {
+					log.Fatalf("Failed to refresh %d shoe(s)", failed)
+				}
+				return
+			}
+
+			log.Printf("Next refresh at %s", time.Now().Add(*every).Format(time.RFC3339))
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(*every):
+			}
+		}
+	}
+}
+
+func refreshOnce(ctx context.Context, db *database.DB, scrape shoeScraper, bot *discordBot.Bot, productNames []string) int {
+	refreshed, errs := refreshShoes(ctx, db, scrape, alertPosterOf(bot), productNames, refreshDelay)
+	for _, r := range refreshed {
+		fmt.Printf("%s: %.2f %s\n", r.Shoe.ProductName, r.Price.Amount, r.Price.Currency)
+		printAlerts(r.Alerts)
+	}
+	for _, err := range errs {
+		log.Println(err)
+	}
+	return len(errs)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"vertigo/pkg/database"
+	"vertigo/pkg/stockx"
+)
+
+func TestRefreshShoes(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	for _, pd := range []stockx.ProductDetails{
+		{Name: "Air Jordan 1", ProductName: "Air-Jordan-1", LastSale: "$180"},
+		{Name: "Yeezy 350", ProductName: "Yeezy-350", LastSale: "--"},
+	} {
+		if err := store.InsertShoe(ctx, pd); err != nil {
+			t.Fatalf("InsertShoe failed: %v", err)
+		}
+	}
+
+	scrape := func(url string) (stockx.ProductDetails, error) {
+		switch url {
+		case "https://stockx.com/air-jordan-1":
+			return stockx.ProductDetails{LastSale: "$150"}, nil
+		case "https://stockx.com/yeezy-350":
+			return stockx.ProductDetails{}, errors.New("status code error: 404")
+		}
+		t.Fatalf("Unexpected url %s", url)
+		return stockx.ProductDetails{}, nil
+	}
+
+	shoe, _ := store.GetShoeByProductName(ctx, "Air-Jordan-1")
+	var alertIDs []int64
+	for _, alert := range []database.PriceAlert{
+		{ShoeID: shoe.ID, Kind: database.AlertBelow, Threshold: 170},
+		{ShoeID: shoe.ID, Kind: database.AlertBelow, Threshold: 100},
+		{ShoeID: shoe.ID, Kind: database.AlertBelow, Threshold: 170, Currency: "EUR"},
+		{ShoeID: shoe.ID, Kind: database.AlertDrop, Threshold: 10},
+	} {
+		id, err := store.InsertPriceAlert(ctx, alert)
+		if err != nil {
+			t.Fatalf("InsertPriceAlert failed: %v", err)
+		}
+		alertIDs = append(alertIDs, id)
+	}
+
+	var posted []database.TriggeredAlert
+	post := func(ctx context.Context, shoe database.Shoe, alerts []database.TriggeredAlert) error {
+		posted = append(posted, alerts...)
+		return nil
+	}
+	refreshed, errs := refreshShoes(ctx, store, scrape, post, nil, 0)
+	if len(refreshed) != 1 || len(errs) != 1 {
+		t.Fatalf("Expected one refreshed and one failed shoe, got %+v and %v", refreshed, errs)
+	}
+	alerts := refreshed[0].Alerts
+	if len(alerts) != 2 || alerts[0].Alert.ID != alertIDs[0] || alerts[1].Alert.ID != alertIDs[3] || alerts[1].Previous.Amount != 180 {
+		t.Fatalf("Expected the first and last alert to trigger, got %+v", alerts)
+	}
+	if len(posted) != 2 {
+		t.Fatalf("Expected the triggered alerts to be posted, got %+v", posted)
+	}
+
+	shoe, _ = store.GetShoeByProductName(ctx, "Air-Jordan-1")
+	if shoe.LastSale != "$150" {
+		t.Fatalf("Expected LastSale to be updated, got %q", shoe.LastSale)
+	}
+	history, err := store.GetShoePrices(ctx, shoe.ID)
+	if err != nil {
+		t.Fatalf("GetShoePrices failed: %v", err)
+	}
+	if len(history) != 2 || history[0].Amount != 180 || history[1].Amount != 150 || history[1].Currency != "USD" {
+		t.Fatalf("Unexpected price history %+v", history)
+	}
+
+	// A target price triggers once when it is crossed, not on every refresh.
+	if refreshed, _ = refreshShoes(ctx, store, scrape, post, []string{"Air-Jordan-1"}, 0); len(refreshed[0].Alerts) != 0 {
+		t.Fatalf("Expected no alerts for an unchanged price, got %+v", refreshed[0].Alerts)
+	}
+	if len(posted) != 2 {
+		t.Fatalf("Expected nothing more to be posted, got %+v", posted)
+	}
+
+	if _, errs := refreshShoes(ctx, store, scrape, nil, []string{"Unknown"}, 0); len(errs) != 1 {
+		t.Fatalf("Expected an error for an unknown shoe, got %v", errs)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"vertigo/pkg/database"
+)
+
+// setField sets the JSON field key of row to value. Values that are not
+// valid JSON for the field, like most strings, are set as strings.
+func setField(row interface{}, key, value string) error {
+	decode := func(raw []byte) error {
+		field, _ := json.Marshal(key)
+		body := append(append(append([]byte("{"), field...), ':'), raw...)
+		dec := json.NewDecoder(bytes.NewReader(append(body, '}')))
+		dec.DisallowUnknownFields()
+		return dec.Decode(row)
+	}
+	if json.Valid([]byte(value)) && decode([]byte(value)) == nil {
+		return nil
+	}
+	quoted, _ := json.Marshal(value)
+	if err := decode(quoted); err != nil {
+		return fmt.Errorf("invalid value for %s: %v", key, err)
+	}
+	return nil
+}
+
+func editRow(ctx context.Context, store database.Store, table string, id int64, fields []string) (interface{}, error) {
+	allowed, ok := database.EditableFields[table]
+	if !ok {
+		return nil, fmt.Errorf("unknown table %q, expected one of %s", table, strings.Join(database.Tables, ", "))
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("nothing to edit, pass key=value pairs for %s", strings.Join(allowed, ", "))
+	}
+
+	row, err := database.GetRow(ctx, store, table, id)
+	if err != nil {
+		return nil, err
+	}
+	for _, field := range fields {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid field %q, expected key=value", field)
+		}
+		if !slices.Contains(allowed, key) {
+			return nil, fmt.Errorf("%s cannot be edited, expected one of %s", key, strings.Join(allowed, ", "))
+		}
+		if err := setField(row, key, value); err != nil {
+			return nil, err
+		}
+	}
+	if err := database.UpdateRow(ctx, store, id, row); err != nil {
+		return nil, err
+	}
+	return database.GetRow(ctx, store, table, id)
+}
+
+func runEdit(ctx context.Context, a *app, args []string) {
+	table, id := args[0], argID(args[1])
+	row, err := editRow(ctx, a.db, table, id, args[2:])
+	if err != nil {
+		log.Fatalf("Failed to edit %s %d: %v", table, id, err)
+	}
+	fmt.Printf("%+v\n", row)
+}
+
+func runDelete(ctx context.Context, a *app, args []string) {
+	table, id := args[0], argID(args[1])
+	pictures, err := database.DeleteRow(ctx, a.db, table, id)
+	if err != nil {
+		log.Fatalf("Failed to delete %s %d: %v", table, id, err)
+	}
+	fmt.Printf("Deleted %s %d and %d pictures, undo with 'vertigo restore %s %d'\n", table, id, len(pictures), table, id)
+}
+
+func runMerge(ctx context.Context, a *app, args []string) {
+	table, id, intoID := args[0], argID(args[1]), argID(args[2])
+	if err := database.MergeRows(ctx, a.db, table, id, intoID); err != nil {
+		log.Fatalf("Failed to merge %s %d into %d: %v", table, id, intoID, err)
+	}
+	fmt.Printf("Merged %s %d into %d\n", table, id, intoID)
+}
@@ -1,8 +0,0 @@
-CREATE TABLE IF NOT EXISTS foodentries (
-    ID INTEGER PRIMARY KEY AUTOINCREMENT,
-    Name TEXT,
-    ItemID INTEGER,
-    PictureID INTEGER,
-    UpdatedAt DATETIME DEFAULT CURRENT_TIMESTAMP,
-    CreatedAt DATETIME DEFAULT CURRENT_TIMESTAMP
-);
\ No newline at end of file
@@ -1,6 +0,0 @@
-CREATE TABLE IF NOT EXISTS restaurants (
-    ID INTEGER PRIMARY KEY AUTOINCREMENT,
-    Name TEXT,
-    Attributes TEXT,
-    Timestamp DATETIME DEFAULT CURRENT_TIMESTAMP
-);
\ No newline at end of file
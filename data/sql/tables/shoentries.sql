@@ -1,7 +0,0 @@
-CREATE TABLE IF NOT EXISTS shoentries (
-    ID INTEGER PRIMARY KEY AUTOINCREMENT,
-    ItemID INTEGER,
-    PictureID INTEGER,
-    UpdatedAt DATETIME DEFAULT CURRENT_TIMESTAMP,
-    CreatedAt DATETIME DEFAULT CURRENT_TIMESTAMP
-);
\ No newline at end of file
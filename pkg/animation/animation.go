@@ -0,0 +1,153 @@
+// Package animation turns the frames of a 360 view into the spinning shoe,
+// as a GIF or an animated PNG.
+package animation
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"io"
+	"os"
+	"path/filepath"
+	"vertigo/pkg/config"
+
+	"github.com/nfnt/resize"
+)
+
+const (
+	FormatGIF  = "gif"
+	FormatAPNG = "apng"
+)
+
+// Extension returns the file extension of format, animated PNGs are plain
+// .png files to everything that can't animate them.
+func Extension(format string) string {
+	if format == FormatAPNG {
+		return ".png"
+	}
+	return ".gif"
+}
+
+// WriteFile writes frames as an animation to path.
+func WriteFile(path string, frames []image.Image, cfg config.Animation) error {
+	var buf bytes.Buffer
+	if err := Encode(&buf, frames, cfg); err != nil {
+		return err
+	}
+	tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("error writing animation: %v", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// Encode writes frames as an animation that loops forever. Every frame is
+// scaled to cfg.Width, or the width of the first frame, and the height
+// that keeps the first frame's aspect ratio.
+func Encode(w io.Writer, frames []image.Image, cfg config.Animation) error {
+	if len(frames) == 0 {
+		return fmt.Errorf("no frames to animate")
+	}
+	frames = scale(frames, cfg.Width)
+
+	switch cfg.Format {
+	case FormatGIF, "":
+		return encodeGIF(w, frames, cfg)
+	case FormatAPNG:
+		return encodeAPNG(w, frames, cfg)
+	}
+	return fmt.Errorf("unknown animation format %q", cfg.Format)
+}
+
+func scale(frames []image.Image, width int) []image.Image {
+	first := frames[0].Bounds()
+	if width <= 0 {
+		width = first.Dx()
+	}
+	height := first.Dy() * width / first.Dx()
+
+	scaled := make([]image.Image, len(frames))
+	for i, frame := range frames {
+		b := frame.Bounds()
+		if b.Min == (image.Point{}) && b.Dx() == width && b.Dy() == height {
+			scaled[i] = frame
+			continue
+		}
+		scaled[i] = resize.Resize(uint(width), uint(height), frame, resize.Lanczos3)
+	}
+	return scaled
+}
+
+// encodeGIF quantizes all frames to one palette. Frames with a transparent
+// background keep it, a palette entry is reserved for it.
+func encodeGIF(w io.Writer, frames []image.Image, cfg config.Animation) error {
+	transparent := !opaque(frames)
+	var palette color.Palette
+	if transparent {
+		palette = append(quantize(frames, 255), color.Transparent)
+	} else {
+		palette = quantize(frames, 256)
+	}
+	bounds := frames[0].Bounds()
+	anim := &gif.GIF{
+		Config: image.Config{ColorModel: palette, Width: bounds.Dx(), Height: bounds.Dy()},
+	}
+	// GIF delays are in hundredths of a second.
+	delay := int(cfg.Delay.Milliseconds() / 10)
+	if delay < 1 {
+		delay = 1
+	}
+	for _, frame := range frames {
+		paletted := image.NewPaletted(image.Rect(0, 0, bounds.Dx(), bounds.Dy()), palette)
+		draw.FloydSteinberg.Draw(paletted, paletted.Bounds(), frame, frame.Bounds().Min)
+		disposal := byte(gif.DisposalNone)
+		if transparent {
+			clearTransparent(paletted, frame, uint8(len(palette)-1))
+			// Without disposal the previous frame would show through.
+			disposal = gif.DisposalBackground
+		}
+		anim.Image = append(anim.Image, paletted)
+		anim.Delay = append(anim.Delay, delay)
+		anim.Disposal = append(anim.Disposal, disposal)
+	}
+	if err := gif.EncodeAll(w, anim); err != nil {
+		return fmt.Errorf("error encoding GIF: %v", err)
+	}
+	return nil
+}
+
+func opaque(frames []image.Image) bool {
+	for _, frame := range frames {
+		if o, ok := frame.(interface{ Opaque() bool }); ok {
+			if !o.Opaque() {
+				return false
+			}
+			continue
+		}
+		b := frame.Bounds()
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				if _, _, _, a := frame.At(x, y).RGBA(); a != 0xffff {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// clearTransparent points the mostly transparent pixels of frame at the
+// transparent palette entry, dithering may have spread colours into them.
+func clearTransparent(paletted *image.Paletted, frame image.Image, index uint8) {
+	b := frame.Bounds()
+	for y := 0; y < paletted.Rect.Dy(); y++ {
+		for x := 0; x < paletted.Rect.Dx(); x++ {
+			if _, _, _, a := frame.At(b.Min.X+x, b.Min.Y+y).RGBA(); a < 0x8000 {
+				paletted.SetColorIndex(x, y, index)
+			}
+		}
+	}
+}
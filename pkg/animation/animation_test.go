@@ -0,0 +1,134 @@
+package animation
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"testing"
+	"time"
+	"vertigo/pkg/config"
+)
+
+// testFrames returns frames of a red square moving over white.
+func testFrames(n int) []image.Image {
+	frames := make([]image.Image, n)
+	for i := range frames {
+		frame := image.NewRGBA(image.Rect(0, 0, 80, 40))
+		for y := 0; y < 40; y++ {
+			for x := 0; x < 80; x++ {
+				c := color.RGBA{0xff, 0xff, 0xff, 0xff}
+				if x >= i*10 && x < i*10+10 && y >= 10 && y < 20 {
+					c = color.RGBA{0xd0, 0x10, 0x10, 0xff}
+				}
+				frame.Set(x, y, c)
+			}
+		}
+		frames[i] = frame
+	}
+	return frames
+}
+
+func TestEncodeGIF(t *testing.T) {
+	tests := []struct {
+		width, wantWidth, wantHeight int
+	}{
+		{0, 80, 40},
+		{40, 40, 20},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		cfg := config.Animation{Format: FormatGIF, Delay: 120 * time.Millisecond, Width: tt.width}
+		if err := Encode(&buf, testFrames(4), cfg); err != nil {
+			t.Fatalf("Encode failed: %v", err)
+		}
+		anim, err := gif.DecodeAll(&buf)
+		if err != nil {
+			t.Fatalf("Expected a valid GIF: %v", err)
+		}
+		if len(anim.Image) != 4 || anim.Delay[0] != 12 || anim.LoopCount != 0 {
+			t.Fatalf("Expected 4 looping frames of 12/100s, got %d frames, delay %v, loop %d", len(anim.Image), anim.Delay, anim.LoopCount)
+		}
+		if anim.Config.Width != tt.wantWidth || anim.Config.Height != tt.wantHeight {
+			t.Fatalf("Expected %dx%d, got %dx%d", tt.wantWidth, tt.wantHeight, anim.Config.Width, anim.Config.Height)
+		}
+	}
+
+	// Two colours survive the quantization unchanged.
+	var buf bytes.Buffer
+	if err := Encode(&buf, testFrames(2), config.Animation{Format: FormatGIF, Delay: 100 * time.Millisecond}); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	anim, _ := gif.DecodeAll(&buf)
+	r, g, b, _ := anim.Image[1].At(15, 15).RGBA()
+	if r>>8 != 0xd0 || g>>8 != 0x10 || b>>8 != 0x10 {
+		t.Fatalf("Expected the square to stay red, got %d %d %d", r>>8, g>>8, b>>8)
+	}
+}
+
+func TestEncodeTransparentGIF(t *testing.T) {
+	frames := testFrames(2)
+	for _, frame := range frames {
+		frame.(*image.RGBA).Set(0, 0, color.Transparent)
+	}
+	var buf bytes.Buffer
+	if err := Encode(&buf, frames, config.Animation{Format: FormatGIF, Delay: 100 * time.Millisecond}); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	anim, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatalf("Expected a valid GIF: %v", err)
+	}
+	if _, _, _, a := anim.Image[1].At(0, 0).RGBA(); a != 0 {
+		t.Fatalf("Expected the transparent pixel to stay transparent")
+	}
+	if _, _, _, a := anim.Image[1].At(40, 30).RGBA(); a != 0xffff {
+		t.Fatalf("Expected the white background to stay opaque")
+	}
+	if anim.Disposal[1] != gif.DisposalBackground {
+		t.Fatalf("Expected transparent frames to be disposed")
+	}
+}
+
+func TestEncodeAPNG(t *testing.T) {
+	var buf bytes.Buffer
+	cfg := config.Animation{Format: FormatAPNG, Delay: 80 * time.Millisecond}
+	if err := Encode(&buf, testFrames(3), cfg); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+
+	// Viewers without APNG support show the first frame.
+	first, err := png.Decode(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("Expected a valid PNG: %v", err)
+	}
+	if r, _, _, _ := first.At(5, 15).RGBA(); r>>8 != 0xd0 {
+		t.Fatalf("Expected the first frame as the default image")
+	}
+
+	chunks, err := readChunks(buf.Bytes())
+	if err != nil {
+		t.Fatalf("readChunks failed: %v", err)
+	}
+	counts := make(map[string]int)
+	for _, c := range chunks {
+		counts[c.kind]++
+		if c.kind == "acTL" && binary.BigEndian.Uint32(c.data) != 3 {
+			t.Fatalf("Expected acTL to announce 3 frames")
+		}
+		if c.kind == "fcTL" && binary.BigEndian.Uint16(c.data[20:]) != 80 {
+			t.Fatalf("Expected a delay of 80/1000s")
+		}
+	}
+	if counts["acTL"] != 1 || counts["fcTL"] != 3 || counts["fdAT"] < 2 || counts["IEND"] != 1 {
+		t.Fatalf("Unexpected chunks %v", counts)
+	}
+}
+
+func TestEncodeUnknownFormat(t *testing.T) {
+	if err := Encode(&bytes.Buffer{}, testFrames(1), config.Animation{Format: "webp"}); err == nil {
+		t.Fatalf("Expected an error for an unknown format")
+	}
+}
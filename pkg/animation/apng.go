@@ -0,0 +1,134 @@
+package animation
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/draw"
+	"image/png"
+	"io"
+	"vertigo/pkg/config"
+)
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+type chunk struct {
+	kind string
+	data []byte
+}
+
+// encodeAPNG encodes every frame with image/png and reassembles their
+// chunks into an animated PNG: the first frame's IDAT chunks stay the image
+// viewers without APNG support show, the others become fdAT chunks.
+func encodeAPNG(w io.Writer, frames []image.Image, cfg config.Animation) error {
+	var out bytes.Buffer
+	out.Write(pngSignature)
+
+	var ihdr []byte
+	sequence := uint32(0)
+	for i, frame := range frames {
+		chunks, err := encodePNG(frame)
+		if err != nil {
+			return fmt.Errorf("error encoding frame %d: %v", i+1, err)
+		}
+
+		header := chunks[0].data
+		if i == 0 {
+			ihdr = header
+			writeChunk(&out, "IHDR", ihdr)
+			actl := make([]byte, 8)
+			binary.BigEndian.PutUint32(actl[0:], uint32(len(frames)))
+			// 0 plays loops forever.
+			binary.BigEndian.PutUint32(actl[4:], 0)
+			writeChunk(&out, "acTL", actl)
+		} else if !bytes.Equal(header, ihdr) {
+			return fmt.Errorf("frame %d differs in size or colour type from the first", i+1)
+		}
+
+		writeChunk(&out, "fcTL", frameControl(sequence, frame.Bounds(), cfg))
+		sequence++
+		for _, c := range chunks {
+			if c.kind != "IDAT" {
+				continue
+			}
+			if i == 0 {
+				writeChunk(&out, "IDAT", c.data)
+				continue
+			}
+			fdat := make([]byte, 4+len(c.data))
+			binary.BigEndian.PutUint32(fdat, sequence)
+			copy(fdat[4:], c.data)
+			writeChunk(&out, "fdAT", fdat)
+			sequence++
+		}
+	}
+	writeChunk(&out, "IEND", nil)
+
+	if _, err := w.Write(out.Bytes()); err != nil {
+		return fmt.Errorf("error writing APNG: %v", err)
+	}
+	return nil
+}
+
+// encodePNG encodes frame as a non-paletted PNG, so all frames share the
+// same IHDR, and returns its chunks.
+func encodePNG(frame image.Image) ([]chunk, error) {
+	nrgba := image.NewNRGBA(image.Rect(0, 0, frame.Bounds().Dx(), frame.Bounds().Dy()))
+	draw.Draw(nrgba, nrgba.Bounds(), frame, frame.Bounds().Min, draw.Src)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, nrgba); err != nil {
+		return nil, err
+	}
+	return readChunks(buf.Bytes())
+}
+
+func readChunks(data []byte) ([]chunk, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, errors.New("not a PNG")
+	}
+	data = data[len(pngSignature):]
+	var chunks []chunk
+	for len(data) >= 12 {
+		length := binary.BigEndian.Uint32(data)
+		if uint64(len(data)) < 12+uint64(length) {
+			return nil, errors.New("truncated PNG chunk")
+		}
+		chunks = append(chunks, chunk{kind: string(data[4:8]), data: data[8 : 8+length]})
+		data = data[12+length:]
+	}
+	if len(chunks) == 0 || chunks[0].kind != "IHDR" {
+		return nil, errors.New("PNG without IHDR")
+	}
+	return chunks, nil
+}
+
+func frameControl(sequence uint32, bounds image.Rectangle, cfg config.Animation) []byte {
+	fctl := make([]byte, 26)
+	binary.BigEndian.PutUint32(fctl[0:], sequence)
+	binary.BigEndian.PutUint32(fctl[4:], uint32(bounds.Dx()))
+	binary.BigEndian.PutUint32(fctl[8:], uint32(bounds.Dy()))
+	// The offsets at 12 and 16 stay 0, every frame covers the whole image.
+	binary.BigEndian.PutUint16(fctl[20:], uint16(cfg.Delay.Milliseconds()))
+	binary.BigEndian.PutUint16(fctl[22:], 1000)
+	// Dispose and blend at 24 and 25 stay 0: keep the frame, replace the
+	// pixels.
+	return fctl
+}
+
+func writeChunk(w *bytes.Buffer, kind string, data []byte) {
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(data)))
+	w.Write(length[:])
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(kind))
+	crc.Write(data)
+	w.WriteString(kind)
+	w.Write(data)
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc.Sum32())
+	w.Write(sum[:])
+}
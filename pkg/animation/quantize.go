@@ -0,0 +1,139 @@
+package animation
+
+import (
+	"image"
+	"image/color"
+	"sort"
+)
+
+// maxSamples bounds how many pixels of all frames are looked at.
+const maxSamples = 1 << 20
+
+// bucket holds the pixels that fall into one cell of a 5 bit per channel
+// histogram.
+type bucket struct {
+	rgb     [3]uint8
+	count   int
+	r, g, b int
+}
+
+// quantize picks up to n colours for the opaque pixels of all frames with
+// median cut. One palette for the whole animation keeps the colours from
+// flickering between frames.
+func quantize(frames []image.Image, n int) color.Palette {
+	pixels := 0
+	for _, frame := range frames {
+		pixels += frame.Bounds().Dx() * frame.Bounds().Dy()
+	}
+	step := pixels/maxSamples + 1
+
+	histogram := make(map[[3]uint8]*bucket)
+	for _, frame := range frames {
+		b := frame.Bounds()
+		i := 0
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				i++
+				if i%step != 0 {
+					continue
+				}
+				r, g, bl, a := frame.At(x, y).RGBA()
+				if a < 0x8000 {
+					continue
+				}
+				key := [3]uint8{uint8(r >> 11), uint8(g >> 11), uint8(bl >> 11)}
+				bk := histogram[key]
+				if bk == nil {
+					bk = &bucket{rgb: key}
+					histogram[key] = bk
+				}
+				bk.count++
+				bk.r += int(r >> 8)
+				bk.g += int(g >> 8)
+				bk.b += int(bl >> 8)
+			}
+		}
+	}
+
+	all := make([]*bucket, 0, len(histogram))
+	for _, bk := range histogram {
+		all = append(all, bk)
+	}
+	boxes := [][]*bucket{all}
+	for len(boxes) < n {
+		// Split the box that spans the widest range of a channel.
+		widest, channel, span := -1, 0, 0
+		for i, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+			if c, s := widestChannel(box); s > span {
+				widest, channel, span = i, c, s
+			}
+		}
+		if widest < 0 {
+			break
+		}
+		low, high := split(boxes[widest], channel)
+		boxes[widest] = low
+		boxes = append(boxes, high)
+	}
+
+	palette := make(color.Palette, 0, len(boxes))
+	for _, box := range boxes {
+		if len(box) == 0 {
+			continue
+		}
+		var count, r, g, b int
+		for _, bk := range box {
+			count += bk.count
+			r += bk.r
+			g += bk.g
+			b += bk.b
+		}
+		palette = append(palette, color.RGBA{uint8(r / count), uint8(g / count), uint8(b / count), 0xff})
+	}
+	if len(palette) == 0 {
+		palette = append(palette, color.RGBA{0xff, 0xff, 0xff, 0xff})
+	}
+	return palette
+}
+
+func widestChannel(box []*bucket) (int, int) {
+	channel, span := 0, -1
+	for c := 0; c < 3; c++ {
+		lo, hi := uint8(255), uint8(0)
+		for _, bk := range box {
+			if bk.rgb[c] < lo {
+				lo = bk.rgb[c]
+			}
+			if bk.rgb[c] > hi {
+				hi = bk.rgb[c]
+			}
+		}
+		if s := int(hi) - int(lo); s > span {
+			channel, span = c, s
+		}
+	}
+	return channel, span
+}
+
+// split cuts box at the median pixel along channel.
+func split(box []*bucket, channel int) ([]*bucket, []*bucket) {
+	sort.Slice(box, func(i, j int) bool { return box[i].rgb[channel] < box[j].rgb[channel] })
+	total := 0
+	for _, bk := range box {
+		total += bk.count
+	}
+	seen := 0
+	for i, bk := range box {
+		seen += bk.count
+		if seen*2 >= total {
+			if i == len(box)-1 {
+				i--
+			}
+			return box[:i+1], box[i+1:]
+		}
+	}
+	return box[:len(box)/2], box[len(box)/2:]
+}
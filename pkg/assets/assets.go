@@ -0,0 +1,617 @@
+// Package assets reconciles the pictures of the collection in the three
+// places they are kept: the img_data tree, the blob store and the URLs in
+// the database.
+package assets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"vertigo/pkg/animation"
+	"vertigo/pkg/blobstore"
+	"vertigo/pkg/config"
+	"vertigo/pkg/database"
+	"vertigo/pkg/imaging"
+)
+
+// MainFile is the picture of a shoe, next to it are its renditions and the
+// spinning shoe.
+const MainFile = "main.png"
+
+// gcGrace keeps gc away from files that may belong to a shoe or picture
+// being onboarded right now, before its row exists.
+const gcGrace = time.Hour
+
+// SpinningFile is the name of the spinning shoe in format.
+func SpinningFile(format string) string {
+	return "spinning" + animation.Extension(format)
+}
+
+// Files returns the files published for a shoe: main.png, its renditions
+// and the spinning shoe.
+func Files(spinningFile string) []string {
+	files := []string{MainFile}
+	for _, rendition := range imaging.Renditions {
+		if rendition.Width > 0 {
+			files = append(files, imaging.RenditionPath(MainFile, rendition))
+		}
+	}
+	return append(files, spinningFile)
+}
+
+// Key is the blob key of a file of a shoe.
+func Key(productName, file string) string {
+	return blobstore.ShoeKey(productName, file)
+}
+
+// Issue is something out of sync. Fixed is set once sync or gc resolved it.
+type Issue struct {
+	Path    string
+	Problem string
+	Fixed   bool
+}
+
+func (i Issue) String() string {
+	if i.Fixed {
+		return fmt.Sprintf("%s: %s (fixed)", i.Path, i.Problem)
+	}
+	return fmt.Sprintf("%s: %s", i.Path, i.Problem)
+}
+
+// Unresolved counts the issues that are not fixed.
+func Unresolved(issues []Issue) int {
+	n := 0
+	for _, issue := range issues {
+		if !issue.Fixed {
+			n++
+		}
+	}
+	return n
+}
+
+type Reconciler struct {
+	Store database.AssetStore
+	Blobs blobstore.BlobStore
+	// ShoeDir holds a folder per shoe, EntryDirs the pictures of the entries.
+	ShoeDir   string
+	EntryDirs []string
+	// Format is the animation format new spinning shoes are made in.
+	Format string
+	// DryRun makes Sync and GC report what they would do.
+	DryRun bool
+	now    func() time.Time
+}
+
+func New(cfg *config.Config, store database.AssetStore, blobs blobstore.BlobStore) *Reconciler {
+	return &Reconciler{
+		Store:     store,
+		Blobs:     blobs,
+		ShoeDir:   cfg.ShoeImageDir(),
+		EntryDirs: entryDirs(cfg),
+		Format:    cfg.Animation.Format,
+		now:       time.Now,
+	}
+}
+
+// entryDirs returns the picture folders of the shoe and food entries and of
+// the entries of the other categories.
+func entryDirs(cfg *config.Config) []string {
+	dirs := []string{cfg.ShoentryImageDir(), cfg.FoodImageDir()}
+	types, _ := os.ReadDir(cfg.EntriesImageDir())
+	for _, entry := range types {
+		if entry.IsDir() {
+			dirs = append(dirs, filepath.Join(cfg.EntriesImageDir(), entry.Name()))
+		}
+	}
+	return dirs
+}
+
+// Verify reports every shoe whose files are missing on disk or in the blob
+// store, differ between the two or whose URLs in the database are stale.
+func (r *Reconciler) Verify(ctx context.Context) ([]Issue, error) {
+	return r.check(ctx, false)
+}
+
+// Sync moves folders and blobs in the legacy layout to the current one,
+// uploads the files the blob store is missing, downloads the ones missing on disk,
+// renders missing renditions and rewrites stale URLs.
+func (r *Reconciler) Sync(ctx context.Context) ([]Issue, error) {
+	return r.check(ctx, !r.DryRun)
+}
+
+func (r *Reconciler) check(ctx context.Context, fix bool) ([]Issue, error) {
+	shoes, err := r.Store.ListShoeAssets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	legacy, err := r.legacyBlobs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var issues []Issue
+	for _, shoe := range shoes {
+		if err := ctx.Err(); err != nil {
+			return issues, err
+		}
+		shoeIssues, err := r.checkShoe(ctx, shoe, legacy[shoe.ProductName], fix)
+		issues = append(issues, shoeIssues...)
+		if err != nil {
+			return issues, fmt.Errorf("error syncing %s: %v", shoe.ProductName, err)
+		}
+	}
+	return issues, nil
+}
+
+func (r *Reconciler) checkShoe(ctx context.Context, shoe database.ShoeAsset, legacyBlobs []blobstore.Info, fix bool) ([]Issue, error) {
+	var issues []Issue
+	report := func(file, problem string, fixed bool) {
+		issues = append(issues, Issue{Path: Key(shoe.ProductName, file), Problem: problem, Fixed: fixed})
+	}
+	dir := filepath.Join(r.ShoeDir, shoe.ProductName)
+
+	for _, legacy := range legacyFiles(shoe.ProductName) {
+		from, to := filepath.Join(dir, legacy.from), filepath.Join(dir, legacy.to)
+		if !exists(from) || exists(to) {
+			continue
+		}
+		if fix {
+			if err := os.Rename(from, to); err != nil {
+				return issues, err
+			}
+			os.Remove(filepath.Dir(from))
+		}
+		report(legacy.from, "legacy layout, belongs at "+legacy.to, fix)
+	}
+	for _, blob := range legacyBlobs {
+		_, file, _ := strings.Cut(blob.Key, "/")
+		if fix {
+			if err := r.moveBlob(ctx, blob, Key(shoe.ProductName, file)); err != nil {
+				return issues, err
+			}
+		}
+		report(file, "legacy key "+blob.Key, fix)
+	}
+
+	spinningFile, err := r.spinningFile(ctx, shoe.ProductName, dir)
+	if err != nil {
+		return issues, err
+	}
+	files := Files(spinningFile)
+	if shoe.SpinningGifURL == "" && !r.available(ctx, dir, shoe.ProductName, spinningFile) {
+		// Shoes entered by hand have no spinning shoe.
+		files = files[:len(files)-1]
+	}
+	for _, file := range files {
+		path := filepath.Join(dir, file)
+		rendition, isRendition := renditionOf(file)
+		if isRendition && exists(filepath.Join(dir, MainFile)) && !r.available(ctx, dir, shoe.ProductName, file) {
+			if fix {
+				if err := renderRendition(filepath.Join(dir, MainFile), path, rendition); err != nil {
+					return issues, err
+				}
+			}
+			report(file, "rendition missing", fix)
+			if !fix {
+				continue
+			}
+		}
+		problem, err := r.syncFile(ctx, Key(shoe.ProductName, file), path, fix)
+		if err != nil {
+			return issues, err
+		}
+		if problem != "" {
+			report(file, problem, fix && problem != problemMissing)
+		}
+	}
+
+	if shoe.Deleted {
+		return issues, nil
+	}
+	mainURL, spinningURL := shoe.MainPicture, shoe.SpinningGifURL
+	if r.available(ctx, dir, shoe.ProductName, MainFile) {
+		mainURL = r.Blobs.URL(Key(shoe.ProductName, MainFile))
+	}
+	if r.available(ctx, dir, shoe.ProductName, spinningFile) {
+		spinningURL = r.Blobs.URL(Key(shoe.ProductName, spinningFile))
+	}
+	if mainURL == shoe.MainPicture && spinningURL == shoe.SpinningGifURL {
+		return issues, nil
+	}
+	if fix {
+		if err := r.Store.UpdateShoeAssets(ctx, shoe.ID, mainURL, spinningURL); err != nil {
+			return issues, err
+		}
+	}
+	if mainURL != shoe.MainPicture {
+		report(MainFile, fmt.Sprintf("stale URL %q, should be %q", shoe.MainPicture, mainURL), fix)
+	}
+	if spinningURL != shoe.SpinningGifURL {
+		report(spinningFile, fmt.Sprintf("stale URL %q, should be %q", shoe.SpinningGifURL, spinningURL), fix)
+	}
+	return issues, nil
+}
+
+// legacyBlobs returns the blobs older versions published at the root of
+// the bucket, like <ProductName>/main.png, by product name. Other keys at
+// the root aren't vertigo's and are left alone.
+func (r *Reconciler) legacyBlobs(ctx context.Context) (map[string][]blobstore.Info, error) {
+	blobs, err := r.Blobs.List(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]bool)
+	for _, file := range append(Files(SpinningFile(animation.FormatGIF)), SpinningFile(animation.FormatAPNG)) {
+		files[file] = true
+	}
+	legacy := make(map[string][]blobstore.Info)
+	for _, blob := range blobs {
+		productName, file, ok := strings.Cut(blob.Key, "/")
+		if !ok || productName+"/" == blobstore.ShoePrefix || !files[file] {
+			continue
+		}
+		legacy[productName] = append(legacy[productName], blob)
+	}
+	return legacy, nil
+}
+
+// moveBlob moves blob to key. A blob already at key was published by the
+// current layout and wins.
+func (r *Reconciler) moveBlob(ctx context.Context, blob blobstore.Info, key string) error {
+	_, err := r.Blobs.Stat(ctx, key)
+	if errors.Is(err, blobstore.ErrNotExist) {
+		err = r.copyBlob(ctx, blob, key)
+	}
+	if err != nil {
+		return err
+	}
+	return r.Blobs.Delete(ctx, blob.Key)
+}
+
+func (r *Reconciler) copyBlob(ctx context.Context, blob blobstore.Info, key string) error {
+	in, err := r.Blobs.Open(ctx, blob.Key)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if _, err := r.Blobs.Put(ctx, key, in, blob.ContentType); err != nil {
+		return fmt.Errorf("error copying %s to %s: %v", blob.Key, key, err)
+	}
+	return nil
+}
+
+type legacyFile struct {
+	from, to string
+}
+
+// legacyFiles are where older versions kept main.png and the spinning shoe.
+func legacyFiles(productName string) []legacyFile {
+	return []legacyFile{
+		{filepath.Join("img", "MAIN.png"), MainFile},
+		{filepath.Join("gif", productName+".gif"), SpinningFile(animation.FormatGIF)},
+	}
+}
+
+// spinningFile returns the spinning shoe in the configured format, or in the
+// other one when only that exists.
+func (r *Reconciler) spinningFile(ctx context.Context, productName, dir string) (string, error) {
+	preferred := SpinningFile(r.Format)
+	for _, format := range []string{r.Format, animation.FormatGIF, animation.FormatAPNG} {
+		file := SpinningFile(format)
+		if exists(filepath.Join(dir, file)) {
+			return file, nil
+		}
+		_, err := r.Blobs.Stat(ctx, Key(productName, file))
+		if err == nil {
+			return file, nil
+		}
+		if !errors.Is(err, blobstore.ErrNotExist) {
+			return "", err
+		}
+	}
+	return preferred, nil
+}
+
+const problemMissing = "missing on disk and in the blob store"
+
+// syncFile compares the file at path with the blob at key. The copy on disk
+// wins, it is what the pictures are made from.
+func (r *Reconciler) syncFile(ctx context.Context, key, path string, fix bool) (string, error) {
+	local, localErr := os.Stat(path)
+	if localErr != nil && !os.IsNotExist(localErr) {
+		return "", localErr
+	}
+	blob, blobErr := r.Blobs.Stat(ctx, key)
+	if blobErr != nil && !errors.Is(blobErr, blobstore.ErrNotExist) {
+		return "", blobErr
+	}
+
+	switch {
+	case localErr == nil && blobErr == nil && local.Size() == blob.Size:
+		return "", nil
+	case localErr == nil:
+		if fix {
+			if _, err := blobstore.PutFile(ctx, r.Blobs, key, path); err != nil {
+				return "", err
+			}
+		}
+		if blobErr == nil {
+			return fmt.Sprintf("blob is %d bytes, the file %d", blob.Size, local.Size()), nil
+		}
+		return "missing in the blob store", nil
+	case blobErr == nil:
+		if fix {
+			if err := r.download(ctx, key, path); err != nil {
+				return "", err
+			}
+		}
+		return "missing on disk", nil
+	default:
+		return problemMissing, nil
+	}
+}
+
+func (r *Reconciler) download(ctx context.Context, key, path string) error {
+	blob, err := r.Blobs.Open(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer blob.Close()
+
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	out, err := os.Create(path + ".part")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, blob)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(path+".part", path)
+	}
+	if err != nil {
+		os.Remove(path + ".part")
+		return fmt.Errorf("error downloading %s: %v", key, err)
+	}
+	return nil
+}
+
+// available reports whether the file is published or can be once synced.
+func (r *Reconciler) available(ctx context.Context, dir, productName, file string) bool {
+	if exists(filepath.Join(dir, file)) {
+		return true
+	}
+	_, err := r.Blobs.Stat(ctx, Key(productName, file))
+	return err == nil
+}
+
+// renditionOf returns the rendition file is, other than the full one.
+func renditionOf(file string) (imaging.Rendition, bool) {
+	for _, rendition := range imaging.Renditions {
+		if rendition.Width > 0 && imaging.RenditionPath(MainFile, rendition) == file {
+			return rendition, true
+		}
+	}
+	return imaging.Rendition{}, false
+}
+
+func renderRendition(mainPath, path string, rendition imaging.Rendition) error {
+	file, err := os.Open(mainPath)
+	if err != nil {
+		return err
+	}
+	img, _, err := image.Decode(file)
+	file.Close()
+	if err != nil {
+		return fmt.Errorf("error decoding %s: %v", mainPath, err)
+	}
+	return imaging.WritePNG(path, imaging.Resize(img, rendition.Width))
+}
+
+// GC deletes what no row refers to any more: the folders and blobs of shoes
+// that were purged, the frames and partial files a finished download left
+// behind, legacy copies on disk and in the bucket that sync already moved
+// and entry pictures without a picture row. Deleted rows still own their files until they are purged.
+func (r *Reconciler) GC(ctx context.Context) ([]Issue, error) {
+	shoes, err := r.Store.ListShoeAssets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool, len(shoes))
+	for _, shoe := range shoes {
+		known[shoe.ProductName] = true
+	}
+
+	var issues []Issue
+	remove := func(path, problem string, fn func() error) error {
+		fixed := false
+		if !r.DryRun {
+			if err := fn(); err != nil {
+				return err
+			}
+			fixed = true
+		}
+		issues = append(issues, Issue{Path: path, Problem: problem, Fixed: fixed})
+		return nil
+	}
+
+	// Folders of shoes without a row. One modified lately may belong to a
+	// shoe whose row is not inserted yet, as may its blobs.
+	recent := make(map[string]bool)
+	dirs, err := os.ReadDir(r.ShoeDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, entry := range dirs {
+		name := entry.Name()
+		path := filepath.Join(r.ShoeDir, name)
+		if !entry.IsDir() || known[name] {
+			continue
+		}
+		if r.recent(entry) {
+			recent[name] = true
+			continue
+		}
+		if err := remove(path, "folder of a shoe that doesn't exist", func() error { return os.RemoveAll(path) }); err != nil {
+			return issues, err
+		}
+	}
+
+	for _, shoe := range shoes {
+		dir := filepath.Join(r.ShoeDir, shoe.ProductName)
+		for _, path := range leftovers(dir, shoe.ProductName) {
+			if err := remove(path, "left over", func() error { return removeFileAndEmptyDir(path, dir) }); err != nil {
+				return issues, err
+			}
+		}
+	}
+
+	// Only the keys below the prefix are vertigo's, the bucket may hold
+	// anything else.
+	blobs, err := r.Blobs.List(ctx, blobstore.ShoePrefix)
+	if err != nil {
+		return issues, err
+	}
+	for _, blob := range blobs {
+		productName, _, ok := strings.Cut(strings.TrimPrefix(blob.Key, blobstore.ShoePrefix), "/")
+		if !ok || known[productName] || recent[productName] {
+			continue
+		}
+		key := blob.Key
+		if err := remove(key, "blob of a shoe that doesn't exist", func() error { return r.Blobs.Delete(ctx, key) }); err != nil {
+			return issues, err
+		}
+	}
+
+	// Blobs in the legacy layout at the root of the bucket. Those of an
+	// existing shoe are left to sync until it has moved them.
+	legacy, err := r.legacyBlobs(ctx)
+	if err != nil {
+		return issues, err
+	}
+	productNames := make([]string, 0, len(legacy))
+	for productName := range legacy {
+		productNames = append(productNames, productName)
+	}
+	sort.Strings(productNames)
+	for _, productName := range productNames {
+		if recent[productName] {
+			continue
+		}
+		for _, blob := range legacy[productName] {
+			key, problem := blob.Key, "legacy blob of a shoe that doesn't exist"
+			if known[productName] {
+				_, file, _ := strings.Cut(key, "/")
+				_, err := r.Blobs.Stat(ctx, Key(productName, file))
+				if errors.Is(err, blobstore.ErrNotExist) {
+					continue
+				}
+				if err != nil {
+					return issues, err
+				}
+				problem = "legacy copy of " + Key(productName, file)
+			}
+			if err := remove(key, problem, func() error { return r.Blobs.Delete(ctx, key) }); err != nil {
+				return issues, err
+			}
+		}
+	}
+
+	locations, err := r.Store.ListPictureLocations(ctx)
+	if err != nil {
+		return issues, err
+	}
+	referenced := make(map[string]bool, len(locations))
+	for _, location := range locations {
+		referenced[absPath(location)] = true
+	}
+	for _, dir := range r.EntryDirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil && !os.IsNotExist(err) {
+			return issues, err
+		}
+		for _, entry := range entries {
+			path := filepath.Join(dir, entry.Name())
+			if entry.IsDir() || referenced[absPath(path)] || r.recent(entry) {
+				continue
+			}
+			if err := remove(path, "no picture refers to it", func() error { return os.Remove(path) }); err != nil {
+				return issues, err
+			}
+		}
+	}
+	return issues, nil
+}
+
+// leftovers returns what a finished download or an older layout left in
+// the folder of a shoe. While the spinning shoe is missing the frames are
+// kept, they belong to a download that can be resumed.
+func leftovers(dir, productName string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	finished := false
+	for _, format := range []string{animation.FormatGIF, animation.FormatAPNG} {
+		finished = finished || exists(filepath.Join(dir, SpinningFile(format)))
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		name := entry.Name()
+		var frame int
+		_, frameErr := fmt.Sscanf(name, "%d.jpg", &frame)
+		switch {
+		case entry.IsDir():
+		case strings.HasSuffix(name, ".part") || strings.HasSuffix(name, ".tmp"):
+			paths = append(paths, filepath.Join(dir, name))
+		case finished && (frameErr == nil || name == "manifest.json"):
+			paths = append(paths, filepath.Join(dir, name))
+		}
+	}
+	for _, legacy := range legacyFiles(productName) {
+		if exists(filepath.Join(dir, legacy.from)) && exists(filepath.Join(dir, legacy.to)) {
+			paths = append(paths, filepath.Join(dir, legacy.from))
+		}
+	}
+	return paths
+}
+
+func (r *Reconciler) recent(entry os.DirEntry) bool {
+	info, err := entry.Info()
+	return err == nil && r.now().Sub(info.ModTime()) < gcGrace
+}
+
+// removeFileAndEmptyDir removes path and its folder when that is left empty
+// and isn't dir itself.
+func removeFileAndEmptyDir(path, dir string) error {
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	if parent := filepath.Dir(path); parent != filepath.Clean(dir) {
+		os.Remove(parent)
+	}
+	return nil
+}
+
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,227 @@
+package assets
+
+import (
+	"context"
+	"errors"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"vertigo/pkg/blobstore"
+	"vertigo/pkg/database"
+	"vertigo/pkg/imaging"
+	"vertigo/pkg/stockx"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	shoeDir := filepath.Join(dir, "shoes")
+	entryDir := filepath.Join(dir, "shoentries")
+
+	store := database.NewMemoryStore()
+	for _, name := range []string{"Legacy-Shoe", "Current-Shoe"} {
+		if err := store.InsertShoe(ctx, stockx.ProductDetails{Name: name, ProductName: name, MainPicture: "https://images.stockx.com/" + name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := store.InsertPicture(ctx, filepath.Join(entryDir, "kept.jpg"), "", "", 0, 0, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	blobs := blobstore.NewMemory("")
+
+	// Legacy-Shoe is in the old layout, with the frames of its download left
+	// over. Current-Shoe was only uploaded, Gone-Shoe was purged.
+	mainPath := filepath.Join(shoeDir, "Legacy-Shoe", "img", "MAIN.png")
+	os.MkdirAll(filepath.Dir(mainPath), os.ModePerm)
+	if err := imaging.WritePNG(mainPath, image.NewNRGBA(image.Rect(0, 0, 800, 600))); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(shoeDir, "Legacy-Shoe", "gif", "Legacy-Shoe.gif"), "GIF89a")
+	writeFile(t, filepath.Join(shoeDir, "Legacy-Shoe", "01.jpg"), "frame")
+	writeFile(t, filepath.Join(shoeDir, "Legacy-Shoe", "manifest.json"), "{}")
+	if _, err := blobs.Put(ctx, Key("Current-Shoe", "spinning.gif"), strings.NewReader("GIF89a"), ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := imaging.WritePNG(filepath.Join(dir, "current.png"), image.NewNRGBA(image.Rect(0, 0, 400, 300))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := blobstore.PutFile(ctx, blobs, Key("Current-Shoe", MainFile), filepath.Join(dir, "current.png")); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(shoeDir, "Gone-Shoe", "main.png"), "gone")
+	blobs.Put(ctx, Key("Gone-Shoe", "main.png"), strings.NewReader("gone"), "")
+	// Blobs outside the shoes prefix are not vertigo's to delete.
+	blobs.Put(ctx, "backups/vertigo.db", strings.NewReader("backup"), "")
+	writeFile(t, filepath.Join(entryDir, "kept.jpg"), "entry")
+	writeFile(t, filepath.Join(entryDir, "orphan.jpg"), "entry")
+
+	r := &Reconciler{
+		Store:     store,
+		Blobs:     blobs,
+		ShoeDir:   shoeDir,
+		EntryDirs: []string{entryDir},
+		Format:    "gif",
+		now:       func() time.Time { return time.Now().Add(2 * gcGrace) },
+	}
+
+	issues, err := r.Verify(ctx)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if len(issues) == 0 || Unresolved(issues) != len(issues) {
+		t.Fatalf("Expected unresolved issues, got %v", issues)
+	}
+	if _, err := os.Stat(mainPath); err != nil {
+		t.Fatalf("Verify must not change anything: %v", err)
+	}
+
+	issues, err = r.Sync(ctx)
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if Unresolved(issues) != 0 {
+		t.Fatalf("Expected sync to resolve everything, got %v", issues)
+	}
+	for _, name := range []string{"Legacy-Shoe", "Current-Shoe"} {
+		for _, file := range Files("spinning.gif") {
+			if _, err := os.Stat(filepath.Join(shoeDir, name, file)); err != nil {
+				t.Errorf("Expected %s/%s on disk: %v", name, file, err)
+			}
+			if _, err := blobs.Stat(ctx, Key(name, file)); err != nil {
+				t.Errorf("Expected %s/%s in the blob store: %v", name, file, err)
+			}
+		}
+	}
+	shoes, _ := store.ListShoeAssets(ctx)
+	for _, shoe := range shoes {
+		if shoe.MainPicture != "memory://shoes/"+shoe.ProductName+"/main.png" || shoe.SpinningGifURL != "memory://shoes/"+shoe.ProductName+"/spinning.gif" {
+			t.Errorf("Expected the URLs of %s to be rewritten, got %+v", shoe.ProductName, shoe)
+		}
+	}
+	if issues, err := r.Verify(ctx); err != nil || len(issues) != 0 {
+		t.Fatalf("Expected verify to pass after sync, got %v, %v", issues, err)
+	}
+
+	r.DryRun = true
+	if issues, err := r.GC(ctx); err != nil || len(issues) != 5 || Unresolved(issues) != 5 {
+		t.Fatalf("Expected 5 orphans in the dry run, got %v, %v", issues, err)
+	}
+	r.DryRun = false
+	if _, err := r.GC(ctx); err != nil {
+		t.Fatalf("GC failed: %v", err)
+	}
+	for _, path := range []string{
+		filepath.Join(shoeDir, "Gone-Shoe"),
+		filepath.Join(shoeDir, "Legacy-Shoe", "01.jpg"),
+		filepath.Join(shoeDir, "Legacy-Shoe", "manifest.json"),
+		filepath.Join(entryDir, "orphan.jpg"),
+	} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be deleted", path)
+		}
+	}
+	if _, err := blobs.Stat(ctx, Key("Gone-Shoe", "main.png")); !errors.Is(err, blobstore.ErrNotExist) {
+		t.Errorf("Expected the blob of Gone-Shoe to be deleted, got %v", err)
+	}
+	if _, err := blobs.Stat(ctx, "backups/vertigo.db"); err != nil {
+		t.Errorf("Expected the blob outside the shoes prefix to be kept: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(entryDir, "kept.jpg")); err != nil {
+		t.Errorf("Expected the referenced picture to be kept: %v", err)
+	}
+}
+
+func TestLegacyBlobs(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	shoeDir := filepath.Join(dir, "shoes")
+
+	store := database.NewMemoryStore()
+	if err := store.InsertShoe(ctx, stockx.ProductDetails{Name: "R2 Shoe", ProductName: "R2-Shoe", MainPicture: "memory://R2-Shoe/main.png"}); err != nil {
+		t.Fatal(err)
+	}
+	blobs := blobstore.NewMemory("")
+
+	// R2-Shoe was uploaded by a version that kept the files at the root of
+	// the bucket, as was Gone-Shoe before it was purged.
+	mainPath := filepath.Join(dir, "main.png")
+	if err := imaging.WritePNG(mainPath, image.NewNRGBA(image.Rect(0, 0, 800, 600))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := blobstore.PutFile(ctx, blobs, "R2-Shoe/main.png", mainPath); err != nil {
+		t.Fatal(err)
+	}
+	blobs.Put(ctx, "R2-Shoe/spinning.gif", strings.NewReader("GIF89a"), "")
+	blobs.Put(ctx, "Gone-Shoe/main.png", strings.NewReader("gone"), "")
+	blobs.Put(ctx, "photos/holiday.jpg", strings.NewReader("photo"), "")
+
+	r := &Reconciler{
+		Store:   store,
+		Blobs:   blobs,
+		ShoeDir: shoeDir,
+		Format:  "gif",
+		now:     func() time.Time { return time.Now().Add(2 * gcGrace) },
+	}
+
+	issues, err := r.Verify(ctx)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	legacy := 0
+	for _, issue := range issues {
+		if strings.HasPrefix(issue.Problem, "legacy key R2-Shoe/") {
+			legacy++
+		}
+	}
+	if legacy != 2 {
+		t.Fatalf("Expected both legacy keys to be reported, got %v", issues)
+	}
+
+	if issues, err := r.Sync(ctx); err != nil || Unresolved(issues) != 0 {
+		t.Fatalf("Expected sync to resolve everything, got %v, %v", issues, err)
+	}
+	for _, file := range []string{MainFile, "spinning.gif"} {
+		if _, err := blobs.Stat(ctx, Key("R2-Shoe", file)); err != nil {
+			t.Errorf("Expected %s to be moved: %v", file, err)
+		}
+		if _, err := blobs.Stat(ctx, "R2-Shoe/"+file); !errors.Is(err, blobstore.ErrNotExist) {
+			t.Errorf("Expected the legacy key of %s to be deleted, got %v", file, err)
+		}
+		if _, err := os.Stat(filepath.Join(shoeDir, "R2-Shoe", file)); err != nil {
+			t.Errorf("Expected %s on disk: %v", file, err)
+		}
+	}
+	if shoe, _ := store.GetShoeByProductName(ctx, "R2-Shoe"); shoe == nil || shoe.MainPicture != "memory://shoes/R2-Shoe/main.png" {
+		t.Errorf("Expected the URL to be rewritten, got %+v", shoe)
+	}
+
+	// A copy left behind by an interrupted sync.
+	blobs.Put(ctx, "R2-Shoe/main.png", strings.NewReader("stale"), "")
+	issues, err = r.GC(ctx)
+	if err != nil || len(issues) != 2 {
+		t.Fatalf("Expected the legacy copy and the blob of Gone-Shoe to be collected, got %v, %v", issues, err)
+	}
+	for _, key := range []string{"R2-Shoe/main.png", "Gone-Shoe/main.png"} {
+		if _, err := blobs.Stat(ctx, key); !errors.Is(err, blobstore.ErrNotExist) {
+			t.Errorf("Expected %s to be deleted, got %v", key, err)
+		}
+	}
+	for _, key := range []string{Key("R2-Shoe", MainFile), "photos/holiday.jpg"} {
+		if _, err := blobs.Stat(ctx, key); err != nil {
+			t.Errorf("Expected %s to be kept: %v", key, err)
+		}
+	}
+}
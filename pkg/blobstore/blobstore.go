@@ -0,0 +1,121 @@
+// Package blobstore stores the published pictures behind one interface, in
+// an S3 compatible bucket such as R2 or MinIO, in a local directory or in
+// memory.
+package blobstore
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"vertigo/pkg/config"
+)
+
+var ErrNotExist = errors.New("blob does not exist")
+
+type Info struct {
+	Key         string
+	Size        int64
+	ContentType string
+}
+
+type BlobStore interface {
+	// Put streams r to key and returns the public URL of the blob. An
+	// empty contentType is detected from the key and the first bytes.
+	Put(ctx context.Context, key string, r io.Reader, contentType string) (string, error)
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Stat(ctx context.Context, key string) (Info, error)
+	Delete(ctx context.Context, key string) error
+	// List returns the blobs whose keys start with prefix, sorted by key.
+	List(ctx context.Context, prefix string) ([]Info, error)
+	URL(key string) string
+}
+
+// New returns the store cfg selects. Without a backend the bucket is used
+// when an endpoint is configured and the local directory otherwise, so
+// onboarding works offline.
+func New(cfg config.Storage) (BlobStore, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid storage configuration: %v", err)
+	}
+	switch cfg.ResolvedBackend() {
+	case config.StorageLocal:
+		return NewLocal(cfg.LocalDir, cfg.PublicURL)
+	case config.StorageMemory:
+		return NewMemory(cfg.PublicURL), nil
+	default:
+		return NewS3(cfg)
+	}
+}
+
+// ShoePrefix is the part of the bucket vertigo owns, the pictures of a shoe
+// are kept below ShoePrefix<ProductName>/. Older versions kept them below
+// <ProductName>/, assets sync moves those.
+const ShoePrefix = "shoes/"
+
+// ShoeKey is the key of a file of a shoe.
+func ShoeKey(productName, file string) string {
+	return ShoePrefix + productName + "/" + file
+}
+
+// PutFile streams the file at filePath to key.
+func PutFile(ctx context.Context, store BlobStore, key, filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file %q: %v", filePath, err)
+	}
+	defer file.Close()
+	return store.Put(ctx, key, file, "")
+}
+
+// DetectContentType returns the content type of a blob from its key's
+// extension or, failing that, its first 512 bytes. The returned reader
+// still yields all of r.
+func DetectContentType(key string, r io.Reader) (string, io.Reader, error) {
+	if contentType := mime.TypeByExtension(path.Ext(key)); contentType != "" {
+		return contentType, r, nil
+	}
+	br := bufio.NewReaderSize(r, 512)
+	head, err := br.Peek(512)
+	if err != nil && err != io.EOF && !errors.Is(err, bufio.ErrBufferFull) {
+		return "", nil, err
+	}
+	return http.DetectContentType(head), br, nil
+}
+
+// expandURL fills in the placeholders {key}, {bucket}, {endpoint} and {dir}
+// of a public URL template.
+func expandURL(template, key string, vars map[string]string) string {
+	pairs := []string{"{key}", key}
+	for name, value := range vars {
+		pairs = append(pairs, "{"+name+"}", strings.TrimSuffix(value, "/"))
+	}
+	return strings.NewReplacer(pairs...).Replace(template)
+}
+
+// validKey rejects keys that would escape a local directory.
+func validKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return fmt.Errorf("invalid key %q", key)
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == ".." || part == "." || part == "" {
+			return fmt.Errorf("invalid key %q", key)
+		}
+	}
+	return nil
+}
+
+func absDir(dir string) string {
+	if abs, err := filepath.Abs(dir); err == nil {
+		return abs
+	}
+	return dir
+}
@@ -0,0 +1,181 @@
+package blobstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"vertigo/pkg/config"
+)
+
+// fakeS3 implements the part of the S3 API the store uses, path style like
+// MinIO.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string]fakeObject
+}
+
+type fakeObject struct {
+	data        []byte
+	contentType string
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != "vertigo" {
+		http.Error(w, "NoSuchBucket", http.StatusNotFound)
+		return
+	}
+
+	if key == "" && r.Method == http.MethodGet {
+		prefix := r.URL.Query().Get("prefix")
+		var keys []string
+		for k := range f.objects {
+			if strings.HasPrefix(k, prefix) {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		var b strings.Builder
+		fmt.Fprintf(&b, `<ListBucketResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Name>vertigo</Name><KeyCount>%d</KeyCount><IsTruncated>false</IsTruncated>`, len(keys))
+		for _, k := range keys {
+			fmt.Fprintf(&b, "<Contents><Key>%s</Key><Size>%d</Size></Contents>", k, len(f.objects[k].data))
+		}
+		b.WriteString("</ListBucketResult>")
+		w.Header().Set("Content-Type", "application/xml")
+		io.WriteString(w, b.String())
+		return
+	}
+
+	object, ok := f.objects[key]
+	switch r.Method {
+	case http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		f.objects[key] = fakeObject{data: data, contentType: r.Header.Get("Content-Type")}
+		w.Header().Set("ETag", `"etag"`)
+		return
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if !ok {
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(http.StatusNotFound)
+		if r.Method == http.MethodGet {
+			io.WriteString(w, `<Error><Code>NoSuchKey</Code><Message>not found</Message></Error>`)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", object.contentType)
+	w.Header().Set("Content-Length", fmt.Sprint(len(object.data)))
+	if r.Method == http.MethodGet {
+		w.Write(object.data)
+	}
+}
+
+func pngBytes(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatalf("Failed to encode PNG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestStores(t *testing.T) {
+	server := httptest.NewServer(&fakeS3{objects: make(map[string]fakeObject)})
+	defer server.Close()
+
+	stores := []struct {
+		name    string
+		cfg     config.Storage
+		wantURL string
+	}{
+		{"memory", config.Storage{Backend: config.StorageMemory}, "memory://Nike-Mars-Yard/main.png"},
+		{"local", config.Storage{LocalDir: t.TempDir(), PublicURL: "http://localhost:8080/blobs/{key}"}, "http://localhost:8080/blobs/Nike-Mars-Yard/main.png"},
+		{"minio", config.Storage{Backend: config.StorageMinIO, BucketName: "vertigo", Endpoint: server.URL}, server.URL + "/vertigo/Nike-Mars-Yard/main.png"},
+	}
+	for _, tt := range stores {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store, err := New(tt.cfg)
+			if err != nil {
+				t.Fatalf("New failed: %v", err)
+			}
+
+			picture := pngBytes(t)
+			url, err := store.Put(ctx, "Nike-Mars-Yard/main.png", bytes.NewReader(picture), "")
+			if err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+			if url != tt.wantURL || store.URL("Nike-Mars-Yard/main.png") != url {
+				t.Fatalf("Expected the URL %s, got %s", tt.wantURL, url)
+			}
+			// Without an extension the type is sniffed from the content.
+			if _, err := store.Put(ctx, "Nike-Mars-Yard/raw", bytes.NewReader(picture), ""); err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+			if _, err := store.Put(ctx, "Other/main.png", strings.NewReader("other"), "image/png"); err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+
+			for _, key := range []string{"Nike-Mars-Yard/main.png", "Nike-Mars-Yard/raw"} {
+				info, err := store.Stat(ctx, key)
+				if err != nil || info.Size != int64(len(picture)) || info.ContentType != "image/png" {
+					t.Fatalf("Stat %s: unexpected %+v (%v)", key, info, err)
+				}
+			}
+			r, err := store.Open(ctx, "Nike-Mars-Yard/main.png")
+			if err != nil {
+				t.Fatalf("Open failed: %v", err)
+			}
+			data, _ := io.ReadAll(r)
+			r.Close()
+			if !bytes.Equal(data, picture) {
+				t.Fatalf("Expected the stored picture back")
+			}
+
+			infos, err := store.List(ctx, "Nike-Mars-Yard/")
+			if err != nil || len(infos) != 2 || infos[0].Key != "Nike-Mars-Yard/main.png" || infos[1].Key != "Nike-Mars-Yard/raw" {
+				t.Fatalf("Unexpected listing %+v (%v)", infos, err)
+			}
+
+			if err := store.Delete(ctx, "Nike-Mars-Yard/raw"); err != nil {
+				t.Fatalf("Delete failed: %v", err)
+			}
+			if _, err := store.Stat(ctx, "Nike-Mars-Yard/raw"); !errors.Is(err, ErrNotExist) {
+				t.Fatalf("Expected ErrNotExist after Delete, got %v", err)
+			}
+			if _, err := store.Open(ctx, "missing.png"); !errors.Is(err, ErrNotExist) {
+				t.Fatalf("Expected ErrNotExist for a missing blob, got %v", err)
+			}
+			if err := store.Delete(ctx, "missing.png"); !errors.Is(err, ErrNotExist) {
+				t.Fatalf("Expected ErrNotExist deleting a missing blob, got %v", err)
+			}
+			if _, err := store.Put(ctx, "../escape.png", strings.NewReader("x"), ""); err == nil {
+				t.Fatalf("Expected an error for a key leaving the store")
+			}
+		})
+	}
+}
+
+func TestNewValidates(t *testing.T) {
+	if _, err := New(config.Storage{Backend: config.StorageR2, BucketName: "vertigo"}); err == nil {
+		t.Fatalf("Expected R2 without endpoint and credentials to be rejected")
+	}
+	if _, err := New(config.Storage{Backend: "ftp"}); err == nil {
+		t.Fatalf("Expected an unknown backend to be rejected")
+	}
+}
@@ -0,0 +1,136 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const defaultLocalURL = "file://{dir}/{key}"
+
+// LocalStore keeps blobs as files below a directory, bertigo serves them at
+// /blobs/.
+type LocalStore struct {
+	dir         string
+	urlTemplate string
+}
+
+func NewLocal(dir, urlTemplate string) (*LocalStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating blob directory: %v", err)
+	}
+	if urlTemplate == "" {
+		urlTemplate = defaultLocalURL
+	}
+	return &LocalStore{dir: dir, urlTemplate: urlTemplate}, nil
+}
+
+func (s *LocalStore) path(key string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(key))
+}
+
+func (s *LocalStore) Put(ctx context.Context, key string, r io.Reader, contentType string) (string, error) {
+	if err := validKey(key); err != nil {
+		return "", err
+	}
+	path := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to store %s: %v", key, err)
+	}
+	return s.URL(key), nil
+}
+
+func (s *LocalStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	if err := validKey(key); err != nil {
+		return nil, err
+	}
+	file, err := os.Open(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotExist
+	}
+	return file, err
+}
+
+func (s *LocalStore) Stat(ctx context.Context, key string) (Info, error) {
+	file, err := s.Open(ctx, key)
+	if err != nil {
+		return Info{}, err
+	}
+	defer file.Close()
+	info, err := file.(*os.File).Stat()
+	if err != nil {
+		return Info{}, err
+	}
+	contentType, _, err := DetectContentType(key, file)
+	if err != nil {
+		return Info{}, err
+	}
+	return Info{Key: key, Size: info.Size(), ContentType: contentType}, nil
+}
+
+func (s *LocalStore) Delete(ctx context.Context, key string) error {
+	if err := validKey(key); err != nil {
+		return err
+	}
+	err := os.Remove(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotExist
+	}
+	return err
+}
+
+func (s *LocalStore) List(ctx context.Context, prefix string) ([]Info, error) {
+	infos := []Info{}
+	err := filepath.WalkDir(s.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".upload-") {
+			return nil
+		}
+		rel, err := filepath.Rel(s.dir, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		infos = append(infos, Info{Key: key, Size: info.Size()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing blobs: %v", err)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Key < infos[j].Key })
+	return infos, nil
+}
+
+func (s *LocalStore) URL(key string) string {
+	return expandURL(s.urlTemplate, key, map[string]string{"dir": filepath.ToSlash(absDir(s.dir))})
+}
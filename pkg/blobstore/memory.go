@@ -0,0 +1,98 @@
+package blobstore
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const defaultMemoryURL = "memory://{key}"
+
+type memoryBlob struct {
+	data        []byte
+	contentType string
+}
+
+// MemoryStore keeps blobs in memory, for tests.
+type MemoryStore struct {
+	mu          sync.Mutex
+	blobs       map[string]memoryBlob
+	urlTemplate string
+}
+
+func NewMemory(urlTemplate string) *MemoryStore {
+	if urlTemplate == "" {
+		urlTemplate = defaultMemoryURL
+	}
+	return &MemoryStore{blobs: make(map[string]memoryBlob), urlTemplate: urlTemplate}
+}
+
+func (s *MemoryStore) Put(ctx context.Context, key string, r io.Reader, contentType string) (string, error) {
+	if err := validKey(key); err != nil {
+		return "", err
+	}
+	if contentType == "" {
+		var err error
+		if contentType, r, err = DetectContentType(key, r); err != nil {
+			return "", err
+		}
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.blobs[key] = memoryBlob{data: data, contentType: contentType}
+	return s.URL(key), nil
+}
+
+func (s *MemoryStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	blob, ok := s.blobs[key]
+	if !ok {
+		return nil, ErrNotExist
+	}
+	return io.NopCloser(bytes.NewReader(blob.data)), nil
+}
+
+func (s *MemoryStore) Stat(ctx context.Context, key string) (Info, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	blob, ok := s.blobs[key]
+	if !ok {
+		return Info{}, ErrNotExist
+	}
+	return Info{Key: key, Size: int64(len(blob.data)), ContentType: blob.contentType}, nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.blobs[key]; !ok {
+		return ErrNotExist
+	}
+	delete(s.blobs, key)
+	return nil
+}
+
+func (s *MemoryStore) List(ctx context.Context, prefix string) ([]Info, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	infos := []Info{}
+	for key, blob := range s.blobs {
+		if strings.HasPrefix(key, prefix) {
+			infos = append(infos, Info{Key: key, Size: int64(len(blob.data)), ContentType: blob.contentType})
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Key < infos[j].Key })
+	return infos, nil
+}
+
+func (s *MemoryStore) URL(key string) string {
+	return expandURL(s.urlTemplate, key, nil)
+}
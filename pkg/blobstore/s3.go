@@ -0,0 +1,172 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"vertigo/pkg/config"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+const defaultS3URL = "{endpoint}/{bucket}/{key}"
+
+// S3Store keeps blobs in an S3 compatible bucket: R2, S3 or MinIO. One
+// session is shared by all uploads.
+type S3Store struct {
+	client      *s3.S3
+	uploader    *s3manager.Uploader
+	bucket      string
+	endpoint    string
+	urlTemplate string
+}
+
+// MinIO's defaults, so a local server works without configuration.
+const (
+	minioEndpoint = "http://localhost:9000"
+	minioUser     = "minioadmin"
+)
+
+func NewS3(cfg config.Storage) (*S3Store, error) {
+	if cfg.ResolvedBackend() == config.StorageMinIO {
+		if cfg.Endpoint == "" {
+			cfg.Endpoint = minioEndpoint
+		}
+		if cfg.AccessKeyID == "" && cfg.SecretAccessKey == "" {
+			cfg.AccessKeyID, cfg.SecretAccessKey = minioUser, minioUser
+		}
+	}
+	if cfg.Region == "" {
+		// R2 ignores the region, MinIO and the SDK need one.
+		cfg.Region = "us-east-1"
+		if cfg.ResolvedBackend() == config.StorageR2 {
+			cfg.Region = "auto"
+		}
+	}
+	awsConfig := &aws.Config{
+		Region:      aws.String(cfg.Region),
+		Credentials: credentials.NewStaticCredentials(cfg.AccessKeyID, cfg.SecretAccessKey, ""),
+		// MinIO serves buckets below the endpoint's path, not as
+		// subdomains.
+		S3ForcePathStyle: aws.Bool(cfg.ResolvedBackend() == config.StorageMinIO),
+	}
+	if cfg.Endpoint != "" {
+		awsConfig.Endpoint = aws.String(cfg.Endpoint)
+	}
+	sess, err := session.NewSession(awsConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %v", err)
+	}
+
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", cfg.Region)
+	}
+	urlTemplate := cfg.PublicURL
+	if urlTemplate == "" {
+		urlTemplate = defaultS3URL
+	}
+	client := s3.New(sess)
+	return &S3Store{
+		client:      client,
+		uploader:    s3manager.NewUploaderWithClient(client),
+		bucket:      cfg.BucketName,
+		endpoint:    endpoint,
+		urlTemplate: urlTemplate,
+	}, nil
+}
+
+// Put streams r to the bucket, large blobs are uploaded in parts.
+func (s *S3Store) Put(ctx context.Context, key string, r io.Reader, contentType string) (string, error) {
+	if err := validKey(key); err != nil {
+		return "", err
+	}
+	if contentType == "" {
+		var err error
+		if contentType, r, err = DetectContentType(key, r); err != nil {
+			return "", err
+		}
+	}
+	_, err := s.uploader.UploadWithContext(ctx, &s3manager.UploadInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		Body:        r,
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to upload %s: %v", key, err)
+	}
+	log.Printf("Successfully uploaded %s/%s\n", s.bucket, key)
+	return s.URL(key), nil
+}
+
+func (s *S3Store) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	out, err := s.client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, notExist(err)
+	}
+	return out.Body, nil
+}
+
+func (s *S3Store) Stat(ctx context.Context, key string) (Info, error) {
+	out, err := s.client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return Info{}, notExist(err)
+	}
+	return Info{Key: key, Size: aws.Int64Value(out.ContentLength), ContentType: aws.StringValue(out.ContentType)}, nil
+}
+
+// Delete reports ErrNotExist for a missing blob, S3 itself doesn't.
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	if _, err := s.Stat(ctx, key); err != nil {
+		return err
+	}
+	_, err := s.client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	return err
+}
+
+func (s *S3Store) List(ctx context.Context, prefix string) ([]Info, error) {
+	infos := []Info{}
+	err := s.client.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	}, func(page *s3.ListObjectsV2Output, last bool) bool {
+		for _, object := range page.Contents {
+			infos = append(infos, Info{Key: aws.StringValue(object.Key), Size: aws.Int64Value(object.Size)})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing blobs: %v", err)
+	}
+	return infos, nil
+}
+
+func (s *S3Store) URL(key string) string {
+	return expandURL(s.urlTemplate, key, map[string]string{"bucket": s.bucket, "endpoint": s.endpoint})
+}
+
+func notExist(err error) error {
+	var reqErr awserr.RequestFailure
+	if errors.As(err, &reqErr) && reqErr.StatusCode() == http.StatusNotFound {
+		return ErrNotExist
+	}
+	return err
+}
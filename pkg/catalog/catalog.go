@@ -0,0 +1,108 @@
+package catalog
+
+import (
+	"context"
+	"fmt"
+	"vertigo/pkg/database"
+)
+
+// Provider adds the items of a category, from whatever its arguments name:
+// a StockX URL, a restaurant name, field=value pairs.
+type Provider interface {
+	Add(ctx context.Context, store database.Store, category Category, args []string) (*database.Item, error)
+}
+
+// Locator is a Provider that finds the item a picture is of itself, e.g. the
+// restaurant it was taken at. item is the name given, if any. Locate does
+// the lookups that need the network, the Resolver it returns adds the item
+// if needed.
+type Locator interface {
+	Locate(ctx context.Context, category Category, item, imagePath string) (Resolver, error)
+}
+
+// Resolver returns the item found by Catalogue.Locate from store, adding it
+// if needed. It only talks to store, so it can run in the unit of work of
+// the entry of the picture.
+type Resolver func(ctx context.Context, store database.Store) (*database.Item, error)
+
+// Catalogue ties the categories to the providers their configuration names.
+// A new category is a category file plus, unless a registered provider
+// fits, a Provider registered under the name the file gives.
+type Catalogue struct {
+	categories map[string]Category
+	providers  map[string]Provider
+}
+
+func New(categories []Category) *Catalogue {
+	c := &Catalogue{
+		categories: make(map[string]Category, len(categories)),
+		providers:  make(map[string]Provider),
+	}
+	for _, category := range categories {
+		c.categories[category.Name] = category
+	}
+	return c
+}
+
+// Register makes p the provider of the categories that name it.
+func (c *Catalogue) Register(name string, p Provider) {
+	c.providers[name] = p
+}
+
+// Categories returns the categories by name.
+func (c *Catalogue) Categories() []Category {
+	categories := make([]Category, 0, len(c.categories))
+	for _, category := range c.categories {
+		categories = append(categories, category)
+	}
+	sortCategories(categories)
+	return categories
+}
+
+// Category returns the category name and its provider.
+func (c *Catalogue) Category(name string) (Category, Provider, error) {
+	category, ok := c.categories[name]
+	if !ok {
+		return Category{}, nil, fmt.Errorf("unknown category %q", name)
+	}
+	p, ok := c.providers[category.Provider]
+	if !ok {
+		return Category{}, nil, fmt.Errorf("category %s uses provider %q, which is not available", name, category.Provider)
+	}
+	return category, p, nil
+}
+
+// Add adds an item to the category name.
+func (c *Catalogue) Add(ctx context.Context, store database.Store, name string, args []string) (*database.Item, error) {
+	category, p, err := c.Category(name)
+	if err != nil {
+		return nil, err
+	}
+	return p.Add(ctx, store, category, args)
+}
+
+// Locate finds the item of the category name a picture is of. Providers
+// that are a Locator find it, for the others item names an existing item.
+// Call it before the unit of work of the entry and the Resolver in it.
+func (c *Catalogue) Locate(ctx context.Context, name, item, imagePath string) (Resolver, error) {
+	category, p, err := c.Category(name)
+	if err != nil {
+		return nil, err
+	}
+	if locator, ok := p.(Locator); ok {
+		return locator.Locate(ctx, category, item, imagePath)
+	}
+	if item == "" {
+		return nil, fmt.Errorf("provider %s cannot tell which item a picture is of, name the item", category.Provider)
+	}
+	return func(ctx context.Context, store database.Store) (*database.Item, error) {
+		found, err := store.GetItemByName(ctx, category.Name, item)
+		if err != nil {
+			return nil, err
+		}
+		if found == nil {
+			return nil, fmt.Errorf("no %s item %q", category.Name, item)
+		}
+		return found, nil
+	}, nil
+}
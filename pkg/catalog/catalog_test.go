@@ -0,0 +1,94 @@
+package catalog
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"vertigo/pkg/database"
+)
+
+func TestDefaultCategories(t *testing.T) {
+	categories := DefaultCategories()
+	if len(categories) != 2 || categories[0].Name != database.RestaurantCategory || categories[1].Name != database.ShoeCategory {
+		t.Fatalf("Expected the restaurants and shoes categories, got %+v", categories)
+	}
+	if categories[1].Provider != "stockx" || categories[1].ImageType != "shoe" {
+		t.Fatalf("Unexpected shoes category %+v", categories[1])
+	}
+}
+
+func TestLoadCategories(t *testing.T) {
+	categories, err := LoadCategories(fstest.MapFS{"watches.json": {Data: []byte(`{"provider": "manual"}`)}})
+	if err != nil || len(categories) != 1 {
+		t.Fatalf("LoadCategories failed: %v", err)
+	}
+	if c := categories[0]; c.Name != "watches" || c.Title != "watches" || c.ImageType != "watches" {
+		t.Fatalf("Expected the defaults from the file name, got %+v", c)
+	}
+
+	for file, content := range map[string]string{
+		"watches.json": `{}`,
+		"Watches.json": `{"provider": "manual"}`,
+		"books.json":   `{"provider": "manual", "image_type": "../shoes"}`,
+	} {
+		if _, err := LoadCategories(fstest.MapFS{file: {Data: []byte(content)}}); err == nil {
+			t.Errorf("Expected %s %s to be rejected", file, content)
+		}
+	}
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "watches.json"), []byte(`{"title": "Watches", "provider": "manual"}`), 0644)
+	os.WriteFile(filepath.Join(dir, "shoes.json"), []byte(`{"provider": "manual", "image_type": "shoe"}`), 0644)
+	categories, err = CategoriesWithDir(dir)
+	if err != nil || len(categories) != 3 || categories[1].Provider != "manual" || categories[2].Title != "Watches" {
+		t.Fatalf("Expected the categories in the directory to be added, got %+v, %v", categories, err)
+	}
+}
+
+func TestCatalogue(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	catalogue := New([]Category{
+		{Name: "watches", Provider: "manual", ImageType: "watch"},
+		{Name: "books", Provider: "goodreads", ImageType: "book"},
+	})
+	catalogue.Register("manual", Manual{})
+
+	item, err := catalogue.Add(ctx, store, "watches", []string{"name=Speedmaster", "title=Omega Speedmaster", "retail_price=$6,000", "image_url=https://example.com/s.jpg"})
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if item.Title != "Omega Speedmaster" || item.Attributes != `{"Retail Price":"$6,000"}` || item.Picture != "https://example.com/s.jpg" {
+		t.Fatalf("Unexpected item %+v", item)
+	}
+
+	for _, tt := range []struct {
+		category string
+		args     []string
+		err      string
+	}{
+		{"watches", []string{"name=Speedmaster"}, "exists already"},
+		{"watches", []string{"title=Seamaster"}, "missing name"},
+		{"watches", []string{"name=Seamaster", "image_url=ftp://example.com/s.jpg"}, "invalid image_url"},
+		{"books", []string{"name=Dune"}, `provider "goodreads", which is not available`},
+		{"cars", nil, `unknown category "cars"`},
+	} {
+		if _, err := catalogue.Add(ctx, store, tt.category, tt.args); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s %q: expected %q, got %v", tt.category, tt.args, tt.err, err)
+		}
+	}
+
+	resolve, err := catalogue.Locate(ctx, "watches", "Speedmaster", "watch.jpg")
+	if err != nil {
+		t.Fatalf("Locate failed: %v", err)
+	}
+	if found, err := resolve(ctx, store); err != nil || found.ID != item.ID {
+		t.Fatalf("Expected the Speedmaster, got %+v, %v", found, err)
+	}
+	if _, err := catalogue.Locate(ctx, "watches", "", "watch.jpg"); err == nil || !strings.Contains(err.Error(), "name the item") {
+		t.Fatalf("Expected the item to be required without a locator, got %v", err)
+	}
+}
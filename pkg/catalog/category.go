@@ -0,0 +1,127 @@
+package catalog
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+//go:embed categories/*.json
+var embeddedCategories embed.FS
+
+// Category is a kind of item the collection keeps, like shoes or
+// restaurants. Provider names the plugin that adds its items, ImageType the
+// directory the pictures of its entries go to.
+type Category struct {
+	Name      string `json:"name"`
+	Title     string `json:"title"`
+	Provider  string `json:"provider"`
+	ImageType string `json:"image_type"`
+}
+
+var validName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+func (c Category) validate() error {
+	if !validName.MatchString(c.Name) {
+		return fmt.Errorf("invalid category name %q, use lower case letters, digits, dashes and underscores", c.Name)
+	}
+	if c.Provider == "" {
+		return fmt.Errorf("category %s has no provider", c.Name)
+	}
+	if c.ImageType != "" && !validName.MatchString(c.ImageType) {
+		return fmt.Errorf("category %s has an invalid image_type %q", c.Name, c.ImageType)
+	}
+	return nil
+}
+
+// LoadCategories reads the *.json categories in fsys, by name. The name
+// defaults to the file name, the title to the name and the image type to
+// the name.
+func LoadCategories(fsys fs.FS) ([]Category, error) {
+	files, err := fs.Glob(fsys, "*.json")
+	if err != nil {
+		return nil, fmt.Errorf("error listing categories: %v", err)
+	}
+
+	var categories []Category
+	names := make(map[string]string)
+	for _, file := range files {
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading category %s: %v", file, err)
+		}
+		var c Category
+		if err := json.Unmarshal(content, &c); err != nil {
+			return nil, fmt.Errorf("error parsing category %s: %v", file, err)
+		}
+		if c.Name == "" {
+			c.Name = strings.TrimSuffix(path.Base(file), ".json")
+		}
+		if c.Title == "" {
+			c.Title = c.Name
+		}
+		if c.ImageType == "" {
+			c.ImageType = c.Name
+		}
+		if err := c.validate(); err != nil {
+			return nil, err
+		}
+		if other, ok := names[c.Name]; ok {
+			return nil, fmt.Errorf("categories %s and %s have the same name %s", other, file, c.Name)
+		}
+		names[c.Name] = file
+		categories = append(categories, c)
+	}
+	sortCategories(categories)
+	return categories, nil
+}
+
+func sortCategories(categories []Category) {
+	sort.Slice(categories, func(i, j int) bool { return categories[i].Name < categories[j].Name })
+}
+
+// DefaultCategories returns the categories built into the binary.
+func DefaultCategories() []Category {
+	sub, err := fs.Sub(embeddedCategories, "categories")
+	if err != nil {
+		panic(err)
+	}
+	categories, err := LoadCategories(sub)
+	if err != nil {
+		panic(fmt.Sprintf("invalid embedded category: %v", err))
+	}
+	return categories
+}
+
+// CategoriesWithDir adds the categories in dir to the built-in ones. A
+// category in dir replaces a built-in category with the same name.
+func CategoriesWithDir(dir string) ([]Category, error) {
+	categories := DefaultCategories()
+	if dir == "" {
+		return categories, nil
+	}
+	extra, err := LoadCategories(os.DirFS(dir))
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]Category)
+	for _, c := range categories {
+		byName[c.Name] = c
+	}
+	for _, c := range extra {
+		byName[c.Name] = c
+	}
+	merged := make([]Category, 0, len(byName))
+	for _, c := range byName {
+		merged = append(merged, c)
+	}
+	sortCategories(merged)
+	return merged, nil
+}
@@ -0,0 +1,66 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"vertigo/pkg/database"
+	"vertigo/pkg/dataitems"
+)
+
+// Manual adds items entered by hand as field=value pairs like name=Speedmaster
+// title="Omega Speedmaster" brand=Omega. name is required, title and image_url
+// are optional and the other fields become attributes. The image is linked,
+// not downloaded: assets gc only keeps the folders and blobs of shoes.
+type Manual struct{}
+
+// ParseItem makes an item of category from field=value pairs.
+func ParseItem(category string, pairs []string) (database.Item, error) {
+	item := database.Item{Category: category}
+	fields := map[string]*string{
+		"name":      &item.Name,
+		"title":     &item.Title,
+		"image_url": &item.Picture,
+	}
+	attributes := make(map[string]string)
+	if err := dataitems.ParseFields(pairs, fields, attributes); err != nil {
+		return database.Item{}, err
+	}
+
+	if item.Name == "" {
+		return database.Item{}, fmt.Errorf("missing name")
+	}
+	if item.Title == "" {
+		item.Title = item.Name
+	}
+	if item.Picture != "" {
+		if err := dataitems.CheckImageURL(item.Picture); err != nil {
+			return database.Item{}, err
+		}
+	}
+	content, err := json.Marshal(attributes)
+	if err != nil {
+		return database.Item{}, fmt.Errorf("error serializing attributes: %v", err)
+	}
+	item.Attributes = string(content)
+	return item, nil
+}
+
+func (m Manual) Add(ctx context.Context, store database.Store, category Category, args []string) (*database.Item, error) {
+	item, err := ParseItem(category.Name, args)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := store.GetItemByName(ctx, category.Name, item.Name)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("%s item %q exists already", category.Name, item.Name)
+	}
+	id, err := store.InsertItem(ctx, item)
+	if err != nil {
+		return nil, err
+	}
+	return store.GetItemByID(ctx, category.Name, id)
+}
@@ -0,0 +1,529 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+const defaultConfigFile = ".env"
+
+type Config struct {
+	DatabasePath    string
+	DatabaseTimeout time.Duration
+	MigrationsDir   string
+	ImageDir        string
+	ProfilesDir     string
+	CategoriesDir   string
+	Port            int
+	User            string
+	Discord         Discord
+	Storage         Storage
+	HTTP            HTTP
+	API             API
+	Animation       Animation
+	Imaging         Imaging
+}
+
+type Discord struct {
+	BotToken            string
+	GuildID             string
+	NotificationChannel string
+	ImageChannel        string
+}
+
+const (
+	StorageR2     = "r2"
+	StorageS3     = "s3"
+	StorageMinIO  = "minio"
+	StorageLocal  = "local"
+	StorageMemory = "memory"
+)
+
+type Storage struct {
+	// Backend is r2, s3, minio, local or memory, empty picks r2 when an
+	// endpoint is set and local otherwise.
+	Backend         string
+	BucketName      string
+	Endpoint        string
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	LocalDir        string
+	// PublicURL is the template of the URLs stored for uploaded pictures,
+	// e.g. https://pub-123.r2.dev/{key}.
+	PublicURL string
+}
+
+// HTTP configures the client used for every outbound request, to StockX,
+// Overpass and Discord's CDN.
+type HTTP struct {
+	Timeout time.Duration
+	// Interval is the minimum time between two requests to the same host.
+	Interval  time.Duration
+	Retries   int
+	UserAgent string
+	// CacheDir keeps responses for conditional requests, empty disables it.
+	CacheDir string
+}
+
+// API configures who may use bertigo to change data.
+type API struct {
+	// Tokens maps the bearer tokens that may change data to the user the
+	// changes are recorded for. Without tokens nothing can be changed.
+	Tokens map[string]string
+	// Origins are the origins browsers may call bertigo from, e.g.
+	// https://vertigo.example.com. Without origins no cross-origin request is
+	// allowed.
+	Origins []string
+}
+
+// Animation configures the spinning shoe made from the frames of the 360
+// view.
+type Animation struct {
+	// Format is gif or apng.
+	Format string
+	Delay  time.Duration
+	// Width scales the frames, 0 keeps their width.
+	Width int
+}
+
+// Imaging configures how product pictures and the frames of the 360 view
+// are framed.
+type Imaging struct {
+	// Threshold is the lowest value of every channel of a background pixel.
+	Threshold uint8
+	// Transparent replaces the background with transparency.
+	Transparent bool
+	// Aspect is width / height of the pictures, 0 keeps the trimmed size.
+	Aspect float64
+}
+
+// setting ties a configuration value to its key in the config file and the
+// environment and, for values that are not secrets, to a command line flag.
+type setting struct {
+	key   string
+	flag  string
+	usage string
+	apply func(c *Config, value string) error
+}
+
+var settings = []setting{
+	{"VERTIGO_DB", "db", "Path of the SQLite database", func(c *Config, v string) error { c.DatabasePath = v; return nil }},
+	{"VERTIGO_DB_TIMEOUT", "db-timeout", "Timeout of a single database call, e.g. 10s, 0 disables it", func(c *Config, v string) error {
+		timeout, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", v)
+		}
+		c.DatabaseTimeout = timeout
+		return nil
+	}},
+	{"VERTIGO_SQL_DIR", "sql-dir", "Load migrations from this directory instead of the embedded ones", func(c *Config, v string) error { c.MigrationsDir = v; return nil }},
+	{"VERTIGO_STOCKX_PROFILES", "stockx-profiles", "Directory with extra StockX selector profiles", func(c *Config, v string) error { c.ProfilesDir = v; return nil }},
+	{"VERTIGO_CATEGORIES", "categories", "Directory with extra item categories", func(c *Config, v string) error { c.CategoriesDir = v; return nil }},
+	{"VERTIGO_IMG_DIR", "img-dir", "Directory holding the img_data tree", func(c *Config, v string) error { c.ImageDir = v; return nil }},
+	{"VERTIGO_PORT", "port", "Port of the bertigo HTTP server", func(c *Config, v string) error {
+		port, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid port %q", v)
+		}
+		c.Port = port
+		return nil
+	}},
+	{"VERTIGO_USER", "user", "Name recorded in the history of changes", func(c *Config, v string) error { c.User = v; return nil }},
+	{"VERTIGO_CORS_ORIGINS", "cors-origins", "Comma-separated origins browsers may call bertigo from", func(c *Config, v string) error {
+		c.API.Origins = splitList(v)
+		return nil
+	}},
+	{"VERTIGO_API_TOKENS", "", "", func(c *Config, v string) error {
+		tokens, err := parseTokens(v)
+		if err != nil {
+			return err
+		}
+		c.API.Tokens = tokens
+		return nil
+	}},
+	{"VERTIGO_HTTP_TIMEOUT", "http-timeout", "Timeout of a single outbound HTTP request, e.g. 30s", func(c *Config, v string) error {
+		timeout, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", v)
+		}
+		c.HTTP.Timeout = timeout
+		return nil
+	}},
+	{"VERTIGO_HTTP_INTERVAL", "http-interval", "Minimum time between two requests to the same host, e.g. 1s", func(c *Config, v string) error {
+		interval, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", v)
+		}
+		c.HTTP.Interval = interval
+		return nil
+	}},
+	{"VERTIGO_HTTP_RETRIES", "http-retries", "Retries of a request answered with 429 or 5xx", func(c *Config, v string) error {
+		retries, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", v)
+		}
+		c.HTTP.Retries = retries
+		return nil
+	}},
+	{"VERTIGO_HTTP_CACHE", "http-cache", "Directory caching HTTP responses, empty disables the cache", func(c *Config, v string) error { c.HTTP.CacheDir = v; return nil }},
+	{"VERTIGO_USER_AGENT", "user-agent", "User-Agent sent with outbound HTTP requests", func(c *Config, v string) error { c.HTTP.UserAgent = v; return nil }},
+	{"VERTIGO_ANIMATION_FORMAT", "animation-format", "Format of the spinning shoe, gif or apng", func(c *Config, v string) error { c.Animation.Format = v; return nil }},
+	{"VERTIGO_ANIMATION_DELAY", "animation-delay", "Time each frame of the spinning shoe is shown, e.g. 100ms", func(c *Config, v string) error {
+		delay, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", v)
+		}
+		c.Animation.Delay = delay
+		return nil
+	}},
+	{"VERTIGO_ANIMATION_WIDTH", "animation-width", "Width of the spinning shoe in pixels, 0 keeps the width of the frames", func(c *Config, v string) error {
+		width, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", v)
+		}
+		c.Animation.Width = width
+		return nil
+	}},
+	{"VERTIGO_IMAGE_THRESHOLD", "image-threshold", "Channel value from which a pixel counts as white background, 0-255", func(c *Config, v string) error {
+		threshold, err := strconv.ParseUint(v, 10, 8)
+		if err != nil {
+			return fmt.Errorf("invalid threshold %q", v)
+		}
+		c.Imaging.Threshold = uint8(threshold)
+		return nil
+	}},
+	{"VERTIGO_IMAGE_TRANSPARENT", "image-transparent", "Make the white background of shoe pictures transparent, true or false", func(c *Config, v string) error {
+		transparent, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", v)
+		}
+		c.Imaging.Transparent = transparent
+		return nil
+	}},
+	{"VERTIGO_IMAGE_ASPECT", "image-aspect", "Pad shoe pictures to this aspect ratio, e.g. 4:3 or 1.5, empty keeps the trimmed size", func(c *Config, v string) error {
+		aspect, err := parseAspect(v)
+		if err != nil {
+			return err
+		}
+		c.Imaging.Aspect = aspect
+		return nil
+	}},
+	{"VERTIGO_STORAGE", "storage", "Where pictures are uploaded: r2, s3, minio or local", func(c *Config, v string) error { c.Storage.Backend = v; return nil }},
+	{"VERTIGO_STORAGE_DIR", "storage-dir", "Directory of the local storage backend", func(c *Config, v string) error { c.Storage.LocalDir = v; return nil }},
+	{"VERTIGO_PUBLIC_URL", "public-url", "URL template of uploaded pictures with {key}, {bucket}, {endpoint} or {dir}", func(c *Config, v string) error { c.Storage.PublicURL = v; return nil }},
+	{"VERTIGO_BUCKET", "bucket", "Bucket the shoe images are uploaded to", func(c *Config, v string) error { c.Storage.BucketName = v; return nil }},
+	{"DISCORD_BOT_TOKEN", "", "", func(c *Config, v string) error { c.Discord.BotToken = v; return nil }},
+	{"DISCORD_GUILD_ID", "", "", func(c *Config, v string) error { c.Discord.GuildID = v; return nil }},
+	{"DISCORD_NOTIFICATION_CHANNEL", "", "", func(c *Config, v string) error { c.Discord.NotificationChannel = v; return nil }},
+	{"DISCORD_IMAGE_CHANNEL", "", "", func(c *Config, v string) error { c.Discord.ImageChannel = v; return nil }},
+	{"R2_ENDPOINT", "", "", func(c *Config, v string) error { c.Storage.Endpoint = v; return nil }},
+	{"AWS_REGION", "", "", func(c *Config, v string) error { c.Storage.Region = v; return nil }},
+	{"AWS_ACCESS_KEY_ID", "", "", func(c *Config, v string) error { c.Storage.AccessKeyID = v; return nil }},
+	{"AWS_SECRET_ACCESS_KEY", "", "", func(c *Config, v string) error { c.Storage.SecretAccessKey = v; return nil }},
+}
+
+func Default() *Config {
+	return &Config{
+		DatabasePath:    "data/database/test.db",
+		DatabaseTimeout: 10 * time.Second,
+		ImageDir:        "img_data",
+		Port:            8080,
+		User:            loginName(),
+		Storage: Storage{
+			BucketName: "vertigo",
+			LocalDir:   "data/blobs",
+		},
+		HTTP: HTTP{
+			Timeout:   30 * time.Second,
+			Interval:  time.Second,
+			Retries:   3,
+			UserAgent: "vertigo/1.0",
+			CacheDir:  "data/http_cache",
+		},
+		Animation: Animation{
+			Format: "gif",
+			Delay:  100 * time.Millisecond,
+		},
+		Imaging: Imaging{
+			Threshold: 230,
+		},
+	}
+}
+
+// Load registers the configuration flags on fs, parses args and resolves the
+// configuration. Later sources win: defaults, the config file (-config,
+// $VERTIGO_CONFIG or ./.env), the environment, then flags set on the command
+// line. Commands may register their own flags on fs before calling Load.
+func Load(fs *flag.FlagSet, args []string) (*Config, error) {
+	configFile, flagValues := registerFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg := Default()
+
+	path, required := *configFile, true
+	if path == "" {
+		path = os.Getenv("VERTIGO_CONFIG")
+	}
+	if path == "" {
+		path, required = defaultConfigFile, false
+	}
+	fileValues, err := godotenv.Read(path)
+	if err != nil {
+		if required || !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("error reading config file %s: %v", path, err)
+		}
+		fileValues = map[string]string{}
+	}
+
+	setFlags := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { setFlags[f.Name] = true })
+
+	for _, s := range settings {
+		value, ok := fileValues[s.key]
+		if env, found := os.LookupEnv(s.key); found {
+			value, ok = env, true
+		}
+		if s.flag != "" && setFlags[s.flag] {
+			value, ok = *flagValues[s.flag], true
+		}
+		if !ok {
+			continue
+		}
+		if err := s.apply(cfg, value); err != nil {
+			return nil, fmt.Errorf("%s: %v", s.key, err)
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Flags registers the flags Load reads on fs without parsing anything, for
+// usage messages and shell completion.
+func Flags(fs *flag.FlagSet) {
+	registerFlags(fs)
+}
+
+func registerFlags(fs *flag.FlagSet) (configFile *string, flagValues map[string]*string) {
+	configFile = fs.String("config", "", "Config file in .env format, defaults to $VERTIGO_CONFIG or ./.env")
+	flagValues = make(map[string]*string)
+	for _, s := range settings {
+		if s.flag != "" {
+			flagValues[s.flag] = fs.String(s.flag, "", s.usage+", defaults to $"+s.key)
+		}
+	}
+	return configFile, flagValues
+}
+
+// parseAspect reads an aspect ratio as width:height or as a number.
+func parseAspect(v string) (float64, error) {
+	if v == "" {
+		return 0, nil
+	}
+	var aspect float64
+	if width, height, ok := strings.Cut(v, ":"); ok {
+		w, werr := strconv.ParseFloat(width, 64)
+		h, herr := strconv.ParseFloat(height, 64)
+		if werr != nil || herr != nil || h <= 0 {
+			return 0, fmt.Errorf("invalid aspect ratio %q", v)
+		}
+		aspect = w / h
+	} else {
+		var err error
+		if aspect, err = strconv.ParseFloat(v, 64); err != nil {
+			return 0, fmt.Errorf("invalid aspect ratio %q", v)
+		}
+	}
+	if aspect <= 0 {
+		return 0, fmt.Errorf("invalid aspect ratio %q", v)
+	}
+	return aspect, nil
+}
+
+// splitList reads a comma-separated list, dropping empty items.
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseTokens reads API tokens as user:token pairs separated by commas.
+func parseTokens(v string) (map[string]string, error) {
+	tokens := make(map[string]string)
+	for _, pair := range splitList(v) {
+		user, token, ok := strings.Cut(pair, ":")
+		user, token = strings.TrimSpace(user), strings.TrimSpace(token)
+		if !ok || user == "" || token == "" {
+			return nil, errors.New("invalid token, expected user:token pairs")
+		}
+		if _, ok := tokens[token]; ok {
+			return nil, fmt.Errorf("token of %s is used twice", user)
+		}
+		tokens[token] = user
+	}
+	return tokens, nil
+}
+
+func loginName() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+func (c *Config) Validate() error {
+	var errs []error
+	if c.DatabasePath == "" {
+		errs = append(errs, errors.New("database path must not be empty"))
+	}
+	if c.DatabaseTimeout < 0 {
+		errs = append(errs, errors.New("database timeout must not be negative"))
+	}
+	if c.ImageDir == "" {
+		errs = append(errs, errors.New("image directory must not be empty"))
+	}
+	if c.Port < 1 || c.Port > 65535 {
+		errs = append(errs, fmt.Errorf("port %d is out of range", c.Port))
+	}
+	if c.HTTP.Timeout < 0 || c.HTTP.Interval < 0 {
+		errs = append(errs, errors.New("HTTP timeout and interval must not be negative"))
+	}
+	if c.HTTP.Retries < 0 {
+		errs = append(errs, errors.New("HTTP retries must not be negative"))
+	}
+	switch c.Storage.Backend {
+	case "", StorageR2, StorageS3, StorageMinIO, StorageLocal, StorageMemory:
+	default:
+		errs = append(errs, fmt.Errorf("unknown storage backend %q, use r2, s3, minio or local", c.Storage.Backend))
+	}
+	if c.Animation.Format != "gif" && c.Animation.Format != "apng" {
+		errs = append(errs, fmt.Errorf("unknown animation format %q, use gif or apng", c.Animation.Format))
+	}
+	if c.Animation.Delay < 10*time.Millisecond || c.Animation.Delay > 10*time.Second {
+		errs = append(errs, errors.New("animation delay must be between 10ms and 10s"))
+	}
+	if c.Animation.Width < 0 {
+		errs = append(errs, errors.New("animation width must not be negative"))
+	}
+	for _, origin := range c.API.Origins {
+		if u, err := url.Parse(origin); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" || u.RawQuery != "" {
+			errs = append(errs, fmt.Errorf("invalid CORS origin %q, expected e.g. https://vertigo.example.com", origin))
+		}
+	}
+	if c.MigrationsDir != "" {
+		if info, err := os.Stat(c.MigrationsDir); err != nil || !info.IsDir() {
+			errs = append(errs, fmt.Errorf("migrations directory %s does not exist", c.MigrationsDir))
+		}
+	}
+	if c.ProfilesDir != "" {
+		if info, err := os.Stat(c.ProfilesDir); err != nil || !info.IsDir() {
+			errs = append(errs, fmt.Errorf("StockX profiles directory %s does not exist", c.ProfilesDir))
+		}
+	}
+	if c.CategoriesDir != "" {
+		if info, err := os.Stat(c.CategoriesDir); err != nil || !info.IsDir() {
+			errs = append(errs, fmt.Errorf("categories directory %s does not exist", c.CategoriesDir))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Config) ShoeImageDir() string {
+	return filepath.Join(c.ImageDir, "shoes")
+}
+
+func (c *Config) ShoentryImageDir() string {
+	return filepath.Join(c.ImageDir, "shoentries")
+}
+
+func (c *Config) FoodImageDir() string {
+	return filepath.Join(c.ImageDir, "food")
+}
+
+// EntryImageDir is where the pictures of entries of imageType are kept. Shoe
+// and food pictures stay where they always were.
+func (c *Config) EntryImageDir(imageType string) string {
+	switch imageType {
+	case "shoe":
+		return c.ShoentryImageDir()
+	case "food":
+		return c.FoodImageDir()
+	}
+	return filepath.Join(c.EntriesImageDir(), imageType)
+}
+
+// EntriesImageDir holds a folder per image type of the other categories.
+func (c *Config) EntriesImageDir() string {
+	return filepath.Join(c.ImageDir, "entries")
+}
+
+// Validate is only called by code paths that talk to Discord, so commands
+// that never post anything work without a bot token.
+func (d Discord) Validate() error {
+	var errs []error
+	if d.BotToken == "" {
+		errs = append(errs, errors.New("please set DISCORD_BOT_TOKEN"))
+	}
+	if d.NotificationChannel == "" {
+		errs = append(errs, errors.New("please set DISCORD_NOTIFICATION_CHANNEL"))
+	}
+	if d.ImageChannel == "" {
+		errs = append(errs, errors.New("please set DISCORD_IMAGE_CHANNEL"))
+	}
+	return errors.Join(errs...)
+}
+
+func (s Storage) ResolvedBackend() string {
+	if s.Backend != "" {
+		return s.Backend
+	}
+	if s.Endpoint != "" {
+		return StorageR2
+	}
+	return StorageLocal
+}
+
+// Validate is only called when pictures are uploaded. MinIO falls back to
+// its defaults on localhost, the other buckets need credentials.
+func (s Storage) Validate() error {
+	var errs []error
+	switch backend := s.ResolvedBackend(); backend {
+	case StorageLocal:
+		if s.LocalDir == "" {
+			errs = append(errs, errors.New("please set VERTIGO_STORAGE_DIR"))
+		}
+	case StorageMemory:
+	case StorageR2, StorageS3, StorageMinIO:
+		if s.BucketName == "" {
+			errs = append(errs, errors.New("please set VERTIGO_BUCKET"))
+		}
+		if backend == StorageR2 && s.Endpoint == "" {
+			errs = append(errs, errors.New("please set R2_ENDPOINT"))
+		}
+		if backend != StorageMinIO && (s.AccessKeyID == "" || s.SecretAccessKey == "") {
+			errs = append(errs, errors.New("please set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unknown storage backend %q, use r2, s3, minio or local", backend))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,156 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clearEnv unsets every setting for the duration of the test, so values of
+// the machine running it don't leak in.
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for _, key := range append([]string{"VERTIGO_CONFIG"}, settingKeys()...) {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+}
+
+func settingKeys() []string {
+	var keys []string
+	for _, s := range settings {
+		keys = append(keys, s.key)
+	}
+	return keys
+}
+
+func writeConfig(t *testing.T, lines ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "vertigo.env")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func load(args ...string) (*Config, error) {
+	return Load(flag.NewFlagSet("test", flag.ContinueOnError), args)
+}
+
+func TestDefaults(t *testing.T) {
+	clearEnv(t)
+
+	cfg, err := load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.DatabasePath != "data/database/test.db" || cfg.DatabaseTimeout != 10*time.Second || cfg.Port != 8080 || cfg.ImageDir != "img_data" {
+		t.Errorf("Unexpected defaults %+v", cfg)
+	}
+	if cfg.HTTP.Timeout != 30*time.Second || cfg.HTTP.Retries != 3 || cfg.Animation.Format != "gif" || cfg.Imaging.Threshold != 230 {
+		t.Errorf("Unexpected defaults %+v %+v %+v", cfg.HTTP, cfg.Animation, cfg.Imaging)
+	}
+	if cfg.Storage.ResolvedBackend() != StorageLocal || len(cfg.API.Tokens) != 0 || len(cfg.API.Origins) != 0 {
+		t.Errorf("Unexpected defaults %+v %+v", cfg.Storage, cfg.API)
+	}
+}
+
+func TestPrecedence(t *testing.T) {
+	clearEnv(t)
+	path := writeConfig(t,
+		"VERTIGO_DB=file.db",
+		"VERTIGO_PORT=8001",
+		"VERTIGO_HTTP_RETRIES=5",
+		"VERTIGO_ANIMATION_FORMAT=apng",
+	)
+	t.Setenv("VERTIGO_PORT", "8002")
+	t.Setenv("VERTIGO_HTTP_RETRIES", "6")
+
+	cfg, err := load("-config", path, "-http-retries", "7")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.DatabasePath != "file.db" {
+		t.Errorf("Expected the config file to beat the default, got %q", cfg.DatabasePath)
+	}
+	if cfg.Port != 8002 {
+		t.Errorf("Expected the environment to beat the config file, got %d", cfg.Port)
+	}
+	if cfg.HTTP.Retries != 7 {
+		t.Errorf("Expected the flag to beat the environment, got %d", cfg.HTTP.Retries)
+	}
+	if cfg.Animation.Format != "apng" || cfg.Animation.Delay != 100*time.Millisecond {
+		t.Errorf("Unexpected animation %+v", cfg.Animation)
+	}
+
+	t.Setenv("VERTIGO_CONFIG", path)
+	if cfg, err := load(); err != nil || cfg.DatabasePath != "file.db" {
+		t.Errorf("Expected $VERTIGO_CONFIG to be read, got %+v, %v", cfg, err)
+	}
+	if _, err := load("-config", filepath.Join(t.TempDir(), "missing.env")); err == nil {
+		t.Errorf("Expected a missing -config file to fail")
+	}
+}
+
+func TestInvalidValues(t *testing.T) {
+	for _, tt := range []struct {
+		section string
+		args    []string
+		env     map[string]string
+		err     string
+	}{
+		{"database", []string{"-db-timeout", "soon"}, nil, "VERTIGO_DB_TIMEOUT: invalid duration"},
+		{"server", []string{"-port", "70000"}, nil, "port 70000 is out of range"},
+		{"storage", []string{"-storage", "ftp"}, nil, `unknown storage backend "ftp"`},
+		{"http", []string{"-http-retries", "-1"}, nil, "HTTP retries must not be negative"},
+		{"http", []string{"-http-interval", "1"}, nil, "VERTIGO_HTTP_INTERVAL: invalid duration"},
+		{"api", []string{"-cors-origins", "https://vertigo.example.com/app"}, nil, "invalid CORS origin"},
+		{"api", nil, map[string]string{"VERTIGO_API_TOKENS": "alice"}, "VERTIGO_API_TOKENS: invalid token"},
+		{"api", nil, map[string]string{"VERTIGO_API_TOKENS": "alice:secret,bob:secret"}, "token of bob is used twice"},
+		{"animation", []string{"-animation-format", "webp"}, nil, `unknown animation format "webp"`},
+		{"animation", []string{"-animation-delay", "1ms"}, nil, "animation delay must be between"},
+		{"imaging", []string{"-image-threshold", "256"}, nil, "VERTIGO_IMAGE_THRESHOLD: invalid threshold"},
+		{"imaging", []string{"-image-aspect", "4:0"}, nil, "VERTIGO_IMAGE_ASPECT: invalid aspect ratio"},
+	} {
+		t.Run(tt.section, func(t *testing.T) {
+			clearEnv(t)
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			if _, err := load(tt.args...); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%v %v: expected %q, got %v", tt.args, tt.env, tt.err, err)
+			}
+		})
+	}
+
+	// Discord and storage credentials are only checked by the commands that
+	// need them.
+	if err := (Discord{BotToken: "token"}).Validate(); err == nil || !strings.Contains(err.Error(), "DISCORD_NOTIFICATION_CHANNEL") {
+		t.Errorf("Expected the missing channels to be reported, got %v", err)
+	}
+	if err := (Storage{Endpoint: "https://r2.example.com"}).Validate(); err == nil || !strings.Contains(err.Error(), "AWS_ACCESS_KEY_ID") {
+		t.Errorf("Expected the missing credentials to be reported, got %v", err)
+	}
+}
+
+func TestLoadWithoutDiscord(t *testing.T) {
+	clearEnv(t)
+
+	cfg, err := load("-db", "vertigo.db")
+	if err != nil {
+		t.Fatalf("Expected Load to work without DISCORD_*, got %v", err)
+	}
+	if err := cfg.Discord.Validate(); err == nil || !strings.Contains(err.Error(), "DISCORD_BOT_TOKEN") {
+		t.Errorf("Expected Discord.Validate to ask for the bot token, got %v", err)
+	}
+
+	t.Setenv("DISCORD_BOT_TOKEN", "token")
+	t.Setenv("DISCORD_NOTIFICATION_CHANNEL", "1")
+	t.Setenv("DISCORD_IMAGE_CHANNEL", "2")
+	if cfg, err = load(); err != nil || cfg.Discord.Validate() != nil {
+		t.Errorf("Expected a complete Discord section, got %+v, %v", cfg.Discord, err)
+	}
+}
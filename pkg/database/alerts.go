@@ -0,0 +1,208 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+const (
+	// AlertBelow triggers when the price drops below Threshold.
+	AlertBelow = "below"
+	// AlertDrop triggers when the price drops by at least Threshold percent
+	// since the previous observation.
+	AlertDrop = "drop"
+)
+
+type PriceAlert struct {
+	ID              int64      `json:"id"`
+	ShoeID          int64      `json:"shoe_id"`
+	Kind            string     `json:"kind"`
+	Threshold       float64    `json:"threshold"`
+	Currency        string     `json:"currency"`
+	LastTriggeredAt *time.Time `json:"last_triggered_at"`
+	CreatedBy       string     `json:"created_by"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+type TriggeredAlert struct {
+	Alert    PriceAlert `json:"alert"`
+	Previous *ShoePrice `json:"previous"`
+	Price    ShoePrice  `json:"price"`
+}
+
+func (a PriceAlert) Validate() error {
+	switch a.Kind {
+	case AlertBelow:
+		if a.Threshold <= 0 {
+			return fmt.Errorf("the target price must be positive")
+		}
+	case AlertDrop:
+		if a.Threshold <= 0 || a.Threshold >= 100 {
+			return fmt.Errorf("the drop must be a percentage between 0 and 100")
+		}
+	default:
+		return fmt.Errorf("unknown alert kind %q, expected %s or %s", a.Kind, AlertBelow, AlertDrop)
+	}
+	return nil
+}
+
+func (a PriceAlert) String() string {
+	if a.Kind == AlertDrop {
+		return fmt.Sprintf("drop of %g%%", a.Threshold)
+	}
+	if a.Currency != "" {
+		return fmt.Sprintf("below %g %s", a.Threshold, a.Currency)
+	}
+	return fmt.Sprintf("below %g", a.Threshold)
+}
+
+// Triggered reports whether the new price triggers the alert. A target price
+// triggers when the price crosses it, not again while the price stays below.
+func (a PriceAlert) Triggered(previous *ShoePrice, price ShoePrice) bool {
+	if a.Currency != "" && a.Currency != price.Currency {
+		return false
+	}
+	if previous != nil && previous.Currency != price.Currency {
+		previous = nil
+	}
+	switch a.Kind {
+	case AlertBelow:
+		return price.Amount < a.Threshold && (previous == nil || previous.Amount >= a.Threshold)
+	case AlertDrop:
+		return previous != nil && previous.Amount > 0 && (previous.Amount-price.Amount)/previous.Amount*100 >= a.Threshold
+	}
+	return false
+}
+
+const priceAlertQuery = `
+	SELECT price_alerts.ID, price_alerts.ShoeID, price_alerts.Kind, price_alerts.Threshold, price_alerts.Currency,
+		price_alerts.LastTriggeredAt, price_alerts.CreatedBy, price_alerts.CreatedAt
+	FROM price_alerts
+	INNER JOIN items ON price_alerts.ShoeID = items.ID
+	WHERE items.DeletedAt IS NULL
+`
+
+func (db *DB) queryPriceAlerts(ctx context.Context, query string, params ...interface{}) ([]PriceAlert, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.conn().QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying price alerts: %v", err)
+	}
+	defer rows.Close()
+
+	alerts := []PriceAlert{}
+	for rows.Next() {
+		var a PriceAlert
+		var currency, createdBy sql.NullString
+		var lastTriggeredAt sql.NullTime
+		if err := rows.Scan(&a.ID, &a.ShoeID, &a.Kind, &a.Threshold, &currency, &lastTriggeredAt, &createdBy, &a.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning price alert: %v", err)
+		}
+		a.Currency = currency.String
+		a.CreatedBy = createdBy.String
+		if lastTriggeredAt.Valid {
+			a.LastTriggeredAt = &lastTriggeredAt.Time
+		}
+		alerts = append(alerts, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading price alert rows: %v", err)
+	}
+	return alerts, nil
+}
+
+// InsertPriceAlert adds an alert on a shoe that is not deleted.
+func (db *DB) InsertPriceAlert(ctx context.Context, alert PriceAlert) (int64, error) {
+	if err := alert.Validate(); err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `INSERT INTO price_alerts (ShoeID, Kind, Threshold, Currency, CreatedBy) SELECT ID, ?, ?, ?, ? FROM items WHERE ID = ? AND Category = '` + ShoeCategory + `' AND DeletedAt IS NULL`
+	result, err := db.conn().ExecContext(ctx, query, alert.Kind, alert.Threshold, nullIfEmpty(alert.Currency), nullIfEmpty(actorFrom(ctx)), alert.ShoeID)
+	if err != nil {
+		return 0, fmt.Errorf("error inserting price alert: %v", err)
+	}
+	if err := checkAffected(result); err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("error getting last insert id: %v", err)
+	}
+	return id, nil
+}
+
+func (db *DB) QueryPriceAlerts(ctx context.Context) ([]PriceAlert, error) {
+	return db.queryPriceAlerts(ctx, priceAlertQuery+` ORDER BY price_alerts.ID`)
+}
+
+func (db *DB) GetPriceAlertsByShoeID(ctx context.Context, shoeID int64) ([]PriceAlert, error) {
+	return db.queryPriceAlerts(ctx, priceAlertQuery+` AND price_alerts.ShoeID = ? ORDER BY price_alerts.ID`, shoeID)
+}
+
+func (db *DB) DeletePriceAlert(ctx context.Context, id int64) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	result, err := db.conn().ExecContext(ctx, `DELETE FROM price_alerts WHERE ID = ?`, id)
+	if err != nil {
+		return fmt.Errorf("error deleting price alert: %v", err)
+	}
+	return checkAffected(result)
+}
+
+func (db *DB) MarkPriceAlertTriggered(ctx context.Context, id int64, at time.Time) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	result, err := db.conn().ExecContext(ctx, `UPDATE price_alerts SET LastTriggeredAt = ? WHERE ID = ?`, at.UTC(), id)
+	if err != nil {
+		return fmt.Errorf("error updating price alert: %v", err)
+	}
+	return checkAffected(result)
+}
+
+// EvaluatePriceAlerts checks the alerts of the shoe against a newly recorded
+// price and marks the ones it triggers.
+func EvaluatePriceAlerts(ctx context.Context, store interface {
+	PriceStore
+	AlertStore
+}, price ShoePrice) ([]TriggeredAlert, error) {
+	alerts, err := store.GetPriceAlertsByShoeID(ctx, price.ShoeID)
+	if err != nil || len(alerts) == 0 {
+		return nil, err
+	}
+
+	prices, err := store.GetShoePrices(ctx, price.ShoeID)
+	if err != nil {
+		return nil, err
+	}
+	var previous *ShoePrice
+	for i := range prices {
+		if prices[i].ID == price.ID {
+			break
+		}
+		previous = &prices[i]
+	}
+
+	var triggered []TriggeredAlert
+	for _, alert := range alerts {
+		if !alert.Triggered(previous, price) {
+			continue
+		}
+		if err := store.MarkPriceAlertTriggered(ctx, alert.ID, price.ObservedAt); err != nil {
+			return triggered, err
+		}
+		at := price.ObservedAt
+		alert.LastTriggeredAt = &at
+		triggered = append(triggered, TriggeredAlert{Alert: alert, Previous: previous, Price: price})
+	}
+	return triggered, nil
+}
@@ -0,0 +1,86 @@
+package database
+
+import (
+	"context"
+	"fmt"
+)
+
+// ShoeAsset is where the pictures of a shoe are published. Deleted shoes are
+// listed as well, their files are kept until the shoe is purged.
+type ShoeAsset struct {
+	ID             int64  `json:"id"`
+	ProductName    string `json:"product_name"`
+	MainPicture    string `json:"main_picture"`
+	SpinningGifURL string `json:"spinning_gif_url"`
+	Deleted        bool   `json:"deleted"`
+}
+
+func (db *DB) ListShoeAssets(ctx context.Context) ([]ShoeAsset, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT items.ID, items.Name, COALESCE(items.Picture, ''), COALESCE(shoe_details.SpinningGifURL, ''), items.DeletedAt IS NOT NULL
+		FROM items LEFT JOIN shoe_details ON shoe_details.ItemID = items.ID
+		WHERE items.Category = '` + ShoeCategory + `'
+		ORDER BY items.ID
+	`
+	rows, err := db.conn().QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error querying shoe assets: %v", err)
+	}
+	defer rows.Close()
+
+	var assets []ShoeAsset
+	for rows.Next() {
+		var asset ShoeAsset
+		if err := rows.Scan(&asset.ID, &asset.ProductName, &asset.MainPicture, &asset.SpinningGifURL, &asset.Deleted); err != nil {
+			return nil, fmt.Errorf("error scanning shoe asset: %v", err)
+		}
+		assets = append(assets, asset)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading shoe assets: %v", err)
+	}
+	return assets, nil
+}
+
+// UpdateShoeAssets points a shoe that is not deleted at its published
+// pictures.
+func (db *DB) UpdateShoeAssets(ctx context.Context, id int64, mainPicture, spinningGifURL string) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	return db.inTx(ctx, func(conn dbtx) error {
+		if err := updateShoeDetails(ctx, conn, id, []string{"SpinningGifURL"}, spinningGifURL); err != nil {
+			return err
+		}
+		return updateColumns(ctx, conn, "items", id, []string{"Picture"}, mainPicture)
+	})
+}
+
+// ListPictureLocations returns the LocalLocation of every picture, deleted
+// ones included.
+func (db *DB) ListPictureLocations(ctx context.Context) ([]string, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.conn().QueryContext(ctx, `SELECT LocalLocation FROM pictures WHERE LocalLocation IS NOT NULL AND LocalLocation <> '' ORDER BY ID`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying picture locations: %v", err)
+	}
+	defer rows.Close()
+
+	var locations []string
+	for rows.Next() {
+		var location string
+		if err := rows.Scan(&location); err != nil {
+			return nil, fmt.Errorf("error scanning picture location: %v", err)
+		}
+		locations = append(locations, location)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading picture locations: %v", err)
+	}
+	return locations, nil
+}
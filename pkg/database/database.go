@@ -1,66 +1,117 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// DefaultQueryTimeout bounds every call into the database that does not run
+// a migration, on top of whatever deadline the caller's context carries.
+const DefaultQueryTimeout = 10 * time.Second
+
+// ErrNotFound is returned by updates, deletes and merges of rows that do not
+// exist. Lookups return nil instead.
+var ErrNotFound = errors.New("not found")
+
 type DB struct {
 	*sql.DB
+	migrationsFS fs.FS
+	queryTimeout time.Duration
+	tx           *sql.Tx
 }
 
-func GetDB(databasePath string) (*DB, error) {
-	db, err := sql.Open("sqlite3", databasePath)
-	if err != nil {
-		return nil, fmt.Errorf("error opening database: %v", err)
-	}
-	return &DB{db}, nil
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
 }
 
-func (db *DB) Initialize() error {
-	query, sqlErr := ReadSQLFile("data/sql/tables/shoes.sql")
-	if sqlErr != nil {
-		return fmt.Errorf("can't read file: %v", sqlErr)
+// conn is what the store methods run their statements on: the pool, or the
+// transaction when the DB belongs to a unit of work.
+func (db *DB) conn() dbtx {
+	if db.tx != nil {
+		return db.tx
 	}
-	query2, sqlErr2 := ReadSQLFile("data/sql/tables/shoentries.sql")
-	if sqlErr2 != nil {
-		return fmt.Errorf("can't read file: %v", sqlErr2)
+	return db.DB
+}
+
+// inTx runs fn in the transaction of the unit of work, or in a transaction of
+// its own, for writes that touch more than one row.
+func (db *DB) inTx(ctx context.Context, fn func(conn dbtx) error) error {
+	if db.tx != nil {
+		return fn(db.tx)
 	}
-	query3, sqlErr3 := ReadSQLFile("data/sql/tables/restaurants.sql")
-	if sqlErr3 != nil {
-		return fmt.Errorf("can't read file: %v", sqlErr3)
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
 	}
-	query4, sqlErr4 := ReadSQLFile("data/sql/tables/foodentries.sql")
-	if sqlErr4 != nil {
-		return fmt.Errorf("can't read file: %v", sqlErr4)
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
 	}
-	query5, sqlErr5 := ReadSQLFile("data/sql/tables/pictures.sql")
-	if sqlErr5 != nil {
-		return fmt.Errorf("can't read file: %v", sqlErr5)
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
 	}
+	return nil
+}
 
-	_, err := db.Exec(query)
+func GetDB(databasePath string) (*DB, error) {
+	if dir := filepath.Dir(databasePath); dir != "" {
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			return nil, fmt.Errorf("error creating database directory: %v", err)
+		}
+	}
+	// The driver runs the pragma on every new connection in the pool.
+	db, err := sql.Open("sqlite3", withForeignKeys(databasePath))
 	if err != nil {
-		return fmt.Errorf("error creating shoe table: %v", err)
+		return nil, fmt.Errorf("error opening database: %v", err)
+	}
+	return &DB{DB: db, queryTimeout: DefaultQueryTimeout}, nil
+}
+
+// SetQueryTimeout changes the per-call timeout, zero disables it.
+func (db *DB) SetQueryTimeout(timeout time.Duration) {
+	db.queryTimeout = timeout
+}
+
+func (db *DB) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if db.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
 	}
-	_, err2 := db.Exec(query2)
-	if err2 != nil {
-		return fmt.Errorf("error creating shoe table: %v", err2)
+	return context.WithTimeout(ctx, db.queryTimeout)
+}
+
+func withForeignKeys(databasePath string) string {
+	separator := "?"
+	if strings.Contains(databasePath, "?") {
+		separator = "&"
 	}
-	_, err3 := db.Exec(query3)
-	if err3 != nil {
-		return fmt.Errorf("error creating shoe table: %v", err3)
+	return databasePath + separator + "_foreign_keys=on"
+}
+
+// UseMigrationsDir makes the migrations in dir take the place of the ones
+// embedded in the binary. An empty dir restores the embedded set.
+func (db *DB) UseMigrationsDir(dir string) error {
+	if dir == "" {
+		db.migrationsFS = nil
+		return nil
 	}
-	_, err4 := db.Exec(query4)
-	if err4 != nil {
-		return fmt.Errorf("error creating shoe table: %v", err4)
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("error opening migrations directory: %v", err)
 	}
-	_, err5 := db.Exec(query5)
-	if err5 != nil {
-		return fmt.Errorf("error creating shoe table: %v", err5)
+	if !info.IsDir() {
+		return fmt.Errorf("migrations path %s is not a directory", dir)
 	}
+	db.migrationsFS = os.DirFS(dir)
 	return nil
 }
-
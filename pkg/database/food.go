@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -10,95 +11,45 @@ import (
 	_ "github.com/mattn/go-sqlite3"
 )
 
-func (db *DB) InsertRestaurant(rt restaurant.RestaurantDetails) (int64, error) {
-	attributesJSON, err := json.Marshal(rt.Attributes)
-	if err != nil {
-		return 0, fmt.Errorf("error marshalling attributes to JSON: %v", err)
-	}
-	query := `INSERT INTO restaurants (Name, Attributes) VALUES (?, ?)`
-	result, err := db.Exec(query, rt.Name, attributesJSON)
+// InsertRestaurant adds the restaurant as an item of the restaurants category
+// and returns its ID.
+func (db *DB) InsertRestaurant(ctx context.Context, rt restaurant.RestaurantDetails) (int64, error) {
+	item, err := restaurantItem(rt)
 	if err != nil {
-		return 0, fmt.Errorf("error inserting new product details: %v", err)
+		return 0, err
 	}
-	return result.LastInsertId()
+	return db.InsertItem(ctx, item)
 }
 
-func (db *DB) QueryRestaurantTemplate(query string, params ...interface{}) ([]restaurant.RestaurantDetails, error) {
-	rows, err := db.Query(query, params...)
+func restaurantItem(rt restaurant.RestaurantDetails) (Item, error) {
+	attributesJSON, err := json.Marshal(rt.Attributes)
 	if err != nil {
-		return nil, fmt.Errorf("error querying product details: %v", err)
-	}
-	defer rows.Close()
-
-	var productsList []restaurant.RestaurantDetails
-	for rows.Next() {
-		var rt restaurant.RestaurantDetails
-		var attributesJSON string
-		err := rows.Scan(&rt.ID, &rt.Name, &attributesJSON)
-		if err != nil {
-			return nil, fmt.Errorf("error scanning product details: %v", err)
-		}
-		json.Unmarshal([]byte(attributesJSON), &rt.Attributes)
-		productsList = append(productsList, rt)
-	}
-	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("error reading product details rows: %v", err)
+		return Item{}, fmt.Errorf("error marshalling attributes to JSON: %v", err)
 	}
-	return productsList, nil
+	return Item{Category: RestaurantCategory, Name: rt.Name, Attributes: string(attributesJSON)}, nil
 }
 
-func (db *DB) QueryRestaurantByName(name string) ([]restaurant.RestaurantDetails, error) {
-	query := `SELECT ID, Name, Attributes FROM restaurants WHERE Name = ?`
-	return db.QueryRestaurantTemplate(query, name)
-}
-
-func (db *DB) QueryRestaurants() ([]restaurant.RestaurantDetails, error) {
-	query := `SELECT ID, Name, Attributes FROM restaurants`
-	return db.QueryRestaurantTemplate(query)
-}
-
-func (db *DB) InsertFoodentry(name string, itemID int64, pictureID int64) (int64, error) {
-	query := `INSERT INTO foodentries (Name, ItemID, PictureID) VALUES (?, ?, ?)`
-	result, err := db.Exec(query, name, itemID, pictureID)
-	if err != nil {
-		return 0, fmt.Errorf("error inserting foodentry: %v", err)
-	}
-	id, err := result.LastInsertId()
-	if err != nil {
-		return 0, fmt.Errorf("error getting last insert id: %v", err)
-	}
-	return id, nil
+// InsertFoodentry adds an entry named after the dish to the restaurant itemID.
+func (db *DB) InsertFoodentry(ctx context.Context, name string, itemID int64, pictureID int64) (int64, error) {
+	return db.InsertEntry(ctx, RestaurantCategory, name, itemID, pictureID)
 }
 
+// Restaurant is an item of the restaurants category.
 type Restaurant struct {
-	ID          int64     `json:"id"`
-	Name        string    `json:"name"`
-	Subtitle    string    `json:"subtitle"`
-	LastSale    string    `json:"last_sale"`
-	ProductName string    `json:"product_name"`
-	MainPicture string    `json:"main_picture"`
-	Attributes  string    `json:"attributes"`
-	Description string    `json:"description"`
-	Timestamp   time.Time `json:"timestamp"`
-}
-
-type Foodentry struct {
-	ID        int64     `json:"id"`
-	Name      string    `json:"foodname"`
-	ItemID    int64     `json:"item_id"`
-	PictureID int64     `json:"picture_id"`
-	UpdatedAt time.Time `json:"updated_at"`
-	CreatedAt time.Time `json:"created_at"`
+	ID         int64     `json:"id"`
+	Name       string    `json:"name"`
+	Attributes string    `json:"attributes"`
+	Timestamp  time.Time `json:"timestamp"`
 }
 
 type FoodentryDetails struct {
-	FoodentryID          int64     `json:"shoentry_id"`
+	FoodentryID          int64     `json:"foodentry_id"`
 	FoodentryName        string    `json:"foodentry_name"`
 	ItemID               int64     `json:"item_id"`
-	RestaurantID         int64     `json:"shoe_id"`
-	RestaurantName       string    `json:"shoe_name"`
-	RestaurantAttributes string    `json:"shoe_attributes"`
-	RestaurantTimestamp  time.Time `json:"shoe_timestamp"`
+	RestaurantID         int64     `json:"restaurant_id"`
+	RestaurantName       string    `json:"restaurant_name"`
+	RestaurantAttributes string    `json:"restaurant_attributes"`
+	RestaurantTimestamp  time.Time `json:"restaurant_timestamp"`
 	PictureID            int64     `json:"picture_id"`
 	PictureLocalPath     string    `json:"picture_local_path"`
 	PictureDiscordURL    string    `json:"picture_discord_url"`
@@ -108,59 +59,74 @@ type FoodentryDetails struct {
 	PictureTakenAt       time.Time `json:"picture_taken_at"`
 	PictureUpdatedAt     time.Time `json:"picture_updated_at"`
 	PictureCreatedAt     time.Time `json:"picture_created_at"`
-	FoodentryUpdatedAt   time.Time `json:"shoentry_updated_at"`
-	FoodentryCreatedAt   time.Time `json:"shoentry_created_at"`
+	FoodentryUpdatedAt   time.Time `json:"foodentry_updated_at"`
+	FoodentryCreatedAt   time.Time `json:"foodentry_created_at"`
 }
 
-func (db *DB) GetRestaurantByName(name string) (*Restaurant, error) {
-	query := `SELECT ID, Name, Attributes, Timestamp FROM restaurants WHERE Name = ?`
-	row := db.QueryRow(query, name)
+func restaurantOf(item *Item, err error) (*Restaurant, error) {
+	if item == nil || err != nil {
+		return nil, err
+	}
+	return &Restaurant{ID: item.ID, Name: item.Name, Attributes: item.Attributes, Timestamp: item.CreatedAt}, nil
+}
 
-	var restaurant Restaurant
-	err := row.Scan(&restaurant.ID, &restaurant.Name, &restaurant.Attributes, &restaurant.Timestamp)
+func restaurantsOf(items []Item, err error) ([]Restaurant, error) {
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
-		}
-		return nil, fmt.Errorf("error retrieving restaurant: %v", err)
+		return nil, err
+	}
+	restaurants := []Restaurant{}
+	for i := range items {
+		restaurant, _ := restaurantOf(&items[i], nil)
+		restaurants = append(restaurants, *restaurant)
 	}
+	return restaurants, nil
+}
+
+// ListRestaurants returns the restaurants by ID.
+func (db *DB) ListRestaurants(ctx context.Context) ([]Restaurant, error) {
+	return restaurantsOf(db.ListItems(ctx, RestaurantCategory))
+}
 
-	return &restaurant, nil
+func (db *DB) GetRestaurantByName(ctx context.Context, name string) (*Restaurant, error) {
+	return restaurantOf(db.GetItemByName(ctx, RestaurantCategory, name))
 }
 
-func (db *DB) GetFoodEntryByID(id int64) (*FoodentryDetails, error) {
-	query := `
-		SELECT 
-			foodentries.ID AS FoodentryID,
-			foodentries.ItemID,
-			foodentries.Name AS FoodentryName,
-			restaurants.ID AS RestaurantID,
-			restaurants.Name AS RestaurantName,
-			restaurants.Attributes AS RestaurantAttributes,
-			restaurants.Timestamp AS RestaurantTimestamp,
-			foodentries.PictureID,
-			pictures.LocalLocation AS PictureLocalPath,
-			pictures.DiscordImageLink AS PictureDiscordURL,
-			pictures.DiscordMessageId AS PictureMessageID,
-			pictures.Latitude AS PictureLatitude,
-			pictures.Longitude AS PictureLongitude,
-			pictures.TakenAt AS PictureTakenAt,
-			pictures.UpdatedAt AS PictureUpdatedAt,
-			pictures.CreatedAt AS PictureCreatedAt,
-			foodentries.UpdatedAt AS FoodentryUpdatedAt,
-			foodentries.CreatedAt AS FoodentryCreatedAt
-		FROM 
-			foodentries
-		INNER JOIN 
-			restaurants ON foodentries.ItemID = restaurants.ID
-		INNER JOIN 
-			pictures ON foodentries.PictureID = pictures.ID
-		WHERE 
-			foodentries.ID = ?
-	`
-
-	row := db.QueryRow(query, id)
+func (db *DB) GetRestaurantByID(ctx context.Context, id int64) (*Restaurant, error) {
+	return restaurantOf(db.GetItemByID(ctx, RestaurantCategory, id))
+}
 
+const foodentryDetailsQuery = `
+	SELECT
+		entries.ID AS FoodentryID,
+		entries.ItemID,
+		COALESCE(entries.Name, '') AS FoodentryName,
+		items.ID AS RestaurantID,
+		items.Name AS RestaurantName,
+		COALESCE(items.Attributes, '{}') AS RestaurantAttributes,
+		items.CreatedAt AS RestaurantTimestamp,
+		entries.PictureID,
+		pictures.LocalLocation AS PictureLocalPath,
+		pictures.DiscordImageLink AS PictureDiscordURL,
+		pictures.DiscordMessageId AS PictureMessageID,
+		pictures.Latitude AS PictureLatitude,
+		pictures.Longitude AS PictureLongitude,
+		pictures.TakenAt AS PictureTakenAt,
+		pictures.UpdatedAt AS PictureUpdatedAt,
+		pictures.CreatedAt AS PictureCreatedAt,
+		entries.UpdatedAt AS FoodentryUpdatedAt,
+		entries.CreatedAt AS FoodentryCreatedAt
+	FROM
+		entries
+	INNER JOIN
+		items ON entries.ItemID = items.ID
+	INNER JOIN
+		pictures ON entries.PictureID = pictures.ID
+	WHERE
+		entries.Category = '` + RestaurantCategory + `'
+		AND entries.DeletedAt IS NULL AND items.DeletedAt IS NULL AND pictures.DeletedAt IS NULL
+`
+
+func scanFoodentryDetails(row rowScanner) (FoodentryDetails, error) {
 	var details FoodentryDetails
 	err := row.Scan(
 		&details.FoodentryID,
@@ -182,12 +148,45 @@ func (db *DB) GetFoodEntryByID(id int64) (*FoodentryDetails, error) {
 		&details.FoodentryUpdatedAt,
 		&details.FoodentryCreatedAt,
 	)
+	return details, err
+}
+
+func (db *DB) GetFoodEntryByID(ctx context.Context, id int64) (*FoodentryDetails, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	details, err := scanFoodentryDetails(db.conn().QueryRowContext(ctx, foodentryDetailsQuery+` AND entries.ID = ?`, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, nil // No shoentry found with the given ID
+			return nil, nil // No foodentry found with the given ID
 		}
-		return nil, fmt.Errorf("error retrieving shoentry: %v", err)
+		return nil, fmt.Errorf("error retrieving foodentry: %v", err)
 	}
 
 	return &details, nil
 }
+
+// ListFoodentries returns the foodentries by ID.
+func (db *DB) ListFoodentries(ctx context.Context) ([]FoodentryDetails, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.conn().QueryContext(ctx, foodentryDetailsQuery+` ORDER BY entries.ID`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying foodentries: %v", err)
+	}
+	defer rows.Close()
+
+	foodentries := []FoodentryDetails{}
+	for rows.Next() {
+		details, err := scanFoodentryDetails(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning foodentry: %v", err)
+		}
+		foodentries = append(foodentries, details)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading foodentry rows: %v", err)
+	}
+	return foodentries, nil
+}
@@ -0,0 +1,488 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type actorKey struct{}
+
+// WithActor names who makes the changes done with ctx in the history.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+func actorFrom(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// Change is a row of the history table. Empty values stand for NULL.
+type Change struct {
+	ID        int64     `json:"id"`
+	Table     string    `json:"table"`
+	RowID     int64     `json:"row_id"`
+	Field     string    `json:"field"`
+	OldValue  string    `json:"old_value"`
+	NewValue  string    `json:"new_value"`
+	ChangedBy string    `json:"changed_by"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+func recordChange(ctx context.Context, conn dbtx, table string, id int64, field, oldValue, newValue string) error {
+	query := `INSERT INTO history (TableName, RowID, Field, OldValue, NewValue, ChangedBy, ChangedAt) VALUES (?, ?, ?, ?, ?, ?, ?)`
+	_, err := conn.ExecContext(ctx, query, table, id, field, nullIfEmpty(oldValue), nullIfEmpty(newValue), actorFrom(ctx), time.Now())
+	if err != nil {
+		return fmt.Errorf("error recording history: %v", err)
+	}
+	return nil
+}
+
+// recordCreated records the insert of a row, by the actor of ctx.
+func recordCreated(ctx context.Context, conn dbtx, table string, id int64) error {
+	return recordChange(ctx, conn, table, id, "CreatedAt", "", time.Now().Format(time.RFC3339Nano))
+}
+
+func nullIfEmpty(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+func validTable(table string) error {
+	for _, t := range Tables {
+		if t == table {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown table %q", table)
+}
+
+// selectFields reads columns of a row that is not deleted as strings.
+func selectFields(ctx context.Context, conn dbtx, table string, id int64, columns []string) (map[string]string, error) {
+	query := fmt.Sprintf(`SELECT %s FROM %s WHERE ID = ? AND DeletedAt IS NULL`, strings.Join(columns, ", "), table)
+	return queryFields(ctx, conn, query, table, id, columns)
+}
+
+// queryFields reads the columns query selects for id as strings.
+func queryFields(ctx context.Context, conn dbtx, query, what string, id int64, columns []string) (map[string]string, error) {
+	values := make([]sql.NullString, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := conn.QueryRowContext(ctx, query, id).Scan(dest...); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("error reading %s: %v", what, err)
+	}
+	fields := make(map[string]string, len(columns))
+	for i, column := range columns {
+		fields[column] = values[i].String
+	}
+	return fields, nil
+}
+
+// updateFields sets columns of a row that is not deleted and records every
+// value that changed in the history.
+func (db *DB) updateFields(ctx context.Context, table string, id int64, columns []string, values ...interface{}) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	return db.inTx(ctx, func(conn dbtx) error {
+		return updateColumns(ctx, conn, table, id, columns, values...)
+	})
+}
+
+// updateColumns is updateFields with conn.
+func updateColumns(ctx context.Context, conn dbtx, table string, id int64, columns []string, values ...interface{}) error {
+	before, err := selectFields(ctx, conn, table, id, columns)
+	if err != nil {
+		return err
+	}
+
+	assignments := make([]string, len(columns))
+	for i, column := range columns {
+		assignments[i] = column + ` = ?`
+	}
+	query := fmt.Sprintf(`UPDATE %s SET %s, UpdatedAt = ? WHERE ID = ?`, table, strings.Join(assignments, ", "))
+	if _, err := conn.ExecContext(ctx, query, append(values, time.Now(), id)...); err != nil {
+		return fmt.Errorf("error updating %s: %v", table, err)
+	}
+
+	after, err := selectFields(ctx, conn, table, id, columns)
+	if err != nil {
+		return err
+	}
+	return recordFieldChanges(ctx, conn, table, id, columns, before, after)
+}
+
+func recordFieldChanges(ctx context.Context, conn dbtx, table string, id int64, columns []string, before, after map[string]string) error {
+	for _, column := range columns {
+		if before[column] != after[column] {
+			if err := recordChange(ctx, conn, table, id, column, before[column], after[column]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func setDeletedAt(ctx context.Context, conn dbtx, table string, id int64, deletedAt time.Time) (bool, error) {
+	query := fmt.Sprintf(`UPDATE %s SET DeletedAt = ? WHERE ID = ? AND DeletedAt IS NULL`, table)
+	result, err := conn.ExecContext(ctx, query, deletedAt, id)
+	if err != nil {
+		return false, fmt.Errorf("error deleting from %s: %v", table, err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	return true, recordChange(ctx, conn, table, id, "DeletedAt", "", deletedAt.Format(time.RFC3339Nano))
+}
+
+func clearDeletedAt(ctx context.Context, conn dbtx, table string, id int64) error {
+	var deletedAt sql.NullString
+	if err := conn.QueryRowContext(ctx, fmt.Sprintf(`SELECT DeletedAt FROM %s WHERE ID = ?`, table), id).Scan(&deletedAt); err != nil {
+		return fmt.Errorf("error reading %s: %v", table, err)
+	}
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf(`UPDATE %s SET DeletedAt = NULL WHERE ID = ?`, table), id); err != nil {
+		return fmt.Errorf("error restoring %s: %v", table, err)
+	}
+	return recordChange(ctx, conn, table, id, "DeletedAt", deletedAt.String, "")
+}
+
+func queryIDs(ctx context.Context, conn dbtx, query string, params ...interface{}) ([]int64, error) {
+	rows, err := conn.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying ids: %v", err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id sql.NullInt64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("error scanning id: %v", err)
+		}
+		if id.Valid {
+			ids = append(ids, id.Int64)
+		}
+	}
+	return ids, rows.Err()
+}
+
+// deleteRow marks the row and the rows that only exist for it as deleted: the
+// entries of an item, the picture of an entry and the entries showing a
+// picture. It returns the pictures that were deleted.
+func (db *DB) deleteRow(ctx context.Context, table string, id int64) ([]Picture, error) {
+	if err := validTable(table); err != nil {
+		return nil, err
+	}
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	var deleted []Picture
+	err := db.inTx(ctx, func(conn dbtx) error {
+		var picture *Picture
+		if table == "pictures" {
+			var err error
+			if picture, err = getPicture(ctx, conn, pictureQuery+` AND DeletedAt IS NULL`, id); err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		ok, err := setDeletedAt(ctx, conn, table, id, now)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrNotFound
+		}
+
+		var pictureIDs []int64
+		deleteEntries := func(column string) error {
+			entryIDs, err := queryIDs(ctx, conn, fmt.Sprintf(`SELECT ID FROM entries WHERE %s = ? AND DeletedAt IS NULL`, column), id)
+			if err != nil {
+				return err
+			}
+			for _, entryID := range entryIDs {
+				ids, err := queryIDs(ctx, conn, `SELECT PictureID FROM entries WHERE ID = ?`, entryID)
+				if err != nil {
+					return err
+				}
+				pictureIDs = append(pictureIDs, ids...)
+				if _, err := setDeletedAt(ctx, conn, "entries", entryID, now); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		switch table {
+		case "items":
+			err = deleteEntries("ItemID")
+		case "entries":
+			pictureIDs, err = queryIDs(ctx, conn, `SELECT PictureID FROM entries WHERE ID = ?`, id)
+		case "pictures":
+			deleted = append(deleted, *picture)
+			err = deleteEntries("PictureID")
+			pictureIDs = nil
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, pictureID := range pictureIDs {
+			picture, err := getPicture(ctx, conn, pictureQuery+` AND DeletedAt IS NULL`, pictureID)
+			if err != nil {
+				return err
+			}
+			if picture == nil {
+				continue
+			}
+			inUse, err := pictureInUse(ctx, conn, pictureID)
+			if err != nil {
+				return err
+			}
+			if inUse {
+				continue
+			}
+			if _, err := setDeletedAt(ctx, conn, "pictures", pictureID, now); err != nil {
+				return err
+			}
+			deleted = append(deleted, *picture)
+		}
+		return nil
+	})
+	return deleted, err
+}
+
+func pictureInUse(ctx context.Context, conn dbtx, id int64) (bool, error) {
+	var inUse bool
+	query := `SELECT EXISTS (SELECT 1 FROM entries WHERE PictureID = ? AND DeletedAt IS NULL)`
+	if err := conn.QueryRowContext(ctx, query, id).Scan(&inUse); err != nil {
+		return false, fmt.Errorf("error checking picture %d: %v", id, err)
+	}
+	return inUse, nil
+}
+
+// mergeRows moves the entries of fromID to intoID and deletes fromID.
+func (db *DB) mergeRows(ctx context.Context, table, entryTable string, fromID, intoID int64) error {
+	if fromID == intoID {
+		return fmt.Errorf("error merging %s: cannot merge %d into itself", table, fromID)
+	}
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	return db.inTx(ctx, func(conn dbtx) error {
+		var exists bool
+		if err := conn.QueryRowContext(ctx, fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM %s WHERE ID = ? AND DeletedAt IS NULL)`, table), intoID).Scan(&exists); err != nil {
+			return fmt.Errorf("error reading %s: %v", table, err)
+		}
+		if !exists {
+			return ErrNotFound
+		}
+
+		entryIDs, err := queryIDs(ctx, conn, fmt.Sprintf(`SELECT ID FROM %s WHERE ItemID = ? AND DeletedAt IS NULL`, entryTable), fromID)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		for _, entryID := range entryIDs {
+			if _, err := conn.ExecContext(ctx, fmt.Sprintf(`UPDATE %s SET ItemID = ?, UpdatedAt = ? WHERE ID = ?`, entryTable), intoID, now, entryID); err != nil {
+				return fmt.Errorf("error moving %s: %v", entryTable, err)
+			}
+			if err := recordChange(ctx, conn, entryTable, entryID, "ItemID", fmt.Sprint(fromID), fmt.Sprint(intoID)); err != nil {
+				return err
+			}
+		}
+
+		ok, err := setDeletedAt(ctx, conn, table, fromID, now)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
+
+// RestoreRow undoes the deletion of a row together with the rows that were
+// deleted along with it.
+func (db *DB) RestoreRow(ctx context.Context, table string, id int64) error {
+	if err := validTable(table); err != nil {
+		return err
+	}
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	return db.inTx(ctx, func(conn dbtx) error {
+		var deletedAt sql.NullString
+		err := conn.QueryRowContext(ctx, fmt.Sprintf(`SELECT DeletedAt FROM %s WHERE ID = ?`, table), id).Scan(&deletedAt)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("error reading %s: %v", table, err)
+		}
+		if !deletedAt.Valid {
+			return fmt.Errorf("%s %d is not deleted", table, id)
+		}
+
+		if table == "entries" {
+			var itemDeleted bool
+			query := `SELECT EXISTS (SELECT 1 FROM items WHERE ID = (SELECT ItemID FROM entries WHERE ID = ?) AND DeletedAt IS NOT NULL)`
+			if err := conn.QueryRowContext(ctx, query, id).Scan(&itemDeleted); err != nil {
+				return fmt.Errorf("error reading items: %v", err)
+			}
+			if itemDeleted {
+				return fmt.Errorf("the item of entries %d is deleted, restore it first", id)
+			}
+		}
+
+		// Rows deleted along with this one carry the same DeletedAt.
+		sameDeletion := fmt.Sprintf(`DeletedAt = (SELECT DeletedAt FROM %s WHERE ID = ?)`, table)
+		restore := map[string][]int64{}
+		collect := func(target, query string) error {
+			ids, err := queryIDs(ctx, conn, query, id, id)
+			if err != nil {
+				return err
+			}
+			restore[target] = append(restore[target], ids...)
+			return nil
+		}
+		switch table {
+		case "items":
+			err = collect("entries", `SELECT ID FROM entries WHERE ItemID = ? AND `+sameDeletion)
+			if err == nil {
+				err = collect("pictures", `SELECT ID FROM pictures WHERE ID IN (SELECT PictureID FROM entries WHERE ItemID = ?) AND `+sameDeletion)
+			}
+		case "entries":
+			err = collect("pictures", `SELECT ID FROM pictures WHERE ID = (SELECT PictureID FROM entries WHERE ID = ?) AND `+sameDeletion)
+		case "pictures":
+			err = collect("entries", `SELECT ID FROM entries WHERE PictureID = ? AND `+sameDeletion)
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := clearDeletedAt(ctx, conn, table, id); err != nil {
+			return err
+		}
+		for target, ids := range restore {
+			for _, rowID := range ids {
+				if err := clearDeletedAt(ctx, conn, target, rowID); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// Purged lists what PurgeRow removed whose files are still on disk.
+type Purged struct {
+	Pictures []Picture
+	// Shoe is set when a shoe was purged, its images are named after it.
+	Shoe *Shoe
+}
+
+// PurgeRow removes a deleted row for good, with its entries and the pictures
+// no entry shows anymore.
+func (db *DB) PurgeRow(ctx context.Context, table string, id int64) (*Purged, error) {
+	if err := validTable(table); err != nil {
+		return nil, err
+	}
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	purged := &Purged{}
+	err := db.inTx(ctx, func(conn dbtx) error {
+		var deletedAt sql.NullString
+		err := conn.QueryRowContext(ctx, fmt.Sprintf(`SELECT DeletedAt FROM %s WHERE ID = ?`, table), id).Scan(&deletedAt)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("error reading %s: %v", table, err)
+		}
+		if !deletedAt.Valid {
+			return fmt.Errorf("%s %d is not deleted, delete it before purging", table, id)
+		}
+
+		var pictureIDs []int64
+		switch table {
+		case "items":
+			var category, name string
+			if err := conn.QueryRowContext(ctx, `SELECT Category, Name FROM items WHERE ID = ?`, id).Scan(&category, &name); err != nil {
+				return fmt.Errorf("error reading item: %v", err)
+			}
+			if category == ShoeCategory {
+				purged.Shoe = &Shoe{ID: id, ProductName: name}
+			}
+			pictureIDs, err = queryIDs(ctx, conn, `SELECT PictureID FROM entries WHERE ItemID = ?`, id)
+		case "entries":
+			pictureIDs, err = queryIDs(ctx, conn, `SELECT PictureID FROM entries WHERE ID = ?`, id)
+		case "pictures":
+			var picture *Picture
+			if picture, err = getPicture(ctx, conn, pictureQuery, id); picture != nil {
+				purged.Pictures = append(purged.Pictures, *picture)
+			}
+		}
+		if err != nil {
+			return err
+		}
+
+		// The foreign keys remove the entries of an item or picture, and the
+		// details, prices and alerts of a shoe.
+		if _, err := conn.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE ID = ?`, table), id); err != nil {
+			return fmt.Errorf("error purging %s: %v", table, err)
+		}
+		if err := recordChange(ctx, conn, table, id, "PurgedAt", "", time.Now().Format(time.RFC3339Nano)); err != nil {
+			return err
+		}
+
+		unused, err := purgeUnreferencedPictures(ctx, conn, pictureIDs)
+		purged.Pictures = append(purged.Pictures, unused...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return purged, nil
+}
+
+// History lists the changes of a row, oldest first.
+func (db *DB) History(ctx context.Context, table string, id int64) ([]Change, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ID, TableName, RowID, Field, OldValue, NewValue, ChangedBy, ChangedAt FROM history WHERE TableName = ? AND RowID = ? ORDER BY ID`
+	rows, err := db.conn().QueryContext(ctx, query, table, id)
+	if err != nil {
+		return nil, fmt.Errorf("error querying history: %v", err)
+	}
+	defer rows.Close()
+
+	var changes []Change
+	for rows.Next() {
+		var c Change
+		var oldValue, newValue, changedBy sql.NullString
+		if err := rows.Scan(&c.ID, &c.Table, &c.RowID, &c.Field, &oldValue, &newValue, &changedBy, &c.ChangedAt); err != nil {
+			return nil, fmt.Errorf("error scanning history: %v", err)
+		}
+		c.OldValue, c.NewValue, c.ChangedBy = oldValue.String, newValue.String, changedBy.String
+		changes = append(changes, c)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading history rows: %v", err)
+	}
+	return changes, nil
+}
@@ -0,0 +1,224 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+type OrphanedRow struct {
+	Table     string
+	RowID     int64
+	Column    string
+	MissingID int64
+}
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// orphanedRowsQuery finds the entries that point at a missing item or
+// picture, legacyOrphanedRowsQuery the entries of the tables before version 9
+// for the foreign key migration.
+const orphanedRowsQuery = `
+	SELECT 'entries', ID, 'ItemID', ItemID FROM entries
+		WHERE ItemID NOT IN (SELECT ID FROM items)
+	UNION ALL
+	SELECT 'entries', ID, 'PictureID', PictureID FROM entries
+		WHERE PictureID IS NOT NULL AND PictureID NOT IN (SELECT ID FROM pictures)
+`
+
+const legacyOrphanedRowsQuery = `
+	SELECT 'shoentries', ID, 'ItemID', ItemID FROM shoentries
+		WHERE ItemID IS NOT NULL AND ItemID NOT IN (SELECT ID FROM shoes)
+	UNION ALL
+	SELECT 'shoentries', ID, 'PictureID', PictureID FROM shoentries
+		WHERE PictureID IS NOT NULL AND PictureID NOT IN (SELECT ID FROM pictures)
+	UNION ALL
+	SELECT 'foodentries', ID, 'ItemID', ItemID FROM foodentries
+		WHERE ItemID IS NOT NULL AND ItemID NOT IN (SELECT ID FROM restaurants)
+	UNION ALL
+	SELECT 'foodentries', ID, 'PictureID', PictureID FROM foodentries
+		WHERE PictureID IS NOT NULL AND PictureID NOT IN (SELECT ID FROM pictures)
+`
+
+func findOrphanedRows(ctx context.Context, q queryer, query string) ([]OrphanedRow, error) {
+	rows, err := q.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error querying orphaned rows: %v", err)
+	}
+	defer rows.Close()
+
+	var orphans []OrphanedRow
+	for rows.Next() {
+		var o OrphanedRow
+		if err := rows.Scan(&o.Table, &o.RowID, &o.Column, &o.MissingID); err != nil {
+			return nil, fmt.Errorf("error scanning orphaned row: %v", err)
+		}
+		orphans = append(orphans, o)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading orphaned rows: %v", err)
+	}
+	return orphans, nil
+}
+
+// FindOrphanedRows lists entries that point at an item or picture that does
+// not exist.
+func (db *DB) FindOrphanedRows(ctx context.Context) ([]OrphanedRow, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	return findOrphanedRows(ctx, db.conn(), orphanedRowsQuery)
+}
+
+// OrphanedRowReport returns the rows quarantined by the foreign key migration.
+func (db *DB) OrphanedRowReport(ctx context.Context) ([]OrphanedRow, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.conn().QueryContext(ctx, `SELECT TableName, RowID, ColumnName, MissingID FROM orphaned_rows ORDER BY ID`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying orphaned_rows: %v", err)
+	}
+	defer rows.Close()
+
+	var orphans []OrphanedRow
+	for rows.Next() {
+		var o OrphanedRow
+		if err := rows.Scan(&o.Table, &o.RowID, &o.Column, &o.MissingID); err != nil {
+			return nil, fmt.Errorf("error scanning orphaned_rows: %v", err)
+		}
+		orphans = append(orphans, o)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading orphaned_rows: %v", err)
+	}
+	return orphans, nil
+}
+
+const createOrphanedRowsTable = `
+	CREATE TABLE IF NOT EXISTS orphaned_rows (
+		ID INTEGER PRIMARY KEY AUTOINCREMENT,
+		TableName TEXT NOT NULL,
+		RowID INTEGER NOT NULL,
+		ColumnName TEXT NOT NULL,
+		MissingID INTEGER,
+		DetectedAt DATETIME DEFAULT CURRENT_TIMESTAMP
+	)
+`
+
+const foreignKeysUp = `
+	CREATE TABLE shoentries_new (
+		ID INTEGER PRIMARY KEY AUTOINCREMENT,
+		ItemID INTEGER REFERENCES shoes(ID) ON DELETE CASCADE,
+		PictureID INTEGER REFERENCES pictures(ID) ON DELETE CASCADE,
+		UpdatedAt DATETIME DEFAULT CURRENT_TIMESTAMP,
+		CreatedAt DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	INSERT INTO shoentries_new (ID, ItemID, PictureID, UpdatedAt, CreatedAt)
+		SELECT ID, ItemID, PictureID, UpdatedAt, CreatedAt FROM shoentries;
+	DROP TABLE shoentries;
+	ALTER TABLE shoentries_new RENAME TO shoentries;
+
+	CREATE TABLE foodentries_new (
+		ID INTEGER PRIMARY KEY AUTOINCREMENT,
+		Name TEXT,
+		ItemID INTEGER REFERENCES restaurants(ID) ON DELETE CASCADE,
+		PictureID INTEGER REFERENCES pictures(ID) ON DELETE CASCADE,
+		UpdatedAt DATETIME DEFAULT CURRENT_TIMESTAMP,
+		CreatedAt DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	INSERT INTO foodentries_new (ID, Name, ItemID, PictureID, UpdatedAt, CreatedAt)
+		SELECT ID, Name, ItemID, PictureID, UpdatedAt, CreatedAt FROM foodentries;
+	DROP TABLE foodentries;
+	ALTER TABLE foodentries_new RENAME TO foodentries;
+
+	CREATE INDEX idx_shoentries_item ON shoentries(ItemID);
+	CREATE INDEX idx_shoentries_picture ON shoentries(PictureID);
+	CREATE INDEX idx_shoentries_created ON shoentries(CreatedAt);
+	CREATE INDEX idx_foodentries_item ON foodentries(ItemID);
+	CREATE INDEX idx_foodentries_picture ON foodentries(PictureID);
+	CREATE UNIQUE INDEX idx_restaurants_name ON restaurants(Name);
+`
+
+// migrateForeignKeys rebuilds the entry tables with foreign keys. Rows that
+// would violate them are reported, recorded in orphaned_rows and have the
+// dangling reference cleared, and restaurants sharing a name are merged into
+// the oldest one so that Name can become unique.
+func migrateForeignKeys(ctx context.Context, tx *sql.Tx) error {
+	orphans, err := findOrphanedRows(ctx, tx, legacyOrphanedRowsQuery)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, createOrphanedRowsTable); err != nil {
+		return fmt.Errorf("error creating orphaned_rows: %v", err)
+	}
+	for _, o := range orphans {
+		log.Printf("Orphaned row: %s.ID=%d references missing %s %d, clearing the reference", o.Table, o.RowID, o.Column, o.MissingID)
+		_, err := tx.ExecContext(ctx, `INSERT INTO orphaned_rows (TableName, RowID, ColumnName, MissingID) VALUES (?, ?, ?, ?)`, o.Table, o.RowID, o.Column, o.MissingID)
+		if err != nil {
+			return fmt.Errorf("error recording orphaned row: %v", err)
+		}
+		// Table and column come from legacyOrphanedRowsQuery, never from user
+		// input.
+		_, err = tx.ExecContext(ctx, fmt.Sprintf("UPDATE %s SET %s = NULL WHERE ID = ?", o.Table, o.Column), o.RowID)
+		if err != nil {
+			return fmt.Errorf("error clearing orphaned reference: %v", err)
+		}
+	}
+	if len(orphans) > 0 {
+		log.Printf("Found %d orphaned row(s), see the orphaned_rows table", len(orphans))
+	}
+
+	if err := mergeDuplicateRestaurants(ctx, tx); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, foreignKeysUp); err != nil {
+		return fmt.Errorf("error adding foreign keys: %v", err)
+	}
+	return nil
+}
+
+func mergeDuplicateRestaurants(ctx context.Context, tx *sql.Tx) error {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT r.ID, keep.ID, r.Name
+		FROM restaurants r
+		INNER JOIN (SELECT MIN(ID) AS ID, Name FROM restaurants GROUP BY Name HAVING COUNT(*) > 1) keep
+			ON r.Name = keep.Name AND r.ID <> keep.ID
+	`)
+	if err != nil {
+		return fmt.Errorf("error querying duplicate restaurants: %v", err)
+	}
+	type duplicate struct {
+		id, keepID int64
+		name       string
+	}
+	var duplicates []duplicate
+	for rows.Next() {
+		var d duplicate
+		if err := rows.Scan(&d.id, &d.keepID, &d.name); err != nil {
+			rows.Close()
+			return fmt.Errorf("error scanning duplicate restaurant: %v", err)
+		}
+		duplicates = append(duplicates, d)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("error reading duplicate restaurants: %v", err)
+	}
+
+	for _, d := range duplicates {
+		log.Printf("Merging duplicate restaurant %q: ID %d into ID %d", d.name, d.id, d.keepID)
+		if _, err := tx.ExecContext(ctx, `UPDATE foodentries SET ItemID = ? WHERE ItemID = ?`, d.keepID, d.id); err != nil {
+			return fmt.Errorf("error merging restaurant %d: %v", d.id, err)
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM restaurants WHERE ID = ?`, d.id); err != nil {
+			return fmt.Errorf("error merging restaurant %d: %v", d.id, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,343 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// The categories that are more than a name and attributes: shoes come from
+// StockX and keep the rest of their details in shoe_details, restaurants from
+// OSM.
+const (
+	ShoeCategory       = "shoes"
+	RestaurantCategory = "restaurants"
+)
+
+// Item is a shoe, a restaurant or an item of any other category. Name is what
+// entries refer to it by, for a shoe its product name, Title what it is
+// called.
+type Item struct {
+	ID         int64     `json:"id"`
+	Category   string    `json:"category"`
+	Name       string    `json:"name"`
+	Title      string    `json:"title"`
+	Attributes string    `json:"attributes"`
+	Picture    string    `json:"picture"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Entry is a row of the entries table, a picture of an item.
+type Entry struct {
+	ID        int64     `json:"id"`
+	Category  string    `json:"category"`
+	Name      string    `json:"name"`
+	ItemID    int64     `json:"item_id"`
+	PictureID int64     `json:"picture_id"`
+	UpdatedAt time.Time `json:"updated_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// EntryDetails is an entry of any category with its item and picture.
+type EntryDetails struct {
+	EntryID           int64     `json:"entry_id"`
+	Category          string    `json:"category"`
+	EntryName         string    `json:"entry_name"`
+	ItemID            int64     `json:"item_id"`
+	ItemName          string    `json:"item_name"`
+	ItemTitle         string    `json:"item_title"`
+	PictureID         int64     `json:"picture_id"`
+	PictureLocalPath  string    `json:"picture_local_path"`
+	PictureDiscordURL string    `json:"picture_discord_url"`
+	PictureTakenAt    time.Time `json:"picture_taken_at"`
+	EntryCreatedAt    time.Time `json:"entry_created_at"`
+}
+
+// InsertItem adds item to its category and returns its ID.
+func (db *DB) InsertItem(ctx context.Context, item Item) (int64, error) {
+	if item.Category == "" || item.Name == "" {
+		return 0, fmt.Errorf("error inserting item: category and name are required")
+	}
+
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	var id int64
+	err := db.inTx(ctx, func(conn dbtx) error {
+		var err error
+		id, err = insertItem(ctx, conn, item)
+		return err
+	})
+	return id, err
+}
+
+// insertItem adds item and records its creation in the history.
+func insertItem(ctx context.Context, conn dbtx, item Item) (int64, error) {
+	if item.Title == "" {
+		item.Title = item.Name
+	}
+	if item.Attributes == "" {
+		item.Attributes = "{}"
+	}
+	query := `INSERT INTO items (Category, Name, Title, Attributes, Picture) VALUES (?, ?, ?, ?, ?)`
+	result, err := conn.ExecContext(ctx, query, item.Category, item.Name, item.Title, item.Attributes, item.Picture)
+	if err != nil {
+		return 0, fmt.Errorf("error inserting item: %v", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("error getting last insert id: %v", err)
+	}
+	return id, recordCreated(ctx, conn, "items", id)
+}
+
+const itemColumns = `ID, Category, Name, COALESCE(Title, Name), COALESCE(Attributes, '{}'), COALESCE(Picture, ''), CreatedAt`
+
+func scanItem(row rowScanner) (Item, error) {
+	var item Item
+	err := row.Scan(&item.ID, &item.Category, &item.Name, &item.Title, &item.Attributes, &item.Picture, &item.CreatedAt)
+	return item, err
+}
+
+func (db *DB) getItem(ctx context.Context, query string, params ...interface{}) (*Item, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	item, err := scanItem(db.conn().QueryRowContext(ctx, query, params...))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving item: %v", err)
+	}
+	return &item, nil
+}
+
+// GetItemByName returns the item of category entries refer to by name, or
+// nil.
+func (db *DB) GetItemByName(ctx context.Context, category, name string) (*Item, error) {
+	return db.getItem(ctx, `SELECT `+itemColumns+` FROM items WHERE Category = ? AND Name = ? AND DeletedAt IS NULL`, category, name)
+}
+
+func (db *DB) GetItemByID(ctx context.Context, category string, id int64) (*Item, error) {
+	return db.getItem(ctx, `SELECT `+itemColumns+` FROM items WHERE Category = ? AND ID = ? AND DeletedAt IS NULL`, category, id)
+}
+
+// ListItems returns the items of category by ID.
+func (db *DB) ListItems(ctx context.Context, category string) ([]Item, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.conn().QueryContext(ctx, `SELECT `+itemColumns+` FROM items WHERE Category = ? AND DeletedAt IS NULL ORDER BY ID`, category)
+	if err != nil {
+		return nil, fmt.Errorf("error querying items: %v", err)
+	}
+	defer rows.Close()
+
+	items := []Item{}
+	for rows.Next() {
+		item, err := scanItem(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning item: %v", err)
+		}
+		items = append(items, item)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading item rows: %v", err)
+	}
+	return items, nil
+}
+
+// GetItem returns the item id of any category, or nil.
+func (db *DB) GetItem(ctx context.Context, id int64) (*Item, error) {
+	return db.getItem(ctx, `SELECT `+itemColumns+` FROM items WHERE ID = ? AND DeletedAt IS NULL`, id)
+}
+
+// UpdateItem renames the item and sets its title, attributes and picture.
+func (db *DB) UpdateItem(ctx context.Context, item Item) error {
+	if item.Name == "" {
+		return fmt.Errorf("error updating item: name must not be empty")
+	}
+	if err := validAttributes(item.Attributes); err != nil {
+		return err
+	}
+	return db.updateFields(ctx, "items", item.ID, []string{"Name", "Title", "Attributes", "Picture"}, item.Name, item.Title, item.Attributes, item.Picture)
+}
+
+// DeleteItem deletes the item, its entries and their pictures. The deleted
+// pictures are returned.
+func (db *DB) DeleteItem(ctx context.Context, id int64) ([]Picture, error) {
+	return db.deleteRow(ctx, "items", id)
+}
+
+// MergeItems moves the entries of item fromID to item intoID of the same
+// category and deletes item fromID.
+func (db *DB) MergeItems(ctx context.Context, fromID, intoID int64) error {
+	if err := sameCategory(ctx, db, fromID, intoID); err != nil {
+		return err
+	}
+	return db.mergeRows(ctx, "items", "entries", fromID, intoID)
+}
+
+// sameCategory returns ErrNotFound unless both items exist and an error
+// unless they are of the same category.
+func sameCategory(ctx context.Context, store ItemStore, fromID, intoID int64) error {
+	from, err := store.GetItem(ctx, fromID)
+	if err != nil {
+		return err
+	}
+	into, err := store.GetItem(ctx, intoID)
+	if err != nil {
+		return err
+	}
+	if from == nil || into == nil {
+		return ErrNotFound
+	}
+	if from.Category != into.Category {
+		return fmt.Errorf("error merging items: %s item %d cannot be merged into %s item %d", from.Category, fromID, into.Category, intoID)
+	}
+	return nil
+}
+
+// InsertEntry adds an entry of the item itemID with the picture pictureID.
+// Shoe entries have no name.
+func (db *DB) InsertEntry(ctx context.Context, category, name string, itemID, pictureID int64) (int64, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	var id int64
+	err := db.inTx(ctx, func(conn dbtx) error {
+		var err error
+		id, err = insertEntry(ctx, conn, category, name, itemID, pictureID)
+		return err
+	})
+	return id, err
+}
+
+// insertEntry adds the entry and records its creation in the history.
+func insertEntry(ctx context.Context, conn dbtx, category, name string, itemID, pictureID int64) (int64, error) {
+	var itemCategory string
+	if err := conn.QueryRowContext(ctx, `SELECT Category FROM items WHERE ID = ? AND DeletedAt IS NULL`, itemID).Scan(&itemCategory); err != nil || itemCategory != category {
+		return 0, fmt.Errorf("error inserting entry: no %s item %d", category, itemID)
+	}
+	query := `INSERT INTO entries (Category, Name, ItemID, PictureID) VALUES (?, ?, ?, ?)`
+	result, err := conn.ExecContext(ctx, query, category, name, itemID, pictureID)
+	if err != nil {
+		return 0, fmt.Errorf("error inserting entry: %v", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("error getting last insert id: %v", err)
+	}
+	return id, recordCreated(ctx, conn, "entries", id)
+}
+
+const entryDetailsQuery = `
+	SELECT
+		entries.ID,
+		entries.Category,
+		COALESCE(entries.Name, ''),
+		items.ID,
+		items.Name,
+		COALESCE(items.Title, items.Name),
+		pictures.ID,
+		pictures.LocalLocation,
+		pictures.DiscordImageLink,
+		pictures.TakenAt,
+		entries.CreatedAt
+	FROM
+		entries
+	INNER JOIN
+		items ON entries.ItemID = items.ID
+	INNER JOIN
+		pictures ON entries.PictureID = pictures.ID
+	WHERE
+		entries.DeletedAt IS NULL AND items.DeletedAt IS NULL AND pictures.DeletedAt IS NULL
+		AND entries.Category = ?
+`
+
+func scanEntryDetails(row rowScanner) (EntryDetails, error) {
+	var d EntryDetails
+	err := row.Scan(&d.EntryID, &d.Category, &d.EntryName, &d.ItemID, &d.ItemName, &d.ItemTitle,
+		&d.PictureID, &d.PictureLocalPath, &d.PictureDiscordURL, &d.PictureTakenAt, &d.EntryCreatedAt)
+	return d, err
+}
+
+func (db *DB) GetEntryByID(ctx context.Context, category string, id int64) (*EntryDetails, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	entry, err := scanEntryDetails(db.conn().QueryRowContext(ctx, entryDetailsQuery+` AND entries.ID = ?`, category, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving entry: %v", err)
+	}
+	return &entry, nil
+}
+
+// ListEntries returns the entries of category by ID.
+func (db *DB) ListEntries(ctx context.Context, category string) ([]EntryDetails, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.conn().QueryContext(ctx, entryDetailsQuery+` ORDER BY entries.ID`, category)
+	if err != nil {
+		return nil, fmt.Errorf("error querying entries: %v", err)
+	}
+	defer rows.Close()
+
+	entries := []EntryDetails{}
+	for rows.Next() {
+		entry, err := scanEntryDetails(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning entry: %v", err)
+		}
+		entries = append(entries, entry)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading entry rows: %v", err)
+	}
+	return entries, nil
+}
+
+// GetEntry returns the entry id of the entries table, or nil.
+func (db *DB) GetEntry(ctx context.Context, id int64) (*Entry, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	var entry Entry
+	query := `SELECT ID, Category, COALESCE(Name, ''), ItemID, COALESCE(PictureID, 0), UpdatedAt, CreatedAt FROM entries WHERE ID = ? AND DeletedAt IS NULL`
+	err := db.conn().QueryRowContext(ctx, query, id).Scan(&entry.ID, &entry.Category, &entry.Name, &entry.ItemID, &entry.PictureID, &entry.UpdatedAt, &entry.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving entry: %v", err)
+	}
+	return &entry, nil
+}
+
+// UpdateEntry renames the entry and points it at ItemID, an item of the same
+// category.
+func (db *DB) UpdateEntry(ctx context.Context, entry Entry) error {
+	existing, err := db.GetEntry(ctx, entry.ID)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return ErrNotFound
+	}
+	if item, err := db.GetItem(ctx, entry.ItemID); err != nil || item == nil || item.Category != existing.Category {
+		return fmt.Errorf("error updating entry: no %s item with id %d", existing.Category, entry.ItemID)
+	}
+	return db.updateFields(ctx, "entries", entry.ID, []string{"Name", "ItemID"}, entry.Name, entry.ItemID)
+}
+
+// DeleteEntry deletes the entry and its picture unless another entry shows
+// it. The deleted pictures are returned.
+func (db *DB) DeleteEntry(ctx context.Context, id int64) ([]Picture, error) {
+	return db.deleteRow(ctx, "entries", id)
+}
@@ -0,0 +1,159 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+	"vertigo/pkg/stockx"
+)
+
+func TestItems(t *testing.T) {
+	db, err := GetDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("GetDB failed: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	if err := db.MigrateUp(ctx); err != nil {
+		t.Fatalf("MigrateUp failed: %v", err)
+	}
+
+	for _, store := range []Store{db, NewMemoryStore()} {
+		watchID, err := store.InsertItem(ctx, Item{Category: "watches", Name: "Speedmaster", Title: "Omega Speedmaster", Attributes: `{"Brand":"Omega"}`})
+		if err != nil {
+			t.Fatalf("InsertItem failed: %v", err)
+		}
+		if _, err := store.InsertItem(ctx, Item{Category: "watches", Name: "Speedmaster"}); err == nil {
+			t.Fatalf("Expected a second Speedmaster to be rejected")
+		}
+		if _, err := store.InsertItem(ctx, Item{Category: "books", Name: "Speedmaster"}); err != nil {
+			t.Fatalf("Expected the name to be unique per category only, got %v", err)
+		}
+		item, err := store.GetItemByName(ctx, "watches", "Speedmaster")
+		if err != nil || item == nil || item.ID != watchID || item.Title != "Omega Speedmaster" || item.Attributes != `{"Brand":"Omega"}` {
+			t.Fatalf("Expected the Speedmaster, got %+v, %v", item, err)
+		}
+		if item, err := store.GetItemByID(ctx, "books", watchID); err != nil || item != nil {
+			t.Fatalf("Expected no book with the watch's ID, got %+v, %v", item, err)
+		}
+
+		pictureID, _ := store.InsertPicture(ctx, "watch.jpg", "", "1", 0, 0, time.Now())
+		entryID, err := store.InsertEntry(ctx, "watches", "Wrist shot", watchID, pictureID)
+		if err != nil {
+			t.Fatalf("InsertEntry failed: %v", err)
+		}
+		if _, err := store.InsertEntry(ctx, "books", "", watchID, pictureID); err == nil {
+			t.Fatalf("Expected an entry of an item of another category to be rejected")
+		}
+		entry, err := store.GetEntryByID(ctx, "watches", entryID)
+		if err != nil || entry == nil || entry.ItemTitle != "Omega Speedmaster" || entry.EntryName != "Wrist shot" || entry.PictureLocalPath != "watch.jpg" {
+			t.Fatalf("Expected the wrist shot, got %+v, %v", entry, err)
+		}
+
+		// Shoes are items with their details.
+		shoeID, err := store.InsertItem(ctx, Item{Category: ShoeCategory, Name: "Mars-Yard", Title: "Mars Yard", Attributes: `{"Brand":"Nike"}`})
+		if err != nil {
+			t.Fatalf("InsertItem of a shoe failed: %v", err)
+		}
+		shoe, err := store.GetShoeByProductName(ctx, "Mars-Yard")
+		if err != nil || shoe == nil || shoe.ID != shoeID || shoe.Name != "Mars Yard" {
+			t.Fatalf("Expected the item as a shoe, got %+v, %v", shoe, err)
+		}
+		store.InsertShoe(ctx, stockx.ProductDetails{Name: "Air Jordan 1", ProductName: "Air-Jordan-1"})
+		shoes, err := store.ListItems(ctx, ShoeCategory)
+		if err != nil || len(shoes) != 2 || shoes[1].Name != "Air-Jordan-1" || shoes[1].Category != ShoeCategory {
+			t.Fatalf("Expected both shoes as items, got %+v, %v", shoes, err)
+		}
+		shoePicture, _ := store.InsertPicture(ctx, "shoe.jpg", "", "2", 0, 0, time.Now())
+		if _, err := store.InsertEntry(ctx, ShoeCategory, "", shoeID, shoePicture); err != nil {
+			t.Fatalf("InsertEntry of a shoe failed: %v", err)
+		}
+		shoentries, _ := store.ListShoentries(ctx)
+		entries, err := store.ListEntries(ctx, ShoeCategory)
+		if err != nil || len(entries) != 1 || len(shoentries) != 1 || entries[0].ItemTitle != "Mars Yard" {
+			t.Fatalf("Expected one shoe entry, got %+v, %v", entries, err)
+		}
+		if entries, err := store.ListEntries(ctx, "watches"); err != nil || len(entries) != 1 || entries[0].EntryID != entryID {
+			t.Fatalf("Expected the watch entry only, got %+v, %v", entries, err)
+		}
+	}
+}
+
+func TestItemRows(t *testing.T) {
+	db, err := GetDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("GetDB failed: %v", err)
+	}
+	defer db.Close()
+	ctx := WithActor(context.Background(), "tester")
+	if err := db.MigrateUp(ctx); err != nil {
+		t.Fatalf("MigrateUp failed: %v", err)
+	}
+
+	for _, store := range []Store{db, NewMemoryStore()} {
+		keepID, _ := store.InsertItem(ctx, Item{Category: "watches", Name: "Speedmaster"})
+		dropID, _ := store.InsertItem(ctx, Item{Category: "watches", Name: "Speedy"})
+		bookID, _ := store.InsertItem(ctx, Item{Category: "books", Name: "Dune"})
+		pictureID, _ := store.InsertPicture(ctx, "watch.jpg", "", "1", 0, 0, time.Now())
+		entryID, _ := store.InsertEntry(ctx, "watches", "Wrist shot", dropID, pictureID)
+
+		if err := MergeRows(ctx, store, "items", dropID, bookID); err == nil {
+			t.Fatalf("Expected items of different categories not to be merged")
+		}
+		if err := MergeRows(ctx, store, "items", dropID, keepID); err != nil {
+			t.Fatalf("MergeRows failed: %v", err)
+		}
+		if entry, _ := store.GetEntryByID(ctx, "watches", entryID); entry == nil || entry.ItemID != keepID {
+			t.Fatalf("Expected the entry to be moved to %d, got %+v", keepID, entry)
+		}
+		// The name of the merged item is free again.
+		if _, err := store.InsertItem(ctx, Item{Category: "watches", Name: "Speedy"}); err != nil {
+			t.Fatalf("Expected the name of a deleted item to be free, got %v", err)
+		}
+
+		row, err := GetRow(ctx, store, "entries", entryID)
+		if err != nil {
+			t.Fatalf("GetRow failed: %v", err)
+		}
+		row.(*Entry).Name = "On the wrist"
+		if err := UpdateRow(ctx, store, entryID, row); err != nil {
+			t.Fatalf("UpdateRow failed: %v", err)
+		}
+		row.(*Entry).ItemID = bookID
+		if err := UpdateRow(ctx, store, entryID, row); err == nil {
+			t.Fatalf("Expected the entry not to be moved to a book")
+		}
+
+		pictures, err := DeleteRow(ctx, store, "items", keepID)
+		if err != nil || len(pictures) != 1 || pictures[0].ID != pictureID {
+			t.Fatalf("Expected the picture of the entry to be deleted, got %+v, %v", pictures, err)
+		}
+		if entries, _ := store.ListEntries(ctx, "watches"); len(entries) != 0 {
+			t.Fatalf("Expected the entries to be hidden, got %+v", entries)
+		}
+		if err := store.RestoreRow(ctx, "entries", entryID); err == nil {
+			t.Fatalf("Expected the entry not to be restored before its item")
+		}
+		if err := store.RestoreRow(ctx, "items", keepID); err != nil {
+			t.Fatalf("RestoreRow failed: %v", err)
+		}
+		if entries, _ := store.ListEntries(ctx, "watches"); len(entries) != 1 || entries[0].EntryName != "On the wrist" {
+			t.Fatalf("Expected the entry to be restored, got %+v", entries)
+		}
+
+		changes, _ := store.History(ctx, "entries", entryID)
+		if len(changes) != 5 || changes[0].Field != "CreatedAt" || changes[1].Field != "ItemID" || changes[2].Field != "Name" || changes[4].ChangedBy != "tester" {
+			t.Fatalf("Unexpected history %+v", changes)
+		}
+
+		DeleteRow(ctx, store, "items", keepID)
+		purged, err := store.PurgeRow(ctx, "items", keepID)
+		if err != nil || len(purged.Pictures) != 1 {
+			t.Fatalf("Expected the picture to be purged, got %+v, %v", purged, err)
+		}
+		if _, err := GetRow(ctx, store, "entries", entryID); err != ErrNotFound {
+			t.Fatalf("Expected the entry to be purged, got %v", err)
+		}
+	}
+}
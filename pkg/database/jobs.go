@@ -0,0 +1,204 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobFailed    = "failed"
+	JobDone      = "done"
+	JobCancelled = "cancelled"
+)
+
+// Job is a shoe URL waiting to be onboarded, or the outcome of onboarding
+// it. Queued jobs are claimed once NextAttemptAt has passed.
+type Job struct {
+	ID            int64     `json:"id"`
+	URL           string    `json:"url"`
+	State         string    `json:"state"`
+	Attempts      int       `json:"attempts"`
+	LastError     string    `json:"last_error"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+const jobColumns = `ID, URL, State, Attempts, LastError, NextAttemptAt, CreatedAt, UpdatedAt`
+
+func scanJob(scan func(dest ...interface{}) error) (Job, error) {
+	var job Job
+	var lastError sql.NullString
+	err := scan(&job.ID, &job.URL, &job.State, &job.Attempts, &lastError, &job.NextAttemptAt, &job.CreatedAt, &job.UpdatedAt)
+	job.LastError = lastError.String
+	return job, err
+}
+
+// EnqueueJob queues url. When the URL is queued or running already that job
+// is returned and queued is false, a failed or cancelled job for it is
+// queued again.
+func (db *DB) EnqueueJob(ctx context.Context, url string) (job Job, queued bool, err error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	now := time.Now().UTC()
+	err = db.inTx(ctx, func(conn dbtx) error {
+		row := conn.QueryRowContext(ctx, `SELECT `+jobColumns+` FROM jobs WHERE URL = ? AND State <> ? ORDER BY ID DESC LIMIT 1`, url, JobDone)
+		existing, err := scanJob(row.Scan)
+		switch {
+		case err == sql.ErrNoRows:
+			query := `INSERT INTO jobs (URL, State, NextAttemptAt, CreatedAt, UpdatedAt) VALUES (?, ?, ?, ?, ?) RETURNING ` + jobColumns
+			job, err = scanJob(conn.QueryRowContext(ctx, query, url, JobQueued, now, now, now).Scan)
+			queued = true
+		case err != nil:
+		case existing.State == JobQueued || existing.State == JobRunning:
+			job = existing
+		default:
+			job, err = requeueJob(ctx, conn, existing.ID, now)
+			queued = true
+		}
+		if err != nil {
+			return fmt.Errorf("error enqueueing job: %v", err)
+		}
+		return nil
+	})
+	return job, queued, err
+}
+
+func requeueJob(ctx context.Context, conn dbtx, id int64, now time.Time) (Job, error) {
+	query := `UPDATE jobs SET State = ?, Attempts = 0, LastError = NULL, NextAttemptAt = ?, UpdatedAt = ? WHERE ID = ? AND State IN (?, ?) RETURNING ` + jobColumns
+	job, err := scanJob(conn.QueryRowContext(ctx, query, JobQueued, now, now, id, JobFailed, JobCancelled).Scan)
+	if err == sql.ErrNoRows {
+		return Job{}, ErrNotFound
+	}
+	return job, err
+}
+
+// ClaimJob marks the queued job that is due first as running and returns
+// it, or nil when no job is due at now.
+func (db *DB) ClaimJob(ctx context.Context, now time.Time) (*Job, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE jobs SET State = ?, Attempts = Attempts + 1, UpdatedAt = ?
+		WHERE ID = (SELECT ID FROM jobs WHERE State = ? AND NextAttemptAt <= ? ORDER BY NextAttemptAt, ID LIMIT 1)
+		RETURNING ` + jobColumns
+	job, err := scanJob(db.conn().QueryRowContext(ctx, query, JobRunning, now.UTC(), JobQueued, now.UTC()).Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error claiming job: %v", err)
+	}
+	return &job, nil
+}
+
+// FinishJob marks a running job as done. ErrNotFound means it was cancelled
+// in the meantime.
+func (db *DB) FinishJob(ctx context.Context, id int64) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	result, err := db.conn().ExecContext(ctx, `UPDATE jobs SET State = ?, LastError = NULL, UpdatedAt = ? WHERE ID = ? AND State = ?`, JobDone, time.Now().UTC(), id, JobRunning)
+	if err != nil {
+		return fmt.Errorf("error finishing job: %v", err)
+	}
+	return checkAffected(result)
+}
+
+// FailJob records why a running job failed. It is queued again for retryAt,
+// or failed for good when retryAt is zero.
+func (db *DB) FailJob(ctx context.Context, id int64, message string, retryAt time.Time) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	state, next := JobQueued, retryAt.UTC()
+	if retryAt.IsZero() {
+		state, next = JobFailed, time.Now().UTC()
+	}
+	result, err := db.conn().ExecContext(ctx, `UPDATE jobs SET State = ?, LastError = ?, NextAttemptAt = ?, UpdatedAt = ? WHERE ID = ? AND State = ?`, state, message, next, time.Now().UTC(), id, JobRunning)
+	if err != nil {
+		return fmt.Errorf("error failing job: %v", err)
+	}
+	return checkAffected(result)
+}
+
+// QueryJobs lists the jobs in state, or all of them for an empty state.
+func (db *DB) QueryJobs(ctx context.Context, state string) ([]Job, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query, params := `SELECT `+jobColumns+` FROM jobs ORDER BY ID`, []interface{}{}
+	if state != "" {
+		query, params = `SELECT `+jobColumns+` FROM jobs WHERE State = ? ORDER BY ID`, []interface{}{state}
+	}
+	rows, err := db.conn().QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying jobs: %v", err)
+	}
+	defer rows.Close()
+
+	jobs := []Job{}
+	for rows.Next() {
+		job, err := scanJob(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning job: %v", err)
+		}
+		jobs = append(jobs, job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading job rows: %v", err)
+	}
+	return jobs, nil
+}
+
+// RetryJob queues a failed or cancelled job again with a fresh budget of
+// attempts.
+func (db *DB) RetryJob(ctx context.Context, id int64) (*Job, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	job, err := requeueJob(ctx, db.conn(), id, time.Now().UTC())
+	if err == ErrNotFound {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error retrying job: %v", err)
+	}
+	return &job, nil
+}
+
+// CancelJob cancels a queued or running job. A running job is finished by
+// its worker, but its outcome is not recorded.
+func (db *DB) CancelJob(ctx context.Context, id int64) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	result, err := db.conn().ExecContext(ctx, `UPDATE jobs SET State = ?, UpdatedAt = ? WHERE ID = ? AND State IN (?, ?)`, JobCancelled, time.Now().UTC(), id, JobQueued, JobRunning)
+	if err != nil {
+		return fmt.Errorf("error cancelling job: %v", err)
+	}
+	return checkAffected(result)
+}
+
+// RequeueRunningJobs queues the jobs a run that crashed left running and
+// returns how many there were.
+func (db *DB) RequeueRunningJobs(ctx context.Context) (int64, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	now := time.Now().UTC()
+	result, err := db.conn().ExecContext(ctx, `UPDATE jobs SET State = ?, NextAttemptAt = ?, UpdatedAt = ? WHERE State = ?`, JobQueued, now, now, JobRunning)
+	if err != nil {
+		return 0, fmt.Errorf("error requeueing running jobs: %v", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error getting affected rows: %v", err)
+	}
+	return n, nil
+}
@@ -0,0 +1,81 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestJobs(t *testing.T) {
+	db, err := GetDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("GetDB failed: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	if err := db.MigrateUp(ctx); err != nil {
+		t.Fatalf("MigrateUp failed: %v", err)
+	}
+
+	for _, store := range []Store{db, NewMemoryStore()} {
+		first, queued, err := store.EnqueueJob(ctx, "https://stockx.com/a")
+		if err != nil || !queued {
+			t.Fatalf("EnqueueJob failed: %v", err)
+		}
+		if again, queued, _ := store.EnqueueJob(ctx, "https://stockx.com/a"); queued || again.ID != first.ID {
+			t.Fatalf("Expected the queued job to be returned, got %+v", again)
+		}
+		second, _, _ := store.EnqueueJob(ctx, "https://stockx.com/b")
+
+		now := time.Now()
+		job, err := store.ClaimJob(ctx, now)
+		if err != nil || job == nil || job.ID != first.ID || job.State != JobRunning || job.Attempts != 1 {
+			t.Fatalf("Expected to claim the first job, got %+v, %v", job, err)
+		}
+		if err := store.FailJob(ctx, job.ID, "timeout", now.Add(time.Hour)); err != nil {
+			t.Fatalf("FailJob failed: %v", err)
+		}
+		// The first job is not due before the hour has passed.
+		if job, _ := store.ClaimJob(ctx, now); job == nil || job.ID != second.ID {
+			t.Fatalf("Expected to claim the second job, got %+v", job)
+		}
+		if job, _ := store.ClaimJob(ctx, now); job != nil {
+			t.Fatalf("Expected no due job, got %+v", job)
+		}
+		if err := store.CancelJob(ctx, second.ID); err != nil {
+			t.Fatalf("CancelJob failed: %v", err)
+		}
+		if err := store.FinishJob(ctx, second.ID); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Expected a cancelled job to stay cancelled, got %v", err)
+		}
+
+		job, _ = store.ClaimJob(ctx, now.Add(2*time.Hour))
+		if job == nil || job.ID != first.ID || job.Attempts != 2 {
+			t.Fatalf("Expected the retry of the first job, got %+v", job)
+		}
+		if err := store.FailJob(ctx, job.ID, "gave up", time.Time{}); err != nil {
+			t.Fatalf("FailJob failed: %v", err)
+		}
+		failed, _ := store.QueryJobs(ctx, JobFailed)
+		if len(failed) != 1 || failed[0].LastError != "gave up" {
+			t.Fatalf("Expected one failed job, got %+v", failed)
+		}
+		retried, err := store.RetryJob(ctx, first.ID)
+		if err != nil || retried.State != JobQueued || retried.Attempts != 0 || retried.LastError != "" {
+			t.Fatalf("Expected the job to be queued again, got %+v, %v", retried, err)
+		}
+		if _, err := store.RetryJob(ctx, first.ID); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Expected a queued job not to be retried, got %v", err)
+		}
+
+		store.ClaimJob(ctx, time.Now())
+		if n, err := store.RequeueRunningJobs(ctx); err != nil || n != 1 {
+			t.Fatalf("Expected one running job to be queued again, got %d, %v", n, err)
+		}
+		if jobs, _ := store.QueryJobs(ctx, ""); len(jobs) != 2 {
+			t.Fatalf("Expected 2 jobs, got %+v", jobs)
+		}
+	}
+}
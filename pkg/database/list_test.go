@@ -0,0 +1,68 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+	"vertigo/pkg/restaurant"
+	"vertigo/pkg/stockx"
+)
+
+func TestListRows(t *testing.T) {
+	db, err := GetDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("GetDB failed: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	if err := db.MigrateUp(ctx); err != nil {
+		t.Fatalf("MigrateUp failed: %v", err)
+	}
+
+	for _, store := range []Store{db, NewMemoryStore()} {
+		if shoes, err := store.ListShoes(ctx); err != nil || shoes == nil || len(shoes) != 0 {
+			t.Fatalf("Expected an empty list of shoes, got %v, %v", shoes, err)
+		}
+
+		for _, name := range []string{"Air-Jordan-1", "Yeezy-350"} {
+			if err := store.InsertShoe(ctx, stockx.ProductDetails{Name: name, ProductName: name, Attributes: map[string]string{"Brand": "Nike"}}); err != nil {
+				t.Fatalf("InsertShoe failed: %v", err)
+			}
+		}
+		shoe, _ := store.GetShoeByProductName(ctx, "Air-Jordan-1")
+		yeezy, _ := store.GetShoeByProductName(ctx, "Yeezy-350")
+		shoePicture, _ := store.InsertPicture(ctx, "1.jpg", "", "1", 0, 0, time.Now())
+		store.InsertShoentry(ctx, shoe.ID, shoePicture)
+		restaurantID, err := store.InsertRestaurant(ctx, restaurant.RestaurantDetails{ID: 42, Name: "Da Mario", Attributes: map[string]string{"Cuisine": "pizza"}})
+		if err != nil {
+			t.Fatalf("InsertRestaurant failed: %v", err)
+		}
+		foodPicture, _ := store.InsertPicture(ctx, "2.jpg", "", "2", 0, 0, time.Now())
+		store.InsertFoodentry(ctx, "Margherita", restaurantID, foodPicture)
+		if _, err := store.DeleteItem(ctx, yeezy.ID); err != nil {
+			t.Fatalf("DeleteItem failed: %v", err)
+		}
+
+		shoes, err := store.ListShoes(ctx)
+		if err != nil || len(shoes) != 1 || shoes[0].ProductName != "Air-Jordan-1" || shoes[0].Attributes != `{"Brand":"Nike"}` {
+			t.Fatalf("Expected the shoe that isn't deleted, got %+v, %v", shoes, err)
+		}
+		shoentries, err := store.ListShoentries(ctx)
+		if err != nil || len(shoentries) != 1 || shoentries[0].ShoeID != shoe.ID || shoentries[0].PictureLocalPath != "1.jpg" {
+			t.Fatalf("Expected one shoentry, got %+v, %v", shoentries, err)
+		}
+		restaurants, err := store.ListRestaurants(ctx)
+		if err != nil || len(restaurants) != 1 || restaurants[0].Name != "Da Mario" {
+			t.Fatalf("Expected one restaurant, got %+v, %v", restaurants, err)
+		}
+		foodentries, err := store.ListFoodentries(ctx)
+		if err != nil || len(foodentries) != 1 || foodentries[0].FoodentryName != "Margherita" || foodentries[0].RestaurantName != "Da Mario" {
+			t.Fatalf("Expected one foodentry, got %+v, %v", foodentries, err)
+		}
+		pictures, err := store.ListPictures(ctx)
+		if err != nil || len(pictures) != 2 || pictures[0].LocalLocation != "1.jpg" {
+			t.Fatalf("Expected two pictures, got %+v, %v", pictures, err)
+		}
+	}
+}
@@ -0,0 +1,807 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+	"vertigo/pkg/restaurant"
+	"vertigo/pkg/stockx"
+)
+
+// MemoryStore is an in-memory Store for tests. It enforces the same unique
+// and foreign key constraints as the SQLite schema.
+type MemoryStore struct {
+	mu          sync.Mutex
+	pictures    map[int64]*memoryPicture
+	shoeDetails map[int64]shoeDetails
+	nextID      map[string]int64
+	// deletedAt holds the soft deleted rows by table and id.
+	deletedAt map[string]map[int64]time.Time
+	history   []Change
+	prices    []ShoePrice
+	alerts    []PriceAlert
+	jobs      []Job
+	items     []Item
+	entries   []Entry
+}
+
+// shoeDetails is a row of shoe_details.
+type shoeDetails struct {
+	Subtitle       string
+	LastSale       string
+	Description    string
+	SpinningGifURL string
+}
+
+type memoryPicture struct {
+	ID               int64
+	LocalLocation    string
+	DiscordImageLink string
+	DiscordMessageId string
+	Latitude         float64
+	Longitude        float64
+	TakenAt          time.Time
+	UpdatedAt        time.Time
+	CreatedAt        time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		pictures:    make(map[int64]*memoryPicture),
+		shoeDetails: make(map[int64]shoeDetails),
+		nextID:      make(map[string]int64),
+		deletedAt:   make(map[string]map[int64]time.Time),
+	}
+}
+
+func (m *MemoryStore) newID(table string) int64 {
+	m.nextID[table]++
+	return m.nextID[table]
+}
+
+func (m *MemoryStore) InsertShoe(ctx context.Context, pd stockx.ProductDetails) error {
+	if err := pd.Validate(); err != nil {
+		return fmt.Errorf("error inserting new product details: %v", err)
+	}
+	attributesJSON, err := json.Marshal(pd.Attributes)
+	if err != nil {
+		return fmt.Errorf("error marshalling attributes to JSON: %v", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, item := range m.items {
+		if item.Category == ShoeCategory && item.Name == pd.ProductName && m.deleted("items", item.ID) {
+			return fmt.Errorf("error inserting new product details: %s was deleted, restore it with 'vertigo restore items %d'", pd.ProductName, item.ID)
+		}
+	}
+	id, err := m.insertItem(ctx, Item{Category: ShoeCategory, Name: pd.ProductName, Title: pd.Name, Attributes: string(attributesJSON), Picture: pd.MainPicture})
+	if err != nil {
+		return err
+	}
+	m.shoeDetails[id] = shoeDetails{Subtitle: pd.Subtitle, LastSale: pd.LastSale, Description: pd.Description, SpinningGifURL: pd.SpinningGifURL}
+	if price, err := stockx.ParsePrice(pd.LastSale); err == nil {
+		m.addPrice(id, price, time.Now())
+	}
+	return nil
+}
+
+func (m *MemoryStore) QueryShoes(ctx context.Context) ([]stockx.ProductDetails, error) {
+	return productDetails(m.ListShoes(ctx))
+}
+
+// shoe returns the shoe of an item of the shoes category, the caller holds
+// m.mu.
+func (m *MemoryStore) shoe(item Item) Shoe {
+	details := m.shoeDetails[item.ID]
+	return Shoe{
+		ID:             item.ID,
+		Name:           item.Title,
+		Subtitle:       details.Subtitle,
+		LastSale:       details.LastSale,
+		ProductName:    item.Name,
+		MainPicture:    item.Picture,
+		SpinningGifURL: details.SpinningGifURL,
+		Attributes:     item.Attributes,
+		Description:    details.Description,
+		Timestamp:      item.CreatedAt,
+	}
+}
+
+func (m *MemoryStore) GetShoeByProductName(ctx context.Context, name string) (*Shoe, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if item := m.itemByName(ShoeCategory, name); item != nil {
+		shoe := m.shoe(*item)
+		return &shoe, nil
+	}
+	return nil, nil
+}
+
+func (m *MemoryStore) GetShoeByID(ctx context.Context, id int64) (*Shoe, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if item := m.itemByID(ShoeCategory, id); item != nil {
+		shoe := m.shoe(*item)
+		return &shoe, nil
+	}
+	return nil, nil
+}
+
+func (m *MemoryStore) ListShoes(ctx context.Context) ([]Shoe, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	shoes := []Shoe{}
+	for _, item := range m.items {
+		if item.Category == ShoeCategory && !m.deleted("items", item.ID) {
+			shoes = append(shoes, m.shoe(item))
+		}
+	}
+	return shoes, nil
+}
+
+func (m *MemoryStore) InsertShoentry(ctx context.Context, itemID int64, pictureID int64) (int64, error) {
+	return m.InsertEntry(ctx, ShoeCategory, "", itemID, pictureID)
+}
+
+func (m *MemoryStore) shoentryDetails(entry Entry) (ShoentryDetails, bool) {
+	item := m.itemByID(ShoeCategory, entry.ItemID)
+	picture := m.pictures[entry.PictureID]
+	if entry.Category != ShoeCategory || item == nil || picture == nil || m.deleted("entries", entry.ID) || m.deleted("pictures", picture.ID) {
+		return ShoentryDetails{}, false
+	}
+	s := m.shoe(*item)
+	return ShoentryDetails{
+		ShoentryID:        entry.ID,
+		ItemID:            entry.ItemID,
+		ShoeID:            s.ID,
+		ShoeName:          s.Name,
+		ShoeSubtitle:      s.Subtitle,
+		ShoeLastSale:      s.LastSale,
+		ShoeProductName:   s.ProductName,
+		ShoeMainPicture:   s.MainPicture,
+		ShoeAttributes:    s.Attributes,
+		ShoeDescription:   s.Description,
+		ShoeTimestamp:     s.Timestamp,
+		PictureID:         picture.ID,
+		PictureLocalPath:  picture.LocalLocation,
+		PictureDiscordURL: picture.DiscordImageLink,
+		PictureMessageID:  picture.DiscordMessageId,
+		PictureLatitude:   picture.Latitude,
+		PictureLongitude:  picture.Longitude,
+		PictureTakenAt:    picture.TakenAt,
+		PictureUpdatedAt:  picture.UpdatedAt,
+		PictureCreatedAt:  picture.CreatedAt,
+		ShoentryUpdatedAt: entry.UpdatedAt,
+		ShoentryCreatedAt: entry.CreatedAt,
+	}, true
+}
+
+func (m *MemoryStore) GetShoentryByID(ctx context.Context, id int64) (*ShoentryDetails, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry := m.entry(id)
+	if entry == nil {
+		return nil, nil
+	}
+	details, ok := m.shoentryDetails(*entry)
+	if !ok {
+		return nil, nil
+	}
+	return &details, nil
+}
+
+func (m *MemoryStore) sortedShoentries(keep func(Entry) bool) []ShoentryDetails {
+	var shoentries []ShoentryDetails
+	for _, entry := range m.entries {
+		if !keep(entry) {
+			continue
+		}
+		if details, ok := m.shoentryDetails(entry); ok {
+			shoentries = append(shoentries, details)
+		}
+	}
+	sort.Slice(shoentries, func(i, j int) bool { return shoentries[i].ShoentryID < shoentries[j].ShoentryID })
+	return shoentries
+}
+
+func (m *MemoryStore) GetShoentriesByShoeID(ctx context.Context, shoeID int64) ([]ShoentryDetails, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.sortedShoentries(func(e Entry) bool { return e.ItemID == shoeID }), nil
+}
+
+func (m *MemoryStore) ListShoentries(ctx context.Context) ([]ShoentryDetails, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.sortedShoentries(func(Entry) bool { return true }), nil
+}
+
+func (m *MemoryStore) GetRecentShoentries(ctx context.Context, limit int) ([]ShoentryDetails, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	shoentries := m.sortedShoentries(func(Entry) bool { return true })
+	sort.SliceStable(shoentries, func(i, j int) bool {
+		return shoentries[i].ShoentryCreatedAt.After(shoentries[j].ShoentryCreatedAt)
+	})
+	if len(shoentries) > limit {
+		shoentries = shoentries[:limit]
+	}
+	return shoentries, nil
+}
+
+func (m *MemoryStore) InsertFoodentry(ctx context.Context, name string, itemID int64, pictureID int64) (int64, error) {
+	return m.InsertEntry(ctx, RestaurantCategory, name, itemID, pictureID)
+}
+
+func (m *MemoryStore) foodentryDetails(entry Entry) (FoodentryDetails, bool) {
+	item := m.itemByID(RestaurantCategory, entry.ItemID)
+	picture := m.pictures[entry.PictureID]
+	if entry.Category != RestaurantCategory || item == nil || picture == nil || m.deleted("entries", entry.ID) || m.deleted("pictures", picture.ID) {
+		return FoodentryDetails{}, false
+	}
+	return FoodentryDetails{
+		FoodentryID:          entry.ID,
+		FoodentryName:        entry.Name,
+		ItemID:               entry.ItemID,
+		RestaurantID:         item.ID,
+		RestaurantName:       item.Name,
+		RestaurantAttributes: item.Attributes,
+		RestaurantTimestamp:  item.CreatedAt,
+		PictureID:            picture.ID,
+		PictureLocalPath:     picture.LocalLocation,
+		PictureDiscordURL:    picture.DiscordImageLink,
+		PictureMessageID:     picture.DiscordMessageId,
+		PictureLatitude:      picture.Latitude,
+		PictureLongitude:     picture.Longitude,
+		PictureTakenAt:       picture.TakenAt,
+		PictureUpdatedAt:     picture.UpdatedAt,
+		PictureCreatedAt:     picture.CreatedAt,
+		FoodentryUpdatedAt:   entry.UpdatedAt,
+		FoodentryCreatedAt:   entry.CreatedAt,
+	}, true
+}
+
+func (m *MemoryStore) GetFoodEntryByID(ctx context.Context, id int64) (*FoodentryDetails, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry := m.entry(id)
+	if entry == nil {
+		return nil, nil
+	}
+	details, ok := m.foodentryDetails(*entry)
+	if !ok {
+		return nil, nil
+	}
+	return &details, nil
+}
+
+func (m *MemoryStore) ListFoodentries(ctx context.Context) ([]FoodentryDetails, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	foodentries := []FoodentryDetails{}
+	for _, entry := range m.entries {
+		if details, ok := m.foodentryDetails(entry); ok {
+			foodentries = append(foodentries, details)
+		}
+	}
+	sort.Slice(foodentries, func(i, j int) bool { return foodentries[i].FoodentryID < foodentries[j].FoodentryID })
+	return foodentries, nil
+}
+
+func (m *MemoryStore) InsertRestaurant(ctx context.Context, rt restaurant.RestaurantDetails) (int64, error) {
+	item, err := restaurantItem(rt)
+	if err != nil {
+		return 0, err
+	}
+	return m.InsertItem(ctx, item)
+}
+
+func (m *MemoryStore) GetRestaurantByName(ctx context.Context, name string) (*Restaurant, error) {
+	return restaurantOf(m.GetItemByName(ctx, RestaurantCategory, name))
+}
+
+func (m *MemoryStore) GetRestaurantByID(ctx context.Context, id int64) (*Restaurant, error) {
+	return restaurantOf(m.GetItemByID(ctx, RestaurantCategory, id))
+}
+
+func (m *MemoryStore) ListRestaurants(ctx context.Context) ([]Restaurant, error) {
+	return restaurantsOf(m.ListItems(ctx, RestaurantCategory))
+}
+
+func (m *MemoryStore) InsertPicture(ctx context.Context, localLocation, discordImageUrl, discordMessageId string, latitude, longitude float64, takenAt time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	id := m.newID("pictures")
+	m.pictures[id] = &memoryPicture{
+		ID:               id,
+		LocalLocation:    localLocation,
+		DiscordImageLink: discordImageUrl,
+		DiscordMessageId: discordMessageId,
+		Latitude:         latitude,
+		Longitude:        longitude,
+		TakenAt:          takenAt,
+		UpdatedAt:        now,
+		CreatedAt:        now,
+	}
+	return id, nil
+}
+
+func (m *MemoryStore) UpdatePictureFilePathAndTimestamp(ctx context.Context, id int64, newFilePath string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	picture, ok := m.pictures[id]
+	if !ok {
+		return nil
+	}
+	picture.LocalLocation = newFilePath
+	picture.UpdatedAt = time.Now()
+	return nil
+}
+
+type memorySnapshot struct {
+	pictures    map[int64]memoryPicture
+	shoeDetails map[int64]shoeDetails
+	nextID      map[string]int64
+	deletedAt   map[string]map[int64]time.Time
+	history     []Change
+	prices      []ShoePrice
+	alerts      []PriceAlert
+	jobs        []Job
+	items       []Item
+	entries     []Entry
+}
+
+// snapshot copies the state, the caller holds m.mu.
+func (m *MemoryStore) snapshot() memorySnapshot {
+	s := memorySnapshot{
+		pictures:    make(map[int64]memoryPicture, len(m.pictures)),
+		shoeDetails: make(map[int64]shoeDetails, len(m.shoeDetails)),
+		nextID:      make(map[string]int64, len(m.nextID)),
+		deletedAt:   make(map[string]map[int64]time.Time, len(m.deletedAt)),
+		history:     append([]Change(nil), m.history...),
+		prices:      append([]ShoePrice(nil), m.prices...),
+		alerts:      append([]PriceAlert(nil), m.alerts...),
+		jobs:        append([]Job(nil), m.jobs...),
+		items:       append([]Item(nil), m.items...),
+		entries:     append([]Entry(nil), m.entries...),
+	}
+	for id, p := range m.pictures {
+		s.pictures[id] = *p
+	}
+	for id, d := range m.shoeDetails {
+		s.shoeDetails[id] = d
+	}
+	for table, id := range m.nextID {
+		s.nextID[table] = id
+	}
+	for table, rows := range m.deletedAt {
+		s.deletedAt[table] = make(map[int64]time.Time, len(rows))
+		for id, deletedAt := range rows {
+			s.deletedAt[table][id] = deletedAt
+		}
+	}
+	return s
+}
+
+// restore resets the state to s, the caller holds m.mu.
+func (m *MemoryStore) restore(s memorySnapshot) {
+	m.pictures = make(map[int64]*memoryPicture, len(s.pictures))
+	for id, p := range s.pictures {
+		p := p
+		m.pictures[id] = &p
+	}
+	m.shoeDetails = s.shoeDetails
+	m.nextID = s.nextID
+	m.deletedAt = s.deletedAt
+	m.history = s.history
+	m.prices = s.prices
+	m.alerts = s.alerts
+	m.jobs = s.jobs
+	m.items = s.items
+	m.entries = s.entries
+}
+
+func (m *MemoryStore) ListShoeAssets(ctx context.Context) ([]ShoeAsset, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	assets := []ShoeAsset{}
+	for _, item := range m.items {
+		if item.Category != ShoeCategory {
+			continue
+		}
+		assets = append(assets, ShoeAsset{
+			ID:             item.ID,
+			ProductName:    item.Name,
+			MainPicture:    item.Picture,
+			SpinningGifURL: m.shoeDetails[item.ID].SpinningGifURL,
+			Deleted:        m.deleted("items", item.ID),
+		})
+	}
+	return assets, nil
+}
+
+func (m *MemoryStore) UpdateShoeAssets(ctx context.Context, id int64, mainPicture, spinningGifURL string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item := m.itemByID(ShoeCategory, id)
+	if item == nil {
+		return ErrNotFound
+	}
+	details := m.shoeDetails[id]
+	m.recordChanges(ctx, "items", id, map[string]string{"SpinningGifURL": details.SpinningGifURL}, map[string]string{"SpinningGifURL": spinningGifURL})
+	m.recordChanges(ctx, "items", id, map[string]string{"Picture": item.Picture}, map[string]string{"Picture": mainPicture})
+	details.SpinningGifURL = spinningGifURL
+	m.shoeDetails[id] = details
+	item.Picture = mainPicture
+	return nil
+}
+
+func (m *MemoryStore) ListPictureLocations(ctx context.Context) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ids := make([]int64, 0, len(m.pictures))
+	for id, p := range m.pictures {
+		if p.LocalLocation != "" {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	locations := make([]string, len(ids))
+	for i, id := range ids {
+		locations[i] = m.pictures[id].LocalLocation
+	}
+	return locations, nil
+}
+
+func (p *memoryPicture) toPicture() Picture {
+	return Picture(*p)
+}
+
+func (p *memoryPicture) fields() map[string]string {
+	return map[string]string{
+		"Latitude":  fmt.Sprint(p.Latitude),
+		"Longitude": fmt.Sprint(p.Longitude),
+		"TakenAt":   p.TakenAt.Format(time.RFC3339Nano),
+	}
+}
+
+func (m *MemoryStore) GetPictureByID(ctx context.Context, id int64) (*Picture, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.pictures[id]
+	if !ok || m.deleted("pictures", id) {
+		return nil, nil
+	}
+	picture := p.toPicture()
+	return &picture, nil
+}
+
+func (m *MemoryStore) ListPictures(ctx context.Context) ([]Picture, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	pictures := []Picture{}
+	for id, p := range m.pictures {
+		if !m.deleted("pictures", id) {
+			pictures = append(pictures, p.toPicture())
+		}
+	}
+	sort.Slice(pictures, func(i, j int) bool { return pictures[i].ID < pictures[j].ID })
+	return pictures, nil
+}
+
+func (m *MemoryStore) UpdatePicture(ctx context.Context, picture Picture) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.pictures[picture.ID]
+	if !ok || m.deleted("pictures", picture.ID) {
+		return ErrNotFound
+	}
+	before := p.fields()
+	p.Latitude = picture.Latitude
+	p.Longitude = picture.Longitude
+	p.TakenAt = picture.TakenAt
+	p.UpdatedAt = time.Now()
+	m.recordChanges(ctx, "pictures", picture.ID, before, p.fields())
+	return nil
+}
+
+func (m *MemoryStore) DeletePicture(ctx context.Context, id int64) ([]Picture, error) {
+	return m.deleteRow(ctx, "pictures", id)
+}
+
+// addPrice appends to the price history, the caller holds m.mu.
+func (m *MemoryStore) addPrice(shoeID int64, price stockx.Price, observedAt time.Time) ShoePrice {
+	p := ShoePrice{ID: m.newID("shoe_prices"), ShoeID: shoeID, Amount: price.Amount, Currency: price.Currency, ObservedAt: observedAt.UTC()}
+	m.prices = append(m.prices, p)
+	return p
+}
+
+func (m *MemoryStore) RecordShoePrice(ctx context.Context, shoeID int64, lastSale string, observedAt time.Time) (*ShoePrice, error) {
+	price, err := stockx.ParsePrice(lastSale)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.itemByID(ShoeCategory, shoeID) == nil {
+		return nil, ErrNotFound
+	}
+	details := m.shoeDetails[shoeID]
+	m.recordChanges(refreshContext(ctx), "items", shoeID, map[string]string{"LastSale": details.LastSale}, map[string]string{"LastSale": lastSale})
+	details.LastSale = lastSale
+	m.shoeDetails[shoeID] = details
+	p := m.addPrice(shoeID, price, observedAt)
+	return &p, nil
+}
+
+func (m *MemoryStore) GetShoePrices(ctx context.Context, shoeID int64) ([]ShoePrice, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	prices := []ShoePrice{}
+	if m.itemByID(ShoeCategory, shoeID) == nil {
+		return prices, nil
+	}
+	for _, p := range m.prices {
+		if p.ShoeID == shoeID {
+			prices = append(prices, p)
+		}
+	}
+	sort.SliceStable(prices, func(i, j int) bool { return prices[i].ObservedAt.Before(prices[j].ObservedAt) })
+	return prices, nil
+}
+
+func (m *MemoryStore) InsertPriceAlert(ctx context.Context, alert PriceAlert) (int64, error) {
+	if err := alert.Validate(); err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.itemByID(ShoeCategory, alert.ShoeID) == nil {
+		return 0, ErrNotFound
+	}
+	alert.ID = m.newID("price_alerts")
+	alert.LastTriggeredAt = nil
+	alert.CreatedBy = actorFrom(ctx)
+	alert.CreatedAt = time.Now()
+	m.alerts = append(m.alerts, alert)
+	return alert.ID, nil
+}
+
+func (m *MemoryStore) priceAlerts(keep func(PriceAlert) bool) []PriceAlert {
+	alerts := []PriceAlert{}
+	for _, a := range m.alerts {
+		if m.itemByID(ShoeCategory, a.ShoeID) != nil && keep(a) {
+			alerts = append(alerts, a)
+		}
+	}
+	return alerts
+}
+
+func (m *MemoryStore) QueryPriceAlerts(ctx context.Context) ([]PriceAlert, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.priceAlerts(func(PriceAlert) bool { return true }), nil
+}
+
+func (m *MemoryStore) GetPriceAlertsByShoeID(ctx context.Context, shoeID int64) ([]PriceAlert, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.priceAlerts(func(a PriceAlert) bool { return a.ShoeID == shoeID }), nil
+}
+
+func (m *MemoryStore) DeletePriceAlert(ctx context.Context, id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, a := range m.alerts {
+		if a.ID == id {
+			m.alerts = append(m.alerts[:i:i], m.alerts[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (m *MemoryStore) MarkPriceAlertTriggered(ctx context.Context, id int64, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.alerts {
+		if m.alerts[i].ID == id {
+			at := at.UTC()
+			m.alerts[i].LastTriggeredAt = &at
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// jobByID returns the job if its state is one of states.
+func (m *MemoryStore) jobByID(id int64, states ...string) *Job {
+	for i := range m.jobs {
+		if m.jobs[i].ID != id {
+			continue
+		}
+		for _, state := range states {
+			if m.jobs[i].State == state {
+				return &m.jobs[i]
+			}
+		}
+	}
+	return nil
+}
+
+func requeue(job *Job, now time.Time) {
+	job.State = JobQueued
+	job.Attempts = 0
+	job.LastError = ""
+	job.NextAttemptAt = now
+	job.UpdatedAt = now
+}
+
+func (m *MemoryStore) EnqueueJob(ctx context.Context, url string) (Job, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now().UTC()
+	for i := len(m.jobs) - 1; i >= 0; i-- {
+		job := &m.jobs[i]
+		if job.URL != url || job.State == JobDone {
+			continue
+		}
+		if job.State == JobQueued || job.State == JobRunning {
+			return *job, false, nil
+		}
+		requeue(job, now)
+		return *job, true, nil
+	}
+	job := Job{ID: m.newID("jobs"), URL: url, State: JobQueued, NextAttemptAt: now, CreatedAt: now, UpdatedAt: now}
+	m.jobs = append(m.jobs, job)
+	return job, true, nil
+}
+
+func (m *MemoryStore) ClaimJob(ctx context.Context, now time.Time) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var next *Job
+	for i := range m.jobs {
+		job := &m.jobs[i]
+		if job.State != JobQueued || job.NextAttemptAt.After(now) {
+			continue
+		}
+		if next == nil || job.NextAttemptAt.Before(next.NextAttemptAt) {
+			next = job
+		}
+	}
+	if next == nil {
+		return nil, nil
+	}
+	next.State = JobRunning
+	next.Attempts++
+	next.UpdatedAt = now.UTC()
+	job := *next
+	return &job, nil
+}
+
+func (m *MemoryStore) FinishJob(ctx context.Context, id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job := m.jobByID(id, JobRunning)
+	if job == nil {
+		return ErrNotFound
+	}
+	job.State = JobDone
+	job.LastError = ""
+	job.UpdatedAt = time.Now().UTC()
+	return nil
+}
+
+func (m *MemoryStore) FailJob(ctx context.Context, id int64, message string, retryAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job := m.jobByID(id, JobRunning)
+	if job == nil {
+		return ErrNotFound
+	}
+	job.State, job.NextAttemptAt = JobQueued, retryAt.UTC()
+	if retryAt.IsZero() {
+		job.State, job.NextAttemptAt = JobFailed, time.Now().UTC()
+	}
+	job.LastError = message
+	job.UpdatedAt = time.Now().UTC()
+	return nil
+}
+
+func (m *MemoryStore) QueryJobs(ctx context.Context, state string) ([]Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	jobs := []Job{}
+	for _, job := range m.jobs {
+		if state == "" || job.State == state {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs, nil
+}
+
+func (m *MemoryStore) RetryJob(ctx context.Context, id int64) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job := m.jobByID(id, JobFailed, JobCancelled)
+	if job == nil {
+		return nil, ErrNotFound
+	}
+	for _, other := range m.jobs {
+		if other.URL == job.URL && (other.State == JobQueued || other.State == JobRunning) {
+			return nil, fmt.Errorf("error retrying job: %s is queued already as job %d", job.URL, other.ID)
+		}
+	}
+	requeue(job, time.Now().UTC())
+	retried := *job
+	return &retried, nil
+}
+
+func (m *MemoryStore) CancelJob(ctx context.Context, id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job := m.jobByID(id, JobQueued, JobRunning)
+	if job == nil {
+		return ErrNotFound
+	}
+	job.State = JobCancelled
+	job.UpdatedAt = time.Now().UTC()
+	return nil
+}
+
+func (m *MemoryStore) RequeueRunningJobs(ctx context.Context) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var n int64
+	now := time.Now().UTC()
+	for i := range m.jobs {
+		if m.jobs[i].State == JobRunning {
+			m.jobs[i].State = JobQueued
+			m.jobs[i].NextAttemptAt = now
+			m.jobs[i].UpdatedAt = now
+			n++
+		}
+	}
+	return n, nil
+}
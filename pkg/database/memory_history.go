@@ -0,0 +1,312 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// deleted reports whether the row is soft deleted, the caller holds m.mu.
+func (m *MemoryStore) deleted(table string, id int64) bool {
+	_, ok := m.deletedAt[table][id]
+	return ok
+}
+
+func (m *MemoryStore) record(ctx context.Context, table string, id int64, field, oldValue, newValue string) {
+	m.history = append(m.history, Change{
+		ID:        m.newID("history"),
+		Table:     table,
+		RowID:     id,
+		Field:     field,
+		OldValue:  oldValue,
+		NewValue:  newValue,
+		ChangedBy: actorFrom(ctx),
+		ChangedAt: time.Now(),
+	})
+}
+
+func (m *MemoryStore) recordChanges(ctx context.Context, table string, id int64, before, after map[string]string) {
+	fields := make([]string, 0, len(before))
+	for field := range before {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		if before[field] != after[field] {
+			m.record(ctx, table, id, field, before[field], after[field])
+		}
+	}
+}
+
+func (m *MemoryStore) recordCreated(ctx context.Context, table string, id int64, now time.Time) {
+	m.record(ctx, table, id, "CreatedAt", "", now.Format(time.RFC3339Nano))
+}
+
+func (m *MemoryStore) markDeleted(ctx context.Context, table string, id int64, now time.Time) {
+	if m.deletedAt[table] == nil {
+		m.deletedAt[table] = make(map[int64]time.Time)
+	}
+	m.deletedAt[table][id] = now
+	m.record(ctx, table, id, "DeletedAt", "", now.Format(time.RFC3339Nano))
+}
+
+func (m *MemoryStore) clearDeleted(ctx context.Context, table string, id int64) {
+	deletedAt := m.deletedAt[table][id]
+	delete(m.deletedAt[table], id)
+	m.record(ctx, table, id, "DeletedAt", deletedAt.Format(time.RFC3339Nano), "")
+}
+
+// exists reports whether the row exists, deleted or not.
+func (m *MemoryStore) exists(table string, id int64) bool {
+	switch table {
+	case "items":
+		for _, item := range m.items {
+			if item.ID == id {
+				return true
+			}
+		}
+	case "entries":
+		return m.entry(id) != nil
+	case "pictures":
+		return m.pictures[id] != nil
+	}
+	return false
+}
+
+// entriesOf returns the ids of the entries whose column is id, and the
+// pictures they show.
+func (m *MemoryStore) entriesOf(column string, id int64) (entryIDs, pictureIDs []int64) {
+	for _, e := range m.entries {
+		if (column == "ItemID" && e.ItemID == id) || (column == "PictureID" && e.PictureID == id) {
+			entryIDs, pictureIDs = append(entryIDs, e.ID), append(pictureIDs, e.PictureID)
+		}
+	}
+	return entryIDs, pictureIDs
+}
+
+func (m *MemoryStore) pictureInUse(id int64, includeDeleted bool) bool {
+	entryIDs, _ := m.entriesOf("PictureID", id)
+	for _, entryID := range entryIDs {
+		if includeDeleted || !m.deleted("entries", entryID) {
+			return true
+		}
+	}
+	return false
+}
+
+// deleteRow mirrors DB.deleteRow.
+func (m *MemoryStore) deleteRow(ctx context.Context, table string, id int64) ([]Picture, error) {
+	if err := validTable(table); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.exists(table, id) || m.deleted(table, id) {
+		return nil, ErrNotFound
+	}
+	now := time.Now()
+	m.markDeleted(ctx, table, id, now)
+
+	var deleted []Picture
+	var pictureIDs []int64
+	switch table {
+	case "items":
+		entryIDs, entryPictureIDs := m.entriesOf("ItemID", id)
+		for i, entryID := range entryIDs {
+			if !m.deleted("entries", entryID) {
+				m.markDeleted(ctx, "entries", entryID, now)
+				pictureIDs = append(pictureIDs, entryPictureIDs[i])
+			}
+		}
+	case "entries":
+		pictureIDs = []int64{m.entry(id).PictureID}
+	case "pictures":
+		deleted = append(deleted, m.pictures[id].toPicture())
+		entryIDs, _ := m.entriesOf("PictureID", id)
+		for _, entryID := range entryIDs {
+			if !m.deleted("entries", entryID) {
+				m.markDeleted(ctx, "entries", entryID, now)
+			}
+		}
+	}
+
+	for _, pictureID := range pictureIDs {
+		p, ok := m.pictures[pictureID]
+		if !ok || m.deleted("pictures", pictureID) || m.pictureInUse(pictureID, false) {
+			continue
+		}
+		m.markDeleted(ctx, "pictures", pictureID, now)
+		deleted = append(deleted, p.toPicture())
+	}
+	return deleted, nil
+}
+
+func (m *MemoryStore) RestoreRow(ctx context.Context, table string, id int64) error {
+	if err := validTable(table); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.exists(table, id) {
+		return ErrNotFound
+	}
+	when, ok := m.deletedAt[table][id]
+	if !ok {
+		return fmt.Errorf("%s %d is not deleted", table, id)
+	}
+	if table == "entries" && m.deleted("items", m.entry(id).ItemID) {
+		return fmt.Errorf("the item of entries %d is deleted, restore it first", id)
+	}
+
+	sameDeletion := func(table string, id int64) bool {
+		deletedAt, ok := m.deletedAt[table][id]
+		return ok && deletedAt.Equal(when)
+	}
+	restore := map[string][]int64{}
+	switch table {
+	case "items":
+		entryIDs, pictureIDs := m.entriesOf("ItemID", id)
+		for i, entryID := range entryIDs {
+			if sameDeletion("entries", entryID) {
+				restore["entries"] = append(restore["entries"], entryID)
+			}
+			if sameDeletion("pictures", pictureIDs[i]) {
+				restore["pictures"] = append(restore["pictures"], pictureIDs[i])
+			}
+		}
+	case "entries":
+		if pictureID := m.entry(id).PictureID; sameDeletion("pictures", pictureID) {
+			restore["pictures"] = append(restore["pictures"], pictureID)
+		}
+	case "pictures":
+		entryIDs, _ := m.entriesOf("PictureID", id)
+		for _, entryID := range entryIDs {
+			if sameDeletion("entries", entryID) {
+				restore["entries"] = append(restore["entries"], entryID)
+			}
+		}
+	}
+
+	m.clearDeleted(ctx, table, id)
+	for target, ids := range restore {
+		for _, rowID := range ids {
+			if m.deleted(target, rowID) {
+				m.clearDeleted(ctx, target, rowID)
+			}
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) PurgeRow(ctx context.Context, table string, id int64) (*Purged, error) {
+	if err := validTable(table); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.exists(table, id) {
+		return nil, ErrNotFound
+	}
+	if !m.deleted(table, id) {
+		return nil, fmt.Errorf("%s %d is not deleted, delete it before purging", table, id)
+	}
+
+	purged := &Purged{}
+	var pictureIDs []int64
+	switch table {
+	case "items":
+		for i := range m.items {
+			if m.items[i].ID == id {
+				if m.items[i].Category == ShoeCategory {
+					purged.Shoe = &Shoe{ID: id, ProductName: m.items[i].Name}
+					m.purgeShoe(id)
+				}
+				m.items = append(m.items[:i], m.items[i+1:]...)
+				break
+			}
+		}
+		var entryIDs []int64
+		entryIDs, pictureIDs = m.entriesOf("ItemID", id)
+		for _, entryID := range entryIDs {
+			m.purge("entries", entryID)
+		}
+	case "entries":
+		pictureIDs = []int64{m.entry(id).PictureID}
+	case "pictures":
+		purged.Pictures = append(purged.Pictures, m.pictures[id].toPicture())
+		entryIDs, _ := m.entriesOf("PictureID", id)
+		for _, entryID := range entryIDs {
+			m.purge("entries", entryID)
+		}
+	}
+	m.purge(table, id)
+	m.record(ctx, table, id, "PurgedAt", "", time.Now().Format(time.RFC3339Nano))
+
+	for _, pictureID := range pictureIDs {
+		p, ok := m.pictures[pictureID]
+		if !ok || m.pictureInUse(pictureID, true) {
+			continue
+		}
+		m.purge("pictures", pictureID)
+		m.record(ctx, "pictures", pictureID, "PurgedAt", "", time.Now().Format(time.RFC3339Nano))
+		purged.Pictures = append(purged.Pictures, p.toPicture())
+	}
+	return purged, nil
+}
+
+// purgeShoe removes the details, prices and alerts of a shoe like the foreign
+// keys of shoe_details, shoe_prices and price_alerts do.
+func (m *MemoryStore) purgeShoe(id int64) {
+	delete(m.shoeDetails, id)
+	var prices []ShoePrice
+	for _, p := range m.prices {
+		if p.ShoeID != id {
+			prices = append(prices, p)
+		}
+	}
+	m.prices = prices
+	var alerts []PriceAlert
+	for _, a := range m.alerts {
+		if a.ShoeID != id {
+			alerts = append(alerts, a)
+		}
+	}
+	m.alerts = alerts
+}
+
+// purge removes an entry or picture and its deletion mark, items are removed
+// from their slice by the caller.
+func (m *MemoryStore) purge(table string, id int64) {
+	switch table {
+	case "entries":
+		for i := range m.entries {
+			if m.entries[i].ID == id {
+				m.entries = append(m.entries[:i], m.entries[i+1:]...)
+				break
+			}
+		}
+	case "pictures":
+		delete(m.pictures, id)
+	}
+	delete(m.deletedAt[table], id)
+}
+
+func (m *MemoryStore) History(ctx context.Context, table string, id int64) ([]Change, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var changes []Change
+	for _, c := range m.history {
+		if c.Table == table && c.RowID == id {
+			changes = append(changes, c)
+		}
+	}
+	return changes, nil
+}
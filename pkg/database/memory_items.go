@@ -0,0 +1,285 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+func (m *MemoryStore) InsertItem(ctx context.Context, item Item) (int64, error) {
+	if item.Category == "" || item.Name == "" {
+		return 0, fmt.Errorf("error inserting item: category and name are required")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.insertItem(ctx, item)
+}
+
+// insertItem adds item, the caller holds m.mu.
+func (m *MemoryStore) insertItem(ctx context.Context, item Item) (int64, error) {
+	if m.itemByName(item.Category, item.Name) != nil {
+		return 0, fmt.Errorf("error inserting item: UNIQUE constraint failed: items.Category, items.Name")
+	}
+	if item.Title == "" {
+		item.Title = item.Name
+	}
+	if item.Attributes == "" {
+		item.Attributes = "{}"
+	}
+	item.ID = m.newID("items")
+	item.CreatedAt = time.Now()
+	m.items = append(m.items, item)
+	m.recordCreated(ctx, "items", item.ID, item.CreatedAt)
+	return item.ID, nil
+}
+
+// item returns the item unless it is deleted, the caller holds m.mu.
+func (m *MemoryStore) item(id int64) *Item {
+	if m.deleted("items", id) {
+		return nil
+	}
+	for i := range m.items {
+		if m.items[i].ID == id {
+			return &m.items[i]
+		}
+	}
+	return nil
+}
+
+// itemByID returns the item of category, the caller holds m.mu.
+func (m *MemoryStore) itemByID(category string, id int64) *Item {
+	if item := m.item(id); item != nil && item.Category == category {
+		return item
+	}
+	return nil
+}
+
+// itemByName returns the item of category, the caller holds m.mu.
+func (m *MemoryStore) itemByName(category, name string) *Item {
+	for i := range m.items {
+		if m.items[i].Category == category && m.items[i].Name == name && !m.deleted("items", m.items[i].ID) {
+			return &m.items[i]
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) GetItemByName(ctx context.Context, category, name string) (*Item, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if item := m.itemByName(category, name); item != nil {
+		found := *item
+		return &found, nil
+	}
+	return nil, nil
+}
+
+func (m *MemoryStore) GetItemByID(ctx context.Context, category string, id int64) (*Item, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if item := m.itemByID(category, id); item != nil {
+		found := *item
+		return &found, nil
+	}
+	return nil, nil
+}
+
+func (m *MemoryStore) ListItems(ctx context.Context, category string) ([]Item, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	items := []Item{}
+	for _, item := range m.items {
+		if item.Category == category && !m.deleted("items", item.ID) {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+func (m *MemoryStore) GetItem(ctx context.Context, id int64) (*Item, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if item := m.item(id); item != nil {
+		found := *item
+		return &found, nil
+	}
+	return nil, nil
+}
+
+func (item *Item) fields() map[string]string {
+	return map[string]string{"Name": item.Name, "Title": item.Title, "Attributes": item.Attributes, "Picture": item.Picture}
+}
+
+func (m *MemoryStore) UpdateItem(ctx context.Context, item Item) error {
+	if item.Name == "" {
+		return fmt.Errorf("error updating item: name must not be empty")
+	}
+	if err := validAttributes(item.Attributes); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing := m.item(item.ID)
+	if existing == nil {
+		return ErrNotFound
+	}
+	if other := m.itemByName(existing.Category, item.Name); other != nil && other.ID != item.ID {
+		return fmt.Errorf("error updating item: UNIQUE constraint failed: items.Category, items.Name")
+	}
+	before := existing.fields()
+	existing.Name = item.Name
+	existing.Title = item.Title
+	existing.Attributes = item.Attributes
+	existing.Picture = item.Picture
+	m.recordChanges(ctx, "items", item.ID, before, existing.fields())
+	return nil
+}
+
+func (m *MemoryStore) DeleteItem(ctx context.Context, id int64) ([]Picture, error) {
+	return m.deleteRow(ctx, "items", id)
+}
+
+func (m *MemoryStore) MergeItems(ctx context.Context, fromID, intoID int64) error {
+	if fromID == intoID {
+		return fmt.Errorf("error merging items: cannot merge %d into itself", fromID)
+	}
+	if err := sameCategory(ctx, m, fromID, intoID); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.item(fromID) == nil || m.item(intoID) == nil {
+		return ErrNotFound
+	}
+	now := time.Now()
+	for i := range m.entries {
+		entry := &m.entries[i]
+		if entry.ItemID == fromID && !m.deleted("entries", entry.ID) {
+			entry.ItemID = intoID
+			entry.UpdatedAt = now
+			m.record(ctx, "entries", entry.ID, "ItemID", fmt.Sprint(fromID), fmt.Sprint(intoID))
+		}
+	}
+	m.markDeleted(ctx, "items", fromID, now)
+	return nil
+}
+
+func (m *MemoryStore) InsertEntry(ctx context.Context, category, name string, itemID, pictureID int64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.itemByID(category, itemID) == nil {
+		return 0, fmt.Errorf("error inserting entry: no %s item %d", category, itemID)
+	}
+	if m.pictures[pictureID] == nil {
+		return 0, fmt.Errorf("error inserting entry: FOREIGN KEY constraint failed")
+	}
+	now := time.Now()
+	id := m.newID("entries")
+	m.entries = append(m.entries, Entry{ID: id, Category: category, Name: name, ItemID: itemID, PictureID: pictureID, UpdatedAt: now, CreatedAt: now})
+	m.recordCreated(ctx, "entries", id, now)
+	return id, nil
+}
+
+// entry returns the entry, deleted or not, the caller holds m.mu.
+func (m *MemoryStore) entry(id int64) *Entry {
+	for i := range m.entries {
+		if m.entries[i].ID == id {
+			return &m.entries[i]
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) entryDetails(entry Entry) (EntryDetails, bool) {
+	item := m.itemByID(entry.Category, entry.ItemID)
+	picture := m.pictures[entry.PictureID]
+	if item == nil || picture == nil || m.deleted("entries", entry.ID) || m.deleted("pictures", picture.ID) {
+		return EntryDetails{}, false
+	}
+	return EntryDetails{
+		EntryID:           entry.ID,
+		Category:          entry.Category,
+		EntryName:         entry.Name,
+		ItemID:            item.ID,
+		ItemName:          item.Name,
+		ItemTitle:         item.Title,
+		PictureID:         picture.ID,
+		PictureLocalPath:  picture.LocalLocation,
+		PictureDiscordURL: picture.DiscordImageLink,
+		PictureTakenAt:    picture.TakenAt,
+		EntryCreatedAt:    entry.CreatedAt,
+	}, true
+}
+
+func (m *MemoryStore) GetEntryByID(ctx context.Context, category string, id int64) (*EntryDetails, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if entry := m.entry(id); entry != nil && entry.Category == category {
+		if details, ok := m.entryDetails(*entry); ok {
+			return &details, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *MemoryStore) ListEntries(ctx context.Context, category string) ([]EntryDetails, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entries := []EntryDetails{}
+	for _, entry := range m.entries {
+		if entry.Category != category {
+			continue
+		}
+		if details, ok := m.entryDetails(entry); ok {
+			entries = append(entries, details)
+		}
+	}
+	return entries, nil
+}
+
+func (m *MemoryStore) GetEntry(ctx context.Context, id int64) (*Entry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if entry := m.entry(id); entry != nil && !m.deleted("entries", id) {
+		found := *entry
+		return &found, nil
+	}
+	return nil, nil
+}
+
+func (m *MemoryStore) UpdateEntry(ctx context.Context, entry Entry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing := m.entry(entry.ID)
+	if existing == nil || m.deleted("entries", entry.ID) {
+		return ErrNotFound
+	}
+	if m.itemByID(existing.Category, entry.ItemID) == nil {
+		return fmt.Errorf("error updating entry: no %s item with id %d", existing.Category, entry.ItemID)
+	}
+	before := map[string]string{"Name": existing.Name, "ItemID": fmt.Sprint(existing.ItemID)}
+	existing.Name = entry.Name
+	existing.ItemID = entry.ItemID
+	existing.UpdatedAt = time.Now()
+	m.recordChanges(ctx, "entries", entry.ID, before, map[string]string{"Name": existing.Name, "ItemID": fmt.Sprint(existing.ItemID)})
+	return nil
+}
+
+func (m *MemoryStore) DeleteEntry(ctx context.Context, id int64) ([]Picture, error) {
+	return m.deleteRow(ctx, "entries", id)
+}
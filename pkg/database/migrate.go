@@ -0,0 +1,402 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var embeddedMigrations embed.FS
+
+var ErrDirty = errors.New("database is dirty, a previous migration did not finish; inspect it and run 'vertigo migrate force <version>'")
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      func(ctx context.Context, tx *sql.Tx) error
+	Down    func(ctx context.Context, tx *sql.Tx) error
+}
+
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	Dirty     bool
+	AppliedAt time.Time
+}
+
+// goMigrations holds migrations that cannot be expressed in plain SQL. A
+// version may combine a Go up step with a SQL down file or vice versa.
+var goMigrations = []Migration{
+	{
+		// Older databases were created before shoes.SpinningGifURL existed,
+		// newer ones already have it, and SQLite has no ADD COLUMN IF NOT EXISTS.
+		Version: 2,
+		Name:    "shoes_spinning_gif_url",
+		Up: func(ctx context.Context, tx *sql.Tx) error {
+			return addColumnIfMissing(ctx, tx, "shoes", "SpinningGifURL", "TEXT")
+		},
+	},
+	{
+		Version: 3,
+		Name:    "foreign_keys",
+		Up:      migrateForeignKeys,
+	},
+	{
+		// The prices scraped so far are parsed from shoes.LastSale.
+		Version: 5,
+		Name:    "shoe_prices",
+		Up:      migrateShoePrices,
+	},
+}
+
+const createMigrationsTable = `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		Version INTEGER PRIMARY KEY,
+		Name TEXT,
+		Dirty BOOLEAN NOT NULL DEFAULT 0,
+		AppliedAt DATETIME DEFAULT CURRENT_TIMESTAMP
+	)
+`
+
+// LoadMigrations reads the *.sql migrations in fsys and adds the Go
+// migrations for the versions and directions they leave out.
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	files, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, fmt.Errorf("error listing migrations: %v", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	get := func(version int64, name string) (*Migration, error) {
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, m.Name, name)
+		}
+		return m, nil
+	}
+
+	for _, file := range files {
+		version, name, direction, err := parseMigrationFileName(path.Base(file))
+		if err != nil {
+			return nil, err
+		}
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading migration %s: %v", file, err)
+		}
+		m, err := get(version, name)
+		if err != nil {
+			return nil, err
+		}
+		step := execSQL(string(content))
+		if direction == "up" {
+			m.Up = step
+		} else {
+			m.Down = step
+		}
+	}
+
+	// A SQL step takes precedence over the Go step of the same version, so a
+	// migrations directory can replace the Go migrations as well.
+	for _, gm := range goMigrations {
+		m, ok := byVersion[gm.Version]
+		if !ok {
+			m = &Migration{Version: gm.Version, Name: gm.Name}
+			byVersion[gm.Version] = m
+		}
+		if m.Up == nil {
+			m.Up = gm.Up
+		}
+		if m.Down == nil {
+			m.Down = gm.Down
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == nil {
+			return nil, fmt.Errorf("migration %d_%s has no up step", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// parseMigrationFileName splits "0001_initial.up.sql" into its version, name
+// and direction.
+func parseMigrationFileName(fileName string) (int64, string, string, error) {
+	base := strings.TrimSuffix(fileName, ".sql")
+	direction := path.Ext(base)
+	if direction != ".up" && direction != ".down" {
+		return 0, "", "", fmt.Errorf("migration %s must end in .up.sql or .down.sql", fileName)
+	}
+	base = strings.TrimSuffix(base, direction)
+
+	versionPart, name, found := strings.Cut(base, "_")
+	if !found || name == "" {
+		return 0, "", "", fmt.Errorf("migration %s must be named <version>_<name>", fileName)
+	}
+	version, err := strconv.ParseInt(versionPart, 10, 64)
+	if err != nil || version <= 0 {
+		return 0, "", "", fmt.Errorf("migration %s has an invalid version", fileName)
+	}
+	return version, name, strings.TrimPrefix(direction, "."), nil
+}
+
+func execSQL(query string) func(ctx context.Context, tx *sql.Tx) error {
+	return func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, query)
+		return err
+	}
+}
+
+func addColumnIfMissing(ctx context.Context, tx *sql.Tx, table, column, columnType string) error {
+	var count int
+	err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&count)
+	if err != nil {
+		return fmt.Errorf("error inspecting table %s: %v", table, err)
+	}
+	if count > 0 {
+		return nil
+	}
+	_, err = tx.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, columnType))
+	return err
+}
+
+func (db *DB) migrations() ([]Migration, error) {
+	if db.migrationsFS != nil {
+		return LoadMigrations(db.migrationsFS)
+	}
+	sub, err := fs.Sub(embeddedMigrations, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	return LoadMigrations(sub)
+}
+
+func (db *DB) appliedMigrations(ctx context.Context) (map[int64]MigrationStatus, error) {
+	if _, err := db.ExecContext(ctx, createMigrationsTable); err != nil {
+		return nil, fmt.Errorf("error creating schema_migrations table: %v", err)
+	}
+
+	rows, err := db.QueryContext(ctx, `SELECT Version, Name, Dirty, AppliedAt FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying schema_migrations: %v", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]MigrationStatus)
+	for rows.Next() {
+		var status MigrationStatus
+		if err := rows.Scan(&status.Version, &status.Name, &status.Dirty, &status.AppliedAt); err != nil {
+			return nil, fmt.Errorf("error scanning schema_migrations: %v", err)
+		}
+		status.Applied = true
+		applied[status.Version] = status
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading schema_migrations rows: %v", err)
+	}
+	return applied, nil
+}
+
+func checkDirty(applied map[int64]MigrationStatus) error {
+	for _, status := range applied {
+		if status.Dirty {
+			return fmt.Errorf("migration %d_%s: %w", status.Version, status.Name, ErrDirty)
+		}
+	}
+	return nil
+}
+
+// MigrateUp applies every pending migration in version order.
+func (db *DB) MigrateUp(ctx context.Context) error {
+	migrations, err := db.migrations()
+	if err != nil {
+		return err
+	}
+	applied, err := db.appliedMigrations(ctx)
+	if err != nil {
+		return err
+	}
+	if err := checkDirty(applied); err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		if err := db.applyMigration(ctx, m, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MigrateDown reverts the last steps applied migrations, newest first.
+func (db *DB) MigrateDown(ctx context.Context, steps int) error {
+	migrations, err := db.migrations()
+	if err != nil {
+		return err
+	}
+	applied, err := db.appliedMigrations(ctx)
+	if err != nil {
+		return err
+	}
+	if err := checkDirty(applied); err != nil {
+		return err
+	}
+
+	for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+		if m.Down == nil {
+			return fmt.Errorf("migration %d_%s cannot be reverted", m.Version, m.Name)
+		}
+		if err := db.applyMigration(ctx, m, false); err != nil {
+			return err
+		}
+		steps--
+	}
+	return nil
+}
+
+// applyMigration marks the version dirty, then runs the step and clears the
+// mark in one transaction. A crash in between leaves the mark behind so that
+// later runs refuse to touch the schema.
+func (db *DB) applyMigration(ctx context.Context, m Migration, up bool) error {
+	if up {
+		_, err := db.ExecContext(ctx, `INSERT INTO schema_migrations (Version, Name, Dirty) VALUES (?, ?, 1)`, m.Version, m.Name)
+		if err != nil {
+			return fmt.Errorf("error marking migration %d as dirty: %v", m.Version, err)
+		}
+	} else {
+		_, err := db.ExecContext(ctx, `UPDATE schema_migrations SET Dirty = 1 WHERE Version = ?`, m.Version)
+		if err != nil {
+			return fmt.Errorf("error marking migration %d as dirty: %v", m.Version, err)
+		}
+	}
+
+	err := db.runMigrationStep(ctx, m, up)
+	if err == nil {
+		return nil
+	}
+
+	// The transaction was rolled back, so the schema is unchanged and the
+	// dirty mark can be undone.
+	var restoreErr error
+	if up {
+		_, restoreErr = db.ExecContext(ctx, `DELETE FROM schema_migrations WHERE Version = ?`, m.Version)
+	} else {
+		_, restoreErr = db.ExecContext(ctx, `UPDATE schema_migrations SET Dirty = 0 WHERE Version = ?`, m.Version)
+	}
+	if restoreErr != nil {
+		return fmt.Errorf("error applying migration %d_%s: %v (database left dirty: %v)", m.Version, m.Name, err, restoreErr)
+	}
+	return fmt.Errorf("error applying migration %d_%s: %v", m.Version, m.Name, err)
+}
+
+func (db *DB) runMigrationStep(ctx context.Context, m Migration, up bool) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if up {
+		if err := m.Up(ctx, tx); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE schema_migrations SET Dirty = 0, AppliedAt = ? WHERE Version = ?`, time.Now(), m.Version)
+	} else {
+		if err := m.Down(ctx, tx); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE Version = ?`, m.Version)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// MigrationStatus lists every known migration together with the versions
+// recorded in the database that this binary does not know about.
+func (db *DB) MigrationStatus(ctx context.Context) ([]MigrationStatus, error) {
+	migrations, err := db.migrations()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := db.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []MigrationStatus
+	for _, m := range migrations {
+		status, ok := applied[m.Version]
+		if !ok {
+			status = MigrationStatus{Version: m.Version, Name: m.Name}
+		}
+		statuses = append(statuses, status)
+		delete(applied, m.Version)
+	}
+	for _, status := range applied {
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// ForceMigrationVersion clears the dirty mark after a failed migration has
+// been repaired by hand. Versions up to and including version are recorded
+// as applied, newer ones are forgotten.
+func (db *DB) ForceMigrationVersion(ctx context.Context, version int64) error {
+	migrations, err := db.migrations()
+	if err != nil {
+		return err
+	}
+	if _, err := db.appliedMigrations(ctx); err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE Version > ?`, version); err != nil {
+		return fmt.Errorf("error forcing migration version: %v", err)
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE schema_migrations SET Dirty = 0`); err != nil {
+		return fmt.Errorf("error forcing migration version: %v", err)
+	}
+	for _, m := range migrations {
+		if m.Version > version {
+			break
+		}
+		_, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO schema_migrations (Version, Name, Dirty) VALUES (?, ?, 0)`, m.Version, m.Name)
+		if err != nil {
+			return fmt.Errorf("error forcing migration version: %v", err)
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,230 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMigrateUpDown(t *testing.T) {
+	db, err := GetDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("GetDB failed: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	if err := db.MigrateUp(ctx); err != nil {
+		t.Fatalf("MigrateUp failed: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO items (Category, Name, Title) VALUES ('shoes', 'a', 'b')`); err != nil {
+		t.Fatalf("Expected migrated items table: %v", err)
+	}
+
+	statuses, err := db.MigrationStatus(ctx)
+	if err != nil {
+		t.Fatalf("MigrationStatus failed: %v", err)
+	}
+	if err := db.MigrateDown(ctx, len(statuses)); err != nil {
+		t.Fatalf("MigrateDown failed: %v", err)
+	}
+	if _, err := db.Exec(`SELECT ID FROM items`); err == nil {
+		t.Fatalf("Expected items to be dropped")
+	}
+
+	if err := db.MigrateUp(ctx); err != nil {
+		t.Fatalf("MigrateUp after MigrateDown failed: %v", err)
+	}
+	statuses, err = db.MigrationStatus(ctx)
+	if err != nil {
+		t.Fatalf("MigrationStatus failed: %v", err)
+	}
+	for _, s := range statuses {
+		if !s.Applied || s.Dirty {
+			t.Fatalf("Expected migration %d to be applied and clean, got %+v", s.Version, s)
+		}
+	}
+}
+
+func TestMigrateRefusesDirtyDatabase(t *testing.T) {
+	db, err := GetDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("GetDB failed: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	if err := db.MigrateUp(ctx); err != nil {
+		t.Fatalf("MigrateUp failed: %v", err)
+	}
+	statuses, err := db.MigrationStatus(ctx)
+	if err != nil {
+		t.Fatalf("MigrationStatus failed: %v", err)
+	}
+	latest := statuses[len(statuses)-1].Version
+	if _, err := db.Exec(`UPDATE schema_migrations SET Dirty = 1 WHERE Version = ?`, latest); err != nil {
+		t.Fatalf("Failed to mark database dirty: %v", err)
+	}
+
+	if err := db.MigrateUp(ctx); !errors.Is(err, ErrDirty) {
+		t.Fatalf("Expected ErrDirty, got %v", err)
+	}
+	if err := db.ForceMigrationVersion(ctx, latest); err != nil {
+		t.Fatalf("ForceMigrationVersion failed: %v", err)
+	}
+	if err := db.MigrateUp(ctx); err != nil {
+		t.Fatalf("MigrateUp after force failed: %v", err)
+	}
+}
+
+func TestMigrateForeignKeysReportsOrphans(t *testing.T) {
+	db, err := GetDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("GetDB failed: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	if err := db.MigrateUp(ctx); err != nil {
+		t.Fatalf("MigrateUp failed: %v", err)
+	}
+	statuses, err := db.MigrationStatus(ctx)
+	if err != nil {
+		t.Fatalf("MigrationStatus failed: %v", err)
+	}
+	// Go back to the schema before the foreign keys.
+	steps := 0
+	for _, s := range statuses {
+		if s.Version >= 3 {
+			steps++
+		}
+	}
+	if err := db.MigrateDown(ctx, steps); err != nil {
+		t.Fatalf("MigrateDown failed: %v", err)
+	}
+	_, err = db.Exec(`
+		INSERT INTO shoes (ID, ProductName) VALUES (1, 'a');
+		INSERT INTO pictures (ID) VALUES (1);
+		INSERT INTO shoentries (ID, ItemID, PictureID) VALUES (1, 1, 1), (2, 7, 1);
+		INSERT INTO restaurants (ID, Name) VALUES (1, 'Pizza'), (2, 'Pizza');
+		INSERT INTO foodentries (ID, ItemID, PictureID) VALUES (1, 2, 1);
+	`)
+	if err != nil {
+		t.Fatalf("Failed to insert fixtures: %v", err)
+	}
+
+	if err := db.MigrateUp(ctx); err != nil {
+		t.Fatalf("MigrateUp failed: %v", err)
+	}
+
+	report, err := db.OrphanedRowReport(ctx)
+	if err != nil {
+		t.Fatalf("OrphanedRowReport failed: %v", err)
+	}
+	expected := OrphanedRow{Table: "shoentries", RowID: 2, Column: "ItemID", MissingID: 7}
+	if len(report) != 1 || report[0] != expected {
+		t.Fatalf("Expected report %+v, got %+v", expected, report)
+	}
+
+	var entries int
+	query := `SELECT COUNT(*) FROM entries INNER JOIN items ON items.ID = entries.ItemID
+		WHERE entries.Category = 'restaurants' AND items.Name = 'Pizza' AND items.DeletedAt IS NULL`
+	if err := db.QueryRow(query).Scan(&entries); err != nil {
+		t.Fatalf("Failed to read entries: %v", err)
+	}
+	if entries != 1 {
+		t.Fatalf("Expected the foodentry to move to the merged restaurant, got %d entries", entries)
+	}
+
+	if _, err := db.Exec(`INSERT INTO entries (Category, ItemID, PictureID) VALUES ('shoes', 42, 1)`); err == nil {
+		t.Fatalf("Expected foreign key violation")
+	}
+	if _, err := db.Exec(`INSERT INTO items (Category, Name) VALUES ('restaurants', 'Pizza')`); err == nil {
+		t.Fatalf("Expected unique restaurant name violation")
+	}
+}
+
+func TestMigrateLegacyItems(t *testing.T) {
+	db, err := GetDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("GetDB failed: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	if err := db.MigrateUp(ctx); err != nil {
+		t.Fatalf("MigrateUp failed: %v", err)
+	}
+	statuses, err := db.MigrationStatus(ctx)
+	if err != nil {
+		t.Fatalf("MigrationStatus failed: %v", err)
+	}
+	// Go back to the schema with the tables of shoes and restaurants.
+	steps := 0
+	for _, s := range statuses {
+		if s.Version >= 9 {
+			steps++
+		}
+	}
+	if err := db.MigrateDown(ctx, steps); err != nil {
+		t.Fatalf("MigrateDown failed: %v", err)
+	}
+	_, err = db.Exec(`
+		INSERT INTO items (Category, Name, Title) VALUES ('watches', 'Speedmaster', 'Speedmaster');
+		INSERT INTO shoes (ID, Name, ProductName, LastSale, Description) VALUES (5, 'Air Jordan 1', 'Air-Jordan-1', '$180', 'Worn once');
+		INSERT INTO pictures (ID, LocalLocation, DiscordImageLink, DiscordMessageId, Latitude, Longitude, TakenAt)
+			VALUES (1, '1.jpg', '', '1', 0, 0, CURRENT_TIMESTAMP);
+		INSERT INTO shoentries (ID, ItemID, PictureID) VALUES (3, 5, 1);
+		INSERT INTO shoe_prices (ShoeID, Amount, Currency) VALUES (5, 180, 'USD');
+		INSERT INTO history (TableName, RowID, Field, OldValue, NewValue) VALUES ('shoes', 5, 'Name', 'AJ1', 'Air Jordan 1');
+	`)
+	if err != nil {
+		t.Fatalf("Failed to insert fixtures: %v", err)
+	}
+
+	if err := db.MigrateUp(ctx); err != nil {
+		t.Fatalf("MigrateUp failed: %v", err)
+	}
+	shoe, err := db.GetShoeByProductName(ctx, "Air-Jordan-1")
+	if err != nil || shoe == nil || shoe.Name != "Air Jordan 1" || shoe.LastSale != "$180" || shoe.Description != "Worn once" {
+		t.Fatalf("Expected the shoe as an item with its details, got %+v, %v", shoe, err)
+	}
+	entries, err := db.GetShoentriesByShoeID(ctx, shoe.ID)
+	if err != nil || len(entries) != 1 || entries[0].PictureID != 1 {
+		t.Fatalf("Expected the shoentry as an entry of the shoe, got %+v, %v", entries, err)
+	}
+	if prices, _ := db.GetShoePrices(ctx, shoe.ID); len(prices) != 1 {
+		t.Fatalf("Expected the price to follow the shoe, got %+v", prices)
+	}
+	changes, err := db.History(ctx, "items", shoe.ID)
+	if err != nil || len(changes) != 1 || changes[0].OldValue != "AJ1" {
+		t.Fatalf("Expected the history to follow the shoe, got %+v, %v", changes, err)
+	}
+
+	if err := db.MigrateDown(ctx, steps); err != nil {
+		t.Fatalf("MigrateDown failed: %v", err)
+	}
+	var name string
+	if err := db.QueryRow(`SELECT shoes.Name FROM shoentries INNER JOIN shoes ON shoes.ID = shoentries.ItemID`).Scan(&name); err != nil || name != "Air Jordan 1" {
+		t.Fatalf("Expected the shoentry back in its table, got %q, %v", name, err)
+	}
+}
+
+func TestLoadMigrationsOverridesGoSteps(t *testing.T) {
+	migrations, err := LoadMigrations(fstest.MapFS{
+		"0001_initial.up.sql":      {Data: []byte("CREATE TABLE shoes (ID INTEGER PRIMARY KEY)")},
+		"0002_spinning_gif.up.sql": {Data: []byte("ALTER TABLE shoes ADD COLUMN SpinningGifURL TEXT")},
+	})
+	if err != nil {
+		t.Fatalf("LoadMigrations failed: %v", err)
+	}
+	names := make(map[int64]string)
+	for _, m := range migrations {
+		names[m.Version] = m.Name
+	}
+	if names[2] != "spinning_gif" || names[3] != "foreign_keys" || names[5] != "shoe_prices" {
+		t.Fatalf("Expected the SQL step of version 2 and the Go steps of 3 and 5, got %v", names)
+	}
+}
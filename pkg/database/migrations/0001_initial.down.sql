@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS pictures;
+DROP TABLE IF EXISTS foodentries;
+DROP TABLE IF EXISTS restaurants;
+DROP TABLE IF EXISTS shoentries;
+DROP TABLE IF EXISTS shoes;
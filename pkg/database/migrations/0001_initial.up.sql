@@ -0,0 +1,47 @@
+CREATE TABLE IF NOT EXISTS shoes (
+    ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    Name TEXT,
+    Subtitle TEXT,
+    LastSale TEXT,
+    ProductName TEXT UNIQUE,
+    MainPicture TEXT,
+    Attributes TEXT,
+    Description TEXT,
+    Timestamp DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS shoentries (
+    ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    ItemID INTEGER,
+    PictureID INTEGER,
+    UpdatedAt DATETIME DEFAULT CURRENT_TIMESTAMP,
+    CreatedAt DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS restaurants (
+    ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    Name TEXT,
+    Attributes TEXT,
+    Timestamp DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS foodentries (
+    ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    Name TEXT,
+    ItemID INTEGER,
+    PictureID INTEGER,
+    UpdatedAt DATETIME DEFAULT CURRENT_TIMESTAMP,
+    CreatedAt DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS pictures (
+    ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    LocalLocation TEXT,
+    DiscordImageLink TEXT,
+    DiscordMessageId TEXT,
+    Latitude REAL,
+    Longitude REAL,
+    TakenAt DATETIME,
+    UpdatedAt DATETIME DEFAULT CURRENT_TIMESTAMP,
+    CreatedAt DATETIME DEFAULT CURRENT_TIMESTAMP
+);
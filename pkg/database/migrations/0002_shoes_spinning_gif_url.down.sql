@@ -0,0 +1 @@
+ALTER TABLE shoes DROP COLUMN SpinningGifURL;
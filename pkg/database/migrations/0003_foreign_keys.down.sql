@@ -0,0 +1,28 @@
+DROP INDEX IF EXISTS idx_restaurants_name;
+
+CREATE TABLE shoentries_old (
+    ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    ItemID INTEGER,
+    PictureID INTEGER,
+    UpdatedAt DATETIME DEFAULT CURRENT_TIMESTAMP,
+    CreatedAt DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+INSERT INTO shoentries_old (ID, ItemID, PictureID, UpdatedAt, CreatedAt)
+    SELECT ID, ItemID, PictureID, UpdatedAt, CreatedAt FROM shoentries;
+DROP TABLE shoentries;
+ALTER TABLE shoentries_old RENAME TO shoentries;
+
+CREATE TABLE foodentries_old (
+    ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    Name TEXT,
+    ItemID INTEGER,
+    PictureID INTEGER,
+    UpdatedAt DATETIME DEFAULT CURRENT_TIMESTAMP,
+    CreatedAt DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+INSERT INTO foodentries_old (ID, Name, ItemID, PictureID, UpdatedAt, CreatedAt)
+    SELECT ID, Name, ItemID, PictureID, UpdatedAt, CreatedAt FROM foodentries;
+DROP TABLE foodentries;
+ALTER TABLE foodentries_old RENAME TO foodentries;
+
+DROP TABLE IF EXISTS orphaned_rows;
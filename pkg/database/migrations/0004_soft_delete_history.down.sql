@@ -0,0 +1,17 @@
+DROP TABLE history;
+
+-- Deleted rows come back without DeletedAt, deleted restaurants are renamed
+-- when their name was reused.
+DROP INDEX idx_restaurants_name;
+UPDATE restaurants SET Name = Name || ' (deleted ' || ID || ')'
+    WHERE DeletedAt IS NOT NULL AND Name IN (SELECT Name FROM restaurants WHERE DeletedAt IS NULL);
+CREATE UNIQUE INDEX idx_restaurants_name ON restaurants(Name);
+
+ALTER TABLE restaurants DROP COLUMN UpdatedAt;
+ALTER TABLE shoes DROP COLUMN UpdatedAt;
+
+ALTER TABLE pictures DROP COLUMN DeletedAt;
+ALTER TABLE foodentries DROP COLUMN DeletedAt;
+ALTER TABLE restaurants DROP COLUMN DeletedAt;
+ALTER TABLE shoentries DROP COLUMN DeletedAt;
+ALTER TABLE shoes DROP COLUMN DeletedAt;
@@ -0,0 +1,27 @@
+ALTER TABLE shoes ADD COLUMN DeletedAt DATETIME;
+ALTER TABLE shoentries ADD COLUMN DeletedAt DATETIME;
+ALTER TABLE restaurants ADD COLUMN DeletedAt DATETIME;
+ALTER TABLE foodentries ADD COLUMN DeletedAt DATETIME;
+ALTER TABLE pictures ADD COLUMN DeletedAt DATETIME;
+
+-- Entries and pictures already track changes in UpdatedAt.
+ALTER TABLE shoes ADD COLUMN UpdatedAt DATETIME;
+UPDATE shoes SET UpdatedAt = Timestamp;
+ALTER TABLE restaurants ADD COLUMN UpdatedAt DATETIME;
+UPDATE restaurants SET UpdatedAt = Timestamp;
+
+-- A deleted restaurant must not block a new one with the same name.
+DROP INDEX IF EXISTS idx_restaurants_name;
+CREATE UNIQUE INDEX idx_restaurants_name ON restaurants(Name) WHERE DeletedAt IS NULL;
+
+CREATE TABLE history (
+    ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    TableName TEXT NOT NULL,
+    RowID INTEGER NOT NULL,
+    Field TEXT NOT NULL,
+    OldValue TEXT,
+    NewValue TEXT,
+    ChangedBy TEXT,
+    ChangedAt DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX idx_history_row ON history(TableName, RowID);
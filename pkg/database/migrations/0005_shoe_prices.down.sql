@@ -0,0 +1 @@
+DROP TABLE shoe_prices;
@@ -0,0 +1,11 @@
+CREATE TABLE price_alerts (
+    ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    ShoeID INTEGER NOT NULL REFERENCES shoes(ID) ON DELETE CASCADE,
+    Kind TEXT NOT NULL CHECK (Kind IN ('below', 'drop')),
+    Threshold REAL NOT NULL,
+    Currency TEXT,
+    LastTriggeredAt DATETIME,
+    CreatedBy TEXT,
+    CreatedAt DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX idx_price_alerts_shoe ON price_alerts(ShoeID);
@@ -0,0 +1,12 @@
+CREATE TABLE jobs (
+    ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    URL TEXT NOT NULL,
+    State TEXT NOT NULL DEFAULT 'queued' CHECK (State IN ('queued', 'running', 'failed', 'done', 'cancelled')),
+    Attempts INTEGER NOT NULL DEFAULT 0,
+    LastError TEXT,
+    NextAttemptAt DATETIME NOT NULL,
+    CreatedAt DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UpdatedAt DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX idx_jobs_open_url ON jobs(URL) WHERE State IN ('queued', 'running');
+CREATE INDEX idx_jobs_state ON jobs(State, NextAttemptAt);
@@ -0,0 +1,26 @@
+CREATE TABLE items (
+    ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    Category TEXT NOT NULL,
+    Name TEXT NOT NULL,
+    Title TEXT,
+    Attributes TEXT,
+    Picture TEXT,
+    CreatedAt DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UpdatedAt DATETIME DEFAULT CURRENT_TIMESTAMP,
+    DeletedAt DATETIME
+);
+-- A deleted item must not block a new one with the same name.
+CREATE UNIQUE INDEX idx_items_name ON items(Category, Name) WHERE DeletedAt IS NULL;
+
+CREATE TABLE entries (
+    ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    Category TEXT NOT NULL,
+    Name TEXT,
+    ItemID INTEGER NOT NULL REFERENCES items(ID) ON DELETE CASCADE,
+    PictureID INTEGER REFERENCES pictures(ID) ON DELETE CASCADE,
+    CreatedAt DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UpdatedAt DATETIME DEFAULT CURRENT_TIMESTAMP,
+    DeletedAt DATETIME
+);
+CREATE INDEX idx_entries_item ON entries(ItemID);
+CREATE INDEX idx_entries_category ON entries(Category, CreatedAt);
@@ -0,0 +1,103 @@
+-- The items of shoes and restaurants and their entries move back to their
+-- tables, keeping their IDs.
+CREATE TABLE shoes (
+    ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    Name TEXT,
+    Subtitle TEXT,
+    LastSale TEXT,
+    ProductName TEXT UNIQUE,
+    MainPicture TEXT,
+    Attributes TEXT,
+    Description TEXT,
+    Timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
+    SpinningGifURL TEXT,
+    DeletedAt DATETIME,
+    UpdatedAt DATETIME
+);
+INSERT INTO shoes (ID, Name, Subtitle, LastSale, ProductName, MainPicture, Attributes, Description, Timestamp, SpinningGifURL, DeletedAt, UpdatedAt)
+    SELECT items.ID, items.Title, shoe_details.Subtitle, shoe_details.LastSale, items.Name, items.Picture, items.Attributes,
+        shoe_details.Description, items.CreatedAt, shoe_details.SpinningGifURL, items.DeletedAt, items.UpdatedAt
+    FROM items LEFT JOIN shoe_details ON shoe_details.ItemID = items.ID
+    WHERE items.Category = 'shoes';
+
+CREATE TABLE restaurants (
+    ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    Name TEXT,
+    Attributes TEXT,
+    Timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
+    DeletedAt DATETIME,
+    UpdatedAt DATETIME
+);
+CREATE UNIQUE INDEX idx_restaurants_name ON restaurants(Name) WHERE DeletedAt IS NULL;
+INSERT INTO restaurants (ID, Name, Attributes, Timestamp, DeletedAt, UpdatedAt)
+    SELECT ID, Name, Attributes, CreatedAt, DeletedAt, UpdatedAt FROM items WHERE Category = 'restaurants';
+
+CREATE TABLE shoentries (
+    ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    ItemID INTEGER REFERENCES shoes(ID) ON DELETE CASCADE,
+    PictureID INTEGER REFERENCES pictures(ID) ON DELETE CASCADE,
+    UpdatedAt DATETIME DEFAULT CURRENT_TIMESTAMP,
+    CreatedAt DATETIME DEFAULT CURRENT_TIMESTAMP,
+    DeletedAt DATETIME
+);
+CREATE INDEX idx_shoentries_item ON shoentries(ItemID);
+CREATE INDEX idx_shoentries_picture ON shoentries(PictureID);
+CREATE INDEX idx_shoentries_created ON shoentries(CreatedAt);
+INSERT INTO shoentries (ID, ItemID, PictureID, UpdatedAt, CreatedAt, DeletedAt)
+    SELECT ID, ItemID, PictureID, UpdatedAt, CreatedAt, DeletedAt FROM entries WHERE Category = 'shoes';
+
+CREATE TABLE foodentries (
+    ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    Name TEXT,
+    ItemID INTEGER REFERENCES restaurants(ID) ON DELETE CASCADE,
+    PictureID INTEGER REFERENCES pictures(ID) ON DELETE CASCADE,
+    UpdatedAt DATETIME DEFAULT CURRENT_TIMESTAMP,
+    CreatedAt DATETIME DEFAULT CURRENT_TIMESTAMP,
+    DeletedAt DATETIME
+);
+CREATE INDEX idx_foodentries_item ON foodentries(ItemID);
+CREATE INDEX idx_foodentries_picture ON foodentries(PictureID);
+INSERT INTO foodentries (ID, Name, ItemID, PictureID, UpdatedAt, CreatedAt, DeletedAt)
+    SELECT ID, Name, ItemID, PictureID, UpdatedAt, CreatedAt, DeletedAt FROM entries WHERE Category = 'restaurants';
+
+CREATE TABLE shoe_prices_new (
+    ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    ShoeID INTEGER NOT NULL REFERENCES shoes(ID) ON DELETE CASCADE,
+    Amount REAL NOT NULL,
+    Currency TEXT NOT NULL,
+    ObservedAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+INSERT INTO shoe_prices_new (ID, ShoeID, Amount, Currency, ObservedAt)
+    SELECT ID, ShoeID, Amount, Currency, ObservedAt FROM shoe_prices;
+DROP TABLE shoe_prices;
+ALTER TABLE shoe_prices_new RENAME TO shoe_prices;
+CREATE INDEX idx_shoe_prices_shoe ON shoe_prices(ShoeID, ObservedAt);
+
+CREATE TABLE price_alerts_new (
+    ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    ShoeID INTEGER NOT NULL REFERENCES shoes(ID) ON DELETE CASCADE,
+    Kind TEXT NOT NULL CHECK (Kind IN ('below', 'drop')),
+    Threshold REAL NOT NULL,
+    Currency TEXT,
+    LastTriggeredAt DATETIME,
+    CreatedBy TEXT,
+    CreatedAt DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+INSERT INTO price_alerts_new (ID, ShoeID, Kind, Threshold, Currency, LastTriggeredAt, CreatedBy, CreatedAt)
+    SELECT ID, ShoeID, Kind, Threshold, Currency, LastTriggeredAt, CreatedBy, CreatedAt FROM price_alerts;
+DROP TABLE price_alerts;
+ALTER TABLE price_alerts_new RENAME TO price_alerts;
+CREATE INDEX idx_price_alerts_shoe ON price_alerts(ShoeID);
+
+UPDATE history SET TableName = 'shoes'
+    WHERE TableName = 'items' AND RowID IN (SELECT ID FROM items WHERE Category = 'shoes');
+UPDATE history SET TableName = 'restaurants'
+    WHERE TableName = 'items' AND RowID IN (SELECT ID FROM items WHERE Category = 'restaurants');
+UPDATE history SET TableName = 'shoentries'
+    WHERE TableName = 'entries' AND RowID IN (SELECT ID FROM entries WHERE Category = 'shoes');
+UPDATE history SET TableName = 'foodentries'
+    WHERE TableName = 'entries' AND RowID IN (SELECT ID FROM entries WHERE Category = 'restaurants');
+
+DELETE FROM entries WHERE Category IN ('shoes', 'restaurants');
+DELETE FROM items WHERE Category IN ('shoes', 'restaurants');
+DROP TABLE shoe_details;
@@ -0,0 +1,88 @@
+-- Shoes and restaurants become items of their category, shoentries and
+-- foodentries their entries. LegacyID holds the old ID while the rows move.
+CREATE TABLE shoe_details (
+    ItemID INTEGER PRIMARY KEY REFERENCES items(ID) ON DELETE CASCADE,
+    Subtitle TEXT,
+    LastSale TEXT,
+    Description TEXT,
+    SpinningGifURL TEXT
+);
+
+ALTER TABLE items ADD COLUMN LegacyID INTEGER;
+ALTER TABLE entries ADD COLUMN LegacyID INTEGER;
+
+INSERT INTO items (Category, Name, Title, Attributes, Picture, CreatedAt, UpdatedAt, DeletedAt, LegacyID)
+    SELECT 'shoes', COALESCE(ProductName, 'shoe ' || ID), COALESCE(Name, ProductName), COALESCE(Attributes, '{}'), COALESCE(MainPicture, ''),
+        COALESCE(Timestamp, CURRENT_TIMESTAMP), COALESCE(UpdatedAt, Timestamp, CURRENT_TIMESTAMP), DeletedAt, ID
+    FROM shoes ORDER BY ID;
+INSERT INTO shoe_details (ItemID, Subtitle, LastSale, Description, SpinningGifURL)
+    SELECT items.ID, shoes.Subtitle, shoes.LastSale, shoes.Description, shoes.SpinningGifURL
+    FROM shoes INNER JOIN items ON items.Category = 'shoes' AND items.LegacyID = shoes.ID;
+
+INSERT INTO items (Category, Name, Title, Attributes, Picture, CreatedAt, UpdatedAt, DeletedAt, LegacyID)
+    SELECT 'restaurants', COALESCE(Name, 'restaurant ' || ID), Name, COALESCE(Attributes, '{}'), '',
+        COALESCE(Timestamp, CURRENT_TIMESTAMP), COALESCE(UpdatedAt, Timestamp, CURRENT_TIMESTAMP), DeletedAt, ID
+    FROM restaurants ORDER BY ID;
+
+-- Entries that lost their shoe or restaurant were reported by the foreign key
+-- migration and are not carried over.
+INSERT INTO entries (Category, Name, ItemID, PictureID, CreatedAt, UpdatedAt, DeletedAt, LegacyID)
+    SELECT 'shoes', '', items.ID, shoentries.PictureID, shoentries.CreatedAt, shoentries.UpdatedAt, shoentries.DeletedAt, shoentries.ID
+    FROM shoentries INNER JOIN items ON items.Category = 'shoes' AND items.LegacyID = shoentries.ItemID
+    ORDER BY shoentries.ID;
+INSERT INTO entries (Category, Name, ItemID, PictureID, CreatedAt, UpdatedAt, DeletedAt, LegacyID)
+    SELECT 'restaurants', COALESCE(foodentries.Name, ''), items.ID, foodentries.PictureID, foodentries.CreatedAt, foodentries.UpdatedAt, foodentries.DeletedAt, foodentries.ID
+    FROM foodentries INNER JOIN items ON items.Category = 'restaurants' AND items.LegacyID = foodentries.ItemID
+    ORDER BY foodentries.ID;
+
+-- Prices and alerts keep their IDs and refer to the items of the shoes.
+CREATE TABLE shoe_prices_new (
+    ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    ShoeID INTEGER NOT NULL REFERENCES items(ID) ON DELETE CASCADE,
+    Amount REAL NOT NULL,
+    Currency TEXT NOT NULL,
+    ObservedAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+INSERT INTO shoe_prices_new (ID, ShoeID, Amount, Currency, ObservedAt)
+    SELECT shoe_prices.ID, items.ID, shoe_prices.Amount, shoe_prices.Currency, shoe_prices.ObservedAt
+    FROM shoe_prices INNER JOIN items ON items.Category = 'shoes' AND items.LegacyID = shoe_prices.ShoeID;
+DROP TABLE shoe_prices;
+ALTER TABLE shoe_prices_new RENAME TO shoe_prices;
+CREATE INDEX idx_shoe_prices_shoe ON shoe_prices(ShoeID, ObservedAt);
+
+CREATE TABLE price_alerts_new (
+    ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    ShoeID INTEGER NOT NULL REFERENCES items(ID) ON DELETE CASCADE,
+    Kind TEXT NOT NULL CHECK (Kind IN ('below', 'drop')),
+    Threshold REAL NOT NULL,
+    Currency TEXT,
+    LastTriggeredAt DATETIME,
+    CreatedBy TEXT,
+    CreatedAt DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+INSERT INTO price_alerts_new (ID, ShoeID, Kind, Threshold, Currency, LastTriggeredAt, CreatedBy, CreatedAt)
+    SELECT price_alerts.ID, items.ID, price_alerts.Kind, price_alerts.Threshold, price_alerts.Currency,
+        price_alerts.LastTriggeredAt, price_alerts.CreatedBy, price_alerts.CreatedAt
+    FROM price_alerts INNER JOIN items ON items.Category = 'shoes' AND items.LegacyID = price_alerts.ShoeID;
+DROP TABLE price_alerts;
+ALTER TABLE price_alerts_new RENAME TO price_alerts;
+CREATE INDEX idx_price_alerts_shoe ON price_alerts(ShoeID);
+
+-- The history of the moved rows follows them. Purged rows keep theirs under
+-- the old table.
+UPDATE history SET TableName = 'items', RowID = (SELECT ID FROM items WHERE Category = 'shoes' AND LegacyID = history.RowID)
+    WHERE TableName = 'shoes' AND RowID IN (SELECT LegacyID FROM items WHERE Category = 'shoes');
+UPDATE history SET TableName = 'items', RowID = (SELECT ID FROM items WHERE Category = 'restaurants' AND LegacyID = history.RowID)
+    WHERE TableName = 'restaurants' AND RowID IN (SELECT LegacyID FROM items WHERE Category = 'restaurants');
+UPDATE history SET TableName = 'entries', RowID = (SELECT ID FROM entries WHERE Category = 'shoes' AND LegacyID = history.RowID)
+    WHERE TableName = 'shoentries' AND RowID IN (SELECT LegacyID FROM entries WHERE Category = 'shoes');
+UPDATE history SET TableName = 'entries', RowID = (SELECT ID FROM entries WHERE Category = 'restaurants' AND LegacyID = history.RowID)
+    WHERE TableName = 'foodentries' AND RowID IN (SELECT LegacyID FROM entries WHERE Category = 'restaurants');
+
+DROP TABLE shoentries;
+DROP TABLE foodentries;
+DROP TABLE shoes;
+DROP TABLE restaurants;
+
+ALTER TABLE items DROP COLUMN LegacyID;
+ALTER TABLE entries DROP COLUMN LegacyID;
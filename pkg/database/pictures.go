@@ -1,13 +1,33 @@
 package database
 
 import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
 	"time"
 )
 
-func (db *DB) InsertPicture(localLocation, discordImageUrl, discordMessageId string, latitude, longitude float64, takenAt time.Time) (int64, error) {
+type Picture struct {
+	ID               int64     `json:"id"`
+	LocalLocation    string    `json:"local_location"`
+	DiscordImageLink string    `json:"discord_image_link"`
+	DiscordMessageId string    `json:"discord_message_id"`
+	Latitude         float64   `json:"latitude"`
+	Longitude        float64   `json:"longitude"`
+	TakenAt          time.Time `json:"taken_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+func (db *DB) InsertPicture(ctx context.Context, localLocation, discordImageUrl, discordMessageId string, latitude, longitude float64, takenAt time.Time) (int64, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
 	query := `INSERT INTO pictures (LocalLocation, DiscordImageLink, DiscordMessageId, Latitude, Longitude, TakenAt) VALUES (?, ?, ?, ?, ?, ?)`
-	result, err := db.Exec(query, localLocation, discordImageUrl, discordMessageId, latitude, longitude, takenAt)
+	result, err := db.conn().ExecContext(ctx, query, localLocation, discordImageUrl, discordMessageId, latitude, longitude, takenAt)
 	if err != nil {
 		return 0, fmt.Errorf("error inserting picture: %v", err)
 	}
@@ -18,11 +38,143 @@ func (db *DB) InsertPicture(localLocation, discordImageUrl, discordMessageId str
 	return id, nil
 }
 
-func (db *DB) UpdatePictureFilePathAndTimestamp(id int64, newFilePath string) error {
+func (db *DB) UpdatePictureFilePathAndTimestamp(ctx context.Context, id int64, newFilePath string) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
 	query := `UPDATE pictures SET LocalLocation = ?, UpdatedAt = ? WHERE id = ?`
-	_, err := db.Exec(query, newFilePath, time.Now(), id)
+	_, err := db.conn().ExecContext(ctx, query, newFilePath, time.Now(), id)
 	if err != nil {
 		return fmt.Errorf("error updating picture file path and timestamp: %v", err)
 	}
 	return nil
 }
+
+const pictureColumns = `ID, LocalLocation, DiscordImageLink, DiscordMessageId, Latitude, Longitude, TakenAt, UpdatedAt, CreatedAt`
+
+const pictureQuery = `SELECT ` + pictureColumns + ` FROM pictures WHERE ID = ?`
+
+func scanPicture(row rowScanner) (Picture, error) {
+	var p Picture
+	var local, link, messageID sql.NullString
+	var latitude, longitude sql.NullFloat64
+	var takenAt sql.NullTime
+	err := row.Scan(&p.ID, &local, &link, &messageID, &latitude, &longitude, &takenAt, &p.UpdatedAt, &p.CreatedAt)
+	p.LocalLocation, p.DiscordImageLink, p.DiscordMessageId = local.String, link.String, messageID.String
+	p.Latitude, p.Longitude, p.TakenAt = latitude.Float64, longitude.Float64, takenAt.Time
+	return p, err
+}
+
+func getPicture(ctx context.Context, conn dbtx, query string, id int64) (*Picture, error) {
+	p, err := scanPicture(conn.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error retrieving picture: %v", err)
+	}
+	return &p, nil
+}
+
+// ListPictures returns the pictures by ID.
+func (db *DB) ListPictures(ctx context.Context) ([]Picture, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.conn().QueryContext(ctx, `SELECT `+pictureColumns+` FROM pictures WHERE DeletedAt IS NULL ORDER BY ID`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying pictures: %v", err)
+	}
+	defer rows.Close()
+
+	pictures := []Picture{}
+	for rows.Next() {
+		picture, err := scanPicture(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning picture: %v", err)
+		}
+		pictures = append(pictures, picture)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading picture rows: %v", err)
+	}
+	return pictures, nil
+}
+
+func (db *DB) GetPictureByID(ctx context.Context, id int64) (*Picture, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	return getPicture(ctx, db.conn(), pictureQuery+` AND DeletedAt IS NULL`, id)
+}
+
+// UpdatePicture writes the location and time the picture was taken at. The
+// file and the Discord message belong to the onboarding and are left alone.
+func (db *DB) UpdatePicture(ctx context.Context, picture Picture) error {
+	return db.updateFields(ctx, "pictures", picture.ID, []string{"Latitude", "Longitude", "TakenAt"}, picture.Latitude, picture.Longitude, picture.TakenAt)
+}
+
+// DeletePicture deletes the picture together with the entries showing it.
+func (db *DB) DeletePicture(ctx context.Context, id int64) ([]Picture, error) {
+	return db.deleteRow(ctx, "pictures", id)
+}
+
+// purgeUnreferencedPictures removes the pictures in ids that no entry,
+// deleted or not, shows anymore and returns them.
+func purgeUnreferencedPictures(ctx context.Context, conn dbtx, ids []int64) ([]Picture, error) {
+	var purged []Picture
+	for _, id := range ids {
+		picture, err := getPicture(ctx, conn, pictureQuery, id)
+		if err != nil {
+			return nil, err
+		}
+		if picture == nil {
+			continue
+		}
+		query := `DELETE FROM pictures WHERE ID = ? AND NOT EXISTS (SELECT 1 FROM entries WHERE PictureID = ?)`
+		result, err := conn.ExecContext(ctx, query, id, id)
+		if err != nil {
+			return nil, fmt.Errorf("error purging picture: %v", err)
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			if err := recordChange(ctx, conn, "pictures", id, "PurgedAt", "", time.Now().Format(time.RFC3339Nano)); err != nil {
+				return nil, err
+			}
+			purged = append(purged, *picture)
+		}
+	}
+	return purged, nil
+}
+
+func validAttributes(attributes string) error {
+	if attributes != "" && !json.Valid([]byte(attributes)) {
+		return fmt.Errorf("attributes must be a JSON object, got %q", attributes)
+	}
+	return nil
+}
+
+func checkAffected(result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting affected rows: %v", err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RemovePictureFiles removes the local copies of deleted pictures. Files that
+// are already gone are not an error.
+func RemovePictureFiles(pictures []Picture) error {
+	var errs []error
+	for _, p := range pictures {
+		if p.LocalLocation == "" {
+			continue
+		}
+		if err := os.Remove(p.LocalLocation); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, fmt.Errorf("error removing %s: %v", p.LocalLocation, err))
+		}
+	}
+	return errors.Join(errs...)
+}
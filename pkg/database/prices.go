@@ -0,0 +1,152 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+	"vertigo/pkg/stockx"
+)
+
+type ShoePrice struct {
+	ID         int64     `json:"id"`
+	ShoeID     int64     `json:"shoe_id"`
+	Amount     float64   `json:"amount"`
+	Currency   string    `json:"currency"`
+	ObservedAt time.Time `json:"observed_at"`
+}
+
+const createShoePricesTable = `
+	CREATE TABLE shoe_prices (
+		ID INTEGER PRIMARY KEY AUTOINCREMENT,
+		ShoeID INTEGER NOT NULL REFERENCES shoes(ID) ON DELETE CASCADE,
+		Amount REAL NOT NULL,
+		Currency TEXT NOT NULL,
+		ObservedAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX idx_shoe_prices_shoe ON shoe_prices(ShoeID, ObservedAt);
+`
+
+// migrateShoePrices creates shoe_prices and records the LastSale of every
+// shoe as observed when the shoe was added.
+func migrateShoePrices(ctx context.Context, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, createShoePricesTable); err != nil {
+		return fmt.Errorf("error creating shoe_prices: %v", err)
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT ID, LastSale FROM shoes WHERE LastSale IS NOT NULL AND LastSale <> ''`)
+	if err != nil {
+		return fmt.Errorf("error querying last sales: %v", err)
+	}
+	lastSales := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var lastSale string
+		if err := rows.Scan(&id, &lastSale); err != nil {
+			rows.Close()
+			return fmt.Errorf("error scanning last sale: %v", err)
+		}
+		lastSales[id] = lastSale
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error reading last sales: %v", err)
+	}
+
+	for id, lastSale := range lastSales {
+		price, err := stockx.ParsePrice(lastSale)
+		if err != nil {
+			log.Printf("Skipping the last sale of shoe %d: %v", id, err)
+			continue
+		}
+		_, err = tx.ExecContext(ctx, `INSERT INTO shoe_prices (ShoeID, Amount, Currency, ObservedAt) SELECT ID, ?, ?, Timestamp FROM shoes WHERE ID = ?`, price.Amount, price.Currency, id)
+		if err != nil {
+			return fmt.Errorf("error recording last sale of shoe %d: %v", id, err)
+		}
+	}
+	return nil
+}
+
+func insertShoePrice(ctx context.Context, conn dbtx, shoeID int64, price stockx.Price, observedAt time.Time) (int64, error) {
+	result, err := conn.ExecContext(ctx, `INSERT INTO shoe_prices (ShoeID, Amount, Currency, ObservedAt) VALUES (?, ?, ?, ?)`, shoeID, price.Amount, price.Currency, observedAt.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("error inserting shoe price: %v", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("error getting last insert id: %v", err)
+	}
+	return id, nil
+}
+
+// refreshActor is who changes the LastSale of a shoe in the history when ctx
+// names no one.
+const refreshActor = "refresh"
+
+// refreshContext returns ctx with refreshActor unless it has an actor.
+func refreshContext(ctx context.Context) context.Context {
+	if actorFrom(ctx) == "" {
+		return WithActor(ctx, refreshActor)
+	}
+	return ctx
+}
+
+// RecordShoePrice appends the scraped last sale to the price history of the
+// shoe and shows it as the shoe's LastSale, recording the change in the
+// history. Prices that can't be parsed, like "--" for a shoe that never sold,
+// are an error and nothing is recorded.
+func (db *DB) RecordShoePrice(ctx context.Context, shoeID int64, lastSale string, observedAt time.Time) (*ShoePrice, error) {
+	price, err := stockx.ParsePrice(lastSale)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	var id int64
+	err = db.inTx(ctx, func(conn dbtx) error {
+		if err := updateShoeDetails(refreshContext(ctx), conn, shoeID, []string{"LastSale"}, lastSale); err != nil {
+			return err
+		}
+		id, err = insertShoePrice(ctx, conn, shoeID, price, observedAt)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &ShoePrice{ID: id, ShoeID: shoeID, Amount: price.Amount, Currency: price.Currency, ObservedAt: observedAt.UTC()}, nil
+}
+
+// GetShoePrices returns the price history of the shoe, oldest first.
+func (db *DB) GetShoePrices(ctx context.Context, shoeID int64) ([]ShoePrice, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT shoe_prices.ID, shoe_prices.ShoeID, shoe_prices.Amount, shoe_prices.Currency, shoe_prices.ObservedAt
+		FROM shoe_prices
+		INNER JOIN items ON shoe_prices.ShoeID = items.ID
+		WHERE shoe_prices.ShoeID = ? AND items.DeletedAt IS NULL
+		ORDER BY shoe_prices.ObservedAt, shoe_prices.ID
+	`
+	rows, err := db.conn().QueryContext(ctx, query, shoeID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying shoe prices: %v", err)
+	}
+	defer rows.Close()
+
+	prices := []ShoePrice{}
+	for rows.Next() {
+		var p ShoePrice
+		if err := rows.Scan(&p.ID, &p.ShoeID, &p.Amount, &p.Currency, &p.ObservedAt); err != nil {
+			return nil, fmt.Errorf("error scanning shoe price: %v", err)
+		}
+		prices = append(prices, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading shoe price rows: %v", err)
+	}
+	return prices, nil
+}
@@ -0,0 +1,128 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+	"vertigo/pkg/stockx"
+)
+
+func TestShoePrices(t *testing.T) {
+	db, err := GetDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("GetDB failed: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	if err := db.MigrateUp(ctx); err != nil {
+		t.Fatalf("MigrateUp failed: %v", err)
+	}
+	if err := db.InsertShoe(ctx, stockx.ProductDetails{Name: "Air Jordan 1", ProductName: "Air-Jordan-1", LastSale: "$180"}); err != nil {
+		t.Fatalf("InsertShoe failed: %v", err)
+	}
+	shoe, _ := db.GetShoeByProductName(ctx, "Air-Jordan-1")
+	var insertedAt string
+	db.QueryRow(`SELECT UpdatedAt FROM items WHERE ID = ?`, shoe.ID).Scan(&insertedAt)
+
+	if _, err := db.RecordShoePrice(ctx, shoe.ID, "€1.234,50", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("RecordShoePrice failed: %v", err)
+	}
+	if _, err := db.RecordShoePrice(ctx, shoe.ID, "--", time.Now()); err == nil {
+		t.Fatalf("Expected an error for a missing price")
+	}
+	if _, err := db.RecordShoePrice(ctx, shoe.ID+1, "$200", time.Now()); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound, got %v", err)
+	}
+
+	prices, err := db.GetShoePrices(ctx, shoe.ID)
+	if err != nil {
+		t.Fatalf("GetShoePrices failed: %v", err)
+	}
+	if len(prices) != 2 || prices[0].Amount != 180 || prices[1].Amount != 1234.5 || prices[1].Currency != "EUR" {
+		t.Fatalf("Unexpected prices %+v", prices)
+	}
+	if shoe, _ = db.GetShoeByID(ctx, shoe.ID); shoe.LastSale != "€1.234,50" {
+		t.Fatalf("Expected LastSale to show the latest price, got %q", shoe.LastSale)
+	}
+	changes, err := db.History(ctx, "items", shoe.ID)
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	last := changes[len(changes)-1]
+	if last.Field != "LastSale" || last.OldValue != "$180" || last.NewValue != "€1.234,50" || last.ChangedBy != "refresh" {
+		t.Fatalf("Expected the new LastSale in the history, got %+v", changes)
+	}
+	var updatedAt string
+	db.QueryRow(`SELECT UpdatedAt FROM items WHERE ID = ?`, shoe.ID).Scan(&updatedAt)
+	if updatedAt == insertedAt {
+		t.Fatalf("Expected UpdatedAt to change with LastSale, still %q", updatedAt)
+	}
+
+	alertID, err := db.InsertPriceAlert(WithActor(ctx, "tester"), PriceAlert{ShoeID: shoe.ID, Kind: AlertBelow, Threshold: 1300, Currency: "EUR"})
+	if err != nil {
+		t.Fatalf("InsertPriceAlert failed: %v", err)
+	}
+	if _, err := db.InsertPriceAlert(ctx, PriceAlert{ShoeID: shoe.ID, Kind: "above", Threshold: 1}); err == nil {
+		t.Fatalf("Expected an error for an unknown kind")
+	}
+	if _, err := db.InsertPriceAlert(ctx, PriceAlert{ShoeID: shoe.ID + 1, Kind: AlertDrop, Threshold: 10}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound, got %v", err)
+	}
+	triggered, err := EvaluatePriceAlerts(ctx, db, prices[1])
+	if err != nil {
+		t.Fatalf("EvaluatePriceAlerts failed: %v", err)
+	}
+	if len(triggered) != 1 || triggered[0].Previous == nil || triggered[0].Previous.Currency != "USD" {
+		t.Fatalf("Expected the alert to trigger, got %+v", triggered)
+	}
+	alerts, err := db.QueryPriceAlerts(ctx)
+	if err != nil {
+		t.Fatalf("QueryPriceAlerts failed: %v", err)
+	}
+	if len(alerts) != 1 || alerts[0].LastTriggeredAt == nil || alerts[0].CreatedBy != "tester" {
+		t.Fatalf("Unexpected alerts %+v", alerts)
+	}
+	if err := db.DeletePriceAlert(ctx, alertID); err != nil {
+		t.Fatalf("DeletePriceAlert failed: %v", err)
+	}
+	if err := db.DeletePriceAlert(ctx, alertID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound, got %v", err)
+	}
+
+	// Migrating again backfills the history from LastSale.
+	statuses, err := db.MigrationStatus(ctx)
+	if err != nil {
+		t.Fatalf("MigrationStatus failed: %v", err)
+	}
+	steps := 0
+	for _, s := range statuses {
+		if s.Version >= 5 {
+			steps++
+		}
+	}
+	if err := db.MigrateDown(ctx, steps); err != nil {
+		t.Fatalf("MigrateDown failed: %v", err)
+	}
+	if err := db.MigrateUp(ctx); err != nil {
+		t.Fatalf("MigrateUp failed: %v", err)
+	}
+	prices, _ = db.GetShoePrices(ctx, shoe.ID)
+	if len(prices) != 1 || prices[0].Amount != 1234.5 {
+		t.Fatalf("Expected the last sale to be backfilled, got %+v", prices)
+	}
+
+	if _, err := db.DeleteItem(ctx, shoe.ID); err != nil {
+		t.Fatalf("DeleteItem failed: %v", err)
+	}
+	if _, err := db.PurgeRow(ctx, "items", shoe.ID); err != nil {
+		t.Fatalf("PurgeRow failed: %v", err)
+	}
+	var count int
+	db.QueryRow(`SELECT COUNT(*) FROM shoe_prices`).Scan(&count)
+	if count != 0 {
+		t.Fatalf("Expected the prices to be purged with the shoe, got %d", count)
+	}
+}
@@ -0,0 +1,118 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Tables lists the tables GetRow, UpdateRow and DeleteRow work on.
+var Tables = []string{"items", "entries", "pictures"}
+
+// MergeTables lists the tables MergeRows works on, the tables of items.
+var MergeTables = []string{"items"}
+
+// EditableFields are the fields of the rows of each table that can be
+// changed, named like their JSON fields.
+var EditableFields = map[string][]string{
+	"items":    {"name", "title", "attributes", "picture"},
+	"entries":  {"name", "item_id"},
+	"pictures": {"latitude", "longitude", "taken_at"},
+}
+
+// GetRow returns the row id of table as an *Item, *Entry or *Picture, or
+// ErrNotFound.
+func GetRow(ctx context.Context, store Store, table string, id int64) (interface{}, error) {
+	var row interface{}
+	var err error
+	switch table {
+	case "items":
+		var item *Item
+		if item, err = store.GetItem(ctx, id); item != nil {
+			row = item
+		}
+	case "entries":
+		var entry *Entry
+		if entry, err = store.GetEntry(ctx, id); entry != nil {
+			row = entry
+		}
+	case "pictures":
+		var picture *Picture
+		if picture, err = store.GetPictureByID(ctx, id); picture != nil {
+			row = picture
+		}
+	default:
+		return nil, fmt.Errorf("unknown table %q", table)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if row == nil {
+		return nil, ErrNotFound
+	}
+	return row, nil
+}
+
+// UpdateRow writes back a row returned by GetRow as row id, whatever ID the
+// row was changed to.
+func UpdateRow(ctx context.Context, store Store, id int64, row interface{}) error {
+	switch row := row.(type) {
+	case *Item:
+		row.ID = id
+		return store.UpdateItem(ctx, *row)
+	case *Entry:
+		row.ID = id
+		return store.UpdateEntry(ctx, *row)
+	case *Picture:
+		row.ID = id
+		return store.UpdatePicture(ctx, *row)
+	default:
+		return fmt.Errorf("cannot update a %T", row)
+	}
+}
+
+// DeleteRow marks the row and everything that depends on it as deleted. It
+// returns the deleted pictures, their files stay until the row is purged.
+func DeleteRow(ctx context.Context, store Store, table string, id int64) ([]Picture, error) {
+	switch table {
+	case "items":
+		return store.DeleteItem(ctx, id)
+	case "entries":
+		return store.DeleteEntry(ctx, id)
+	case "pictures":
+		return store.DeletePicture(ctx, id)
+	default:
+		return nil, fmt.Errorf("unknown table %q", table)
+	}
+}
+
+// PurgeRowAndFiles is PurgeRow followed by removing the files of the purged
+// pictures and, for a shoe, its folder in shoeImageDir. The rows are gone once
+// the purge committed, so files that cannot be removed are only logged.
+func PurgeRowAndFiles(ctx context.Context, store Store, shoeImageDir string, table string, id int64) (*Purged, error) {
+	purged, err := store.PurgeRow(ctx, table, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := RemovePictureFiles(purged.Pictures); err != nil {
+		log.Printf("Failed to remove pictures of %s %d: %v", table, id, err)
+	}
+	if purged.Shoe != nil && purged.Shoe.ProductName != "" {
+		shoeFolder := filepath.Join(shoeImageDir, purged.Shoe.ProductName)
+		if err := os.RemoveAll(shoeFolder); err != nil {
+			log.Printf("Failed to remove %s: %v", shoeFolder, err)
+		}
+	}
+	return purged, nil
+}
+
+func MergeRows(ctx context.Context, store Store, table string, fromID, intoID int64) error {
+	if table != "items" {
+		return fmt.Errorf("cannot merge rows of %q, only %s", table, strings.Join(MergeTables, ", "))
+	}
+	return store.MergeItems(ctx, fromID, intoID)
+}
@@ -0,0 +1,132 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"vertigo/pkg/restaurant"
+	"vertigo/pkg/stockx"
+)
+
+func TestDeleteAndMergeRows(t *testing.T) {
+	dir := t.TempDir()
+	db, err := GetDB(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatalf("GetDB failed: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	if err := db.MigrateUp(ctx); err != nil {
+		t.Fatalf("MigrateUp failed: %v", err)
+	}
+
+	if err := db.InsertShoe(ctx, stockx.ProductDetails{Name: "Air Jordan 1", ProductName: "Air-Jordan-1", Attributes: map[string]string{}}); err != nil {
+		t.Fatalf("InsertShoe failed: %v", err)
+	}
+	shoe, _ := db.GetShoeByProductName(ctx, "Air-Jordan-1")
+	picturePath := filepath.Join(dir, "1.jpg")
+	if err := os.WriteFile(picturePath, []byte("jpg"), 0o644); err != nil {
+		t.Fatalf("Failed to write picture: %v", err)
+	}
+	pictureID, _ := db.InsertPicture(ctx, picturePath, "", "1", 0, 0, time.Now())
+	if _, err := db.InsertShoentry(ctx, shoe.ID, pictureID); err != nil {
+		t.Fatalf("InsertShoentry failed: %v", err)
+	}
+
+	ctx = WithActor(ctx, "tester")
+	pictures, err := DeleteRow(ctx, db, "items", shoe.ID)
+	if err != nil {
+		t.Fatalf("DeleteRow failed: %v", err)
+	}
+	if len(pictures) != 1 || pictures[0].ID != pictureID {
+		t.Fatalf("Expected picture %d to be deleted, got %+v", pictureID, pictures)
+	}
+	if picture, _ := db.GetPictureByID(ctx, pictureID); picture != nil {
+		t.Fatalf("Expected picture to be hidden")
+	}
+	if entries, _ := db.GetShoentriesByShoeID(ctx, shoe.ID); len(entries) != 0 {
+		t.Fatalf("Expected shoentries to be hidden, got %d", len(entries))
+	}
+	if _, err := db.DeleteItem(ctx, shoe.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound, got %v", err)
+	}
+
+	if err := db.RestoreRow(ctx, "items", shoe.ID); err != nil {
+		t.Fatalf("RestoreRow failed: %v", err)
+	}
+	if entries, _ := db.GetShoentriesByShoeID(ctx, shoe.ID); len(entries) != 1 {
+		t.Fatalf("Expected the shoentry to be restored with its shoe, got %d", len(entries))
+	}
+	changes, err := db.History(ctx, "items", shoe.ID)
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	if len(changes) != 3 || changes[0].Field != "CreatedAt" || changes[1].Field != "DeletedAt" || changes[2].NewValue != "" || changes[2].ChangedBy != "tester" {
+		t.Fatalf("Expected the insert, a deletion and a restore, got %+v", changes)
+	}
+
+	if _, err := PurgeRowAndFiles(ctx, db, filepath.Join(dir, "shoes"), "items", shoe.ID); err == nil {
+		t.Fatalf("Expected purging a shoe that is not deleted to fail")
+	}
+	if _, err := db.DeleteItem(ctx, shoe.ID); err != nil {
+		t.Fatalf("DeleteItem failed: %v", err)
+	}
+	if _, err := os.Stat(picturePath); err != nil {
+		t.Fatalf("Expected %s to be kept until the purge: %v", picturePath, err)
+	}
+	purged, err := PurgeRowAndFiles(ctx, db, filepath.Join(dir, "shoes"), "items", shoe.ID)
+	if err != nil {
+		t.Fatalf("PurgeRowAndFiles failed: %v", err)
+	}
+	if len(purged.Pictures) != 1 || purged.Shoe.ProductName != "Air-Jordan-1" {
+		t.Fatalf("Unexpected purge %+v", purged)
+	}
+	if _, err := os.Stat(picturePath); !os.IsNotExist(err) {
+		t.Fatalf("Expected %s to be removed, got %v", picturePath, err)
+	}
+
+	keepID, _ := db.InsertRestaurant(ctx, restaurant.RestaurantDetails{Name: "Pizza Place"})
+	dropID, _ := db.InsertRestaurant(ctx, restaurant.RestaurantDetails{Name: "Pizza-Place"})
+	pictureID, _ = db.InsertPicture(ctx, "", "", "2", 0, 0, time.Now())
+	entryID, _ := db.InsertFoodentry(ctx, "Margherita", dropID, pictureID)
+
+	if err := MergeRows(ctx, db, "items", dropID, keepID); err != nil {
+		t.Fatalf("MergeRows failed: %v", err)
+	}
+	entry, _ := db.GetFoodEntryByID(ctx, entryID)
+	if entry == nil || entry.RestaurantID != keepID {
+		t.Fatalf("Expected foodentry to move to restaurant %d, got %+v", keepID, entry)
+	}
+	if rt, _ := db.GetRestaurantByID(ctx, dropID); rt != nil {
+		t.Fatalf("Expected merged restaurant to be deleted")
+	}
+
+	row, err := GetRow(ctx, db, "items", keepID)
+	if err != nil {
+		t.Fatalf("GetRow failed: %v", err)
+	}
+	row.(*Item).Name = "Pizza Palace"
+	if err := UpdateRow(ctx, db, keepID, row); err != nil {
+		t.Fatalf("UpdateRow failed: %v", err)
+	}
+	if rt, _ := db.GetRestaurantByName(ctx, "Pizza Palace"); rt == nil || rt.ID != keepID {
+		t.Fatalf("Expected restaurant to be renamed, got %+v", rt)
+	}
+	changes, err = db.History(ctx, "items", keepID)
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	if len(changes) != 2 || changes[0].Field != "CreatedAt" || changes[0].ChangedBy != "tester" {
+		t.Fatalf("Expected the insert by tester in the history, got %+v", changes)
+	}
+	if changes[1].Field != "Name" || changes[1].OldValue != "Pizza Place" || changes[1].NewValue != "Pizza Palace" {
+		t.Fatalf("Expected the rename in the history, got %+v", changes)
+	}
+	if _, err := db.InsertRestaurant(ctx, restaurant.RestaurantDetails{Name: "Pizza-Place"}); err != nil {
+		t.Fatalf("Expected the name of a deleted restaurant to be free: %v", err)
+	}
+}
@@ -1,95 +1,100 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 	"vertigo/pkg/stockx"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// InsertShoe adds the shoe and, when its LastSale is a price, the first entry
+// of its price history.
+func (db *DB) InsertShoe(ctx context.Context, pd stockx.ProductDetails) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
 
-
-func (db *DB) InsertShoe(pd stockx.ProductDetails) error {
-	attributesJSON, err := json.Marshal(pd.Attributes)
-	if err != nil {
-		return fmt.Errorf("error marshalling attributes to JSON: %v", err)
-	}
-	query := `INSERT INTO shoes (Name, Subtitle, LastSale, ProductName, MainPicture, Attributes, Description) VALUES (?, ?, ?, ?, ?, ?, ?)`
-	_, err = db.Exec(query, pd.Name, pd.Subtitle, pd.LastSale, pd.ProductName, pd.MainPicture, attributesJSON, pd.Description)
-	if err != nil {
+	if err := pd.Validate(); err != nil {
 		return fmt.Errorf("error inserting new product details: %v", err)
 	}
-	return nil
-}
-
-func (db *DB) QueryShoesTemplate(query string, params ...interface{}) ([]stockx.ProductDetails, error) {
-	rows, err := db.Query(query, params...)
+	attributesJSON, err := json.Marshal(pd.Attributes)
 	if err != nil {
-		return nil, fmt.Errorf("error querying product details: %v", err)
+		return fmt.Errorf("error marshalling attributes to JSON: %v", err)
 	}
-	defer rows.Close()
-
-	var productsList []stockx.ProductDetails
-	for rows.Next() {
-		var pd stockx.ProductDetails
-		var attributesJSON string
-		err := rows.Scan(&pd.ID, &pd.Name, &pd.Subtitle, &pd.LastSale, &pd.ProductName, &pd.MainPicture, &attributesJSON, &pd.Description)
+	return db.inTx(ctx, func(conn dbtx) error {
+		// The images of a shoe are named after its product name, a deleted
+		// shoe keeps them until it is purged.
+		var deletedID int64
+		if conn.QueryRowContext(ctx, `SELECT ID FROM items WHERE Category = ? AND Name = ? AND DeletedAt IS NOT NULL`, ShoeCategory, pd.ProductName).Scan(&deletedID) == nil {
+			return fmt.Errorf("error inserting new product details: %s was deleted, restore it with 'vertigo restore items %d'", pd.ProductName, deletedID)
+		}
+		shoeID, err := insertItem(ctx, conn, Item{Category: ShoeCategory, Name: pd.ProductName, Title: pd.Name, Attributes: string(attributesJSON), Picture: pd.MainPicture})
 		if err != nil {
-			return nil, fmt.Errorf("error scanning product details: %v", err)
+			return err
+		}
+		query := `INSERT INTO shoe_details (ItemID, Subtitle, LastSale, Description, SpinningGifURL) VALUES (?, ?, ?, ?, ?)`
+		if _, err := conn.ExecContext(ctx, query, shoeID, pd.Subtitle, pd.LastSale, pd.Description, pd.SpinningGifURL); err != nil {
+			return fmt.Errorf("error inserting shoe details: %v", err)
 		}
-		json.Unmarshal([]byte(attributesJSON), &pd.Attributes)
-		productsList = append(productsList, pd)
-	}
-	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("error reading product details rows: %v", err)
-	}
-	return productsList, nil
-}
 
-func (db *DB) QueryShoeByName(name string) ([]stockx.ProductDetails, error) {
-	query := `SELECT ID, Name, Subtitle, LastSale, ProductName, MainPicture, Attributes, Description FROM shoes WHERE Name = ?`
-	return db.QueryShoesTemplate(query, name)
+		price, err := stockx.ParsePrice(pd.LastSale)
+		if err != nil {
+			return nil
+		}
+		_, err = insertShoePrice(ctx, conn, shoeID, price, time.Now())
+		return err
+	})
 }
 
-func (db *DB) QueryShoes() ([]stockx.ProductDetails, error) {
-	query := `SELECT ID, Name, Subtitle, LastSale, ProductName, MainPicture, Attributes, Description FROM shoes`
-	return db.QueryShoesTemplate(query)
+// QueryShoes returns the shoes as scraped from StockX, or nil.
+func (db *DB) QueryShoes(ctx context.Context) ([]stockx.ProductDetails, error) {
+	return productDetails(db.ListShoes(ctx))
 }
 
-func (db *DB) InsertShoentry(itemID int64, pictureID int64) (int64, error) {
-	query := `INSERT INTO shoentries (ItemID, PictureID) VALUES (?, ?)`
-	result, err := db.Exec(query, itemID, pictureID)
+func productDetails(shoes []Shoe, err error) ([]stockx.ProductDetails, error) {
 	if err != nil {
-		return 0, fmt.Errorf("error inserting shoentry: %v", err)
+		return nil, err
 	}
-	id, err := result.LastInsertId()
-	if err != nil {
-		return 0, fmt.Errorf("error getting last insert id: %v", err)
+	var productsList []stockx.ProductDetails
+	for _, shoe := range shoes {
+		pd := stockx.ProductDetails{
+			ID:             int(shoe.ID),
+			Name:           shoe.Name,
+			Subtitle:       shoe.Subtitle,
+			LastSale:       shoe.LastSale,
+			ProductName:    shoe.ProductName,
+			MainPicture:    shoe.MainPicture,
+			SpinningGifURL: shoe.SpinningGifURL,
+			Description:    shoe.Description,
+		}
+		json.Unmarshal([]byte(shoe.Attributes), &pd.Attributes)
+		productsList = append(productsList, pd)
 	}
-	return id, nil
+	return productsList, nil
 }
 
-type Shoe struct {
-	ID          int64     `json:"id"`
-	Name        string    `json:"name"`
-	Subtitle    string    `json:"subtitle"`
-	LastSale    string    `json:"last_sale"`
-	ProductName string    `json:"product_name"`
-	MainPicture string    `json:"main_picture"`
-	Attributes  string    `json:"attributes"`
-	Description string    `json:"description"`
-	Timestamp   time.Time `json:"timestamp"`
+// InsertShoentry adds an entry of the shoe itemID with the picture pictureID.
+func (db *DB) InsertShoentry(ctx context.Context, itemID int64, pictureID int64) (int64, error) {
+	return db.InsertEntry(ctx, ShoeCategory, "", itemID, pictureID)
 }
 
-type Shoentry struct {
-	ID        int64     `json:"id"`
-	ItemID    int64     `json:"item_id"`
-	PictureID int64     `json:"picture_id"`
-	UpdatedAt time.Time `json:"updated_at"`
-	CreatedAt time.Time `json:"created_at"`
+// Shoe is an item of the shoes category with its shoe_details. Name is the
+// Title of the item, ProductName its Name.
+type Shoe struct {
+	ID             int64     `json:"id"`
+	Name           string    `json:"name"`
+	Subtitle       string    `json:"subtitle"`
+	LastSale       string    `json:"last_sale"`
+	ProductName    string    `json:"product_name"`
+	MainPicture    string    `json:"main_picture"`
+	SpinningGifURL string    `json:"spinning_gif_url"`
+	Attributes     string    `json:"attributes"`
+	Description    string    `json:"description"`
+	Timestamp      time.Time `json:"timestamp"`
 }
 
 type ShoentryDetails struct {
@@ -117,12 +122,37 @@ type ShoentryDetails struct {
 	ShoentryCreatedAt time.Time `json:"shoentry_created_at"`
 }
 
-func (db *DB) GetShoeByProductName(name string) (*Shoe, error) {
-	query := `SELECT ID, Name, Subtitle, LastSale, ProductName, MainPicture, Attributes, Description, Timestamp FROM shoes WHERE ProductName = ?`
-	row := db.QueryRow(query, name)
+const shoeQuery = `
+	SELECT
+		items.ID,
+		COALESCE(items.Title, items.Name),
+		COALESCE(shoe_details.Subtitle, ''),
+		COALESCE(shoe_details.LastSale, ''),
+		items.Name,
+		COALESCE(items.Picture, ''),
+		COALESCE(shoe_details.SpinningGifURL, ''),
+		COALESCE(items.Attributes, '{}'),
+		COALESCE(shoe_details.Description, ''),
+		items.CreatedAt
+	FROM
+		items
+	LEFT JOIN
+		shoe_details ON shoe_details.ItemID = items.ID
+	WHERE
+		items.Category = '` + ShoeCategory + `' AND items.DeletedAt IS NULL
+`
 
+func scanShoe(row rowScanner) (Shoe, error) {
 	var shoe Shoe
-	err := row.Scan(&shoe.ID, &shoe.Name, &shoe.Subtitle, &shoe.LastSale, &shoe.ProductName, &shoe.MainPicture, &shoe.Attributes, &shoe.Description, &shoe.Timestamp)
+	err := row.Scan(&shoe.ID, &shoe.Name, &shoe.Subtitle, &shoe.LastSale, &shoe.ProductName, &shoe.MainPicture, &shoe.SpinningGifURL, &shoe.Attributes, &shoe.Description, &shoe.Timestamp)
+	return shoe, err
+}
+
+func (db *DB) getShoe(ctx context.Context, where string, param interface{}) (*Shoe, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	shoe, err := scanShoe(db.conn().QueryRowContext(ctx, shoeQuery+` AND `+where, param))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -133,43 +163,81 @@ func (db *DB) GetShoeByProductName(name string) (*Shoe, error) {
 	return &shoe, nil
 }
 
-func (db *DB) GetShoentryByID(id int64) (*ShoentryDetails, error) {
-	query := `
-		SELECT 
-			shoentries.ID AS ShoentryID,
-			shoentries.ItemID,
-			shoes.ID AS ShoeID,
-			shoes.Name AS ShoeName,
-			shoes.Subtitle AS ShoeSubtitle,
-			shoes.LastSale AS ShoeLastSale,
-			shoes.ProductName AS ShoeProductName,
-			shoes.MainPicture AS ShoeMainPicture,
-			shoes.Attributes AS ShoeAttributes,
-			shoes.Description AS ShoeDescription,
-			shoes.Timestamp AS ShoeTimestamp,
-			shoentries.PictureID,
-			pictures.LocalLocation AS PictureLocalPath,
-			pictures.DiscordImageLink AS PictureDiscordURL,
-			pictures.DiscordMessageId AS PictureMessageID,
-			pictures.Latitude AS PictureLatitude,
-			pictures.Longitude AS PictureLongitude,
-			pictures.TakenAt AS PictureTakenAt,
-			pictures.UpdatedAt AS PictureUpdatedAt,
-			pictures.CreatedAt AS PictureCreatedAt,
-			shoentries.UpdatedAt AS ShoentryUpdatedAt,
-			shoentries.CreatedAt AS ShoentryCreatedAt
-		FROM 
-			shoentries
-		INNER JOIN 
-			shoes ON shoentries.ItemID = shoes.ID
-		INNER JOIN 
-			pictures ON shoentries.PictureID = pictures.ID
-		WHERE 
-			shoentries.ID = ?
-	`
-
-	row := db.QueryRow(query, id)
+// ListShoes returns the shoes by ID.
+func (db *DB) ListShoes(ctx context.Context) ([]Shoe, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.conn().QueryContext(ctx, shoeQuery+` ORDER BY items.ID`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying shoes: %v", err)
+	}
+	defer rows.Close()
 
+	shoes := []Shoe{}
+	for rows.Next() {
+		shoe, err := scanShoe(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning shoe: %v", err)
+		}
+		shoes = append(shoes, shoe)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading shoe rows: %v", err)
+	}
+	return shoes, nil
+}
+
+func (db *DB) GetShoeByProductName(ctx context.Context, name string) (*Shoe, error) {
+	return db.getShoe(ctx, `items.Name = ?`, name)
+}
+
+func (db *DB) GetShoeByID(ctx context.Context, id int64) (*Shoe, error) {
+	return db.getShoe(ctx, `items.ID = ?`, id)
+}
+
+const shoentryDetailsQuery = `
+	SELECT
+		entries.ID AS ShoentryID,
+		entries.ItemID,
+		items.ID AS ShoeID,
+		COALESCE(items.Title, items.Name) AS ShoeName,
+		COALESCE(shoe_details.Subtitle, '') AS ShoeSubtitle,
+		COALESCE(shoe_details.LastSale, '') AS ShoeLastSale,
+		items.Name AS ShoeProductName,
+		COALESCE(items.Picture, '') AS ShoeMainPicture,
+		COALESCE(items.Attributes, '{}') AS ShoeAttributes,
+		COALESCE(shoe_details.Description, '') AS ShoeDescription,
+		items.CreatedAt AS ShoeTimestamp,
+		entries.PictureID,
+		pictures.LocalLocation AS PictureLocalPath,
+		pictures.DiscordImageLink AS PictureDiscordURL,
+		pictures.DiscordMessageId AS PictureMessageID,
+		pictures.Latitude AS PictureLatitude,
+		pictures.Longitude AS PictureLongitude,
+		pictures.TakenAt AS PictureTakenAt,
+		pictures.UpdatedAt AS PictureUpdatedAt,
+		pictures.CreatedAt AS PictureCreatedAt,
+		entries.UpdatedAt AS ShoentryUpdatedAt,
+		entries.CreatedAt AS ShoentryCreatedAt
+	FROM
+		entries
+	INNER JOIN
+		items ON entries.ItemID = items.ID
+	LEFT JOIN
+		shoe_details ON shoe_details.ItemID = items.ID
+	INNER JOIN
+		pictures ON entries.PictureID = pictures.ID
+	WHERE
+		entries.Category = '` + ShoeCategory + `'
+		AND entries.DeletedAt IS NULL AND items.DeletedAt IS NULL AND pictures.DeletedAt IS NULL
+`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanShoentryDetails(row rowScanner) (ShoentryDetails, error) {
 	var details ShoentryDetails
 	err := row.Scan(
 		&details.ShoentryID,
@@ -195,6 +263,40 @@ func (db *DB) GetShoentryByID(id int64) (*ShoentryDetails, error) {
 		&details.ShoentryUpdatedAt,
 		&details.ShoentryCreatedAt,
 	)
+	return details, err
+}
+
+func (db *DB) queryShoentryDetails(ctx context.Context, query string, params ...interface{}) ([]ShoentryDetails, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.conn().QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying shoentries: %v", err)
+	}
+	defer rows.Close()
+
+	var shoentries []ShoentryDetails
+	for rows.Next() {
+		details, err := scanShoentryDetails(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning shoentry: %v", err)
+		}
+		shoentries = append(shoentries, details)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading shoentry rows: %v", err)
+	}
+	return shoentries, nil
+}
+
+func (db *DB) GetShoentryByID(ctx context.Context, id int64) (*ShoentryDetails, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	row := db.conn().QueryRowContext(ctx, shoentryDetailsQuery+` AND entries.ID = ?`, id)
+
+	details, err := scanShoentryDetails(row)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil // No shoentry found with the given ID
@@ -205,56 +307,49 @@ func (db *DB) GetShoentryByID(id int64) (*ShoentryDetails, error) {
 	return &details, nil
 }
 
-type Shoentry1 struct {
-    ID                 int64     `json:"id"`
-    ItemID             int64     `json:"item_id"`
-    PictureID          int64     `json:"picture_id"`
-    UpdatedAt          time.Time `json:"updated_at"`
-    CreatedAt          time.Time `json:"created_at"`
-    PictureLocalPath   string    `json:"picture_local_path"`
-    PictureDiscordURL  string    `json:"picture_discord_url"`
-    PictureMessageID   string    `json:"picture_message_id"`
-    PictureLatitude    float64   `json:"picture_latitude"`
-    PictureLongitude   float64   `json:"picture_longitude"`
-    PictureTakenAt     time.Time `json:"picture_taken_at"`
-    PictureUpdatedAt   time.Time `json:"picture_updated_at"`
-    PictureCreatedAt   time.Time `json:"picture_created_at"`
+func (db *DB) GetShoentriesByShoeID(ctx context.Context, shoeID int64) ([]ShoentryDetails, error) {
+	return db.queryShoentryDetails(ctx, shoentryDetailsQuery+` AND items.ID = ?`, shoeID)
+}
+
+// ListShoentries returns the shoentries by ID.
+func (db *DB) ListShoentries(ctx context.Context) ([]ShoentryDetails, error) {
+	return db.queryShoentryDetails(ctx, shoentryDetailsQuery+` ORDER BY entries.ID`)
+}
+
+func (db *DB) GetRecentShoentries(ctx context.Context, limit int) ([]ShoentryDetails, error) {
+	return db.queryShoentryDetails(ctx, shoentryDetailsQuery+` ORDER BY entries.CreatedAt DESC LIMIT ?`, limit)
 }
 
+// updateShoeDetails sets columns of the shoe_details of a shoe that is not
+// deleted and records every value that changed in the history of its item.
+func updateShoeDetails(ctx context.Context, conn dbtx, id int64, columns []string, values ...interface{}) error {
+	query := fmt.Sprintf(`
+		SELECT %s FROM items LEFT JOIN shoe_details ON shoe_details.ItemID = items.ID
+		WHERE items.ID = ? AND items.Category = '`+ShoeCategory+`' AND items.DeletedAt IS NULL`, strings.Join(columns, ", "))
+	before, err := queryFields(ctx, conn, query, "shoe", id, columns)
+	if err != nil {
+		return err
+	}
+
+	// Shoes added as plain items have no details yet.
+	if _, err := conn.ExecContext(ctx, `INSERT OR IGNORE INTO shoe_details (ItemID) VALUES (?)`, id); err != nil {
+		return fmt.Errorf("error adding shoe details: %v", err)
+	}
+	assignments := make([]string, len(columns))
+	for i, column := range columns {
+		assignments[i] = column + ` = ?`
+	}
+	update := fmt.Sprintf(`UPDATE shoe_details SET %s WHERE ItemID = ?`, strings.Join(assignments, ", "))
+	if _, err := conn.ExecContext(ctx, update, append(values, id)...); err != nil {
+		return fmt.Errorf("error updating shoe details: %v", err)
+	}
+	if _, err := conn.ExecContext(ctx, `UPDATE items SET UpdatedAt = ? WHERE ID = ?`, time.Now(), id); err != nil {
+		return fmt.Errorf("error updating items: %v", err)
+	}
 
-func (db *DB) GetShoentriesByShoeID(shoeID int64) ([]Shoentry1, error) {
-    query := `
-        SELECT 
-            shoentries.ID, shoentries.ItemID, shoentries.PictureID, shoentries.UpdatedAt, shoentries.CreatedAt,
-            pictures.LocalLocation, pictures.DiscordImageLink, pictures.DiscordMessageId, pictures.Latitude, pictures.Longitude, pictures.TakenAt, pictures.UpdatedAt, pictures.CreatedAt
-        FROM 
-            shoentries
-        INNER JOIN 
-            pictures ON shoentries.PictureID = pictures.ID
-        WHERE 
-            shoentries.ItemID = ?
-    `
-
-    rows, err := db.Query(query, shoeID)
-    if err != nil {
-        return nil, fmt.Errorf("error querying shoentries: %v", err)
-    }
-    defer rows.Close()
-
-    var shoentries []Shoentry1
-    for rows.Next() {
-        var shoentry Shoentry1
-        err := rows.Scan(
-            &shoentry.ID, &shoentry.ItemID, &shoentry.PictureID, &shoentry.UpdatedAt, &shoentry.CreatedAt,
-            &shoentry.PictureLocalPath, &shoentry.PictureDiscordURL, &shoentry.PictureMessageID, &shoentry.PictureLatitude, &shoentry.PictureLongitude, &shoentry.PictureTakenAt, &shoentry.PictureUpdatedAt, &shoentry.PictureCreatedAt,
-        )
-        if err != nil {
-            return nil, fmt.Errorf("error scanning shoentry: %v", err)
-        }
-        shoentries = append(shoentries, shoentry)
-    }
-    if err = rows.Err(); err != nil {
-        return nil, fmt.Errorf("error reading shoentry rows: %v", err)
-    }
-    return shoentries, nil
+	after, err := queryFields(ctx, conn, query, "shoe", id, columns)
+	if err != nil {
+		return err
+	}
+	return recordFieldChanges(ctx, conn, "items", id, columns, before, after)
 }
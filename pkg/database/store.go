@@ -0,0 +1,124 @@
+package database
+
+import (
+	"context"
+	"time"
+	"vertigo/pkg/restaurant"
+	"vertigo/pkg/stockx"
+)
+
+// ShoeStore adds shoes scraped from StockX and reads them with their
+// shoe_details.
+type ShoeStore interface {
+	InsertShoe(ctx context.Context, pd stockx.ProductDetails) error
+	QueryShoes(ctx context.Context) ([]stockx.ProductDetails, error)
+	GetShoeByProductName(ctx context.Context, name string) (*Shoe, error)
+	GetShoeByID(ctx context.Context, id int64) (*Shoe, error)
+	ListShoes(ctx context.Context) ([]Shoe, error)
+}
+
+type EntryStore interface {
+	InsertShoentry(ctx context.Context, itemID int64, pictureID int64) (int64, error)
+	GetShoentryByID(ctx context.Context, id int64) (*ShoentryDetails, error)
+	GetShoentriesByShoeID(ctx context.Context, shoeID int64) ([]ShoentryDetails, error)
+	GetRecentShoentries(ctx context.Context, limit int) ([]ShoentryDetails, error)
+	ListShoentries(ctx context.Context) ([]ShoentryDetails, error)
+	InsertFoodentry(ctx context.Context, name string, itemID int64, pictureID int64) (int64, error)
+	GetFoodEntryByID(ctx context.Context, id int64) (*FoodentryDetails, error)
+	ListFoodentries(ctx context.Context) ([]FoodentryDetails, error)
+}
+
+type RestaurantStore interface {
+	InsertRestaurant(ctx context.Context, rt restaurant.RestaurantDetails) (int64, error)
+	GetRestaurantByName(ctx context.Context, name string) (*Restaurant, error)
+	GetRestaurantByID(ctx context.Context, id int64) (*Restaurant, error)
+	ListRestaurants(ctx context.Context) ([]Restaurant, error)
+}
+
+type PictureStore interface {
+	InsertPicture(ctx context.Context, localLocation, discordImageUrl, discordMessageId string, latitude, longitude float64, takenAt time.Time) (int64, error)
+	UpdatePictureFilePathAndTimestamp(ctx context.Context, id int64, newFilePath string) error
+	GetPictureByID(ctx context.Context, id int64) (*Picture, error)
+	ListPictures(ctx context.Context) ([]Picture, error)
+	UpdatePicture(ctx context.Context, picture Picture) error
+	DeletePicture(ctx context.Context, id int64) ([]Picture, error)
+}
+
+// PriceStore keeps the prices a shoe sold for over time.
+type PriceStore interface {
+	RecordShoePrice(ctx context.Context, shoeID int64, lastSale string, observedAt time.Time) (*ShoePrice, error)
+	GetShoePrices(ctx context.Context, shoeID int64) ([]ShoePrice, error)
+}
+
+// AlertStore keeps the rules that are checked against new prices.
+type AlertStore interface {
+	InsertPriceAlert(ctx context.Context, alert PriceAlert) (int64, error)
+	QueryPriceAlerts(ctx context.Context) ([]PriceAlert, error)
+	GetPriceAlertsByShoeID(ctx context.Context, shoeID int64) ([]PriceAlert, error)
+	DeletePriceAlert(ctx context.Context, id int64) error
+	MarkPriceAlertTriggered(ctx context.Context, id int64, at time.Time) error
+}
+
+// AssetStore lists where the pictures of the shoes and entries are kept, so
+// they can be reconciled with the files on disk and in the blob store.
+type AssetStore interface {
+	ListShoeAssets(ctx context.Context) ([]ShoeAsset, error)
+	UpdateShoeAssets(ctx context.Context, id int64, mainPicture, spinningGifURL string) error
+	ListPictureLocations(ctx context.Context) ([]string, error)
+}
+
+// JobStore is the queue of shoe URLs waiting to be onboarded.
+type JobStore interface {
+	EnqueueJob(ctx context.Context, url string) (Job, bool, error)
+	ClaimJob(ctx context.Context, now time.Time) (*Job, error)
+	FinishJob(ctx context.Context, id int64) error
+	FailJob(ctx context.Context, id int64, message string, retryAt time.Time) error
+	QueryJobs(ctx context.Context, state string) ([]Job, error)
+	RetryJob(ctx context.Context, id int64) (*Job, error)
+	CancelJob(ctx context.Context, id int64) error
+	RequeueRunningJobs(ctx context.Context) (int64, error)
+}
+
+// HistoryStore works on rows of any of the Tables. Deletes through the other
+// stores are soft, RestoreRow undoes them and PurgeRow makes them final.
+type HistoryStore interface {
+	RestoreRow(ctx context.Context, table string, id int64) error
+	PurgeRow(ctx context.Context, table string, id int64) (*Purged, error)
+	History(ctx context.Context, table string, id int64) ([]Change, error)
+}
+
+// ItemStore works on the items and entries of any category. The stores above
+// read and add the items and entries of the shoes and restaurants categories,
+// they are changed, deleted and merged here.
+type ItemStore interface {
+	InsertItem(ctx context.Context, item Item) (int64, error)
+	GetItemByName(ctx context.Context, category, name string) (*Item, error)
+	GetItemByID(ctx context.Context, category string, id int64) (*Item, error)
+	ListItems(ctx context.Context, category string) ([]Item, error)
+	InsertEntry(ctx context.Context, category, name string, itemID, pictureID int64) (int64, error)
+	GetEntryByID(ctx context.Context, category string, id int64) (*EntryDetails, error)
+	ListEntries(ctx context.Context, category string) ([]EntryDetails, error)
+	GetItem(ctx context.Context, id int64) (*Item, error)
+	UpdateItem(ctx context.Context, item Item) error
+	DeleteItem(ctx context.Context, id int64) ([]Picture, error)
+	MergeItems(ctx context.Context, fromID, intoID int64) error
+	GetEntry(ctx context.Context, id int64) (*Entry, error)
+	UpdateEntry(ctx context.Context, entry Entry) error
+	DeleteEntry(ctx context.Context, id int64) ([]Picture, error)
+}
+
+type Store interface {
+	ShoeStore
+	EntryStore
+	RestaurantStore
+	PictureStore
+	PriceStore
+	AlertStore
+	AssetStore
+	JobStore
+	HistoryStore
+	ItemStore
+}
+
+var _ Store = (*DB)(nil)
+var _ Store = (*MemoryStore)(nil)
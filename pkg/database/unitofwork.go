@@ -0,0 +1,130 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+)
+
+// Compensation undoes a side effect outside the database, like a copied file
+// or a message posted to Discord.
+type Compensation func(ctx context.Context) error
+
+// UnitOfWork is a Store whose writes are committed together or not at all.
+// Side effects that the transaction cannot roll back register a compensation
+// with OnRollback, these run in reverse order when the unit of work fails.
+type UnitOfWork interface {
+	Store
+	OnRollback(undo Compensation)
+}
+
+type UnitOfWorkRunner interface {
+	InUnitOfWork(ctx context.Context, fn func(uow UnitOfWork) error) error
+}
+
+var _ UnitOfWorkRunner = (*DB)(nil)
+var _ UnitOfWorkRunner = (*MemoryStore)(nil)
+
+type compensations []Compensation
+
+func (c *compensations) OnRollback(undo Compensation) {
+	*c = append(*c, undo)
+}
+
+// run calls the compensations newest first. It uses a context that is not
+// cancelled with ctx, an interrupted onboarding still has to be cleaned up.
+func (c compensations) run(ctx context.Context) error {
+	ctx = context.WithoutCancel(ctx)
+	var errs []error
+	for i := len(c) - 1; i >= 0; i-- {
+		if err := c[i](ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// rollback combines the error that failed the unit of work with the errors of
+// the rollback and the compensations.
+func rollback(ctx context.Context, cause error, undoTx func() error, undo compensations) error {
+	errs := []error{cause}
+	if err := undoTx(); err != nil {
+		errs = append(errs, fmt.Errorf("error rolling back transaction: %v", err))
+	}
+	if err := undo.run(ctx); err != nil {
+		log.Printf("Failed to clean up after rollback: %v", err)
+		errs = append(errs, fmt.Errorf("error cleaning up after rollback: %v", err))
+	}
+	return errors.Join(errs...)
+}
+
+type dbUnitOfWork struct {
+	*DB
+	compensations
+}
+
+// InUnitOfWork runs fn in a transaction. If fn returns an error or panics the
+// transaction is rolled back and the registered compensations run.
+func (db *DB) InUnitOfWork(ctx context.Context, fn func(uow UnitOfWork) error) (err error) {
+	if db.tx != nil {
+		return fmt.Errorf("error starting unit of work: already inside a transaction")
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+
+	txDB := *db
+	txDB.tx = tx
+	uow := &dbUnitOfWork{DB: &txDB}
+
+	defer func() {
+		if p := recover(); p != nil {
+			rollback(ctx, fmt.Errorf("panic: %v", p), tx.Rollback, uow.compensations)
+			panic(p)
+		}
+	}()
+
+	if err := fn(uow); err != nil {
+		return rollback(ctx, err, tx.Rollback, uow.compensations)
+	}
+	if err := tx.Commit(); err != nil {
+		return rollback(ctx, fmt.Errorf("error committing transaction: %v", err), func() error { return nil }, uow.compensations)
+	}
+	return nil
+}
+
+type memoryUnitOfWork struct {
+	*MemoryStore
+	compensations
+}
+
+// InUnitOfWork snapshots the store and restores the snapshot when fn fails.
+// Writes made by other goroutines while fn runs are lost on rollback, which
+// is fine for the tests the MemoryStore is meant for.
+func (m *MemoryStore) InUnitOfWork(ctx context.Context, fn func(uow UnitOfWork) error) (err error) {
+	m.mu.Lock()
+	snapshot := m.snapshot()
+	m.mu.Unlock()
+
+	uow := &memoryUnitOfWork{MemoryStore: m}
+	restore := func() error {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.restore(snapshot)
+		return nil
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			rollback(ctx, fmt.Errorf("panic: %v", p), restore, uow.compensations)
+			panic(p)
+		}
+	}()
+
+	if err := fn(uow); err != nil {
+		return rollback(ctx, err, restore, uow.compensations)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestInUnitOfWorkRollsBack(t *testing.T) {
+	db, err := GetDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("GetDB failed: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	if err := db.MigrateUp(ctx); err != nil {
+		t.Fatalf("MigrateUp failed: %v", err)
+	}
+
+	var undone []string
+	failure := errors.New("copy failed")
+	err = db.InUnitOfWork(ctx, func(uow UnitOfWork) error {
+		uow.OnRollback(func(ctx context.Context) error {
+			undone = append(undone, "discord")
+			return nil
+		})
+		if _, err := uow.InsertPicture(ctx, "a.jpg", "", "1", 0, 0, time.Now()); err != nil {
+			return err
+		}
+		uow.OnRollback(func(ctx context.Context) error {
+			undone = append(undone, "file")
+			return nil
+		})
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("Expected the error of the unit of work, got %v", err)
+	}
+	if len(undone) != 2 || undone[0] != "file" || undone[1] != "discord" {
+		t.Fatalf("Expected compensations in reverse order, got %v", undone)
+	}
+
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM pictures`).Scan(&count); err != nil {
+		t.Fatalf("Failed to count pictures: %v", err)
+	}
+	if count != 0 {
+		t.Fatalf("Expected the picture to be rolled back, got %d rows", count)
+	}
+
+	err = db.InUnitOfWork(ctx, func(uow UnitOfWork) error {
+		_, err := uow.InsertPicture(ctx, "a.jpg", "", "1", 0, 0, time.Now())
+		return err
+	})
+	if err != nil {
+		t.Fatalf("InUnitOfWork failed: %v", err)
+	}
+	if err := db.QueryRow(`SELECT COUNT(*) FROM pictures`).Scan(&count); err != nil {
+		t.Fatalf("Failed to count pictures: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("Expected the picture to be committed, got %d rows", count)
+	}
+}
@@ -0,0 +1,50 @@
+package dataitems
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ParseFields reads field=value pairs like name="Mars Yard" retail-price=$180
+// as entered on the command line. Keys are lowercased with underscores for
+// dashes. Values of the keys in fields are stored there, the others are
+// added to attributes under their AttributeName.
+func ParseFields(pairs []string, fields map[string]*string, attributes map[string]string) error {
+	seen := make(map[string]bool)
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(key), "-", "_"))
+		if !ok || key == "" {
+			return fmt.Errorf("invalid field %q, expected field=value", pair)
+		}
+		if seen[key] {
+			return fmt.Errorf("field %s given twice", key)
+		}
+		seen[key] = true
+		value = strings.TrimSpace(value)
+		if field, ok := fields[key]; ok {
+			*field = value
+		} else {
+			attributes[AttributeName(key)] = value
+		}
+	}
+	return nil
+}
+
+// CheckImageURL returns an error unless imageURL is an http or https URL.
+func CheckImageURL(imageURL string) error {
+	if u, err := url.Parse(imageURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid image_url %q, expected an http or https URL", imageURL)
+	}
+	return nil
+}
+
+// AttributeName turns retail_price into Retail Price.
+func AttributeName(key string) string {
+	words := strings.Fields(strings.ReplaceAll(key, "_", " "))
+	for i, word := range words {
+		words[i] = strings.ToUpper(word[:1]) + word[1:]
+	}
+	return strings.Join(words, " ")
+}
@@ -1,10 +1,94 @@
 package dataitems
 
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"vertigo/pkg/stockx"
+)
+
+// Shoe is a shoe entered by hand, for releases StockX doesn't list.
 type Shoe struct {
-	ID         int
-	Name       string
-	Brand      string
-	Silhouette string
-	ImageUrl   string
-	Tags       string
+	ID          int
+	Name        string
+	ProductName string
+	Brand       string
+	Silhouette  string
+	ImageUrl    string
+	Tags        string
+	Subtitle    string
+	LastSale    string
+	Description string
+	// Attributes are the fields that aren't one of the above, keyed like the
+	// attributes scraped from StockX, e.g. "Retail Price".
+	Attributes map[string]string
+}
+
+var (
+	nonAlphanumeric = regexp.MustCompile(`[^A-Za-z0-9]+`)
+	validProduct    = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+)
+
+// ParseShoe makes a shoe of field=value pairs like name="Mars Yard"
+// brand=Nike image_url=https://... Fields that aren't fields of Shoe become
+// attributes. name and image_url are required, product_name defaults to the
+// name with dashes for spaces.
+func ParseShoe(pairs []string) (Shoe, error) {
+	shoe := Shoe{Attributes: make(map[string]string)}
+	fields := map[string]*string{
+		"name":         &shoe.Name,
+		"product_name": &shoe.ProductName,
+		"brand":        &shoe.Brand,
+		"silhouette":   &shoe.Silhouette,
+		"image_url":    &shoe.ImageUrl,
+		"tags":         &shoe.Tags,
+		"subtitle":     &shoe.Subtitle,
+		"last_sale":    &shoe.LastSale,
+		"description":  &shoe.Description,
+	}
+	if err := ParseFields(pairs, fields, shoe.Attributes); err != nil {
+		return Shoe{}, err
+	}
+
+	if shoe.Name == "" {
+		return Shoe{}, fmt.Errorf("missing name")
+	}
+	if shoe.ProductName == "" {
+		shoe.ProductName = strings.Trim(nonAlphanumeric.ReplaceAllString(shoe.Name, "-"), "-")
+	}
+	if !validProduct.MatchString(shoe.ProductName) {
+		return Shoe{}, fmt.Errorf("invalid product_name %q, use letters, digits, dots, dashes and underscores", shoe.ProductName)
+	}
+	if shoe.ImageUrl == "" {
+		return Shoe{}, fmt.Errorf("missing image_url")
+	}
+	if err := CheckImageURL(shoe.ImageUrl); err != nil {
+		return Shoe{}, err
+	}
+	return shoe, nil
+}
+
+// ProductDetails returns the shoe the way it is stored, with Brand,
+// Silhouette and Tags among the attributes. MainPicture is the image URL
+// until the picture is published.
+func (s Shoe) ProductDetails() stockx.ProductDetails {
+	attributes := make(map[string]string)
+	for key, value := range s.Attributes {
+		attributes[key] = value
+	}
+	for key, value := range map[string]string{"Brand": s.Brand, "Silhouette": s.Silhouette, "Tags": s.Tags} {
+		if value != "" {
+			attributes[key] = value
+		}
+	}
+	return stockx.ProductDetails{
+		ID:          s.ID,
+		Name:        s.Name,
+		Subtitle:    s.Subtitle,
+		LastSale:    s.LastSale,
+		ProductName: s.ProductName,
+		MainPicture: s.ImageUrl,
+		Attributes:  attributes,
+		Description: s.Description,
+	}
 }
@@ -0,0 +1,36 @@
+package dataitems
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseShoe(t *testing.T) {
+	shoe, err := ParseShoe([]string{"name=Mars Yard 2.0", "Brand=Nike", "retail-price=$2,500", "tags=collab,nasa", "image_url=https://example.com/mars-yard.jpg"})
+	if err != nil {
+		t.Fatalf("ParseShoe failed: %v", err)
+	}
+	if shoe.ProductName != "Mars-Yard-2-0" || shoe.Brand != "Nike" || shoe.Attributes["Retail Price"] != "$2,500" {
+		t.Fatalf("Unexpected shoe %+v", shoe)
+	}
+	product := shoe.ProductDetails()
+	if product.Attributes["Brand"] != "Nike" || product.Attributes["Tags"] != "collab,nasa" || product.Attributes["Silhouette"] != "" || product.MainPicture != shoe.ImageUrl {
+		t.Fatalf("Unexpected product details %+v", product)
+	}
+
+	for _, tt := range []struct {
+		pairs []string
+		err   string
+	}{
+		{[]string{"image_url=https://example.com/a.jpg"}, "missing name"},
+		{[]string{"name=Mars Yard"}, "missing image_url"},
+		{[]string{"name=Mars Yard", "image_url=mars-yard.jpg"}, "invalid image_url"},
+		{[]string{"name=Mars Yard", "product_name=../shoes", "image_url=https://example.com/a.jpg"}, "invalid product_name"},
+		{[]string{"name=Mars Yard", "brand"}, `invalid field "brand"`},
+		{[]string{"name=Mars Yard", "name=Mars Yard 2"}, "field name given twice"},
+	} {
+		if _, err := ParseShoe(tt.pairs); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%q: expected %q, got %v", tt.pairs, tt.err, err)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package discordBot
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -9,54 +10,53 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
+	"vertigo/pkg/assets"
+	"vertigo/pkg/config"
 	"vertigo/pkg/database"
+	"vertigo/pkg/httpclient"
 	"vertigo/pkg/imageMetadata"
 	"vertigo/pkg/stockx"
 
 	"github.com/bwmarrin/discordgo"
-	"github.com/joho/godotenv"
 )
 
-var (
+type Bot struct {
 	session               *discordgo.Session
-	botToken              string
 	channelIDShoeUpdates  string
 	channelIDUploadImages string
-)
-
-func init() {
-	err := godotenv.Load()
-	if err != nil {
-		log.Fatalf("Error initializing discord bot: %v", err)
-	}
-
-	botToken = os.Getenv("DISCORD_BOT_TOKEN")
-	if botToken == "" {
-		log.Fatalf("Please set the DISCORD_BOT_TOKEN value in .env")
-	}
-
-	channelIDShoeUpdates = os.Getenv("DISCORD_NOTIFICATION_CHANNEL")
-	if channelIDShoeUpdates == "" {
-		log.Fatalf("Please set the DISCORD_NOTIFICATION_CHANNEL value in .env")
-	}
+	cfg                   *config.Config
+	client                *http.Client
+}
 
-	channelIDUploadImages = os.Getenv("DISCORD_IMAGE_CHANNEL")
-	if channelIDShoeUpdates == "" {
-		log.Fatalf("Please set the DISCORD_IMAGE_CHANNEL value in .env")
+func New(cfg *config.Config) (*Bot, error) {
+	if err := cfg.Discord.Validate(); err != nil {
+		return nil, fmt.Errorf("error initializing discord bot: %v", err)
 	}
 
-	session, err = discordgo.New("Bot " + botToken)
+	session, err := discordgo.New("Bot " + cfg.Discord.BotToken)
 	if err != nil {
-		log.Fatalf("Invalid bot parameters: %v", err)
+		return nil, fmt.Errorf("invalid bot parameters: %v", err)
 	}
+
+	return &Bot{
+		session:               session,
+		channelIDShoeUpdates:  cfg.Discord.NotificationChannel,
+		channelIDUploadImages: cfg.Discord.ImageChannel,
+		cfg:                   cfg,
+		client:                httpclient.New(cfg.HTTP),
+	}, nil
 }
 
-func downloadImage(ImageUrl string) (ImageFile *os.File, Error error) {
-	resp, err := http.Get(ImageUrl)
+func (b *Bot) downloadImage(ImageUrl string) (ImageFile *os.File, Error error) {
+	resp, err := b.client.Get(ImageUrl)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error downloading %s: status code %d", ImageUrl, resp.StatusCode)
+	}
 
 	file, err := os.CreateTemp("", "image-*.jpg")
 	if err != nil {
@@ -72,8 +72,8 @@ func downloadImage(ImageUrl string) (ImageFile *os.File, Error error) {
 	return file, nil
 }
 
-func uploadImage(imageUrl string) (discordImageUrl string, Error error) {
-	file, err := downloadImage(imageUrl)
+func (b *Bot) uploadImage(imageUrl string) (discordImageUrl string, Error error) {
+	file, err := b.downloadImage(imageUrl)
 	if err != nil {
 		log.Printf("error downloading image: %v", err)
 		return "", err
@@ -91,7 +91,7 @@ func uploadImage(imageUrl string) (discordImageUrl string, Error error) {
 		Reader: file,
 	}
 
-	msg, err := session.ChannelFileSend(channelIDUploadImages, imageFile.Name, imageFile.Reader)
+	msg, err := b.session.ChannelFileSend(b.channelIDUploadImages, imageFile.Name, imageFile.Reader)
 	if err != nil {
 		fmt.Println("error uploading file,", err)
 		return
@@ -105,7 +105,7 @@ func uploadImage(imageUrl string) (discordImageUrl string, Error error) {
 	}
 }
 
-func uploadLocalImage(filePath string) (discordImageUrl string, discordMessageId string, err error) {
+func (b *Bot) uploadLocalImage(ctx context.Context, filePath string) (discordImageUrl string, discordMessageId string, err error) {
 	file, err := os.Open(filePath)
 	if err != nil {
 		log.Printf("error opening file: %v", err)
@@ -118,7 +118,7 @@ func uploadLocalImage(filePath string) (discordImageUrl string, discordMessageId
 		Reader: file,
 	}
 
-	msg, err := session.ChannelFileSend(channelIDUploadImages, imageFile.Name, imageFile.Reader)
+	msg, err := b.session.ChannelFileSend(b.channelIDUploadImages, imageFile.Name, imageFile.Reader, discordgo.WithContext(ctx))
 	if err != nil {
 		log.Printf("error uploading file: %v", err)
 		return "", "", err
@@ -132,56 +132,96 @@ func uploadLocalImage(filePath string) (discordImageUrl string, discordMessageId
 	}
 }
 
-func OnboardNewImage(filePath string, ImageType string) (int64, string, error) {
-	if strings.ToLower(ImageType) != "shoe" && strings.ToLower(ImageType) != "food" {
-		return 0, "", fmt.Errorf("cannot open the session: Invalid Image Type passed")
-	}
+// UploadedImage is an image posted to the image channel by UploadImage.
+type UploadedImage struct {
+	FilePath   string
+	DiscordURL string
+	MessageID  string
+	Latitude   float64
+	Longitude  float64
+	TakenAt    time.Time
+}
 
-	session.AddHandler(func(s *discordgo.Session, r *discordgo.Ready) {
+// UploadImage posts the image to Discord. It is called before the unit of
+// work that records the image, so the transaction isn't held open during the
+// upload; DeleteUploadedImage undoes it.
+func (b *Bot) UploadImage(ctx context.Context, filePath string) (*UploadedImage, error) {
+	b.session.AddHandler(func(s *discordgo.Session, r *discordgo.Ready) {
 		log.Printf("Logged in as: %v#%v", s.State.User.Username, s.State.User.Discriminator)
 	})
-	err := session.Open()
+	err := b.session.Open()
 	if err != nil {
-		return 0, "", fmt.Errorf("cannot open the session: %v", err)
+		return nil, fmt.Errorf("cannot open the session: %v", err)
 	}
-	defer session.Close()
+	defer b.session.Close()
 
-	discordImageUrl, discordMessageId, err := uploadLocalImage(filePath)
+	discordImageUrl, discordMessageId, err := b.uploadLocalImage(ctx, filePath)
 	if err != nil {
-		return 0, "", fmt.Errorf("error uploading image to Discord: %v", err)
+		return nil, fmt.Errorf("error uploading image to Discord: %v", err)
 	}
 
 	meta, _ := imageMetadata.GetImageMetaData(filePath)
+	return &UploadedImage{
+		FilePath:   filePath,
+		DiscordURL: discordImageUrl,
+		MessageID:  discordMessageId,
+		Latitude:   meta.Latitude,
+		Longitude:  meta.Longitude,
+		TakenAt:    meta.CreationDate,
+	}, nil
+}
 
-	db, err := database.GetDB("data/database/test.db")
-	if err != nil {
-		return 0, "", fmt.Errorf("error connecting to the database: %v", err)
+// DeleteUploadedImage removes an image posted by UploadImage.
+func (b *Bot) DeleteUploadedImage(ctx context.Context, image UploadedImage) error {
+	return b.deleteUploadedImage(ctx, image.MessageID)
+}
+
+// StoreImage records an uploaded image in pictures and copies it into the
+// image directory of imageType. The copy is removed again if uow is rolled
+// back.
+func (b *Bot) StoreImage(ctx context.Context, uow database.UnitOfWork, image UploadedImage, imageType string) (int64, error) {
+	imageType = strings.ToLower(imageType)
+	if imageType == "" || strings.ContainsAny(imageType, `./\`) {
+		return 0, fmt.Errorf("invalid image type %q", imageType)
 	}
 
-	id, err := db.InsertPicture(filePath, discordImageUrl, discordMessageId, meta.Latitude, meta.Longitude, meta.CreationDate)
+	id, err := uow.InsertPicture(ctx, image.FilePath, image.DiscordURL, image.MessageID, image.Latitude, image.Longitude, image.TakenAt)
 	if err != nil {
-		return 0, "", fmt.Errorf("error inserting image data into the database: %v", err)
+		return 0, fmt.Errorf("error inserting image data into the database: %v", err)
 	}
 
-	var newDir string
-	switch ImageType {
-	case "shoe":
-		newDir = "img_data/shoentries"
-	case "food":
-		newDir = "img_data/food"
+	newDir := b.cfg.EntryImageDir(imageType)
+	if err := os.MkdirAll(newDir, os.ModePerm); err != nil {
+		return 0, fmt.Errorf("error creating image directory: %v", err)
 	}
 	newFilePath := filepath.Join(newDir, fmt.Sprintf("%d.jpg", id))
-	err = copyFile(filePath, newFilePath)
+	uow.OnRollback(func(ctx context.Context) error {
+		if err := os.Remove(newFilePath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error removing copied image %s: %v", newFilePath, err)
+		}
+		return nil
+	})
+	err = copyFile(image.FilePath, newFilePath)
 	if err != nil {
-		return 0, "", fmt.Errorf("error copying image file: %v", err)
+		return 0, fmt.Errorf("error copying image file: %v", err)
 	}
 
-	err = db.UpdatePictureFilePathAndTimestamp(id, newFilePath)
+	err = uow.UpdatePictureFilePathAndTimestamp(ctx, id, newFilePath)
 	if err != nil {
-		return 0, "", fmt.Errorf("error updating image file path and timestamp in the database: %v", err)
+		return 0, fmt.Errorf("error updating image file path and timestamp in the database: %v", err)
 	}
 
-	return id, discordImageUrl, nil
+	return id, nil
+}
+
+// deleteUploadedImage removes an image posted by uploadLocalImage. The session
+// is closed by then, deleting only needs the REST API.
+func (b *Bot) deleteUploadedImage(ctx context.Context, messageID string) error {
+	err := b.session.ChannelMessageDelete(b.channelIDUploadImages, messageID, discordgo.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("error deleting Discord message %s: %v", messageID, err)
+	}
+	return nil
 }
 
 func copyFile(src, dst string) error {
@@ -210,11 +250,11 @@ func copyFile(src, dst string) error {
 	return nil
 }
 
-func PostNewFoodEntry(food database.FoodentryDetails) error {
-	session.AddHandler(func(s *discordgo.Session, r *discordgo.Ready) {
+func (b *Bot) PostNewFoodEntry(ctx context.Context, food database.FoodentryDetails) error {
+	b.session.AddHandler(func(s *discordgo.Session, r *discordgo.Ready) {
 		log.Printf("Logged in as: %v#%v", s.State.User.Username, s.State.User.Discriminator)
 	})
-	err := session.Open()
+	err := b.session.Open()
 	if err != nil {
 		return fmt.Errorf("cannot open the session: %v", err)
 	}
@@ -255,12 +295,12 @@ func PostNewFoodEntry(food database.FoodentryDetails) error {
 		Color: 0x4c00b0,
 	}
 
-	_, err = session.ChannelMessageSendEmbed(channelIDShoeUpdates, embed)
+	_, err = b.session.ChannelMessageSendEmbed(b.channelIDShoeUpdates, embed, discordgo.WithContext(ctx))
 	if err != nil {
 		return fmt.Errorf("cannot send the embedded message: %v", err)
 	}
 
-	err = session.Close()
+	err = b.session.Close()
 	if err != nil {
 		return fmt.Errorf("cannot close the session: %v", err)
 	}
@@ -268,16 +308,20 @@ func PostNewFoodEntry(food database.FoodentryDetails) error {
 	return nil
 }
 
-func PostNewShoe(shoe stockx.ProductDetails) error {
-	session.AddHandler(func(s *discordgo.Session, r *discordgo.Ready) {
+func (b *Bot) PostNewShoe(ctx context.Context, shoe stockx.ProductDetails) error {
+	b.session.AddHandler(func(s *discordgo.Session, r *discordgo.Ready) {
 		log.Printf("Logged in as: %v#%v", s.State.User.Username, s.State.User.Discriminator)
 	})
-	err := session.Open()
+	err := b.session.Open()
 	if err != nil {
 		return fmt.Errorf("cannot open the session: %v", err)
 	}
-	path := "img_data/shoes/" + shoe.ProductName + "/gif/" + shoe.ProductName + ".gif"
-	discordImageUrl, _, err := uploadLocalImage(path)
+	// Shoes entered by hand have no spinning shoe, only their picture.
+	path := filepath.Join(b.cfg.ShoeImageDir(), shoe.ProductName, assets.SpinningFile(b.cfg.Animation.Format))
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		path = filepath.Join(b.cfg.ShoeImageDir(), shoe.ProductName, assets.MainFile)
+	}
+	discordImageUrl, _, err := b.uploadLocalImage(ctx, path)
 	if err != nil {
 		return fmt.Errorf("cannot upload the image to Discord: %v", err)
 	}
@@ -306,12 +350,12 @@ func PostNewShoe(shoe stockx.ProductDetails) error {
 		Color: 0x4c00b0,
 	}
 
-	_, err = session.ChannelMessageSendEmbed(channelIDShoeUpdates, embed)
+	_, err = b.session.ChannelMessageSendEmbed(b.channelIDShoeUpdates, embed, discordgo.WithContext(ctx))
 	if err != nil {
 		return fmt.Errorf("cannot send the embedded message: %v", err)
 	}
 
-	err = session.Close()
+	err = b.session.Close()
 	if err != nil {
 		return fmt.Errorf("cannot close the session: %v", err)
 	}
@@ -319,15 +363,15 @@ func PostNewShoe(shoe stockx.ProductDetails) error {
 	return nil
 }
 
-func PostNewShoeEntry(shoentry database.ShoentryDetails) error {
-	session.AddHandler(func(s *discordgo.Session, r *discordgo.Ready) {
+func (b *Bot) PostNewShoeEntry(ctx context.Context, shoentry database.ShoentryDetails) error {
+	b.session.AddHandler(func(s *discordgo.Session, r *discordgo.Ready) {
 		log.Printf("Logged in as: %v#%v", s.State.User.Username, s.State.User.Discriminator)
 	})
-	err := session.Open()
+	err := b.session.Open()
 	if err != nil {
 		return fmt.Errorf("cannot open the session: %v", err)
 	}
-	defer session.Close()
+	defer b.session.Close()
 
 	description := fmt.Sprintf(
 		"A new shoe entry has been added for **%s %s**!\nTaken at %s",
@@ -345,10 +389,47 @@ func PostNewShoeEntry(shoentry database.ShoentryDetails) error {
 		Color: 0x4c00b0,
 	}
 
-	_, err = session.ChannelMessageSendEmbed(channelIDShoeUpdates, embed)
+	_, err = b.session.ChannelMessageSendEmbed(b.channelIDShoeUpdates, embed, discordgo.WithContext(ctx))
 	if err != nil {
 		return fmt.Errorf("cannot send the embedded message: %v", err)
 	}
 
 	return nil
 }
+
+// PostPriceAlerts sends an embed for every alert a new price of the shoe
+// triggered.
+func (b *Bot) PostPriceAlerts(ctx context.Context, shoe database.Shoe, alerts []database.TriggeredAlert) error {
+	err := b.session.Open()
+	if err != nil {
+		return fmt.Errorf("cannot open the session: %v", err)
+	}
+	defer b.session.Close()
+
+	for _, t := range alerts {
+		previous := "-"
+		if t.Previous != nil {
+			previous = fmt.Sprintf("%.2f %s", t.Previous.Amount, t.Previous.Currency)
+		}
+		embed := &discordgo.MessageEmbed{
+			Title:       fmt.Sprintf("Price Alert: %s", shoe.Name),
+			URL:         stockx.ProductURL(shoe.ProductName),
+			Description: fmt.Sprintf("The last sale of **%s %s** triggered the alert on a %s.", shoe.Name, shoe.Subtitle, t.Alert),
+			Fields: []*discordgo.MessageEmbedField{
+				{Name: "Last Sale", Value: fmt.Sprintf("%.2f %s", t.Price.Amount, t.Price.Currency), Inline: true},
+				{Name: "Previous", Value: previous, Inline: true},
+			},
+			Thumbnail: &discordgo.MessageEmbedThumbnail{
+				URL: shoe.MainPicture,
+			},
+			Timestamp: t.Price.ObservedAt.Format(time.RFC3339),
+			Color:     0x4c00b0,
+		}
+
+		_, err = b.session.ChannelMessageSendEmbed(b.channelIDShoeUpdates, embed, discordgo.WithContext(ctx))
+		if err != nil {
+			return fmt.Errorf("cannot send the embedded message: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package httpclient
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// cacheTransport keeps GET responses that carry an ETag or Last-Modified on
+// disk. The next request for the same URL is sent conditionally and a 304
+// is answered from the disk, so unchanged pages and images are not
+// transferred again.
+type cacheTransport struct {
+	next http.RoundTripper
+	dir  string
+}
+
+// cacheEntry is stored as the first line of a cache file, the body follows.
+type cacheEntry struct {
+	URL          string      `json:"url"`
+	Header       http.Header `json:"header"`
+	ETag         string      `json:"etag,omitempty"`
+	LastModified string      `json:"last_modified,omitempty"`
+	StoredAt     time.Time   `json:"stored_at"`
+}
+
+func (t *cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || req.Header.Get("Range") != "" ||
+		req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != "" {
+		return t.next.RoundTrip(req)
+	}
+
+	path := t.path(req)
+	if entry, err := readEntry(path); err == nil {
+		req = req.Clone(req.Context())
+		if entry.ETag != "" {
+			req.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified {
+		cached, err := openCached(path, req)
+		if err != nil {
+			// The entry went away since the request was sent, the caller
+			// gets the 304 as it is.
+			return resp, nil
+		}
+		resp.Body.Close()
+		return cached, nil
+	}
+
+	if cacheable(resp) {
+		if body, err := newCachingBody(t.dir, path, req, resp); err == nil {
+			resp.Body = body
+		}
+	}
+	return resp, nil
+}
+
+func (t *cacheTransport) path(req *http.Request) string {
+	sum := sha256.Sum256([]byte(req.URL.String()))
+	return filepath.Join(t.dir, hex.EncodeToString(sum[:]))
+}
+
+func cacheable(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusOK {
+		return false
+	}
+	if strings.Contains(resp.Header.Get("Cache-Control"), "no-store") {
+		return false
+	}
+	return resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != ""
+}
+
+func readEntry(path string) (cacheEntry, error) {
+	var entry cacheEntry
+	file, err := os.Open(path)
+	if err != nil {
+		return entry, err
+	}
+	defer file.Close()
+	line, err := bufio.NewReader(file).ReadBytes('\n')
+	if err != nil {
+		return entry, err
+	}
+	return entry, json.Unmarshal(line, &entry)
+}
+
+// openCached builds a 200 response from the cache file at path.
+func openCached(path string, req *http.Request) (*http.Response, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	reader := bufio.NewReader(file)
+	line, err := reader.ReadBytes('\n')
+	var entry cacheEntry
+	if err == nil {
+		err = json.Unmarshal(line, &entry)
+	}
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("error reading cache entry %s: %v", path, err)
+	}
+
+	header := entry.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	header.Set("X-From-Cache", "1")
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          readCloser{reader, file},
+		ContentLength: -1,
+		Request:       req,
+	}, nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// cachingBody copies the body into a temporary file while the caller reads
+// it. Only a body read to the end replaces the cache entry.
+type cachingBody struct {
+	body     io.ReadCloser
+	tmp      *os.File
+	path     string
+	complete bool
+	failed   bool
+}
+
+func newCachingBody(dir, path string, req *http.Request, resp *http.Response) (*cachingBody, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return nil, err
+	}
+
+	entry := cacheEntry{
+		URL:          req.URL.String(),
+		Header:       resp.Header.Clone(),
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		StoredAt:     time.Now(),
+	}
+	line, err := json.Marshal(entry)
+	if err == nil {
+		_, err = tmp.Write(append(line, '\n'))
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	return &cachingBody{body: resp.Body, tmp: tmp, path: path}, nil
+}
+
+func (b *cachingBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	if n > 0 && !b.failed {
+		if _, werr := b.tmp.Write(p[:n]); werr != nil {
+			b.failed = true
+		}
+	}
+	if err == io.EOF {
+		b.complete = true
+	}
+	return n, err
+}
+
+func (b *cachingBody) Close() error {
+	err := b.body.Close()
+	tmpErr := b.tmp.Close()
+	if b.complete && !b.failed && tmpErr == nil {
+		if os.Rename(b.tmp.Name(), b.path) == nil {
+			return err
+		}
+	}
+	os.Remove(b.tmp.Name())
+	return err
+}
@@ -0,0 +1,185 @@
+// Package httpclient builds the client used for every outbound request. It
+// identifies itself with a User-Agent, spaces out requests to the same host,
+// retries 429 and 5xx responses with exponential backoff and, with a cache
+// directory, revalidates earlier responses with conditional requests.
+package httpclient
+
+import (
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+	"vertigo/pkg/config"
+)
+
+// Backoff of the first and the longest wait between two attempts. A
+// Retry-After longer than maxBackoff is not waited for, the response is
+// returned instead.
+var (
+	minBackoff = 500 * time.Millisecond
+	maxBackoff = 30 * time.Second
+)
+
+// New returns a client configured by cfg. The timeout bounds connecting and
+// waiting for the response headers of each attempt, so slow image downloads
+// and the waits between retries are not cut short.
+func New(cfg config.HTTP) *http.Client {
+	dialer := &net.Dialer{Timeout: cfg.Timeout, KeepAlive: 30 * time.Second}
+	base := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   cfg.Timeout,
+		ResponseHeaderTimeout: cfg.Timeout,
+		MaxIdleConnsPerHost:   4,
+		IdleConnTimeout:       90 * time.Second,
+	}
+	return &http.Client{Transport: Wrap(base, cfg)}
+}
+
+// Wrap adds the behaviour of New to base, tests use it to keep their own
+// transport.
+func Wrap(base http.RoundTripper, cfg config.HTTP) http.RoundTripper {
+	var rt http.RoundTripper = &limitTransport{next: base, interval: cfg.Interval, slots: make(map[string]time.Time)}
+	rt = &retryTransport{next: rt, retries: cfg.Retries}
+	if cfg.CacheDir != "" {
+		rt = &cacheTransport{next: rt, dir: cfg.CacheDir}
+	}
+	if cfg.UserAgent != "" {
+		rt = &userAgentTransport{next: rt, userAgent: cfg.UserAgent}
+	}
+	return rt
+}
+
+type userAgentTransport struct {
+	next      http.RoundTripper
+	userAgent string
+}
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("User-Agent") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", t.userAgent)
+	}
+	return t.next.RoundTrip(req)
+}
+
+// limitTransport hands out one slot per interval and host. Requests wait for
+// their slot, so a burst of downloads is spread out instead of rejected.
+type limitTransport struct {
+	next     http.RoundTripper
+	interval time.Duration
+
+	mu    sync.Mutex
+	slots map[string]time.Time
+}
+
+func (t *limitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.interval > 0 {
+		if err := sleep(req, t.reserve(req.URL.Host)); err != nil {
+			return nil, err
+		}
+	}
+	return t.next.RoundTrip(req)
+}
+
+// reserve returns how long the caller has to wait for its slot.
+func (t *limitTransport) reserve(host string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	slot := t.slots[host]
+	if slot.Before(now) {
+		slot = now
+	}
+	t.slots[host] = slot.Add(t.interval)
+	return slot.Sub(now)
+}
+
+type retryTransport struct {
+	next    http.RoundTripper
+	retries int
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Only requests without a body can be sent again safely.
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return t.next.RoundTrip(req)
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := t.next.RoundTrip(req)
+		if attempt >= t.retries || !shouldRetry(resp, err) || req.Context().Err() != nil {
+			return resp, err
+		}
+
+		wait := backoff(attempt)
+		if resp != nil {
+			if after, ok := retryAfter(resp); ok {
+				if after > maxBackoff {
+					return resp, nil
+				}
+				if after > wait {
+					wait = after
+				}
+			}
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+		}
+		if err := sleep(req, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode == http.StatusTooManyRequests ||
+		resp.StatusCode >= 500 && resp.StatusCode != http.StatusNotImplemented
+}
+
+// backoff doubles with every attempt and is jittered, so parallel downloads
+// that failed together don't come back together.
+func backoff(attempt int) time.Duration {
+	wait := maxBackoff
+	if attempt < 16 {
+		wait = minBackoff << attempt
+	}
+	if wait > maxBackoff {
+		wait = maxBackoff
+	}
+	return wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+}
+
+// retryAfter reads Retry-After in either of its forms, seconds or a date.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return time.Until(at), true
+	}
+	return 0, false
+}
+
+func sleep(req *http.Request, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-req.Context().Done():
+		return req.Context().Err()
+	case <-timer.C:
+		return nil
+	}
+}
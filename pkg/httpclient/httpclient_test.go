@@ -0,0 +1,150 @@
+package httpclient
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"vertigo/pkg/config"
+)
+
+func init() {
+	minBackoff = time.Millisecond
+	maxBackoff = 50 * time.Millisecond
+}
+
+func get(t *testing.T, client *http.Client, url string) (*http.Response, string) {
+	t.Helper()
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s failed: %v", url, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read the body: %v", err)
+	}
+	return resp, string(body)
+}
+
+func TestUserAgent(t *testing.T) {
+	var userAgent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.UserAgent()
+	}))
+	defer server.Close()
+
+	get(t, New(config.HTTP{UserAgent: "vertigo-test"}), server.URL)
+	if userAgent != "vertigo-test" {
+		t.Fatalf("Expected the configured User-Agent, got %q", userAgent)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	var mu sync.Mutex
+	var times []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		times = append(times, time.Now())
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	const interval = 40 * time.Millisecond
+	client := New(config.HTTP{Interval: interval})
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Get(server.URL)
+			if err != nil {
+				t.Errorf("GET failed: %v", err)
+				return
+			}
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+
+	first, last := times[0], times[0]
+	for _, at := range times {
+		if at.Before(first) {
+			first = at
+		}
+		if at.After(last) {
+			last = at
+		}
+	}
+	if last.Sub(first) < 2*interval-5*time.Millisecond {
+		t.Fatalf("Expected 3 requests to take at least %s, took %s", 2*interval, last.Sub(first))
+	}
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int
+		status   int
+		retries  int
+		want     int
+		attempts int32
+	}{
+		{"recovers from 503", 2, http.StatusServiceUnavailable, 3, http.StatusOK, 3},
+		{"recovers from 429", 1, http.StatusTooManyRequests, 3, http.StatusOK, 2},
+		{"gives up", 5, http.StatusBadGateway, 2, http.StatusBadGateway, 3},
+		{"doesn't retry 404", 5, http.StatusNotFound, 3, http.StatusNotFound, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.AddInt32(&attempts, 1) <= int32(tt.failures) {
+					w.Header().Set("Retry-After", "0")
+					w.WriteHeader(tt.status)
+					return
+				}
+				io.WriteString(w, "ok")
+			}))
+			defer server.Close()
+
+			resp, _ := get(t, New(config.HTTP{Retries: tt.retries}), server.URL)
+			if resp.StatusCode != tt.want || attempts != tt.attempts {
+				t.Fatalf("Expected %d after %d attempts, got %d after %d", tt.want, tt.attempts, resp.StatusCode, attempts)
+			}
+		})
+	}
+}
+
+func TestCache(t *testing.T) {
+	var sent, notModified int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			atomic.AddInt32(&notModified, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		atomic.AddInt32(&sent, 1)
+		w.Header().Set("Content-Type", "image/jpeg")
+		io.WriteString(w, "picture")
+	}))
+	defer server.Close()
+
+	client := New(config.HTTP{CacheDir: t.TempDir()})
+	for i := 0; i < 3; i++ {
+		resp, body := get(t, client, server.URL+"/img01.jpg")
+		if resp.StatusCode != http.StatusOK || body != "picture" || resp.Header.Get("Content-Type") != "image/jpeg" {
+			t.Fatalf("Request %d: unexpected response %d %q", i, resp.StatusCode, body)
+		}
+		if fromCache := resp.Header.Get("X-From-Cache") != ""; fromCache != (i > 0) {
+			t.Fatalf("Request %d: expected X-From-Cache to be %v", i, i > 0)
+		}
+	}
+	if sent != 1 || notModified != 2 {
+		t.Fatalf("Expected the body once and two 304s, got %d and %d", sent, notModified)
+	}
+}
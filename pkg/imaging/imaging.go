@@ -0,0 +1,150 @@
+// Package imaging frames product pictures consistently: it trims the
+// background on all four sides, optionally makes it transparent, pads the
+// result to a fixed aspect ratio and renders it in several sizes.
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"vertigo/pkg/config"
+)
+
+// Process trims all frames to the one box that holds the product in every
+// frame, so a 360 view doesn't jitter, then applies the background and
+// aspect ratio of cfg. Frames without any product are returned as they are.
+func Process(frames []image.Image, cfg config.Imaging) []image.Image {
+	box := UnionBounds(frames, cfg.Threshold)
+	if box.Empty() {
+		return frames
+	}
+
+	processed := make([]image.Image, len(frames))
+	for i, frame := range frames {
+		img := Crop(frame, box.Add(frame.Bounds().Min))
+		var background color.Color = color.White
+		if cfg.Transparent {
+			RemoveBackground(img, cfg.Threshold)
+			background = color.Transparent
+		}
+		if cfg.Aspect > 0 {
+			img = Pad(img, cfg.Aspect, background)
+		}
+		processed[i] = img
+	}
+	return processed
+}
+
+// IsBackground reports whether c is transparent or near white, every
+// channel at or above threshold.
+func IsBackground(c color.Color, threshold uint8) bool {
+	r, g, b, a := c.RGBA()
+	if a < 0x8000 {
+		return true
+	}
+	t := uint32(threshold)
+	return r>>8 >= t && g>>8 >= t && b>>8 >= t
+}
+
+// ContentBounds returns the smallest rectangle, relative to the image's
+// origin, holding every pixel that isn't background. It is empty for an
+// image that is all background.
+func ContentBounds(img image.Image, threshold uint8) image.Rectangle {
+	b := img.Bounds()
+	minX, minY, maxX, maxY := b.Max.X, b.Max.Y, b.Min.X-1, b.Min.Y-1
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if IsBackground(img.At(x, y), threshold) {
+				continue
+			}
+			minX, maxX = min(minX, x), max(maxX, x)
+			minY, maxY = min(minY, y), max(maxY, y)
+		}
+	}
+	if maxX < minX {
+		return image.Rectangle{}
+	}
+	return image.Rect(minX, minY, maxX+1, maxY+1).Sub(b.Min)
+}
+
+// UnionBounds returns the union of the content bounds of all frames.
+func UnionBounds(frames []image.Image, threshold uint8) image.Rectangle {
+	box := image.Rectangle{}
+	for _, frame := range frames {
+		box = box.Union(ContentBounds(frame, threshold))
+	}
+	return box
+}
+
+// Crop copies the part r of img into a new image, so any image.Image can be
+// cropped, whether or not it supports SubImage.
+func Crop(img image.Image, r image.Rectangle) *image.NRGBA {
+	r = r.Intersect(img.Bounds())
+	dst := image.NewNRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, r.Min, draw.Src)
+	return dst
+}
+
+// RemoveBackground makes the background connected to the border of img
+// transparent. Filling from the border keeps white parts of the product
+// itself, such as the midsole of a white sneaker, opaque.
+func RemoveBackground(img *image.NRGBA, threshold uint8) {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	seen := make([]bool, w*h)
+	var queue []int
+	push := func(x, y int) {
+		i := y*w + x
+		if seen[i] || !IsBackground(img.NRGBAAt(b.Min.X+x, b.Min.Y+y), threshold) {
+			return
+		}
+		seen[i] = true
+		queue = append(queue, i)
+	}
+	for x := 0; x < w; x++ {
+		push(x, 0)
+		push(x, h-1)
+	}
+	for y := 0; y < h; y++ {
+		push(0, y)
+		push(w-1, y)
+	}
+
+	for len(queue) > 0 {
+		i := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		x, y := i%w, i/w
+		img.SetNRGBA(b.Min.X+x, b.Min.Y+y, color.NRGBA{})
+		if x > 0 {
+			push(x-1, y)
+		}
+		if x < w-1 {
+			push(x+1, y)
+		}
+		if y > 0 {
+			push(x, y-1)
+		}
+		if y < h-1 {
+			push(x, y+1)
+		}
+	}
+}
+
+// Pad centres img on a canvas of background with the aspect ratio
+// width / height.
+func Pad(img image.Image, aspect float64, background color.Color) *image.NRGBA {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if float64(w)/float64(h) < aspect {
+		w = int(math.Round(float64(h) * aspect))
+	} else {
+		h = int(math.Round(float64(w) / aspect))
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+	offset := image.Pt((w-b.Dx())/2, (h-b.Dy())/2)
+	draw.Draw(dst, b.Sub(b.Min).Add(offset), img, b.Min, draw.Src)
+	return dst
+}
@@ -0,0 +1,105 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"vertigo/pkg/config"
+)
+
+// shoe returns a white w x h picture with a grey box at r and a white
+// stripe inside the box, like the midsole of a white shoe.
+func shoe(w, h int, r image.Rectangle) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.NRGBA{0xff, 0xff, 0xff, 0xff}
+			stripe := y == r.Min.Y+r.Dy()/2 && x > r.Min.X && x < r.Max.X-1
+			if image.Pt(x, y).In(r) && !stripe {
+				c = color.NRGBA{0x60, 0x60, 0x60, 0xff}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// plain hides the SubImage method of the image it wraps.
+type plain struct{ image.Image }
+
+func TestProcess(t *testing.T) {
+	frames := []image.Image{
+		shoe(100, 80, image.Rect(10, 20, 50, 40)),
+		plain{shoe(100, 80, image.Rect(30, 30, 90, 60))},
+	}
+	if got := UnionBounds(frames, 230); got != image.Rect(10, 20, 90, 60) {
+		t.Fatalf("Expected the union of both boxes, got %v", got)
+	}
+
+	tests := []struct {
+		name string
+		cfg  config.Imaging
+		size image.Point
+	}{
+		{"trim", config.Imaging{Threshold: 230}, image.Pt(80, 40)},
+		{"aspect", config.Imaging{Threshold: 230, Aspect: 1}, image.Pt(80, 80)},
+		{"transparent", config.Imaging{Threshold: 230, Transparent: true, Aspect: 4.0 / 1}, image.Pt(160, 40)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processed := Process(frames, tt.cfg)
+			for i, frame := range processed {
+				if frame.Bounds().Size() != tt.size {
+					t.Fatalf("Frame %d: expected %v, got %v", i, tt.size, frame.Bounds().Size())
+				}
+			}
+			if !tt.cfg.Transparent {
+				return
+			}
+			// The first frame's box sits in the top left corner of the union.
+			frame := processed[0]
+			offset := (tt.size.X - 80) / 2
+			if _, _, _, a := frame.At(offset+60, 30).RGBA(); a != 0 {
+				t.Fatalf("Expected the background to be transparent")
+			}
+			if _, _, _, a := frame.At(offset+10, 10).RGBA(); a != 0xffff {
+				t.Fatalf("Expected the white stripe inside the shoe to stay opaque")
+			}
+			if _, _, _, a := frame.At(0, 0).RGBA(); a != 0 {
+				t.Fatalf("Expected the padding to be transparent")
+			}
+		})
+	}
+
+	blank := []image.Image{image.NewNRGBA(image.Rect(0, 0, 10, 10))}
+	if got := Process(blank, config.Imaging{Threshold: 230}); got[0] != blank[0] {
+		t.Fatalf("Expected a frame without content to be kept")
+	}
+}
+
+func TestWriteRenditions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "main.png")
+	paths, err := WriteRenditions(path, shoe(800, 400, image.Rect(100, 100, 700, 300)))
+	if err != nil {
+		t.Fatalf("WriteRenditions failed: %v", err)
+	}
+
+	want := map[string]int{"main-thumbnail.png": 200, "main-medium.png": 600, "main.png": 800}
+	if len(paths) != len(want) {
+		t.Fatalf("Expected %d renditions, got %v", len(want), paths)
+	}
+	for _, p := range paths {
+		file, err := os.Open(p)
+		if err != nil {
+			t.Fatalf("Expected %s: %v", p, err)
+		}
+		cfg, _, err := image.DecodeConfig(file)
+		file.Close()
+		if err != nil || cfg.Width != want[filepath.Base(p)] || cfg.Height != cfg.Width/2 {
+			t.Fatalf("%s: expected a width of %d, got %dx%d (%v)", p, want[filepath.Base(p)], cfg.Width, cfg.Height, err)
+		}
+	}
+}
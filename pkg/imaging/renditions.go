@@ -0,0 +1,70 @@
+package imaging
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nfnt/resize"
+)
+
+// Rendition is a size a picture is published in. A Width of 0 keeps the
+// picture as it is.
+type Rendition struct {
+	Name  string
+	Width int
+}
+
+var Renditions = []Rendition{
+	{Name: "thumbnail", Width: 200},
+	{Name: "medium", Width: 600},
+	{Name: "full", Width: 0},
+}
+
+// RenditionPath returns where rendition of the picture at path is stored,
+// next to it: main.png, main-medium.png, main-thumbnail.png.
+func RenditionPath(path string, rendition Rendition) string {
+	if rendition.Width == 0 {
+		return path
+	}
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-" + rendition.Name + ext
+}
+
+// Resize scales img down to width, keeping its aspect ratio. Smaller images
+// are not scaled up.
+func Resize(img image.Image, width int) image.Image {
+	if width <= 0 || img.Bounds().Dx() <= width {
+		return img
+	}
+	return resize.Resize(uint(width), 0, img, resize.Lanczos3)
+}
+
+// WriteRenditions writes img as a PNG in every rendition and returns the
+// paths, in the order of Renditions.
+func WriteRenditions(path string, img image.Image) ([]string, error) {
+	paths := make([]string, 0, len(Renditions))
+	for _, rendition := range Renditions {
+		renditionPath := RenditionPath(path, rendition)
+		if err := WritePNG(renditionPath, Resize(img, rendition.Width)); err != nil {
+			return nil, fmt.Errorf("error writing %s rendition: %v", rendition.Name, err)
+		}
+		paths = append(paths, renditionPath)
+	}
+	return paths, nil
+}
+
+func WritePNG(path string, img image.Image) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(file, img); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -56,7 +56,9 @@ func convertTagsToMap(tags RestaurantTags) map[string]string {
 	return result
 }
 
-func FindRestaurants(foodpath string) ([]RestaurantDetails, error) {
+// FindRestaurants asks OpenStreetMap's Overpass API for the places around
+// where the picture at foodpath was taken.
+func FindRestaurants(client *http.Client, foodpath string) ([]RestaurantDetails, error) {
 	metadata, err := imageMetadata.GetImageMetaData(foodpath)
 	if err != nil {
 		return nil, fmt.Errorf("Error in getting Image Metadata: %v", err)
@@ -70,11 +72,14 @@ func FindRestaurants(foodpath string) ([]RestaurantDetails, error) {
 
 	v := url.Values{}
 	v.Set("data", query)
-	res, err := http.Get(baseURL + "?" + v.Encode())
+	res, err := client.Get(baseURL + "?" + v.Encode())
 	if err != nil {
 		return nil, fmt.Errorf("Error in HTTP request: %v", err)
 	}
 	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Error in HTTP request: status code %d", res.StatusCode)
+	}
 
 	body, err := io.ReadAll(res.Body)
 	if err != nil {
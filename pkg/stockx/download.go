@@ -1,169 +1,195 @@
 package stockx
 
 import (
+	"context"
 	"fmt"
 	"image"
-	"image/jpeg"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
 	"io"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
-	"vertigo/pkg/python"
-	"vertigo/pkg/s3"
-	"time"
-	"github.com/nfnt/resize"
-	_ "github.com/mattn/go-sqlite3"
-	"database/sql"
+	"vertigo/pkg/animation"
+	"vertigo/pkg/blobstore"
+	"vertigo/pkg/config"
+	"vertigo/pkg/imaging"
 )
 
 const (
-	imagePath      = "./img_data/shoes"
-	numImages      = 36
-	imageWidth     = 800
-	whiteThreshold = 230
-	bucketName     = "vertigo"
+	numImages  = 36
+	imageWidth = 800
 )
 
-
-type DB struct {
-	*sql.DB
+// VisualItem is what GetVisualItem published for a shoe. Frames is 0 when
+// the spinning shoe was on disk already and nothing was downloaded.
+type VisualItem struct {
+	MainImageURL   string
+	SpinningGifURL string
+	MainImagePath  string
+	SpinningPath   string
+	Frames         int
 }
 
-
-func GetDB(databasePath string) (*DB, error) {
-	db, err := sql.Open("sqlite3", databasePath)
+func GetVisualItem(cfg *config.Config, itemUUID, itemImgURL string) (*VisualItem, error) {
+	store, err := blobstore.New(cfg.Storage)
 	if err != nil {
-		return nil, fmt.Errorf("error opening database: %v", err)
+		return nil, err
 	}
-	return &DB{db}, nil
-}
-func (db *DB) UpdateShoeImageURLs(productName, mainImgURL, spinningGifURL string) error {
-	query := `UPDATE shoes SET MainPicture = ?, SpinningGifURL = ? WHERE ProductName = ?`
-	_, err := db.Exec(query, mainImgURL, spinningGifURL, productName)
-	if err != nil {
-		return fmt.Errorf("error updating shoe image URLs: %v", err)
-	}
-	return nil
+	return defaultScraper.GetVisualItem(context.Background(), cfg, store, itemUUID, itemImgURL)
 }
 
+// GetVisualItem downloads the pictures of a shoe with the scraper's client,
+// turns them into the spinning shoe and uploads both to store. Pictures
+// already on disk are kept, an interrupted download of the 360 view resumes.
+// Storing the URLs is up to the caller.
+func (s *Scraper) GetVisualItem(ctx context.Context, cfg *config.Config, store blobstore.BlobStore, itemUUID, itemImgURL string) (*VisualItem, error) {
+	imagePath := cfg.ShoeImageDir()
+	shoeFolderPath := filepath.Join(imagePath, itemUUID)
+	firstImgPath := filepath.Join(shoeFolderPath, "main.png")
+	if err := downloadFirstImg(s.client(), cfg.Imaging, imagePath, itemUUID, itemImgURL, false); err != nil {
+		return nil, err
+	}
+	spinningName := "spinning" + animation.Extension(cfg.Animation.Format)
+	spinningPath := filepath.Join(shoeFolderPath, spinningName)
+	item := &VisualItem{MainImagePath: firstImgPath, SpinningPath: spinningPath}
+	if !fileExists(spinningPath) {
+		frames, err := download360Images(ctx, s.client(), imagePath, itemUUID, itemImgURL)
+		if err != nil {
+			return nil, err
+		}
+		images := make([]image.Image, frames)
+		for i := range images {
+			if images[i], err = loadImage(filepath.Join(shoeFolderPath, frameName(i+1))); err != nil {
+				return nil, fmt.Errorf("failed to load frame %d: %v", i+1, err)
+			}
+		}
+		images = imaging.Process(images, cfg.Imaging)
+		if err := animation.WriteFile(spinningPath, images, cfg.Animation); err != nil {
+			return nil, fmt.Errorf("failed to make %s: %v", spinningName, err)
+		}
+		if err := deleteImages(imagePath, itemUUID, frames); err != nil {
+			return nil, err
+		}
+		item.Frames = frames
+	}
 
-func saveImageURLsToDB(itemUUID, mainImgURL, spinningGifURL string) error {
-	db, err :=  GetDB("data/database/test.db")
-	if err != nil {
-		return fmt.Errorf("failed to open database: %v", err)
+	var err error
+	if item.MainImageURL, err = uploadMainImage(ctx, store, itemUUID, firstImgPath); err != nil {
+		return nil, err
 	}
 
-	// Assuming there's a function to update the main picture and spinning gif URLs in your database
-	err = db.UpdateShoeImageURLs(itemUUID, mainImgURL, spinningGifURL)
+	// Upload the spinning shoe
+	spinningGifKey := blobstore.ShoeKey(itemUUID, spinningName)
+	item.SpinningGifURL, err = blobstore.PutFile(ctx, store, spinningGifKey, spinningPath)
 	if err != nil {
-		return fmt.Errorf("failed to update image URLs in database: %v", err)
+		return nil, fmt.Errorf("failed to upload %s: %v", spinningName, err)
 	}
-
-	return nil
+	return item, nil
 }
 
-func GetVisualItem(itemUUID, itemImgURL string) error {
-	shoeFolderPath := filepath.Join(imagePath, itemUUID)
-	firstImgPath := filepath.Join(shoeFolderPath, "main.png")
-	if _, err := os.Stat(firstImgPath); os.IsNotExist(err) {
-		if err := downloadFirstImg(itemUUID, itemImgURL, true); err != nil {
-			return err
-		}
-		if err := download360Images(itemUUID, itemImgURL, true); err != nil {
-			return err
-		}
+// GetMainImage downloads the picture at imgURL, which doesn't have to be on
+// StockX, crops it like the pictures of GetVisualItem and uploads it with its
+// renditions to store. There is no spinning shoe, SpinningGifURL is empty.
+func (s *Scraper) GetMainImage(ctx context.Context, cfg *config.Config, store blobstore.BlobStore, itemUUID, imgURL string) (*VisualItem, error) {
+	firstImgPath := filepath.Join(prepareShoeFolder(cfg.ShoeImageDir(), itemUUID), "main.png")
+	if err := downloadPicture(s.client(), imgURL, firstImgPath); err != nil {
+		return nil, err
 	}
-	python.PythonGif(itemUUID, imagePath)
-	if err := deleteImages(itemUUID); err != nil {
-		return err
+	if err := trimImage(firstImgPath, cfg.Imaging); err != nil {
+		os.Remove(firstImgPath)
+		return nil, err
 	}
-
-	time.Sleep(3 * time.Second)
-	// Upload main.png to R2
-	mainImgKey := fmt.Sprintf("%s/main.png", itemUUID)
-	mainImgURL, err := s3.UploadToR2(bucketName, mainImgKey, firstImgPath)
+	url, err := uploadMainImage(ctx, store, itemUUID, firstImgPath)
 	if err != nil {
-		return fmt.Errorf("failed to upload main.png to R2: %v", err)
+		return nil, err
 	}
+	return &VisualItem{MainImageURL: url, MainImagePath: firstImgPath}, nil
+}
 
-	// Upload spinning.gif to R2
-	spinningGifPath := filepath.Join(shoeFolderPath, "spinning.gif")
-	spinningGifKey := fmt.Sprintf("%s/spinning.gif", itemUUID)
-	spinningGifURL, err := s3.UploadToR2(bucketName, spinningGifKey, spinningGifPath)
+// uploadMainImage uploads main.png, the smaller renditions next to it, and
+// returns the URL of main.png.
+func uploadMainImage(ctx context.Context, store blobstore.BlobStore, itemUUID, firstImgPath string) (string, error) {
+	mainImg, err := loadImage(firstImgPath)
 	if err != nil {
-		return fmt.Errorf("failed to upload spinning.gif to R2: %v", err)
+		return "", err
 	}
-
-	// Save URLs to the database
-	err = saveImageURLsToDB(itemUUID, mainImgURL, spinningGifURL)
+	renditions, err := imaging.WriteRenditions(firstImgPath, mainImg)
 	if err != nil {
-		return fmt.Errorf("failed to save image URLs to database: %v", err)
+		return "", err
 	}
-
-	fmt.Println(spinningGifURL, mainImgURL)
-	return nil
+	var mainURL string
+	for _, renditionPath := range renditions {
+		key := blobstore.ShoeKey(itemUUID, filepath.Base(renditionPath))
+		url, err := blobstore.PutFile(ctx, store, key, renditionPath)
+		if err != nil {
+			return "", fmt.Errorf("failed to upload %s: %v", filepath.Base(renditionPath), err)
+		}
+		if renditionPath == firstImgPath {
+			mainURL = url
+		}
+	}
+	return mainURL, nil
 }
-func downloadFirstImg(itemUUID, imgURL string, redownload bool) error {
-	shoeFolderPath := prepareShoeFolder(itemUUID)
+func downloadFirstImg(client *http.Client, cfg config.Imaging, imagePath, itemUUID, imgURL string, redownload bool) error {
+	shoeFolderPath := prepareShoeFolder(imagePath, itemUUID)
 	firstImgPath := filepath.Join(shoeFolderPath, "main.png")
 
 	if !redownload && fileExists(firstImgPath) {
 		return nil
 	}
 
-	if download360Image(imgURL, "01", firstImgPath) || downloadStandardImage(imgURL, firstImgPath) {
-		if err := trimImage(firstImgPath); err != nil {
+	if download360Image(client, imgURL, "01", firstImgPath) || downloadStandardImage(client, imgURL, firstImgPath) {
+		if err := trimImage(firstImgPath, cfg); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func download360Image(baseURL, index, savePath string) bool {
+func download360Image(client *http.Client, baseURL, index, savePath string) bool {
 	imgURL := convertURLTo360URL(baseURL, index)
-	return downloadPicture(imgURL, savePath) == nil
+	return downloadPicture(client, imgURL, savePath) == nil
 }
 
-func downloadStandardImage(baseURL, savePath string) bool {
+func downloadStandardImage(client *http.Client, baseURL, savePath string) bool {
 	imgURL := fmt.Sprintf("%s?w=%d&bg=FFFFFF", strings.Split(baseURL, "?")[0], imageWidth)
-	return downloadPicture(imgURL, savePath) == nil
+	return downloadPicture(client, imgURL, savePath) == nil
 }
 
-func download360Images(itemUUID, baseURL string, redownload bool) error {
-	shoeFolderPath := prepareShoeFolder(itemUUID)
-	if !redownload && fileExists(filepath.Join(shoeFolderPath, "spinning.gif")) {
-		return nil
-	}
-
-	for i := 1; i <= numImages; i++ {
-		index := fmt.Sprintf("%02d", i)
-		imgSavePath := filepath.Join(shoeFolderPath, index+".jpg")
-		if !download360Image(baseURL, index, imgSavePath) {
-			return fmt.Errorf("failed to download image %d for %s", i, itemUUID)
-		}
+// download360Images downloads the frames of the 360 view next to main.png
+// and returns how many there are.
+func download360Images(ctx context.Context, client *http.Client, imagePath, itemUUID, baseURL string) (int, error) {
+	shoeFolderPath := prepareShoeFolder(imagePath, itemUUID)
+	frames, err := newFrameDownloader(client, shoeFolderPath, baseURL).download(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to download the 360 view of %s: %v", itemUUID, err)
 	}
-	return nil
+	return frames, nil
 }
 
-
-func deleteImages(uuid string) error {
+// deleteImages removes the frames and their manifest once the GIF is made.
+func deleteImages(imagePath, uuid string, frames int) error {
 	shoeFolderPath := filepath.Join(imagePath, uuid)
-	for i := 1; i <= numImages; i++ {
-		index := fmt.Sprintf("%02d", i)
-		imgPath := filepath.Join(shoeFolderPath, index+".jpg")
+	for i := 1; i <= frames; i++ {
+		imgPath := filepath.Join(shoeFolderPath, frameName(i))
 		if err := os.Remove(imgPath); err != nil {
-			log.Printf("Failed to remove image %s for %s: %v", index, uuid, err)
+			log.Printf("Failed to remove image %02d for %s: %v", i, uuid, err)
 		} else {
-			log.Printf("Removed image %s for %s", index, uuid)
+			log.Printf("Removed image %02d for %s", i, uuid)
 		}
 	}
+	if err := os.Remove(filepath.Join(shoeFolderPath, manifestFile)); err != nil && !os.IsNotExist(err) {
+		log.Printf("Failed to remove the manifest of %s: %v", uuid, err)
+	}
 	return nil
 }
 
-func downloadPicture(imgURL, savePath string) error {
-	resp, err := http.Get(imgURL)
+func downloadPicture(client *http.Client, imgURL, savePath string) error {
+	resp, err := client.Get(imgURL)
 	if err != nil {
 		return fmt.Errorf("failed to download picture from %s: %w", imgURL, err)
 	}
@@ -204,28 +230,19 @@ func convertURLTo360URL(imageURL, index string) string {
 		urlKey360, urlKey360, index, imageWidth)
 }
 
-func prepareShoeFolder(uuid string) string {
+func prepareShoeFolder(imagePath, uuid string) string {
 	shoeFolderPath := filepath.Join(imagePath, uuid)
 	os.MkdirAll(shoeFolderPath, os.ModePerm)
 	return shoeFolderPath
 }
 
-func isRowWhite(row []uint8, threshold uint8) bool {
-	for _, pixel := range row {
-		if pixel < threshold && pixel != 0 {
-			return false
-		}
-	}
-	return true
-}
-
-func trimImage(path string) error {
+func trimImage(path string, cfg config.Imaging) error {
 	img, err := loadImage(path)
 	if err != nil {
 		return fmt.Errorf("failed to load image for trimming: %w", err)
 	}
 
-	croppedImg := cropImage(img)
+	croppedImg := imaging.Process([]image.Image{img}, cfg)[0]
 	if err := saveImage(path, croppedImg); err != nil {
 		return err
 	}
@@ -253,7 +270,7 @@ func saveImage(path string, img image.Image) error {
 	}
 	defer file.Close()
 
-	if err := jpeg.Encode(file, img, nil); err != nil {
+	if err := png.Encode(file, img); err != nil {
 		return fmt.Errorf("failed to encode image: %w", err)
 	}
 	return nil
@@ -263,39 +280,3 @@ func fileExists(path string) bool {
 	_, err := os.Stat(path)
 	return !os.IsNotExist(err)
 }
-
-func cropImage(img image.Image) image.Image {
-	grayImg := resize.Resize(uint(imageWidth), 0, img, resize.Lanczos3)
-	bounds := grayImg.Bounds()
-	pixels := make([][]uint8, bounds.Dy())
-	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
-		row := make([]uint8, bounds.Dx())
-		for x := bounds.Min.X; x < bounds.Max.X; x++ {
-			r, g, b, _ := grayImg.At(x, y).RGBA()
-			row[x] = uint8((r + g + b) / 3 >> 8)
-		}
-		pixels[y] = row
-	}
-
-	topCrop := 0
-	for _, row := range pixels {
-		if isRowWhite(row, whiteThreshold) {
-			topCrop++
-		} else {
-			break
-		}
-	}
-
-	bottomCrop := 0
-	for i := len(pixels) - 1; i >= 0; i-- {
-		if isRowWhite(pixels[i], whiteThreshold) {
-			bottomCrop++
-		} else {
-			break
-		}
-	}
-
-	return img.(interface {
-		SubImage(r image.Rectangle) image.Image
-	}).SubImage(image.Rect(0, topCrop, bounds.Dx(), bounds.Dy()-bottomCrop))
-}
\ No newline at end of file
@@ -0,0 +1,199 @@
+package stockx
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+	"vertigo/pkg/blobstore"
+	"vertigo/pkg/config"
+)
+
+func TestConvertURLTo360URL(t *testing.T) {
+	tests := []struct {
+		imageURL string
+		index    string
+		want     string
+	}{
+		{
+			"https://images.stockx.com/images/Air-Jordan-1-Retro-High-Travis-Scott-Product.jpg",
+			"01",
+			"https://images.stockx.com/360/Air-Jordan-1-Retro-High-Travis-Scott/Images/Air-Jordan-1-Retro-High-Travis-Scott/Lv2/img01.jpg?w=800",
+		},
+		{
+			"https://images.stockx.com/images/Nike-Dunk-Low-Panda-Product_V2.png",
+			"36",
+			"https://images.stockx.com/360/Nike-Dunk-Low-Panda/Images/Nike-Dunk-Low-Panda/Lv2/img36.jpg?w=800",
+		},
+		{
+			"https://images.stockx.com/images/New-Balance-550-White-Green.jpg",
+			"12",
+			"https://images.stockx.com/360/New-Balance-550-White-Green/Images/New-Balance-550-White-Green/Lv2/img12.jpg?w=800",
+		},
+	}
+	for _, tt := range tests {
+		if got := convertURLTo360URL(tt.imageURL, tt.index); got != tt.want {
+			t.Fatalf("convertURLTo360URL(%q, %q) = %s, want %s", tt.imageURL, tt.index, got, tt.want)
+		}
+	}
+}
+
+func TestDownloadImages(t *testing.T) {
+	client := newFixtureClient(t)
+	dir := t.TempDir()
+
+	tests := []struct {
+		productName string
+		// has360 is false for shoes StockX has no 360 view of, their main
+		// picture falls back to the product image.
+		has360 bool
+	}{
+		{"Air-Jordan-1-Retro-High-Travis-Scott", true},
+		{"Nike-Mars-Yard-2-0", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.productName, func(t *testing.T) {
+			mainPicture := "https://images.stockx.com/images/" + tt.productName + "-Product.jpg"
+			if err := downloadFirstImg(client, config.Default().Imaging, dir, tt.productName, mainPicture, true); err != nil {
+				t.Fatalf("downloadFirstImg failed: %v", err)
+			}
+			img, err := loadImage(filepath.Join(dir, tt.productName, "main.png"))
+			if err != nil {
+				t.Fatalf("Expected main.png: %v", err)
+			}
+			// The recordings have white bands above and below the shoe.
+			if h := img.Bounds().Dy(); h == 0 || h >= 560 {
+				t.Fatalf("Expected main.png to be trimmed, got a height of %d", h)
+			}
+
+			frames, err := download360Images(context.Background(), client, dir, tt.productName, mainPicture)
+			if !tt.has360 {
+				if err == nil {
+					t.Fatalf("Expected an error without a 360 view")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("download360Images failed: %v", err)
+			}
+			if frames != numImages {
+				t.Fatalf("Expected %d frames, got %d", numImages, frames)
+			}
+			for i := 1; i <= numImages; i++ {
+				if _, err := os.Stat(filepath.Join(dir, tt.productName, fmt.Sprintf("%02d.jpg", i))); err != nil {
+					t.Fatalf("Expected frame %d: %v", i, err)
+				}
+			}
+		})
+	}
+}
+
+func TestGetVisualItem(t *testing.T) {
+	scraper := NewScraper(DefaultProfiles())
+	scraper.Client = newFixtureClient(t)
+	cfg := config.Default()
+	cfg.ImageDir = t.TempDir()
+	cfg.Animation.Width = 100
+	store := blobstore.NewMemory("")
+	const productName = "Air-Jordan-1-Retro-High-Travis-Scott"
+	mainPicture := "https://images.stockx.com/images/" + productName + "-Product.jpg"
+
+	for _, wantFrames := range []int{numImages, 0} {
+		item, err := scraper.GetVisualItem(context.Background(), cfg, store, productName, mainPicture)
+		if err != nil {
+			t.Fatalf("GetVisualItem failed: %v", err)
+		}
+		want := VisualItem{
+			MainImageURL:   "memory://shoes/" + productName + "/main.png",
+			SpinningGifURL: "memory://shoes/" + productName + "/spinning.gif",
+			MainImagePath:  filepath.Join(cfg.ShoeImageDir(), productName, "main.png"),
+			SpinningPath:   filepath.Join(cfg.ShoeImageDir(), productName, "spinning.gif"),
+			Frames:         wantFrames,
+		}
+		if *item != want {
+			t.Fatalf("Expected %+v, got %+v", want, *item)
+		}
+	}
+}
+
+func TestFrameDownloader(t *testing.T) {
+	const frames = 24
+	frame, err := os.ReadFile(filepath.Join(fixtureDir, "360", "Air-Jordan-1-Retro-High-Travis-Scott.jpg"))
+	if err != nil {
+		t.Fatalf("Failed to read the recording: %v", err)
+	}
+
+	var mu sync.Mutex
+	requests := make(map[int]int)
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var i int
+		fmt.Sscanf(filepath.Base(r.URL.Path), "img%d.jpg", &i)
+		mu.Lock()
+		requests[i]++
+		attempt := requests[i]
+		mu.Unlock()
+
+		switch {
+		case i > frames:
+			http.NotFound(w, r)
+		case i == 5 && attempt == 1:
+			// The connection drops halfway through the frame.
+			w.Header().Set("Content-Length", strconv.Itoa(len(frame)))
+			w.Write(frame[:len(frame)/2])
+		case i == 7 && attempt == 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.Write(frame)
+		}
+	}))
+
+	dir := t.TempDir()
+	mainPicture := "https://images.stockx.com/images/Nike-Short-Spin-Product.jpg"
+	download := func() (int, map[int]int) {
+		t.Helper()
+		mu.Lock()
+		requests = make(map[int]int)
+		mu.Unlock()
+		d := newFrameDownloader(client, dir, mainPicture)
+		d.backoff = time.Millisecond
+		n, err := d.download(context.Background())
+		if err != nil {
+			t.Fatalf("download failed: %v", err)
+		}
+		return n, requests
+	}
+
+	n, got := download()
+	if n != frames {
+		t.Fatalf("Expected %d frames, got %d", frames, n)
+	}
+	if got[5] != 2 || got[7] != 2 || got[frames+1] != 1 {
+		t.Fatalf("Expected frames 5 and 7 to be retried once, got %v", got)
+	}
+	for i := 1; i <= numImages; i++ {
+		_, err := os.Stat(filepath.Join(dir, frameName(i)))
+		if exists := err == nil; exists != (i <= frames) {
+			t.Fatalf("Frame %d: expected it to exist: %v", i, i <= frames)
+		}
+	}
+
+	// An interrupted run left a broken frame and a partial one behind.
+	if err := os.WriteFile(filepath.Join(dir, frameName(3)), frame[:100], 0o644); err != nil {
+		t.Fatalf("Failed to break frame 3: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, frameName(9)+".part"), frame[:100], 0o644); err != nil {
+		t.Fatalf("Failed to write a partial frame: %v", err)
+	}
+	n, got = download()
+	if n != frames || len(got) != 1 || got[3] != 1 {
+		t.Fatalf("Expected only frame 3 to be downloaded again, got %d frames and %v", n, got)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, frameName(3))); len(data) != len(frame) {
+		t.Fatalf("Expected frame 3 to be repaired, got %d bytes", len(data))
+	}
+}
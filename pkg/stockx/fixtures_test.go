@@ -0,0 +1,74 @@
+package stockx
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fixtureDir holds product pages and images recorded from StockX.
+const fixtureDir = "../../testdata/stockx"
+
+// rewriteTransport sends every request to the test server and keeps the
+// original host in the Host header, so the code under test keeps using the
+// real StockX URLs.
+type rewriteTransport struct {
+	target *url.URL
+}
+
+func (rt rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Host = req.URL.Host
+	req.URL.Scheme = rt.target.Scheme
+	req.URL.Host = rt.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// newFixtureClient serves the fixtures the way stockx.com and
+// images.stockx.com would and returns a client talking to them.
+func newFixtureClient(t *testing.T) *http.Client {
+	t.Helper()
+	return newTestClient(t, http.HandlerFunc(serveFixture))
+}
+
+// newTestClient returns a client sending every request to handler.
+func newTestClient(t *testing.T, handler http.Handler) *http.Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	target, _ := url.Parse(server.URL)
+	return &http.Client{Transport: rewriteTransport{target: target}}
+}
+
+func serveFixture(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	switch r.Host {
+	case "stockx.com":
+		http.ServeFile(w, r, filepath.Join(fixtureDir, "pages", path+".html"))
+	case "images.stockx.com":
+		// Every frame of a 360 view is served from the same recording:
+		// 360/<key>/Images/<key>/Lv2/img01.jpg -> 360/<key>.jpg. The views
+		// are numImages frames long.
+		parts := strings.Split(path, "/")
+		if len(parts) == 6 && parts[0] == "360" {
+			var frame int
+			if _, err := fmt.Sscanf(parts[5], "img%d.jpg", &frame); err != nil || frame > numImages {
+				http.NotFound(w, r)
+				return
+			}
+			http.ServeFile(w, r, filepath.Join(fixtureDir, "360", parts[1]+".jpg"))
+			return
+		}
+		if len(parts) == 2 && parts[0] == "images" {
+			http.ServeFile(w, r, filepath.Join(fixtureDir, "images", parts[1]))
+			return
+		}
+		http.NotFound(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
@@ -0,0 +1,283 @@
+package stockx
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	frameWorkers = 4
+	frameRetries = 3
+	// maxFrames stops probing for frames of a view that never ends.
+	maxFrames    = 4 * numImages
+	manifestFile = "manifest.json"
+)
+
+// errNoFrame is returned for a frame StockX doesn't have, which marks the
+// end of the 360 view.
+var errNoFrame = errors.New("frame does not exist")
+
+// frameManifest records the frames of a 360 view that are completely on
+// disk, so an interrupted download resumes where it stopped.
+type frameManifest struct {
+	BaseURL string `json:"base_url"`
+	// Frames is the length of the view, 0 while it is not known yet.
+	Frames   int                      `json:"frames,omitempty"`
+	Complete map[string]frameChecksum `json:"complete"`
+}
+
+type frameChecksum struct {
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// frameDownloader fetches the frames of a 360 view with a bounded number of
+// workers and retries each frame on its own, a failing frame doesn't stop
+// the others.
+type frameDownloader struct {
+	client  *http.Client
+	dir     string
+	baseURL string
+	workers int
+	retries int
+	backoff time.Duration
+
+	mu       sync.Mutex
+	manifest frameManifest
+}
+
+func newFrameDownloader(client *http.Client, dir, baseURL string) *frameDownloader {
+	return &frameDownloader{
+		client:  client,
+		dir:     dir,
+		baseURL: baseURL,
+		workers: frameWorkers,
+		retries: frameRetries,
+		backoff: time.Second,
+	}
+}
+
+func frameName(i int) string {
+	return fmt.Sprintf("%02d.jpg", i)
+}
+
+// download fetches every frame that isn't verified on disk yet and returns
+// the length of the view. Views are numImages frames long by default, a
+// missing frame ends a shorter one and a longer one is followed until
+// StockX runs out of frames.
+func (d *frameDownloader) download(ctx context.Context) (int, error) {
+	d.loadManifest()
+
+	if d.manifest.Frames > 0 {
+		missing, err := d.downloadRange(ctx, 1, d.manifest.Frames)
+		if err == nil && missing > 0 {
+			err = fmt.Errorf("frame %d of %d went missing", missing, d.manifest.Frames)
+		}
+		return d.manifest.Frames, err
+	}
+
+	frames := maxFrames
+	for start := 1; start <= maxFrames; start += numImages {
+		end := start + numImages - 1
+		if end > maxFrames {
+			end = maxFrames
+		}
+		missing, err := d.downloadRange(ctx, start, end)
+		if err != nil {
+			return 0, err
+		}
+		if missing > 0 {
+			frames = missing - 1
+			break
+		}
+	}
+	if frames == 0 {
+		return 0, fmt.Errorf("no 360 view at %s", convertURLTo360URL(d.baseURL, "01"))
+	}
+
+	// Frames past a gap don't belong to the view.
+	for name := range d.manifest.Complete {
+		var i int
+		if _, err := fmt.Sscanf(name, "%d.jpg", &i); err == nil && i > frames {
+			os.Remove(filepath.Join(d.dir, name))
+			delete(d.manifest.Complete, name)
+		}
+	}
+	d.manifest.Frames = frames
+	return frames, d.saveManifest()
+}
+
+// downloadRange downloads the frames from start to end and returns the
+// first of them that doesn't exist, or 0.
+func (d *frameDownloader) downloadRange(ctx context.Context, start, end int) (int, error) {
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var missing []int
+	var errs []error
+
+	for w := 0; w < d.workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				err := d.downloadWithRetries(ctx, i)
+				mu.Lock()
+				if errors.Is(err, errNoFrame) {
+					missing = append(missing, i)
+				} else if err != nil {
+					errs = append(errs, fmt.Errorf("failed to download frame %d: %v", i, err))
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := start; i <= end; i++ {
+		if d.verified(i) {
+			continue
+		}
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return 0, errors.Join(errs...)
+	}
+	if len(missing) == 0 {
+		return 0, nil
+	}
+	sort.Ints(missing)
+	return missing[0], nil
+}
+
+func (d *frameDownloader) downloadWithRetries(ctx context.Context, i int) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = d.downloadFrame(ctx, i)
+		if err == nil || errors.Is(err, errNoFrame) || ctx.Err() != nil || attempt >= d.retries {
+			return err
+		}
+		log.Printf("Retrying frame %d of %s: %v", i, d.dir, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(d.backoff << attempt):
+		}
+	}
+}
+
+// downloadFrame writes the frame to a partial file first and only moves it
+// in place once it arrived completely.
+func (d *frameDownloader) downloadFrame(ctx context.Context, i int) error {
+	imgURL := convertURLTo360URL(d.baseURL, fmt.Sprintf("%02d", i))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, imgURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return errNoFrame
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("status code %d", resp.StatusCode)
+	}
+
+	path := filepath.Join(d.dir, frameName(i))
+	partPath := path + ".part"
+	out, err := os.Create(partPath)
+	if err != nil {
+		return err
+	}
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(out, hash), resp.Body)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && resp.ContentLength >= 0 && size != resp.ContentLength {
+		err = fmt.Errorf("got %d of %d bytes", size, resp.ContentLength)
+	}
+	if err == nil {
+		err = os.Rename(partPath, path)
+	}
+	if err != nil {
+		os.Remove(partPath)
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.manifest.Complete[frameName(i)] = frameChecksum{Size: size, SHA256: hex.EncodeToString(hash.Sum(nil))}
+	return d.saveManifestLocked()
+}
+
+// verified reports whether frame i is on disk with the size and checksum
+// the manifest recorded for it.
+func (d *frameDownloader) verified(i int) bool {
+	d.mu.Lock()
+	want, ok := d.manifest.Complete[frameName(i)]
+	d.mu.Unlock()
+	if !ok {
+		return false
+	}
+
+	file, err := os.Open(filepath.Join(d.dir, frameName(i)))
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	return err == nil && size == want.Size && hex.EncodeToString(hash.Sum(nil)) == want.SHA256
+}
+
+// loadManifest starts over when there is no manifest or it belongs to
+// another picture.
+func (d *frameDownloader) loadManifest() {
+	d.manifest = frameManifest{}
+	data, err := os.ReadFile(filepath.Join(d.dir, manifestFile))
+	if err == nil {
+		err = json.Unmarshal(data, &d.manifest)
+	}
+	if err != nil || d.manifest.BaseURL != d.baseURL {
+		d.manifest = frameManifest{BaseURL: d.baseURL}
+	}
+	if d.manifest.Complete == nil {
+		d.manifest.Complete = make(map[string]frameChecksum)
+	}
+}
+
+func (d *frameDownloader) saveManifest() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.saveManifestLocked()
+}
+
+func (d *frameDownloader) saveManifestLocked() error {
+	data, err := json.MarshalIndent(d.manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(d.dir, manifestFile)
+	if err := os.WriteFile(path+".tmp", data, 0o644); err != nil {
+		return fmt.Errorf("error writing manifest: %v", err)
+	}
+	return os.Rename(path+".tmp", path)
+}
@@ -0,0 +1,138 @@
+package stockx
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type Price struct {
+	Amount   float64
+	Currency string
+}
+
+// currencySymbols maps the symbols StockX shows in front of a price to their
+// ISO 4217 code. Longer symbols come first so "A$" is not read as "$".
+var currencySymbols = []struct {
+	symbol   string
+	currency string
+}{
+	{"CA$", "CAD"},
+	{"A$", "AUD"},
+	{"HK$", "HKD"},
+	{"NZ$", "NZD"},
+	{"$", "USD"},
+	{"€", "EUR"},
+	{"£", "GBP"},
+	{"¥", "JPY"},
+	{"₩", "KRW"},
+	{"CHF", "CHF"},
+}
+
+// ParsePrice reads a displayed price like "$1,234", "€ 1.234,50",
+// "1234 CHF" or "SEK 2 100".
+func ParsePrice(s string) (Price, error) {
+	text := strings.TrimSpace(s)
+	var price Price
+	for _, cs := range currencySymbols {
+		if strings.HasPrefix(text, cs.symbol) {
+			price.Currency = cs.currency
+			text = strings.TrimPrefix(text, cs.symbol)
+			break
+		}
+		if strings.HasSuffix(text, cs.symbol) {
+			price.Currency = cs.currency
+			text = strings.TrimSuffix(text, cs.symbol)
+			break
+		}
+	}
+	if price.Currency == "" {
+		text, price.Currency = cutCurrencyCode(text)
+	}
+	if price.Currency == "" {
+		return Price{}, fmt.Errorf("error parsing price %q: unknown currency", s)
+	}
+
+	amount, err := parseAmount(strings.TrimSpace(text))
+	if err != nil {
+		return Price{}, fmt.Errorf("error parsing price %q: %v", s, err)
+	}
+	price.Amount = amount
+	return price, nil
+}
+
+// cutCurrencyCode removes an ISO 4217 code in front of or behind the amount.
+func cutCurrencyCode(text string) (string, string) {
+	isCode := func(code string) bool {
+		if len(code) != 3 {
+			return false
+		}
+		for _, r := range code {
+			if r < 'A' || r > 'Z' {
+				return false
+			}
+		}
+		return true
+	}
+	if len(text) > 3 && isCode(text[:3]) {
+		return text[3:], text[:3]
+	}
+	if len(text) > 3 && isCode(text[len(text)-3:]) {
+		return text[:len(text)-3], text[len(text)-3:]
+	}
+	return text, ""
+}
+
+// FormatPrice shows the price the way StockX does, with the currency symbol
+// in front of the amount.
+func FormatPrice(price Price) string {
+	amount := strconv.FormatFloat(price.Amount, 'f', -1, 64)
+	if price.Amount != float64(int64(price.Amount)) {
+		amount = strconv.FormatFloat(price.Amount, 'f', 2, 64)
+	}
+	for _, cs := range currencySymbols {
+		if cs.currency == price.Currency && cs.symbol != cs.currency {
+			return cs.symbol + amount
+		}
+	}
+	return amount + " " + price.Currency
+}
+
+// parseAmount accepts both "1,234.50" and "1.234,50". A single separator
+// followed by exactly three digits is a thousands separator.
+func parseAmount(text string) (float64, error) {
+	text = strings.ReplaceAll(text, " ", "")
+	lastComma := strings.LastIndex(text, ",")
+	lastDot := strings.LastIndex(text, ".")
+
+	decimal := ""
+	switch {
+	case lastComma >= 0 && lastDot >= 0:
+		if lastComma > lastDot {
+			decimal = ","
+		} else {
+			decimal = "."
+		}
+	case lastComma >= 0 && len(text)-lastComma-1 != 3:
+		decimal = ","
+	case lastDot >= 0 && len(text)-lastDot-1 != 3:
+		decimal = "."
+	}
+
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case string(r) == decimal:
+			b.WriteRune('.')
+		case r == ',' || r == '.':
+		default:
+			return 0, fmt.Errorf("unexpected %q", r)
+		}
+	}
+	if b.Len() == 0 {
+		return 0, fmt.Errorf("no amount")
+	}
+	return strconv.ParseFloat(b.String(), 64)
+}
@@ -0,0 +1,39 @@
+package stockx
+
+import "testing"
+
+func TestParsePrice(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Price
+	}{
+		{"$1,234", Price{1234, "USD"}},
+		{" $180 ", Price{180, "USD"}},
+		{"€1.234,50", Price{1234.5, "EUR"}},
+		{"£99.99", Price{99.99, "GBP"}},
+		{"A$2,100", Price{2100, "AUD"}},
+		{"1 234 CHF", Price{1234, "CHF"}},
+		{"SEK 2 100", Price{2100, "SEK"}},
+	}
+	for _, tt := range tests {
+		got, err := ParsePrice(tt.input)
+		if err != nil {
+			t.Fatalf("ParsePrice(%q) failed: %v", tt.input, err)
+		}
+		if got != tt.want {
+			t.Fatalf("ParsePrice(%q) = %+v, want %+v", tt.input, got, tt.want)
+		}
+	}
+
+	for _, input := range []string{"", "--", "$", "1234", "$12a", "usd 12"} {
+		if _, err := ParsePrice(input); err == nil {
+			t.Fatalf("Expected ParsePrice(%q) to fail", input)
+		}
+	}
+
+	for _, price := range []Price{{230, "USD"}, {99.5, "EUR"}, {2100, "SEK"}} {
+		if got, err := ParsePrice(FormatPrice(price)); err != nil || got != price {
+			t.Fatalf("Expected %+v to survive FormatPrice, got %+v (%v)", price, got, err)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package stockx
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+//go:embed profiles/*.json
+var embeddedProfiles embed.FS
+
+// Profile holds the CSS selectors for one layout of the StockX product page.
+// StockX generates some of its class names, so a redeploy can break a
+// profile; a new layout gets a new profile with a higher version instead of
+// editing the code.
+type Profile struct {
+	Version        int    `json:"version"`
+	Name           string `json:"name"`
+	Title          string `json:"title"`
+	Subtitle       string `json:"subtitle"`
+	LastSale       string `json:"last_sale"`
+	Attributes     string `json:"attributes"`
+	AttributeKey   string `json:"attribute_key"`
+	AttributeValue string `json:"attribute_value"`
+	Description    string `json:"description"`
+	Image360       string `json:"image_360"`
+}
+
+func (p Profile) validate() error {
+	if p.Version <= 0 {
+		return fmt.Errorf("profile %q has no version", p.Name)
+	}
+	if p.Title == "" || p.Image360 == "" {
+		return fmt.Errorf("profile %d_%s needs a title and an image_360 selector", p.Version, p.Name)
+	}
+	return nil
+}
+
+// LoadProfiles reads the *.json profiles in fsys, newest version first.
+func LoadProfiles(fsys fs.FS) ([]Profile, error) {
+	files, err := fs.Glob(fsys, "*.json")
+	if err != nil {
+		return nil, fmt.Errorf("error listing profiles: %v", err)
+	}
+
+	var profiles []Profile
+	versions := make(map[int]string)
+	for _, file := range files {
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading profile %s: %v", file, err)
+		}
+		var p Profile
+		if err := json.Unmarshal(content, &p); err != nil {
+			return nil, fmt.Errorf("error parsing profile %s: %v", file, err)
+		}
+		if p.Name == "" {
+			p.Name = strings.TrimSuffix(path.Base(file), ".json")
+		}
+		if err := p.validate(); err != nil {
+			return nil, err
+		}
+		if other, ok := versions[p.Version]; ok {
+			return nil, fmt.Errorf("profiles %s and %s have the same version %d", other, file, p.Version)
+		}
+		versions[p.Version] = file
+		profiles = append(profiles, p)
+	}
+	sortProfiles(profiles)
+	return profiles, nil
+}
+
+func sortProfiles(profiles []Profile) {
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].Version > profiles[j].Version })
+}
+
+// DefaultProfiles returns the profiles built into the binary.
+func DefaultProfiles() []Profile {
+	sub, err := fs.Sub(embeddedProfiles, "profiles")
+	if err != nil {
+		panic(err)
+	}
+	profiles, err := LoadProfiles(sub)
+	if err != nil {
+		panic(fmt.Sprintf("invalid embedded StockX profile: %v", err))
+	}
+	return profiles
+}
+
+// ProfilesWithDir adds the profiles in dir to the built-in ones. A profile in
+// dir replaces a built-in profile with the same version.
+func ProfilesWithDir(dir string) ([]Profile, error) {
+	profiles := DefaultProfiles()
+	if dir == "" {
+		return profiles, nil
+	}
+	extra, err := LoadProfiles(os.DirFS(dir))
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]Profile)
+	for _, p := range profiles {
+		byVersion[p.Version] = p
+	}
+	for _, p := range extra {
+		byVersion[p.Version] = p
+	}
+	merged := make([]Profile, 0, len(byVersion))
+	for _, p := range byVersion {
+		merged = append(merged, p)
+	}
+	sortProfiles(merged)
+	return merged, nil
+}
+
+// extract reads the product details the profile's selectors find. Fields the
+// selectors miss are left empty.
+func (p Profile) extract(doc *goquery.Document) ProductDetails {
+	product := ProductDetails{
+		Attributes: make(map[string]string),
+	}
+
+	title := doc.Find(p.Title).First()
+	if p.Subtitle != "" {
+		product.Subtitle = strings.TrimSpace(title.Find(p.Subtitle).Text())
+		product.Name = strings.TrimSpace(title.Contents().Not(p.Subtitle).Text())
+	} else {
+		product.Name = strings.TrimSpace(title.Text())
+	}
+
+	if p.LastSale != "" {
+		product.LastSale = strings.TrimSpace(doc.Find(p.LastSale).First().Text())
+	}
+
+	if p.Attributes != "" {
+		doc.Find(p.Attributes).Each(func(index int, item *goquery.Selection) {
+			key := strings.TrimSpace(item.Find(p.AttributeKey).Text())
+			value := strings.TrimSpace(item.Find(p.AttributeValue).Text())
+			if key != "" {
+				product.Attributes[key] = value
+			}
+		})
+	}
+
+	if p.Description != "" {
+		product.Description = strings.TrimSpace(doc.Find(p.Description).Text())
+	}
+
+	doc.Find(p.Image360).EachWithBreak(func(i int, s *goquery.Selection) bool {
+		for _, attr := range []string{"srcset", "src"} {
+			value, _ := s.Attr(attr)
+			for _, candidate := range strings.Split(value, ",") {
+				url := strings.Split(strings.TrimSpace(candidate), " ")[0]
+				if name := productNameFromImageURL(url); name != "" {
+					product.ProductName = name
+					return false
+				}
+			}
+		}
+		return true
+	})
+	return product
+}
+
+// productNameFromImageURL finds the product name in the URL of a StockX
+// image, either .../360/<ProductName>/Images/... or
+// .../images/<ProductName>-Product.jpg.
+func productNameFromImageURL(url string) string {
+	url, _, _ = strings.Cut(url, "?")
+	parts := strings.Split(url, "/")
+	for j, part := range parts {
+		if part == "360" && j+2 < len(parts) {
+			return parts[j+1]
+		}
+	}
+	base := parts[len(parts)-1]
+	for _, suffix := range []string{"-Product.jpg", "-Product.png", "-Product.webp"} {
+		if strings.HasSuffix(base, suffix) {
+			return strings.TrimSuffix(base, suffix)
+		}
+	}
+	return ""
+}
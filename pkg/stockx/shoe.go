@@ -2,6 +2,7 @@ package stockx
 
 import (
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
 
@@ -15,12 +16,102 @@ type ProductDetails struct {
 	LastSale    string
 	ProductName string
 	MainPicture string
-	Attributes  map[string]string
-	Description string
+	// SpinningGifURL is set once the pictures are published, see GetVisualItem.
+	SpinningGifURL string
+	Attributes     map[string]string
+	Description    string
 }
 
+// ValidationError lists the required fields a scrape came back without and
+// the profiles that were tried.
+type ValidationError struct {
+	Missing  []string
+	Profiles []string
+}
+
+func (e *ValidationError) Error() string {
+	msg := fmt.Sprintf("missing required fields %s", strings.Join(e.Missing, ", "))
+	if len(e.Profiles) > 0 {
+		msg += fmt.Sprintf(", the StockX page layout may have changed (tried profiles %s)", strings.Join(e.Profiles, ", "))
+	}
+	return msg
+}
+
+// Validate returns a *ValidationError when Name or ProductName is empty.
+func (pd ProductDetails) Validate() error {
+	var missing []string
+	if pd.Name == "" {
+		missing = append(missing, "Name")
+	}
+	if pd.ProductName == "" {
+		missing = append(missing, "ProductName")
+	}
+	if len(missing) > 0 {
+		return &ValidationError{Missing: missing}
+	}
+	return nil
+}
+
+// fill copies the fields pd is missing from other.
+func (pd *ProductDetails) fill(other ProductDetails) {
+	fillString := func(dst *string, src string) {
+		if *dst == "" {
+			*dst = src
+		}
+	}
+	fillString(&pd.Name, other.Name)
+	fillString(&pd.Subtitle, other.Subtitle)
+	fillString(&pd.LastSale, other.LastSale)
+	fillString(&pd.ProductName, other.ProductName)
+	fillString(&pd.MainPicture, other.MainPicture)
+	fillString(&pd.Description, other.Description)
+	if pd.Attributes == nil {
+		pd.Attributes = make(map[string]string)
+	}
+	for key, value := range other.Attributes {
+		if _, ok := pd.Attributes[key]; !ok && value != "" {
+			pd.Attributes[key] = value
+		}
+	}
+}
+
+// Scraper reads product pages with its profiles, newest first, and falls
+// back to the structured data embedded in the page for fields the profiles
+// miss.
+type Scraper struct {
+	// Client fetches the pages and pictures, nil uses http.DefaultClient.
+	Client   *http.Client
+	profiles []Profile
+}
+
+func NewScraper(profiles []Profile) *Scraper {
+	profiles = append([]Profile(nil), profiles...)
+	sortProfiles(profiles)
+	return &Scraper{profiles: profiles}
+}
+
+var defaultScraper = NewScraper(DefaultProfiles())
+
+// ProductURL returns the StockX page of a shoe. Page slugs are the product
+// name in lower case.
+func ProductURL(productName string) string {
+	return "https://stockx.com/" + strings.ToLower(productName)
+}
+
+// GetShoeInformation scrapes the page with the built-in profiles.
 func GetShoeInformation(url string) (ProductDetails, error) {
-	res, err := http.Get(url)
+	return defaultScraper.GetShoeInformation(url)
+}
+
+func (s *Scraper) client() *http.Client {
+	if s.Client != nil {
+		return s.Client
+	}
+	return http.DefaultClient
+}
+
+func (s *Scraper) GetShoeInformation(url string) (ProductDetails, error) {
+	res, err := s.client().Get(url)
 	if err != nil {
 		return ProductDetails{}, fmt.Errorf("error fetching page: %v", err)
 	}
@@ -30,7 +121,13 @@ func GetShoeInformation(url string) (ProductDetails, error) {
 		return ProductDetails{}, fmt.Errorf("status code error: %d %s", res.StatusCode, res.Status)
 	}
 
-	doc, err := goquery.NewDocumentFromReader(res.Body)
+	return s.Parse(res.Body)
+}
+
+// Parse extracts the product details from a product page. It returns a
+// *ValidationError rather than a product without Name or ProductName.
+func (s *Scraper) Parse(r io.Reader) (ProductDetails, error) {
+	doc, err := goquery.NewDocumentFromReader(r)
 	if err != nil {
 		return ProductDetails{}, err
 	}
@@ -38,38 +135,25 @@ func GetShoeInformation(url string) (ProductDetails, error) {
 	product := ProductDetails{
 		Attributes: make(map[string]string),
 	}
-
-	primaryTitleSelection := doc.Find("h1[data-component='primary-product-title']")
-	product.Name = strings.TrimSpace(primaryTitleSelection.Contents().Not("span").Text())
-	product.Subtitle = strings.TrimSpace(primaryTitleSelection.Find("span[data-component='secondary-product-title']").Text())
-
-	product.LastSale = strings.TrimSpace(doc.Find(".css-1q8ctst").First().Text())
-
-	doc.Find(".css-17w8l66 div").Each(func(index int, item *goquery.Selection) {
-		key := strings.TrimSpace(item.Find("span.chakra-text").Text())
-		value := strings.TrimSpace(item.Find("p.chakra-text").Text())
-		product.Attributes[key] = value
-	})
-
-	product.Description = strings.TrimSpace(doc.Find(".css-1k2nzv4").Text())
-
-	doc.Find("img[data-image-type='360']").Each(func(i int, s *goquery.Selection) {
-		srcSet, exists := s.Attr("srcset")
-		if exists {
-			urls := strings.Split(srcSet, ",")
-			for _, url := range urls {
-				trimmedUrl := strings.Split(strings.TrimSpace(url), " ")[0]
-				parts := strings.Split(trimmedUrl, "/")
-				for j, part := range parts {
-					if part == "360" && j+2 < len(parts) {
-						product.ProductName = parts[j+1]
-						break
-					}
-				}
-			}
+	for _, profile := range s.profiles {
+		product.fill(profile.extract(doc))
+		if product.Validate() == nil && product.LastSale != "" && product.Description != "" {
+			break
 		}
-	})
+	}
+
+	product.fill(structuredData(doc))
 
-	product.MainPicture = "https://images.stockx.com/images/" + product.ProductName+ "-Product.jpg"
-	return product, err
+	if product.ProductName != "" {
+		product.MainPicture = "https://images.stockx.com/images/" + product.ProductName + "-Product.jpg"
+	}
+
+	if err := product.Validate(); err != nil {
+		verr := err.(*ValidationError)
+		for _, p := range s.profiles {
+			verr.Profiles = append(verr.Profiles, fmt.Sprintf("%d_%s", p.Version, p.Name))
+		}
+		return product, verr
+	}
+	return product, nil
 }
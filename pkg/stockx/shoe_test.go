@@ -0,0 +1,112 @@
+package stockx
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGetShoeInformation(t *testing.T) {
+	scraper := NewScraper(DefaultProfiles())
+	scraper.Client = newFixtureClient(t)
+
+	tests := []struct {
+		page        string
+		name        string
+		subtitle    string
+		lastSale    string
+		productName string
+		style       string
+		err         string
+	}{
+		{
+			page:        "air-jordan-1-retro-high-travis-scott",
+			name:        "Jordan 1 Retro High OG SP",
+			subtitle:    "Travis Scott Mocha",
+			lastSale:    "$1,234",
+			productName: "Air-Jordan-1-Retro-High-Travis-Scott",
+			style:       "CD4487-100",
+		},
+		{
+			// The classes changed, only the JSON-LD block is left.
+			page:        "nike-mars-yard-2-0",
+			name:        "Nike Mars Yard 2.0 Tom Sachs",
+			productName: "Nike-Mars-Yard-2-0",
+			style:       "AA2261-100",
+		},
+		{
+			page:        "adidas-yeezy-boost-350-v2-zebra",
+			name:        "adidas Yeezy Boost 350 V2",
+			subtitle:    "Zebra",
+			lastSale:    "€289",
+			productName: "Adidas-Yeezy-Boost-350-V2-Zebra",
+			style:       "CP9654",
+		},
+		{page: "unknown-layout", err: "missing required fields Name, ProductName"},
+		{page: "not-recorded", err: "status code error: 404"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.page, func(t *testing.T) {
+			product, err := scraper.GetShoeInformation("https://stockx.com/" + tt.page)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("Expected an error containing %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetShoeInformation failed: %v", err)
+			}
+			if product.Name != tt.name || product.Subtitle != tt.subtitle || product.LastSale != tt.lastSale ||
+				product.ProductName != tt.productName || product.Attributes["Style"] != tt.style {
+				t.Fatalf("Unexpected product %+v", product)
+			}
+			if product.Description == "" {
+				t.Fatalf("Expected a description")
+			}
+			if product.MainPicture != "https://images.stockx.com/images/"+tt.productName+"-Product.jpg" {
+				t.Fatalf("Unexpected main picture %s", product.MainPicture)
+			}
+			if ProductURL(product.ProductName) != "https://stockx.com/"+tt.page {
+				t.Fatalf("Expected the product name to lead back to the page, got %s", ProductURL(product.ProductName))
+			}
+		})
+	}
+
+	_, err := scraper.GetShoeInformation("https://stockx.com/unknown-layout")
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Profiles) == 0 {
+		t.Fatalf("Expected a *ValidationError naming the profiles, got %v", err)
+	}
+}
+
+func TestProfilesWithDir(t *testing.T) {
+	dir := t.TempDir()
+	profile := `{"version": 99, "name": "renamed", "title": "h1.css-renamed", "image_360": "img.spin"}`
+	if err := os.WriteFile(filepath.Join(dir, "0099_renamed.json"), []byte(profile), 0o644); err != nil {
+		t.Fatalf("Failed to write profile: %v", err)
+	}
+
+	profiles, err := ProfilesWithDir(dir)
+	if err != nil {
+		t.Fatalf("ProfilesWithDir failed: %v", err)
+	}
+	if len(profiles) != len(DefaultProfiles())+1 || profiles[0].Version != 99 {
+		t.Fatalf("Expected the new profile first, got %+v", profiles)
+	}
+
+	page := `<html><body><h1 class="css-renamed">Mars Yard</h1><img class="spin" src="https://images.stockx.com/360/Nike-Mars-Yard/Images/Nike-Mars-Yard/Lv2/img01.jpg"></body></html>`
+	product, err := NewScraper(profiles).Parse(strings.NewReader(page))
+	if err != nil || product.Name != "Mars Yard" || product.ProductName != "Nike-Mars-Yard" {
+		t.Fatalf("Expected the loaded profile to match, got %+v (%v)", product, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "broken.json"), []byte(`{"name": "broken"}`), 0o644); err != nil {
+		t.Fatalf("Failed to write profile: %v", err)
+	}
+	if _, err := ProfilesWithDir(dir); err == nil {
+		t.Fatalf("Expected an error for a profile without a version")
+	}
+}
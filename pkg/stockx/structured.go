@@ -0,0 +1,165 @@
+package stockx
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// structuredData reads the product from the data StockX embeds for search
+// engines (JSON-LD) and for its Next.js frontend. These change far less often
+// than the markup. Blocks that don't parse are skipped.
+func structuredData(doc *goquery.Document) ProductDetails {
+	product := ProductDetails{
+		Attributes: make(map[string]string),
+	}
+
+	doc.Find(`script[type="application/ld+json"]`).Each(func(i int, s *goquery.Selection) {
+		var data interface{}
+		if json.Unmarshal([]byte(s.Text()), &data) != nil {
+			return
+		}
+		if ld := findObject(data, isJSONLDProduct); ld != nil {
+			product.fill(fromJSONLD(ld))
+		}
+	})
+
+	if text := doc.Find("script#__NEXT_DATA__").Text(); text != "" {
+		var data interface{}
+		if json.Unmarshal([]byte(text), &data) == nil {
+			if next := findObject(data, isNextProduct); next != nil {
+				product.fill(fromNextData(next))
+			}
+		}
+	}
+	return product
+}
+
+// findObject returns the first object in data, depth first, that match
+// accepts.
+func findObject(data interface{}, match func(map[string]interface{}) bool) map[string]interface{} {
+	switch v := data.(type) {
+	case map[string]interface{}:
+		if match(v) {
+			return v
+		}
+		for _, child := range v {
+			if found := findObject(child, match); found != nil {
+				return found
+			}
+		}
+	case []interface{}:
+		for _, child := range v {
+			if found := findObject(child, match); found != nil {
+				return found
+			}
+		}
+	}
+	return nil
+}
+
+func isJSONLDProduct(v map[string]interface{}) bool {
+	return v["@type"] == "Product"
+}
+
+func isNextProduct(v map[string]interface{}) bool {
+	_, ok := v["primaryTitle"]
+	return ok && v["urlKey"] != nil
+}
+
+func stringField(v map[string]interface{}, key string) string {
+	switch value := v[key].(type) {
+	case string:
+		return strings.TrimSpace(value)
+	case float64:
+		return fmt.Sprint(value)
+	}
+	return ""
+}
+
+func objectField(v map[string]interface{}, keys ...string) map[string]interface{} {
+	for _, key := range keys {
+		next, ok := v[key].(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = next
+	}
+	return v
+}
+
+func fromJSONLD(ld map[string]interface{}) ProductDetails {
+	product := ProductDetails{
+		Name:        stringField(ld, "name"),
+		Description: stringField(ld, "description"),
+		Attributes:  make(map[string]string),
+	}
+
+	var images []string
+	switch image := ld["image"].(type) {
+	case string:
+		images = []string{image}
+	case []interface{}:
+		for _, i := range image {
+			if url, ok := i.(string); ok {
+				images = append(images, url)
+			}
+		}
+	}
+	for _, url := range images {
+		if name := productNameFromImageURL(url); name != "" {
+			product.ProductName = name
+			break
+		}
+	}
+
+	for key, attribute := range map[string]string{"sku": "Style", "color": "Colorway", "releaseDate": "Release Date"} {
+		if value := stringField(ld, key); value != "" {
+			product.Attributes[attribute] = value
+		}
+	}
+	return product
+}
+
+func fromNextData(next map[string]interface{}) ProductDetails {
+	product := ProductDetails{
+		Name:        stringField(next, "primaryTitle"),
+		Subtitle:    stringField(next, "secondaryTitle"),
+		Description: stringField(next, "description"),
+		Attributes:  make(map[string]string),
+	}
+
+	if media := objectField(next, "media"); media != nil {
+		product.ProductName = productNameFromImageURL(stringField(media, "imageUrl"))
+		if spins, ok := media["360"].([]interface{}); ok && product.ProductName == "" && len(spins) > 0 {
+			if url, ok := spins[0].(string); ok {
+				product.ProductName = productNameFromImageURL(url)
+			}
+		}
+	}
+
+	if traits, ok := next["traits"].([]interface{}); ok {
+		for _, t := range traits {
+			trait, ok := t.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if name, value := stringField(trait, "name"), stringField(trait, "value"); name != "" && value != "" {
+				product.Attributes[name] = value
+			}
+		}
+	}
+
+	if sales := objectField(next, "market", "salesInformation"); sales != nil {
+		if amount, ok := sales["lastSale"].(float64); ok && amount > 0 {
+			currency := stringField(objectField(next, "market"), "currencyCode")
+			if currency == "" {
+				currency = "USD"
+			}
+			product.LastSale = FormatPrice(Price{Amount: amount, Currency: currency})
+		}
+	}
+	return product
+}